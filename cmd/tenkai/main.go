@@ -169,6 +169,8 @@ func initRepository(database *dbms.Database) handlers.Repositories {
 	repositories.WebHookDAO = &repository.WebHookDAOImpl{Db: database.Db}
 	repositories.DeploymentDAO = &repository.DeploymentDAOImpl{Db: database.Db}
 	repositories.RequestDeploymentDAO = &repository.RequestDeploymentDAOImpl{Db: database.Db}
+	repositories.RewriteRuleSetDAO = &repository.RewriteRuleSetDAOImpl{Db: database.Db}
+	repositories.VariableRevisionDAO = &repository.VariableRevisionDAOImpl{Db: database.Db}
 
 	return repositories
 }
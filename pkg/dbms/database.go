@@ -51,6 +51,9 @@ func (database *Database) Connect(dbmsURI string, local bool) {
 	database.Db.AutoMigrate(&model2.WebHook{})
 	database.Db.AutoMigrate(&model2.Deployment{})
 	database.Db.AutoMigrate(&model2.RequestDeployment{})
+	database.Db.AutoMigrate(&model2.RewriteRuleSet{})
+	database.Db.AutoMigrate(&model2.RewriteRule{})
+	database.Db.AutoMigrate(&model2.VariableRevision{})
 	database.Db.Model(&model.ValueRule{}).
 		AddForeignKey("variable_rule_id", "variable_rules(id)", "CASCADE", "CASCADE")
 	database.Db.Model(&model.Deployment{}).
@@ -59,4 +62,6 @@ func (database *Database) Connect(dbmsURI string, local bool) {
 		AddForeignKey("request_deployment_id", "request_deployments(id)", "CASCADE", "CASCADE")
 	database.Db.Model(&model.RequestDeployment{}).
 		AddForeignKey("user_id", "users(id)", "CASCADE", "CASCADE")
+	database.Db.Model(&model.RewriteRule{}).
+		AddForeignKey("rewrite_rule_set_id", "rewrite_rule_sets(id)", "CASCADE", "CASCADE")
 }
@@ -0,0 +1,25 @@
+package model
+
+//RulesPolicyAdminOverride lets admins deploy over error severity rule violations, giving a reason.
+//It is the policy of environments with an empty policy.
+const RulesPolicyAdminOverride = "adminOverride"
+
+//RulesPolicyStrict never deploys over error severity rule violations
+const RulesPolicyStrict = "strict"
+
+//RulesPolicies lists the valid environment rules policies
+var RulesPolicies = []string{RulesPolicyAdminOverride, RulesPolicyStrict}
+
+//DeployRulesCheck is the result of the variable rules of a deploy to an environment
+type DeployRulesCheck struct {
+	Environment    string            `json:"environment"`
+	Violations     []InvalidVariable `json:"violations"`
+	Warnings       []InvalidVariable `json:"warnings"`
+	OverrideReason string            `json:"overrideReason,omitempty"`
+}
+
+//DeployRulesResult lists the rules checks of a deploy. A blocked deploy was not started.
+type DeployRulesResult struct {
+	Blocked bool               `json:"blocked"`
+	Checks  []DeployRulesCheck `json:"checks"`
+}
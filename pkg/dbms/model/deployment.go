@@ -9,22 +9,26 @@ import (
 //RequestDeployment deployment requested from user
 type RequestDeployment struct {
 	gorm.Model
-	Success   bool `json:"success"`
-	Processed bool `json:"processed"`
-	UserID    uint `json:"user_id"`
+	Success   bool   `json:"success"`
+	Processed bool   `json:"processed"`
+	UserID    uint   `json:"user_id"`
+	Message   string `json:"message"`
 }
 
 //Deployment  struct
 type Deployment struct {
 	gorm.Model
-	RequestDeploymentID uint   `json:"request_deployment_id"`
-	EnvironmentID       uint   `json:"environment_id"`
-	Chart               string `json:"chart"`
-	ChartVersion        string `json:"chartVersion"`
-	Processed           bool   `json:"processed"`
-	Success             bool   `json:"success"`
-	Message             string `json:"message"`
-	DockerVersion       string `json:"dockerVersion"`
+	RequestDeploymentID      uint   `json:"request_deployment_id"`
+	EnvironmentID            uint   `json:"environment_id"`
+	Chart                    string `json:"chart"`
+	ChartVersion             string `json:"chartVersion"`
+	Processed                bool   `json:"processed"`
+	Success                  bool   `json:"success"`
+	Message                  string `json:"message"`
+	DockerVersion            string `json:"dockerVersion"`
+	ReleaseName              string `json:"releaseName"`
+	PreviousProductVersion   string `json:"previousProductVersion"`
+	PreviousProductVersionID uint   `json:"previousProductVersionId"`
 }
 
 //DeploymentResponse struct response /deployments GET
@@ -77,4 +81,12 @@ type RequestDeployments struct {
 	User      string    `json:"user"`
 	Success   bool      `json:"success"`
 	Processed bool      `json:"processed"`
+	Message   string    `json:"message"`
+}
+
+//RequestDeploymentRollbackResult struct response /requestDeployments/{id}/rollback POST
+type RequestDeploymentRollbackResult struct {
+	RequestDeploymentID int          `json:"request_deployment_id"`
+	Success             bool         `json:"success"`
+	Deployments         []Deployment `json:"deployments"`
 }
@@ -0,0 +1,39 @@
+package model
+
+//DriftReport struct response /environments/{id}/drift GET
+type DriftReport struct {
+	EnvironmentID     uint                   `json:"environmentId"`
+	Environment       string                 `json:"environment"`
+	ProductVersion    string                 `json:"productVersion"`
+	UnknownReleases   []DriftRelease         `json:"unknownReleases"`
+	MissingReleases   []DriftRelease         `json:"missingReleases"`
+	VersionMismatches []DriftVersionMismatch `json:"versionMismatches"`
+	ValueDifferences  []DriftValueDifference `json:"valueDifferences"`
+}
+
+//DriftRelease struct
+type DriftRelease struct {
+	Name         string `json:"name"`
+	Chart        string `json:"chart"`
+	ChartVersion string `json:"chartVersion"`
+}
+
+//DriftVersionMismatch struct
+type DriftVersionMismatch struct {
+	Release  string `json:"release"`
+	Chart    string `json:"chart"`
+	Field    string `json:"field"`
+	Source   string `json:"source"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+//DriftValueDifference struct
+type DriftValueDifference struct {
+	Release  string `json:"release"`
+	Scope    string `json:"scope"`
+	Name     string `json:"name"`
+	Secret   bool   `json:"secret"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
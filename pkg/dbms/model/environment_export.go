@@ -0,0 +1,28 @@
+package model
+
+//EnvironmentExport is a re-importable document with the environment metadata and its variables by scope.
+//Secrets are omitted, unless exported with a passphrase, when their values are encrypted under it.
+type EnvironmentExport struct {
+	Name           string                `json:"name"`
+	Group          string                `json:"group"`
+	Namespace      string                `json:"namespace"`
+	Gateway        string                `json:"gateway"`
+	ProductVersion string                `json:"productVersion"`
+	Scopes         []VariableScopeExport `json:"scopes"`
+	OmittedSecrets []string              `json:"omittedSecrets,omitempty"`
+}
+
+//VariableScopeExport struct
+type VariableScopeExport struct {
+	Scope     string           `json:"scope"`
+	Variables []VariableExport `json:"variables"`
+}
+
+//VariableExport struct
+type VariableExport struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Secret      bool   `json:"secret"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`
+}
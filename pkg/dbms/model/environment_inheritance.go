@@ -0,0 +1,28 @@
+package model
+
+//EffectiveVariablesResult struct response /environments/{id}/effectiveVariables GET
+type EffectiveVariablesResult struct {
+	//Chain lists the environment followed by its ancestors, the first one wins
+	Chain     []VariableSource    `json:"chain"`
+	Variables []EffectiveVariable `json:"variables"`
+}
+
+//EffectiveVariable is the value a variable resolves to through the environment chain
+type EffectiveVariable struct {
+	Scope       string `json:"scope"`
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Secret      bool   `json:"secret"`
+	Description string `json:"description"`
+	//Source is the environment the value comes from
+	Source VariableSource `json:"source"`
+	//Overrides lists the ancestors whose value is hidden by the effective one
+	Overrides []VariableSource `json:"overrides"`
+}
+
+//VariableSource is an environment of the chain, with the value it holds for a variable
+type VariableSource struct {
+	EnvironmentID uint   `json:"environmentId"`
+	Environment   string `json:"environment"`
+	Value         string `json:"value,omitempty"`
+}
@@ -0,0 +1,33 @@
+package model
+
+//HotFixRequest struct request /productVersions/{id}/hotfix POST
+type HotFixRequest struct {
+	Version  string                 `json:"version"`
+	DryRun   bool                   `json:"dryRun"`
+	Services []HotFixServiceRequest `json:"services"`
+}
+
+//HotFixServiceRequest selects a service to be fixed. An empty tag means the next hotfix tag found in the registry.
+type HotFixServiceRequest struct {
+	ServiceName    string `json:"serviceName"`
+	DockerImageTag string `json:"dockerImageTag"`
+}
+
+//HotFixResponse struct
+type HotFixResponse struct {
+	DryRun           bool            `json:"dryRun"`
+	ProductVersionID int             `json:"productVersionId"`
+	BaseVersionID    int             `json:"baseVersionId"`
+	BaseVersion      string          `json:"baseVersion"`
+	Version          string          `json:"version"`
+	Services         []HotFixService `json:"services"`
+}
+
+//HotFixService struct
+type HotFixService struct {
+	ServiceName    string `json:"serviceName"`
+	BaseImageTag   string `json:"baseImageTag"`
+	DockerImageTag string `json:"dockerImageTag"`
+	Fixed          bool   `json:"fixed"`
+	TagNotFound    bool   `json:"tagNotFound"`
+}
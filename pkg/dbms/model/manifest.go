@@ -0,0 +1,16 @@
+package model
+
+//ManifestDiffResult structure
+type ManifestDiffResult struct {
+	Release string                 `json:"release"`
+	Added   []ManifestResourceDiff `json:"added"`
+	Removed []ManifestResourceDiff `json:"removed"`
+	Changed []ManifestResourceDiff `json:"changed"`
+}
+
+//ManifestResourceDiff structure
+type ManifestResourceDiff struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Diff string `json:"diff"`
+}
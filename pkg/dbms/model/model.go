@@ -8,15 +8,18 @@ import (
 //Environment - Environment Model
 type Environment struct {
 	gorm.Model
-	Group          string `json:"group"`
-	Name           string `json:"name"`
-	ClusterURI     string `json:"cluster_uri"`
-	CACertificate  string `json:"ca_certificate"`
-	Token          string `json:"token"`
-	Namespace      string `json:"namespace"`
-	Gateway        string `json:"gateway"`
-	ProductVersion string `json:"productVersion"`
-	CurrentRelease string `json:"currentRelease"`
+	Group            string `json:"group"`
+	Name             string `json:"name"`
+	ClusterURI       string `json:"cluster_uri"`
+	CACertificate    string `json:"ca_certificate"`
+	Token            string `json:"token"`
+	Namespace        string `json:"namespace"`
+	Gateway          string `json:"gateway"`
+	ProductVersion   string `json:"productVersion"`
+	ProductVersionID uint   `json:"productVersionId"`
+	CurrentRelease   string `json:"currentRelease"`
+	ParentID         uint   `json:"parentId"`
+	RulesPolicy      string `json:"rulesPolicy"`
 }
 
 //EnvResult Model
@@ -97,6 +100,7 @@ type Variable struct {
 	Secret        bool   `json:"secret"`
 	Description   string `json:"description"`
 	EnvironmentID int    `json:"environmentId"`
+	Type          string `json:"type"`
 }
 
 //VariableData Struct
@@ -104,6 +108,14 @@ type VariableData struct {
 	Data []Variable `json:"data"`
 }
 
+//RevealedSecret is the decrypted value of a secret variable
+type RevealedSecret struct {
+	ID    uint   `json:"id"`
+	Scope string `json:"scope"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
 //InstallArguments Method
 type InstallArguments struct {
 	Name  string `json:"name"`
@@ -151,6 +163,7 @@ type InvalidVariable struct {
 	VariableRule string `json:"variableRule"`
 	RuleType     string `json:"ruleType"`
 	ValueRule    string `json:"valueRule"`
+	Severity     string `json:"severity"`
 }
 
 type selectItem struct {
@@ -162,6 +175,7 @@ type selectItem struct {
 type CompareEnvironments struct {
 	SourceEnvID             int           `json:"sourceEnvId"`
 	TargetEnvID             int           `json:"targetEnvId"`
+	EnvironmentIDs          []int         `json:"environmentIds"`
 	ExceptCharts            []string      `json:"exceptCharts"`
 	OnlyCharts              []string      `json:"onlyCharts"`
 	ExceptFields            []string      `json:"exceptFields"`
@@ -213,3 +227,33 @@ type EnvironmentsDiff struct {
 type CompareEnvsResponse struct {
 	List []EnvironmentsDiff `json:"list"`
 }
+
+//CompareEnvsMatrixResponse compares the variables of several environments at once
+type CompareEnvsMatrixResponse struct {
+	Environments []CompareEnvsMatrixEnvironment `json:"environments"`
+	List         []CompareEnvsMatrixRow         `json:"list"`
+}
+
+//CompareEnvsMatrixEnvironment is a column of the matrix
+type CompareEnvsMatrixEnvironment struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+//CompareEnvsMatrixRow holds the value of a variable in each environment, in the order of the columns
+type CompareEnvsMatrixRow struct {
+	Scope     string                   `json:"scope"`
+	Name      string                   `json:"name"`
+	Different bool                     `json:"different"`
+	Missing   bool                     `json:"missing"`
+	Values    []CompareEnvsMatrixValue `json:"values"`
+}
+
+//CompareEnvsMatrixValue is the value of a variable in one environment
+type CompareEnvsMatrixValue struct {
+	EnvironmentID int    `json:"environmentId"`
+	VarID         string `json:"varId"`
+	Value         string `json:"value"`
+	Secret        bool   `json:"secret"`
+	Missing       bool   `json:"missing"`
+}
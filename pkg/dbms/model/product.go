@@ -11,17 +11,36 @@ type Product struct {
 	gorm.Model
 	Name             string `json:"name"`
 	ValidateReleases bool   `json:"validateReleases"`
+	VersionScheme    string `json:"versionScheme,omitempty"`
+	VersionPattern   string `json:"versionPattern,omitempty"`
 }
 
 //ProductVersion struct
 type ProductVersion struct {
 	gorm.Model
-	ProductID   int       `json:"productId"`
-	Date        time.Time `json:"date"`
-	Version     string    `json:"version"`
-	BaseRelease int       `gorm:"-" json:"baseRelease"`
-	Locked      bool      `json:"locked"`
-	HotFix      bool      `json:"hotFix"`
+	ProductID     int       `json:"productId"`
+	Date          time.Time `json:"date"`
+	Version       string    `json:"version"`
+	BaseRelease   int       `gorm:"-" json:"baseRelease"`
+	Locked        bool      `json:"locked"`
+	HotFix        bool      `json:"hotFix"`
+	State         string    `json:"state,omitempty"`
+	BaseVersionID int       `json:"baseVersionId,omitempty"`
+}
+
+//Product version lifecycle states. Versions created before the lifecycle existed have no state.
+const (
+	ProductVersionDraft      = "draft"
+	ProductVersionCandidate  = "candidate"
+	ProductVersionReleased   = "released"
+	ProductVersionDeprecated = "deprecated"
+	ProductVersionRetired    = "retired"
+)
+
+//ProductVersionTransition struct request /productVersions/{id}/transition POST
+type ProductVersionTransition struct {
+	State  string `json:"state"`
+	Reason string `json:"reason"`
 }
 
 //ProductVersionService struct
@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+//ProductExport is a self-contained document describing a product and its versions
+type ProductExport struct {
+	Name             string                 `json:"name"`
+	ValidateReleases bool                   `json:"validateReleases"`
+	VersionScheme    string                 `json:"versionScheme,omitempty"`
+	VersionPattern   string                 `json:"versionPattern,omitempty"`
+	Versions         []ProductVersionExport `json:"versions"`
+}
+
+//ProductVersionExport struct
+type ProductVersionExport struct {
+	Version  string                        `json:"version"`
+	Date     time.Time                     `json:"date"`
+	Locked   bool                          `json:"locked"`
+	HotFix   bool                          `json:"hotFix"`
+	State    string                        `json:"state,omitempty"`
+	Services []ProductVersionServiceExport `json:"services"`
+}
+
+//ProductVersionServiceExport struct
+type ProductVersionServiceExport struct {
+	Chart          string `json:"chart"`
+	ChartVersion   string `json:"chartVersion"`
+	DockerImageTag string `json:"dockerImageTag"`
+	Notes          string `json:"notes,omitempty"`
+}
+
+//ProductImportReport struct response /products/import POST
+type ProductImportReport struct {
+	DryRun         bool                  `json:"dryRun"`
+	Product        string                `json:"product"`
+	ProductCreated bool                  `json:"productCreated"`
+	Versions       []ProductImportResult `json:"versions"`
+}
+
+//ProductImportResult struct
+type ProductImportResult struct {
+	Version    string `json:"version"`
+	ImportedAs string `json:"importedAs"`
+	Action     string `json:"action"`
+	Conflict   bool   `json:"conflict"`
+	Services   int    `json:"services"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+//ProductImport is everything an import writes, saved at once
+type ProductImport struct {
+	Product  Product
+	Versions []ProductVersionImport
+	Notes    []Notes
+}
+
+//ProductVersionImport is an imported version with its services
+type ProductVersionImport struct {
+	Version  ProductVersion
+	Services []ProductVersionService
+}
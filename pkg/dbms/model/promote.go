@@ -0,0 +1,36 @@
+package model
+
+//PromotePlan structure
+type PromotePlan struct {
+	Mode              string            `json:"mode"`
+	SourceEnvironment string            `json:"sourceEnvironment"`
+	TargetEnvironment string            `json:"targetEnvironment"`
+	ReleasesToPurge   []PromoteRelease  `json:"releasesToPurge"`
+	ReleasesToDeploy  []PromoteRelease  `json:"releasesToDeploy"`
+	VariablesToDelete []PromoteVariable `json:"variablesToDelete"`
+	VariablesToCopy   []PromoteVariable `json:"variablesToCopy"`
+	VariablesToChange []PromoteVariable `json:"variablesToChange"`
+	SchemaViolations  []InvalidVariable `json:"schemaViolations"`
+}
+
+//PromoteRelease structure
+type PromoteRelease struct {
+	Name         string `json:"name"`
+	Chart        string `json:"chart"`
+	ChartVersion string `json:"chartVersion"`
+}
+
+//PromoteVariable structure
+type PromoteVariable struct {
+	Scope    string `json:"scope"`
+	Name     string `json:"name"`
+	Secret   bool   `json:"secret"`
+	OldValue string `json:"oldValue"`
+	NewValue string `json:"newValue"`
+}
+
+//PromoteResult structure
+type PromoteResult struct {
+	RequestDeploymentID int               `json:"requestDeploymentId"`
+	Rules               *DeployRulesCheck `json:"rules,omitempty"`
+}
@@ -0,0 +1,31 @@
+package model
+
+//ReleaseNotes struct response /productVersions/releaseNotes GET
+type ReleaseNotes struct {
+	Product     string                `json:"product"`
+	FromVersion string                `json:"fromVersion"`
+	ToVersion   string                `json:"toVersion"`
+	Added       []ReleaseNotesService `json:"added"`
+	Removed     []ReleaseNotesService `json:"removed"`
+	Changed     []ReleaseNotesService `json:"changed"`
+}
+
+//ReleaseNotesService struct
+type ReleaseNotesService struct {
+	ServiceName      string `json:"serviceName"`
+	FromImageTag     string `json:"fromImageTag"`
+	ToImageTag       string `json:"toImageTag"`
+	FromChartVersion string `json:"fromChartVersion"`
+	ToChartVersion   string `json:"toChartVersion"`
+	Notes            string `json:"notes"`
+	ServiceNotes     string `json:"serviceNotes"`
+}
+
+//WebHookReleaseNotesPostPayload struct
+type WebHookReleaseNotesPostPayload struct {
+	ProductName    string       `json:"productName"`
+	Release        string       `json:"release"`
+	AdditionalData string       `json:"additionalData"`
+	ReleaseNotes   ReleaseNotes `json:"releaseNotes"`
+	Markdown       string       `json:"markdown"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import "github.com/jinzhu/gorm"
+
+//RewriteRuleSet Structure
+type RewriteRuleSet struct {
+	gorm.Model
+	Name         string         `json:"name"`
+	RewriteRules []*RewriteRule `json:"rewriteRules" gorm:"foreignkey:RewriteRuleSetID"`
+}
+
+//RewriteRule Structure
+type RewriteRule struct {
+	gorm.Model
+	Type             string `json:"type"`
+	Search           string `json:"search"`
+	Replacement      string `json:"replacement"`
+	ChartPattern     string `json:"chartPattern"`
+	VariablePattern  string `json:"variablePattern"`
+	RewriteRuleSetID uint   `json:"rewriteRuleSetId"`
+}
+
+//RewriteRuleSetResponse struct
+type RewriteRuleSetResponse struct {
+	List []RewriteRuleSet `json:"list"`
+}
+
+//RewritePreviewRequest struct
+type RewritePreviewRequest struct {
+	RewriteRuleSetID    int    `json:"rewriteRuleSetId"`
+	SourceEnvironmentID int    `json:"sourceEnvironmentId"`
+	TargetEnvironmentID int    `json:"targetEnvironmentId"`
+	Scope               string `json:"scope"`
+}
+
+//RewrittenVariable struct
+type RewrittenVariable struct {
+	Scope   string `json:"scope"`
+	Name    string `json:"name"`
+	Secret  bool   `json:"secret"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Changed bool   `json:"changed"`
+}
+
+//RewritePreviewResult struct
+type RewritePreviewResult struct {
+	List []RewrittenVariable `json:"list"`
+}
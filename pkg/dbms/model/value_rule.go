@@ -2,12 +2,25 @@ package model
 
 import "github.com/jinzhu/gorm"
 
+//ValueRuleSeverityError is reported for an invalid value that must be fixed
+const ValueRuleSeverityError = "error"
+
+//ValueRuleSeverityWarning is reported for a suspicious value
+const ValueRuleSeverityWarning = "warning"
+
+//ValueRuleSeverityInfo is reported for information only
+const ValueRuleSeverityInfo = "info"
+
+//ValueRuleSeverities lists the valid severities. An empty severity is an error.
+var ValueRuleSeverities = []string{ValueRuleSeverityError, ValueRuleSeverityWarning, ValueRuleSeverityInfo}
+
 //ValueRule Structure
 type ValueRule struct {
 	gorm.Model
 	Type           string `json:"type"`
 	Value          string `json:"value"`
 	VariableRuleID uint
+	Severity       string `json:"severity"`
 }
 
 //ValueRuleReponse struct
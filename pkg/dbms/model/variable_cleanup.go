@@ -0,0 +1,36 @@
+package model
+
+//Reasons a variable is a cleanup candidate
+const (
+	CleanupScopeNotDeployed = "scopeNotDeployed"
+	CleanupScopeInherited   = "scopeInherited"
+	CleanupKeyNotInChart    = "keyNotInChart"
+	CleanupChartRenamed     = "chartRenamed"
+)
+
+//VariableCleanup struct request /variables/cleanup POST
+type VariableCleanup struct {
+	EnvironmentIDs []int  `json:"environmentIds"`
+	VariableIDs    []uint `json:"variableIds"`
+}
+
+//VariableCleanupReport struct response /variables/cleanup POST
+type VariableCleanupReport struct {
+	DryRun     bool                       `json:"dryRun"`
+	Candidates []VariableCleanupCandidate `json:"candidates"`
+	Deleted    int                        `json:"deleted"`
+	Errors     []string                   `json:"errors"`
+}
+
+//VariableCleanupCandidate is a variable that looks unused, and why
+type VariableCleanupCandidate struct {
+	EnvironmentID int    `json:"environmentId"`
+	Environment   string `json:"environment"`
+	VariableID    uint   `json:"variableId"`
+	Scope         string `json:"scope"`
+	Name          string `json:"name"`
+	Value         string `json:"value"`
+	Secret        bool   `json:"secret"`
+	Reason        string `json:"reason"`
+	Deleted       bool   `json:"deleted"`
+}
@@ -0,0 +1,20 @@
+package model
+
+//VariableImportReport struct response /environments/{id}/variables/import POST
+type VariableImportReport struct {
+	DryRun      bool                   `json:"dryRun"`
+	Environment string                 `json:"environment"`
+	Format      string                 `json:"format"`
+	Variables   []VariableImportResult `json:"variables"`
+}
+
+//VariableImportResult tells what was, or would be on a dry run, done with an imported variable
+type VariableImportResult struct {
+	Scope        string `json:"scope"`
+	Name         string `json:"name"`
+	Action       string `json:"action"`
+	CurrentValue string `json:"currentValue"`
+	Value        string `json:"value"`
+	Secret       bool   `json:"secret"`
+	Reason       string `json:"reason,omitempty"`
+}
@@ -0,0 +1,29 @@
+package model
+
+//InterpolationReport struct response /environments/{id}/variables/validate GET
+type InterpolationReport struct {
+	Environment string                 `json:"environment"`
+	Valid       bool                   `json:"valid"`
+	Problems    []InterpolationProblem `json:"problems"`
+}
+
+//InterpolationProblem is a reference of a variable that can not be resolved
+type InterpolationProblem struct {
+	Scope     string `json:"scope"`
+	Name      string `json:"name"`
+	Reference string `json:"reference"`
+	Error     string `json:"error"`
+}
+
+//InterpolationRequest struct request /environments/{id}/variables/interpolate POST
+type InterpolationRequest struct {
+	Scope string `json:"scope"`
+	Value string `json:"value"`
+}
+
+//InterpolationResult is a value interpolated for an environment. Values referencing secrets are masked.
+type InterpolationResult struct {
+	Value    string                 `json:"value"`
+	Secret   bool                   `json:"secret"`
+	Problems []InterpolationProblem `json:"problems"`
+}
@@ -0,0 +1,41 @@
+package model
+
+//VariableReplace struct request /variables/replace POST
+type VariableReplace struct {
+	Search         string   `json:"search"`
+	Replace        string   `json:"replace"`
+	Regex          bool     `json:"regex"`
+	NamePattern    string   `json:"namePattern"`
+	Scopes         []string `json:"scopes"`
+	EnvironmentIDs []int    `json:"environmentIds"`
+	Redeploy       bool     `json:"redeploy"`
+}
+
+//VariableReplaceReport struct response /variables/replace POST
+type VariableReplaceReport struct {
+	DryRun              bool                      `json:"dryRun"`
+	Matches             []VariableReplaceMatch    `json:"matches"`
+	SkippedSecrets      []string                  `json:"skippedSecrets"`
+	Redeployed          []string                  `json:"redeployed"`
+	RedeployFailures    []VariableRedeployFailure `json:"redeployFailures"`
+	RequestDeploymentID int                       `json:"requestDeploymentId,omitempty"`
+}
+
+//VariableReplaceMatch is a variable whose value is, or would be on a dry run, replaced
+type VariableReplaceMatch struct {
+	EnvironmentID int    `json:"environmentId"`
+	Environment   string `json:"environment"`
+	VariableID    uint   `json:"variableId"`
+	Scope         string `json:"scope"`
+	Name          string `json:"name"`
+	OldValue      string `json:"oldValue"`
+	NewValue      string `json:"newValue"`
+	Secret        bool   `json:"secret"`
+	Error         string `json:"error,omitempty"`
+}
+
+//VariableRedeployFailure is a release that could not be deployed again after its variables were replaced
+type VariableRedeployFailure struct {
+	Release string `json:"release"`
+	Error   string `json:"error"`
+}
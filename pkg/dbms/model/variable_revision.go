@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+//Variable revision operations
+const (
+	VariableCreated = "create"
+	VariableUpdated = "update"
+	VariableDeleted = "delete"
+)
+
+//VariableRevision records a change made to a variable. Values of secrets are stored encrypted.
+type VariableRevision struct {
+	gorm.Model
+	EnvironmentID int    `json:"environmentId" gorm:"index:var_rev_env"`
+	Scope         string `json:"scope"`
+	Name          string `json:"name"`
+	Operation     string `json:"operation"`
+	OldValue      string `json:"oldValue"`
+	NewValue      string `json:"newValue"`
+	Secret        bool   `json:"secret"`
+	Type          string `json:"type"`
+	Description   string `json:"description"`
+	User          string `json:"user"`
+}
+
+//VariableRevisionResult struct
+type VariableRevisionResult struct {
+	List []VariableRevision `json:"list"`
+}
+
+//VariableRestoreRequest struct request /environments/{id}/variables/restore POST
+type VariableRestoreRequest struct {
+	At     time.Time `json:"at"`
+	Scope  string    `json:"scope"`
+	DryRun bool      `json:"dryRun"`
+}
+
+//VariableRestoreChange describes how a variable is changed back to the value it had
+type VariableRestoreChange struct {
+	Scope               string `json:"scope"`
+	Name                string `json:"name"`
+	Operation           string `json:"operation"`
+	CurrentValue        string `json:"currentValue"`
+	RestoredValue       string `json:"restoredValue"`
+	Secret              bool   `json:"secret"`
+	RestoredSecret      bool   `json:"restoredSecret"`
+	RestoredType        string `json:"restoredType,omitempty"`
+	RestoredDescription string `json:"restoredDescription,omitempty"`
+}
+
+//VariableRestoreResult struct
+type VariableRestoreResult struct {
+	DryRun  bool                    `json:"dryRun"`
+	Changes []VariableRestoreChange `json:"changes"`
+}
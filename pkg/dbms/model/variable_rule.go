@@ -2,11 +2,16 @@ package model
 
 import "github.com/jinzhu/gorm"
 
-//VariableRule Structure
+//VariableRule Structure. Name is a regular expression over the variable names. The rule only
+//applies to the environment group, environment, charts (regular expression) and product set.
 type VariableRule struct {
 	gorm.Model
-	Name       string       `json:"name"`
-	ValueRules []*ValueRule `gorm:"foreignkey:VariableRuleID"`
+	Name             string       `json:"name"`
+	ValueRules       []*ValueRule `gorm:"foreignkey:VariableRuleID"`
+	EnvironmentGroup string       `json:"environmentGroup"`
+	EnvironmentID    uint         `json:"environmentId"`
+	ChartPattern     string       `json:"chartPattern"`
+	ProductID        uint         `json:"productId"`
 }
 
 //VariableRuleReponse struct
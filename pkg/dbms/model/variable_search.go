@@ -0,0 +1,16 @@
+package model
+
+//VariableSearchResponse struct response /variables/search GET
+type VariableSearchResponse struct {
+	Count      int64                 `json:"count"`
+	TotalPages int                   `json:"total_pages"`
+	Data       []VariableSearchGroup `json:"data"`
+}
+
+//VariableSearchGroup holds the variables found in a scope of an environment
+type VariableSearchGroup struct {
+	EnvironmentID int        `json:"environmentId"`
+	Environment   string     `json:"environment"`
+	Scope         string     `json:"scope"`
+	Variables     []Variable `json:"variables"`
+}
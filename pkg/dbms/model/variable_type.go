@@ -0,0 +1,26 @@
+package model
+
+//VariableTypeString forces the value to a string, even if it looks like a number or a boolean
+const VariableTypeString = "string"
+
+//VariableTypeInt is an integer value
+const VariableTypeInt = "int"
+
+//VariableTypeBool is a true or false value
+const VariableTypeBool = "bool"
+
+//VariableTypeFloat is a floating point value
+const VariableTypeFloat = "float"
+
+//VariableTypeList is a YAML or JSON list
+const VariableTypeList = "list"
+
+//VariableTypeMap is a YAML or JSON object
+const VariableTypeMap = "map"
+
+//VariableTypeMultiline is a string that may span several lines
+const VariableTypeMultiline = "multiline"
+
+//VariableTypes lists the valid types. An empty type keeps the Helm --set inference.
+var VariableTypes = []string{VariableTypeString, VariableTypeInt, VariableTypeBool, VariableTypeFloat,
+	VariableTypeList, VariableTypeMap, VariableTypeMultiline}
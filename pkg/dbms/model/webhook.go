@@ -32,3 +32,14 @@ type WebHookNewReleasePostPayload struct {
 	AdditionalData string   `json:"additionalData"`
 	Services       []string `json:"services"`
 }
+
+//WebHookProductVersionStatePostPayload struct
+type WebHookProductVersionStatePostPayload struct {
+	ProductName    string `json:"productName"`
+	Release        string `json:"release"`
+	FromState      string `json:"fromState"`
+	ToState        string `json:"toState"`
+	User           string `json:"user"`
+	Reason         string `json:"reason"`
+	AdditionalData string `json:"additionalData"`
+}
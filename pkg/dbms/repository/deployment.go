@@ -12,6 +12,8 @@ type DeploymentDAOInterface interface {
 	GetDeploymentByID(id int) (model.Deployment, error)
 	ListDeployments(environmentID, requestDeploymentID string, pageNumber, pageSize int) ([]model.Deployments, error)
 	CountDeployments(environmentID, requestDeploymentID string) (int64, error)
+	ListDeploymentsByRequestDeploymentID(requestDeploymentID int) ([]model.Deployment, error)
+	ListLastSuccessfulDeployments(environmentID int) ([]model.Deployment, error)
 }
 
 //DeploymentDAOImpl DeploymentDAOImpl
@@ -88,3 +90,26 @@ func (dao DeploymentDAOImpl) CountDeployments(environmentID, requestDeploymentID
 	err := dao.Db.Where(sql, requestDeploymentID).Model(&deployment).Count(&count).Error
 	return count, err
 }
+
+//ListDeploymentsByRequestDeploymentID list all deployments of a request in the order they were created
+func (dao DeploymentDAOImpl) ListDeploymentsByRequestDeploymentID(requestDeploymentID int) ([]model.Deployment, error) {
+	deployments := make([]model.Deployment, 0)
+	if err := dao.Db.Where(&model.Deployment{RequestDeploymentID: uint(requestDeploymentID)}).
+		Order("id").Find(&deployments).Error; err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+//ListLastSuccessfulDeployments list the latest successful deployment of each release in an environment,
+//by chart for the deployments recorded before the release name was
+func (dao DeploymentDAOImpl) ListLastSuccessfulDeployments(environmentID int) ([]model.Deployment, error) {
+	deployments := make([]model.Deployment, 0)
+	latest := dao.Db.Model(&model.Deployment{}).Select("MAX(id)").
+		Where("environment_id = ? AND success = ?", environmentID, true).
+		Group("COALESCE(NULLIF(release_name, ''), chart)").SubQuery()
+	if err := dao.Db.Where("id IN ?", latest).Order("id desc").Find(&deployments).Error; err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
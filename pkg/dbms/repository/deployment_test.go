@@ -24,6 +24,8 @@ func getDeployment() model2.Deployment {
 	deployment.RequestDeploymentID = 1
 	deployment.ChartVersion = "0.1.0"
 	deployment.DockerVersion = "master"
+	deployment.ReleaseName = "chart-teste-dev"
+	deployment.PreviousProductVersion = "20.0.1-0"
 	return deployment
 }
 
@@ -56,6 +58,9 @@ func TestCreateDeployment(test *testing.T) {
 		deployment.Success,
 		deployment.Message,
 		deployment.DockerVersion,
+		deployment.ReleaseName,
+		deployment.PreviousProductVersion,
+		deployment.PreviousProductVersionID,
 	).WillReturnRows(rows)
 
 	_, err = deploymentDAO.CreateDeployment(deployment)
@@ -118,6 +123,9 @@ func TestEditDeployment(test *testing.T) {
 		deployment.Success,
 		deployment.Message,
 		deployment.DockerVersion,
+		deployment.ReleaseName,
+		deployment.PreviousProductVersion,
+		deployment.PreviousProductVersionID,
 		deployment.ID,
 	).WillReturnResult(
 		sqlmock.NewResult(1, 1),
@@ -173,3 +181,50 @@ func TestListDeployments(test *testing.T) {
 
 	assert.Nil(test, err, "Error on get count of deployments")
 }
+
+func TestListDeploymentsByRequestDeploymentID(test *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(test, err)
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+	deploymentDAO := DeploymentDAOImpl{Db: gormDB}
+
+	rows := sqlmock.NewRows([]string{"id", "request_deployment_id", "environment_id", "chart", "release_name"}).
+		AddRow(1, 17, 1, "repo/foo", "foo-dev").
+		AddRow(2, 17, 1, "repo/bar", "bar-dev")
+
+	mock.ExpectQuery(`SELECT (.*) FROM "deployments" WHERE (.*) ORDER BY "id"`).
+		WithArgs(17).WillReturnRows(rows)
+
+	result, err := deploymentDAO.ListDeploymentsByRequestDeploymentID(17)
+	assert.Nil(test, err)
+	assert.Equal(test, 2, len(result))
+	assert.Equal(test, "bar-dev", result[1].ReleaseName)
+
+	mock.ExpectationsWereMet()
+}
+
+func TestListLastSuccessfulDeployments(test *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(test, err)
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+	deploymentDAO := DeploymentDAOImpl{Db: gormDB}
+
+	rows := sqlmock.NewRows([]string{"id", "environment_id", "chart", "chart_version", "success", "release_name"}).
+		AddRow(4, 1, "repo/foo", "0.2.0", true, "foo-dev").
+		AddRow(3, 1, "repo/foo", "0.2.0", true, "foo-admin-dev").
+		AddRow(2, 1, "repo/bar", "0.1.0", true, "")
+
+	query := `SELECT (.*) FROM "deployments" WHERE (.*)id IN \(SELECT MAX\(id\) FROM "deployments" ` +
+		`WHERE (.*)environment_id = \$1 AND success = \$2(.*) GROUP BY COALESCE\(NULLIF\(release_name, ''\), chart\)\)(.*) ` +
+		`ORDER BY id desc`
+	mock.ExpectQuery(query).WithArgs(1, true).WillReturnRows(rows)
+
+	result, err := deploymentDAO.ListLastSuccessfulDeployments(1)
+	assert.Nil(test, err)
+	assert.Equal(test, 3, len(result))
+	assert.Equal(test, "foo-admin-dev", result[1].ReleaseName)
+
+	assert.Nil(test, mock.ExpectationsWereMet())
+}
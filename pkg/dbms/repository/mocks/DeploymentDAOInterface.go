@@ -111,3 +111,49 @@ func (_m *DeploymentDAOInterface) ListDeployments(environmentID string, requestD
 
 	return r0, r1
 }
+
+// ListDeploymentsByRequestDeploymentID provides a mock function with given fields: requestDeploymentID
+func (_m *DeploymentDAOInterface) ListDeploymentsByRequestDeploymentID(requestDeploymentID int) ([]model.Deployment, error) {
+	ret := _m.Called(requestDeploymentID)
+
+	var r0 []model.Deployment
+	if rf, ok := ret.Get(0).(func(int) []model.Deployment); ok {
+		r0 = rf(requestDeploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(requestDeploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListLastSuccessfulDeployments provides a mock function with given fields: environmentID
+func (_m *DeploymentDAOInterface) ListLastSuccessfulDeployments(environmentID int) ([]model.Deployment, error) {
+	ret := _m.Called(environmentID)
+
+	var r0 []model.Deployment
+	if rf, ok := ret.Get(0).(func(int) []model.Deployment); ok {
+		r0 = rf(environmentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(environmentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -96,6 +96,27 @@ func (_m *ProductDAOInterface) CreateProductVersionService(e model.ProductVersio
 	return r0, r1
 }
 
+// CreateProductVersionWithServices provides a mock function with given fields: e, services
+func (_m *ProductDAOInterface) CreateProductVersionWithServices(e model.ProductVersion, services []model.ProductVersionService) (int, error) {
+	ret := _m.Called(e, services)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(model.ProductVersion, []model.ProductVersionService) int); ok {
+		r0 = rf(e, services)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(model.ProductVersion, []model.ProductVersionService) error); ok {
+		r1 = rf(e, services)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteProduct provides a mock function with given fields: id
 func (_m *ProductDAOInterface) DeleteProduct(id int) error {
 	ret := _m.Called(id)
@@ -201,6 +222,27 @@ func (_m *ProductDAOInterface) FindProductByID(id int) (model.Product, error) {
 	return r0, r1
 }
 
+// ImportProduct provides a mock function with given fields: data
+func (_m *ProductDAOInterface) ImportProduct(data model.ProductImport) (int, error) {
+	ret := _m.Called(data)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(model.ProductImport) int); ok {
+		r0 = rf(data)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(model.ProductImport) error); ok {
+		r1 = rf(data)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListProductVersionsByID provides a mock function with given fields: id
 func (_m *ProductDAOInterface) ListProductVersionsByID(id int) (*model.ProductVersion, error) {
 	ret := _m.Called(id)
@@ -100,6 +100,20 @@ func (_m *VariableDAOInterface) DeleteVariableByEnvironmentID(envID int) error {
 	return r0
 }
 
+// DeleteVariables provides a mock function with given fields: ids
+func (_m *VariableDAOInterface) DeleteVariables(ids []int) error {
+	ret := _m.Called(ids)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]int) error); ok {
+		r0 = rf(ids)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // EditVariable provides a mock function with given fields: data
 func (_m *VariableDAOInterface) EditVariable(data model.Variable) error {
 	ret := _m.Called(data)
@@ -114,6 +128,20 @@ func (_m *VariableDAOInterface) EditVariable(data model.Variable) error {
 	return r0
 }
 
+// EditVariables provides a mock function with given fields: variables
+func (_m *VariableDAOInterface) EditVariables(variables []model.Variable) error {
+	ret := _m.Called(variables)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]model.Variable) error); ok {
+		r0 = rf(variables)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetAllVariablesByEnvironment provides a mock function with given fields: envID
 func (_m *VariableDAOInterface) GetAllVariablesByEnvironment(envID int) ([]model.Variable, error) {
 	ret := _m.Called(envID)
@@ -226,3 +254,17 @@ func (_m *VariableDAOInterface) GetVarImageTagByEnvAndScope(envID int, scope str
 
 	return r0, r1
 }
+
+// RestoreVariables provides a mock function with given fields: variables, deleteIDs
+func (_m *VariableDAOInterface) RestoreVariables(variables []model.Variable, deleteIDs []int) error {
+	ret := _m.Called(variables, deleteIDs)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]model.Variable, []int) error); ok {
+		r0 = rf(variables, deleteIDs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
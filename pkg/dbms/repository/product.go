@@ -23,6 +23,8 @@ type ProductDAOInterface interface {
 	ListProductsVersionServices(id int) ([]model2.ProductVersionService, error)
 	CreateProductVersionCopying(payload model2.ProductVersion) (int, error)
 	ListProductVersionsByID(id int) (*model2.ProductVersion, error)
+	ImportProduct(data model2.ProductImport) (int, error)
+	CreateProductVersionWithServices(e model2.ProductVersion, services []model2.ProductVersionService) (int, error)
 }
 
 //ProductDAOImpl ProductDAOImpl
@@ -73,6 +75,28 @@ func (dao ProductDAOImpl) CreateProductVersion(e model2.ProductVersion) (int, er
 	return int(e.ID), nil
 }
 
+//CreateProductVersionWithServices - Create a new product version with its services, nothing is saved if one fails
+func (dao ProductDAOImpl) CreateProductVersionWithServices(e model2.ProductVersion,
+	services []model2.ProductVersionService) (int, error) {
+
+	tx := dao.Db.Begin()
+	if tx.Error != nil {
+		return -1, tx.Error
+	}
+	if err := tx.Create(&e).Error; err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+	for _, s := range services {
+		s.ProductVersionID = int(e.ID)
+		if err := tx.Create(&s).Error; err != nil {
+			tx.Rollback()
+			return -1, err
+		}
+	}
+	return int(e.ID), tx.Commit().Error
+}
+
 //CreateProductVersionService - Create a new product version
 func (dao ProductDAOImpl) CreateProductVersionService(e model2.ProductVersionService) (int, error) {
 	if err := dao.Db.Create(&e).Error; err != nil {
@@ -196,3 +220,52 @@ func (dao ProductDAOImpl) ListProductVersionsByID(id int) (*model2.ProductVersio
 	}
 	return &result, nil
 }
+
+//ImportProduct - Saves an imported product with its versions, services and notes, nothing is saved if one fails.
+//The product is created when it has no ID and versions with ID have their services replaced.
+func (dao ProductDAOImpl) ImportProduct(data model2.ProductImport) (int, error) {
+	tx := dao.Db.Begin()
+	if tx.Error != nil {
+		return -1, tx.Error
+	}
+	if err := saveProductImport(tx, &data); err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+	return int(data.Product.ID), tx.Commit().Error
+}
+
+func saveProductImport(tx *gorm.DB, data *model2.ProductImport) error {
+	if data.Product.ID == 0 {
+		if err := tx.Create(&data.Product).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, v := range data.Versions {
+		pv := v.Version
+		pv.ProductID = int(data.Product.ID)
+		if pv.ID != 0 {
+			if err := tx.Unscoped().Where("product_version_id = ?", pv.ID).
+				Delete(model2.ProductVersionService{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&pv).Error; err != nil {
+			return err
+		}
+		for _, s := range v.Services {
+			s.ProductVersionID = int(pv.ID)
+			if err := tx.Create(&s).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := range data.Notes {
+		if err := tx.Save(&data.Notes[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -44,7 +44,7 @@ func TestCreateProductVersionCopying(t *testing.T) {
 
 	mock.ExpectQuery(`INSERT INTO "product_versions"`).
 		WithArgs(payload.CreatedAt, payload.UpdatedAt, payload.DeletedAt, payload.ProductID,
-			payload.Date, payload.Version, payload.Locked, payload.HotFix).
+			payload.Date, payload.Version, payload.Locked, payload.HotFix, payload.State, payload.BaseVersionID).
 		WillReturnRows(rows)
 
 	rows2 := sqlmock.NewRows([]string{"id", "product_version_id"}).AddRow(1, 1)
@@ -92,7 +92,8 @@ func TestCreateProduct(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id"}).AddRow(99)
 
 	mock.ExpectQuery(`INSERT INTO "products"`).
-		WithArgs(AnyTime{}, AnyTime{}, nil, product.Name, product.ValidateReleases).WillReturnRows(rows)
+		WithArgs(AnyTime{}, AnyTime{}, nil, product.Name, product.ValidateReleases,
+			product.VersionScheme, product.VersionPattern).WillReturnRows(rows)
 
 	_, err = produtDAO.CreateProduct(product)
 	assert.Nil(t, err)
@@ -119,7 +120,8 @@ func TestEdit(t *testing.T) {
 	product.ValidateReleases = true
 
 	mock.ExpectExec(`UPDATE "products" SET (.*) WHERE (.*)`).
-		WithArgs(AnyTime{}, nil, product.Name, product.ValidateReleases, product.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+		WithArgs(AnyTime{}, nil, product.Name, product.ValidateReleases, product.VersionScheme,
+			product.VersionPattern, product.ID).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err = produtDAO.EditProduct(product)
 	assert.Nil(t, err)
@@ -133,7 +135,8 @@ func TestEdit(t *testing.T) {
 
 	mock.ExpectExec(`UPDATE "product_versions"`).
 		WithArgs(AnyTime{}, nil, product.ID, AnyTime{},
-			productVersion.Version, productVersion.Locked, productVersion.HotFix, productVersion.ID).
+			productVersion.Version, productVersion.Locked, productVersion.HotFix, productVersion.State,
+			productVersion.BaseVersionID, productVersion.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err = produtDAO.EditProductVersion(productVersion)
@@ -548,3 +551,112 @@ func TestListProductVersionsServiceByID_Error(t *testing.T) {
 
 	mock.ExpectationsWereMet()
 }
+
+func TestImportProduct(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	produtDAO := ProductDAOImpl{}
+	produtDAO.Db = gormDB
+
+	data := model.ProductImport{Product: model.Product{Name: "alfa"}}
+	data.Versions = []model.ProductVersionImport{{
+		Version:  model.ProductVersion{Version: "1.0", State: model.ProductVersionDraft},
+		Services: []model.ProductVersionService{{ServiceName: "repo/alfa - 0.1.0", DockerImageTag: "1.0"}},
+	}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "products"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO "product_versions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`INSERT INTO "product_version_services"`).
+		WithArgs(AnyTime{}, AnyTime{}, nil, 2, "repo/alfa - 0.1.0", "1.0", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	id, err := produtDAO.ImportProduct(data)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, id)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestImportProduct_Rollback(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	produtDAO := ProductDAOImpl{}
+	produtDAO.Db = gormDB
+
+	data := model.ProductImport{}
+	data.Product.ID = 1
+	version := model.ProductVersion{Version: "1.0"}
+	version.ID = 2
+	data.Versions = []model.ProductVersionImport{{Version: version}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "product_version_services"`).WithArgs(2).
+		WillReturnError(errors.New("mock error"))
+	mock.ExpectRollback()
+
+	_, err = produtDAO.ImportProduct(data)
+	assert.Error(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateProductVersionWithServices(t *testing.T) {
+
+	payload := getProductVersion()
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	produtDAO := ProductDAOImpl{}
+	produtDAO.Db = gormDB
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "product_versions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`INSERT INTO "product_version_services"`).
+		WithArgs(AnyTime{}, AnyTime{}, nil, 2, "alfa", "latest", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	id, err := produtDAO.CreateProductVersionWithServices(*payload,
+		[]model.ProductVersionService{{ServiceName: "alfa", DockerImageTag: "latest"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, id)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateProductVersionWithServices_Rollback(t *testing.T) {
+
+	payload := getProductVersion()
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	produtDAO := ProductDAOImpl{}
+	produtDAO.Db = gormDB
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "product_versions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`INSERT INTO "product_version_services"`).WillReturnError(errors.New("mock error"))
+	mock.ExpectRollback()
+
+	_, err = produtDAO.CreateProductVersionWithServices(*payload,
+		[]model.ProductVersionService{{ServiceName: "alfa", DockerImageTag: "latest"}})
+	assert.Error(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
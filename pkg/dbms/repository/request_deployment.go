@@ -93,7 +93,7 @@ func (dao RequestDeploymentDAOImpl) ListRequestDeployments(startDate, endDate, e
 	var rdList []model.RequestDeployments
 	sql := prepareWhere(id, environmentID, userID)
 	rows, err := dao.Db.Table("request_deployments").Select(
-		"DISTINCT request_deployments.id, request_deployments.created_at, request_deployments.updated_at, request_deployments.processed, request_deployments.success, users.email as email",
+		"DISTINCT request_deployments.id, request_deployments.created_at, request_deployments.updated_at, request_deployments.processed, request_deployments.success, users.email as email, request_deployments.message",
 	).Joins(
 		"JOIN deployments ON deployments.request_deployment_id = request_deployments.id",
 	).Joins(
@@ -105,8 +105,8 @@ func (dao RequestDeploymentDAOImpl) ListRequestDeployments(startDate, endDate, e
 		createdAt := time.Time{}
 		updatedAt := time.Time{}
 		success, processed := false, false
-		email := ""
-		rows.Scan(&id, &createdAt, &updatedAt, &processed, &success, &email)
+		email, message := "", ""
+		rows.Scan(&id, &createdAt, &updatedAt, &processed, &success, &email, &message)
 
 		request := model.RequestDeployments{}
 		request.ID = uint(id)
@@ -115,6 +115,7 @@ func (dao RequestDeploymentDAOImpl) ListRequestDeployments(startDate, endDate, e
 		request.Processed = processed
 		request.Success = success
 		request.User = email
+		request.Message = message
 
 		rdList = append(rdList, request)
 	}
@@ -46,6 +46,7 @@ func TestCreateRequestDeployment(test *testing.T) {
 		requestDeployment.Success,
 		requestDeployment.Processed,
 		requestDeployment.UserID,
+		requestDeployment.Message,
 	).WillReturnRows(rows)
 
 	_, err = requestDeploymentDAO.CreateRequestDeployment(requestDeployment)
@@ -100,6 +101,7 @@ func TestEditRequestDeployment(test *testing.T) {
 		deployment.Processed,
 		deployment.Success,
 		deployment.UserID,
+		deployment.Message,
 		deployment.ID,
 	).WillReturnResult(
 		sqlmock.NewResult(1, 1),
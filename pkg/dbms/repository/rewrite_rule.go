@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"github.com/jinzhu/gorm"
+	model "github.com/softplan/tenkai-api/pkg/dbms/model"
+)
+
+//RewriteRuleSetDAOInterface RewriteRuleSetDAOInterface
+type RewriteRuleSetDAOInterface interface {
+	CreateRewriteRuleSet(e model.RewriteRuleSet) (int, error)
+	EditRewriteRuleSet(e model.RewriteRuleSet) error
+	DeleteRewriteRuleSet(id int) error
+	ListRewriteRuleSets() ([]model.RewriteRuleSet, error)
+	GetRewriteRuleSetByID(id int) (model.RewriteRuleSet, error)
+}
+
+//RewriteRuleSetDAOImpl RewriteRuleSetDAOImpl
+type RewriteRuleSetDAOImpl struct {
+	Db *gorm.DB
+}
+
+//CreateRewriteRuleSet - Create a new rewrite rule set
+func (dao RewriteRuleSetDAOImpl) CreateRewriteRuleSet(e model.RewriteRuleSet) (int, error) {
+	if err := dao.Db.Create(&e).Error; err != nil {
+		return -1, err
+	}
+	return int(e.ID), nil
+}
+
+//EditRewriteRuleSet - Updates an existing rewrite rule set, its rules are replaced by the ones submitted
+func (dao RewriteRuleSetDAOImpl) EditRewriteRuleSet(e model.RewriteRuleSet) error {
+	tx := dao.Db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	rules := e.RewriteRules
+	e.RewriteRules = nil
+	if err := tx.Unscoped().Where("rewrite_rule_set_id = ?", e.ID).Delete(model.RewriteRule{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Save(&e).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, rule := range rules {
+		rule.ID = 0
+		rule.RewriteRuleSetID = e.ID
+		if err := tx.Create(rule).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+//DeleteRewriteRuleSet - Deletes a rewrite rule set with its rules
+func (dao RewriteRuleSetDAOImpl) DeleteRewriteRuleSet(id int) error {
+	tx := dao.Db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Unscoped().Where("rewrite_rule_set_id = ?", id).Delete(model.RewriteRule{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Delete(model.RewriteRuleSet{}, id).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+//ListRewriteRuleSets - List rewrite rule sets
+func (dao RewriteRuleSetDAOImpl) ListRewriteRuleSets() ([]model.RewriteRuleSet, error) {
+	list := make([]model.RewriteRuleSet, 0)
+	if err := dao.Db.Preload("RewriteRules").Find(&list).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return make([]model.RewriteRuleSet, 0), nil
+		}
+		return nil, err
+	}
+	return list, nil
+}
+
+//GetRewriteRuleSetByID - Retrieve a rewrite rule set with its rules
+func (dao RewriteRuleSetDAOImpl) GetRewriteRuleSetByID(id int) (model.RewriteRuleSet, error) {
+	var result model.RewriteRuleSet
+	if err := dao.Db.Preload("RewriteRules").First(&result, id).Error; err != nil {
+		return model.RewriteRuleSet{}, err
+	}
+	return result, nil
+}
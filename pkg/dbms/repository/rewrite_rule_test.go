@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func getRewriteRuleSet() model.RewriteRuleSet {
+	var item model.RewriteRuleSet
+	item.Name = "dev to qa"
+	return item
+}
+
+func beforeRewriteRuleSetTest(t *testing.T) (*gorm.DB, sqlmock.Sqlmock, RewriteRuleSetDAOImpl, model.RewriteRuleSet) {
+	db, mock, err := sqlmock.New()
+	gormDB, err := gorm.Open("postgres", db)
+
+	assert.Nil(t, err)
+
+	dao := RewriteRuleSetDAOImpl{}
+	dao.Db = gormDB
+
+	mock.MatchExpectationsInOrder(false)
+
+	item := getRewriteRuleSet()
+
+	return gormDB, mock, dao, item
+}
+
+func TestCreateRewriteRuleSet(t *testing.T) {
+	gormDB, mock, dao, item := beforeRewriteRuleSetTest(t)
+	defer gormDB.Close()
+
+	rows := sqlmock.NewRows([]string{"id"}).AddRow(1)
+
+	mock.ExpectQuery(`INSERT INTO "rewrite_rule_sets"`).
+		WithArgs(AnyTime{}, AnyTime{}, nil, item.Name).
+		WillReturnRows(rows)
+
+	result, e := dao.CreateRewriteRuleSet(item)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, result)
+
+	mock.ExpectationsWereMet()
+}
+
+func TestCreateRewriteRuleSet_Error(t *testing.T) {
+	gormDB, mock, dao, item := beforeRewriteRuleSetTest(t)
+	defer gormDB.Close()
+
+	mock.ExpectQuery(`INSERT INTO "rewrite_rule_sets"`).
+		WithArgs(AnyTime{}, AnyTime{}, nil, item.Name).
+		WillReturnError(errors.New("some error"))
+
+	_, e := dao.CreateRewriteRuleSet(item)
+	assert.Error(t, e)
+
+	mock.ExpectationsWereMet()
+}
+
+func TestEditRewriteRuleSet(t *testing.T) {
+	gormDB, mock, dao, item := beforeRewriteRuleSetTest(t)
+	defer gormDB.Close()
+
+	item.ID = 999
+	rule := model.RewriteRule{Type: "Replace", Search: "dev", Replacement: "qa"}
+	rule.ID = 5
+	item.RewriteRules = []*model.RewriteRule{&rule}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "rewrite_rules" WHERE (.*)`).
+		WithArgs(item.ID).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec(`UPDATE "rewrite_rule_sets" SET (.*) WHERE (.*)`).
+		WithArgs(AnyTime{}, nil, item.Name, item.ID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO "rewrite_rules"`).
+		WithArgs(AnyTime{}, AnyTime{}, nil, rule.Type, rule.Search, rule.Replacement, "", "", item.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	mock.ExpectCommit()
+
+	e := dao.EditRewriteRuleSet(item)
+	assert.Nil(t, e)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestEditRewriteRuleSet_Rollback(t *testing.T) {
+	gormDB, mock, dao, item := beforeRewriteRuleSetTest(t)
+	defer gormDB.Close()
+
+	item.ID = 999
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "rewrite_rules" WHERE (.*)`).
+		WithArgs(item.ID).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec(`UPDATE "rewrite_rule_sets" SET (.*) WHERE (.*)`).
+		WillReturnError(errors.New("some error"))
+	mock.ExpectRollback()
+
+	e := dao.EditRewriteRuleSet(item)
+	assert.Error(t, e)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteRewriteRuleSet(t *testing.T) {
+	gormDB, mock, dao, _ := beforeRewriteRuleSetTest(t)
+	defer gormDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "rewrite_rules" WHERE (.*)`).
+		WithArgs(999).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec(`DELETE FROM "rewrite_rule_sets" WHERE (.*)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := dao.DeleteRewriteRuleSet(999)
+	assert.Nil(t, err)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestListRewriteRuleSets(t *testing.T) {
+	gormDB, mock, dao, item := beforeRewriteRuleSetTest(t)
+	defer gormDB.Close()
+
+	rows1 := sqlmock.NewRows([]string{"id", "name"}).
+		AddRow(999, item.Name)
+
+	rows2 := sqlmock.NewRows([]string{"id", "search", "rewrite_rule_set_id"}).
+		AddRow(1, "dev", 999)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "rewrite_rule_sets" WHERE (.+)`).
+		WillReturnRows(rows1)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "rewrite_rules" WHERE (.+)`).
+		WithArgs(999).
+		WillReturnRows(rows2)
+
+	result, err := dao.ListRewriteRuleSets()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, 1, len(result[0].RewriteRules))
+
+	mock.ExpectationsWereMet()
+}
+
+func TestListRewriteRuleSets_Error(t *testing.T) {
+	gormDB, mock, dao, _ := beforeRewriteRuleSetTest(t)
+	defer gormDB.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "rewrite_rule_sets" WHERE (.+)`).
+		WillReturnError(errors.New("mock error"))
+
+	_, err := dao.ListRewriteRuleSets()
+	assert.Error(t, err)
+
+	mock.ExpectationsWereMet()
+}
+
+func TestGetRewriteRuleSetByID(t *testing.T) {
+	gormDB, mock, dao, item := beforeRewriteRuleSetTest(t)
+	defer gormDB.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "name"}).
+		AddRow(999, item.Name)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "rewrite_rule_sets" WHERE (.+)`).
+		WillReturnRows(rows)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "rewrite_rules" WHERE (.+)`).
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	result, err := dao.GetRewriteRuleSetByID(999)
+	assert.Nil(t, err)
+	assert.Equal(t, item.Name, result.Name)
+
+	mock.ExpectationsWereMet()
+}
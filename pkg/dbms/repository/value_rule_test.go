@@ -41,7 +41,7 @@ func TestCreateValueRule(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id"}).AddRow(1)
 
 	mock.ExpectQuery(`INSERT INTO "value_rules"`).
-		WithArgs(AnyTime{}, AnyTime{}, nil, item.Type, item.Value, item.VariableRuleID).
+		WithArgs(AnyTime{}, AnyTime{}, nil, item.Type, item.Value, item.VariableRuleID, item.Severity).
 		WillReturnRows(rows)
 
 	result, e := dao.CreateValueRule(item)
@@ -56,7 +56,7 @@ func TestCreateValueRule_Error(t *testing.T) {
 	defer gormDB.Close()
 
 	mock.ExpectQuery(`INSERT INTO "value_rules"`).
-		WithArgs(AnyTime{}, AnyTime{}, nil, item.Type, item.Value, item.VariableRuleID).
+		WithArgs(AnyTime{}, AnyTime{}, nil, item.Type, item.Value, item.VariableRuleID, item.Severity).
 		WillReturnError(errors.New("some error"))
 
 	_, e := dao.CreateValueRule(item)
@@ -72,7 +72,7 @@ func TestEditValueRule(t *testing.T) {
 	item.ID = 999
 
 	mock.ExpectExec(`UPDATE "value_rules" SET (.*) WHERE (.*)`).
-		WithArgs(AnyTime{}, nil, item.Type, item.Value, item.VariableRuleID, item.ID).
+		WithArgs(AnyTime{}, nil, item.Type, item.Value, item.VariableRuleID, item.Severity, item.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	e := dao.EditValueRule(item)
@@ -10,12 +10,15 @@ import (
 //VariableDAOInterface VariableDAOInterface
 type VariableDAOInterface interface {
 	EditVariable(data model2.Variable) error
+	EditVariables(variables []model2.Variable) error
 	CreateVariable(variable model2.Variable) (map[string]string, bool, error)
 	CreateVariableWithDefaultValue(variable model2.Variable) (map[string]string, bool, error)
 	GetAllVariablesByEnvironment(envID int) ([]model2.Variable, error)
 	GetAllVariablesByEnvironmentAndScope(envID int, scope string) ([]model2.Variable, error)
 	GetAllVariablesByEnvironmentsAndScopes(envID []string, scope []string) ([]model2.Variable, error)
 	DeleteVariable(id int) error
+	DeleteVariables(ids []int) error
+	RestoreVariables(variables []model2.Variable, deleteIDs []int) error
 	DeleteVariableByEnvironmentID(envID int) error
 	GetByID(id uint) (*model2.Variable, error)
 	GetVarImageTagByEnvAndScope(envID int, scope string) (model2.Variable, error)
@@ -31,6 +34,21 @@ func (dao VariableDAOImpl) EditVariable(data model2.Variable) error {
 	return dao.Db.Save(&data).Error
 }
 
+//EditVariables - Edit several variables at once, none of them is saved if one fails
+func (dao VariableDAOImpl) EditVariables(variables []model2.Variable) error {
+	tx := dao.Db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for i := range variables {
+		if err := tx.Save(&variables[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
 //CreateVariable - Create a new environment
 func (dao VariableDAOImpl) CreateVariable(variable model2.Variable) (map[string]string, bool, error) {
 
@@ -44,7 +62,7 @@ func (dao VariableDAOImpl) CreateVariable(variable model2.Variable) (map[string]
 		Scope:         variable.Scope,
 		Name:          variable.Name}).First(&variableEntity).Error; err == nil {
 
-		if variable.Value != variableEntity.Value {
+		if variable.Value != variableEntity.Value || variable.Type != variableEntity.Type {
 
 			auditValues["variable_name"] = variableEntity.Name
 			auditValues["variable_old_value"] = variableEntity.Value
@@ -52,6 +70,7 @@ func (dao VariableDAOImpl) CreateVariable(variable model2.Variable) (map[string]
 			auditValues["scope"] = variable.Scope
 
 			variableEntity.Value = variable.Value
+			variableEntity.Type = variable.Type
 			if err := dao.Db.Save(variableEntity).Error; err != nil {
 				return auditValues, updated, err
 			}
@@ -180,6 +199,42 @@ func (dao VariableDAOImpl) DeleteVariable(id int) error {
 	return dao.Db.Unscoped().Delete(model2.Variable{}, id).Error
 }
 
+//DeleteVariables - Delete several variables in a single transaction
+func (dao VariableDAOImpl) DeleteVariables(ids []int) error {
+	tx := dao.Db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for _, id := range ids {
+		if err := tx.Unscoped().Delete(model2.Variable{}, id).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+//RestoreVariables - Saves and deletes variables in a single transaction
+func (dao VariableDAOImpl) RestoreVariables(variables []model2.Variable, deleteIDs []int) error {
+	tx := dao.Db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for i := range variables {
+		if err := tx.Save(&variables[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, id := range deleteIDs {
+		if err := tx.Unscoped().Delete(model2.Variable{}, id).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
 //DeleteVariableByEnvironmentID - Delete environment
 func (dao VariableDAOImpl) DeleteVariableByEnvironmentID(envID int) error {
 	return dao.Db.Unscoped().Where(model2.Variable{EnvironmentID: envID}).Delete(model2.Variable{}).Error
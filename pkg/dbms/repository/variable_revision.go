@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"github.com/jinzhu/gorm"
+	model "github.com/softplan/tenkai-api/pkg/dbms/model"
+)
+
+//VariableRevisionDAOInterface VariableRevisionDAOInterface
+type VariableRevisionDAOInterface interface {
+	CreateVariableRevision(e model.VariableRevision) (int, error)
+	ListVariableRevisions(envID int, scope string, name string) ([]model.VariableRevision, error)
+	ListEnvironmentVariableRevisions(envID int, scope string) ([]model.VariableRevision, error)
+}
+
+//VariableRevisionDAOImpl VariableRevisionDAOImpl
+type VariableRevisionDAOImpl struct {
+	Db *gorm.DB
+}
+
+//CreateVariableRevision - Records a variable change
+func (dao VariableRevisionDAOImpl) CreateVariableRevision(e model.VariableRevision) (int, error) {
+	if err := dao.Db.Create(&e).Error; err != nil {
+		return -1, err
+	}
+	return int(e.ID), nil
+}
+
+//ListVariableRevisions - List the changes of a variable, oldest first
+func (dao VariableRevisionDAOImpl) ListVariableRevisions(envID int, scope string,
+	name string) ([]model.VariableRevision, error) {
+
+	list := make([]model.VariableRevision, 0)
+	condition := model.VariableRevision{EnvironmentID: envID, Scope: scope, Name: name}
+	if err := dao.Db.Where(&condition).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+//ListEnvironmentVariableRevisions - List the variable changes of an environment, oldest first.
+//An empty scope lists the changes of every chart.
+func (dao VariableRevisionDAOImpl) ListEnvironmentVariableRevisions(envID int,
+	scope string) ([]model.VariableRevision, error) {
+
+	list := make([]model.VariableRevision, 0)
+	condition := model.VariableRevision{EnvironmentID: envID, Scope: scope}
+	if err := dao.Db.Where(&condition).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func getVariableRevision() model.VariableRevision {
+	var item model.VariableRevision
+	item.EnvironmentID = 999
+	item.Scope = "repo/foo"
+	item.Name = "url"
+	item.Operation = model.VariableUpdated
+	item.OldValue = "dev.host"
+	item.NewValue = "qa.host"
+	item.User = "beta@alfa.com"
+	return item
+}
+
+func beforeVariableRevisionTest(t *testing.T) (*gorm.DB, sqlmock.Sqlmock, VariableRevisionDAOImpl, model.VariableRevision) {
+	db, mock, err := sqlmock.New()
+	gormDB, err := gorm.Open("postgres", db)
+
+	assert.Nil(t, err)
+
+	dao := VariableRevisionDAOImpl{}
+	dao.Db = gormDB
+
+	mock.MatchExpectationsInOrder(false)
+
+	item := getVariableRevision()
+
+	return gormDB, mock, dao, item
+}
+
+func TestCreateVariableRevision(t *testing.T) {
+	gormDB, mock, dao, item := beforeVariableRevisionTest(t)
+	defer gormDB.Close()
+
+	rows := sqlmock.NewRows([]string{"id"}).AddRow(1)
+
+	mock.ExpectQuery(`INSERT INTO "variable_revisions"`).
+		WithArgs(AnyTime{}, AnyTime{}, nil, item.EnvironmentID, item.Scope, item.Name, item.Operation,
+			item.OldValue, item.NewValue, item.Secret, item.Type, item.Description, item.User).
+		WillReturnRows(rows)
+
+	result, e := dao.CreateVariableRevision(item)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, result)
+
+	mock.ExpectationsWereMet()
+}
+
+func TestCreateVariableRevision_Error(t *testing.T) {
+	gormDB, mock, dao, item := beforeVariableRevisionTest(t)
+	defer gormDB.Close()
+
+	mock.ExpectQuery(`INSERT INTO "variable_revisions"`).
+		WillReturnError(errors.New("some error"))
+
+	_, e := dao.CreateVariableRevision(item)
+	assert.Error(t, e)
+
+	mock.ExpectationsWereMet()
+}
+
+func TestListVariableRevisions(t *testing.T) {
+	gormDB, mock, dao, item := beforeVariableRevisionTest(t)
+	defer gormDB.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "environment_id", "scope", "name", "operation"}).
+		AddRow(1, item.EnvironmentID, item.Scope, item.Name, item.Operation)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "variable_revisions" WHERE (.+) ORDER BY "id"`).
+		WithArgs(item.EnvironmentID, item.Scope, item.Name).
+		WillReturnRows(rows)
+
+	result, err := dao.ListVariableRevisions(item.EnvironmentID, item.Scope, item.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, item.Operation, result[0].Operation)
+
+	mock.ExpectationsWereMet()
+}
+
+func TestListEnvironmentVariableRevisions(t *testing.T) {
+	gormDB, mock, dao, item := beforeVariableRevisionTest(t)
+	defer gormDB.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "environment_id", "scope", "name"}).
+		AddRow(1, item.EnvironmentID, item.Scope, item.Name).
+		AddRow(2, item.EnvironmentID, "repo/bar", "pool")
+
+	mock.ExpectQuery(`SELECT (.+) FROM "variable_revisions" WHERE (.+) ORDER BY "id"`).
+		WithArgs(item.EnvironmentID).
+		WillReturnRows(rows)
+
+	result, err := dao.ListEnvironmentVariableRevisions(item.EnvironmentID, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(result))
+
+	mock.ExpectationsWereMet()
+}
+
+func TestListEnvironmentVariableRevisions_Error(t *testing.T) {
+	gormDB, mock, dao, item := beforeVariableRevisionTest(t)
+	defer gormDB.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "variable_revisions" WHERE (.+)`).
+		WillReturnError(errors.New("mock error"))
+
+	_, err := dao.ListEnvironmentVariableRevisions(item.EnvironmentID, item.Scope)
+	assert.Error(t, err)
+
+	mock.ExpectationsWereMet()
+}
@@ -27,7 +27,7 @@ func TestEditVariable(t *testing.T) {
 	v := getVariable()
 
 	mock.ExpectExec(`UPDATE "variables" SET (.*) WHERE (.*)`).
-		WithArgs(AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+		WithArgs(AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.Type, v.ID).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err = dao.EditVariable(v)
 
@@ -60,7 +60,7 @@ func TestCreateVariable(t *testing.T) {
 		WillReturnError(errors.New("mock error"))
 
 	mock.ExpectQuery(`INSERT INTO "variables"`).
-		WithArgs(999, AnyTime{}, AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID).
+		WithArgs(999, AnyTime{}, AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.Type).
 		WillReturnRows(rows)
 
 	audit, updated, err := dao.CreateVariable(v)
@@ -92,7 +92,7 @@ func TestCreateVariable_Error(t *testing.T) {
 		WillReturnError(errors.New("mock error"))
 
 	mock.ExpectQuery(`INSERT INTO "variables"`).
-		WithArgs(999, AnyTime{}, AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID).
+		WithArgs(999, AnyTime{}, AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.Type).
 		WillReturnError(errors.New("mock error"))
 
 	audit, updated, err := dao.CreateVariable(v)
@@ -127,7 +127,7 @@ func TestCreateVariable_Audit(t *testing.T) {
 		WillReturnRows(rows1)
 
 	mock.ExpectExec(`UPDATE "variables" SET (.*) WHERE (.*)`).
-		WithArgs(AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.ID).
+		WithArgs(AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.Type, v.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	audit, updated, err := dao.CreateVariable(v)
@@ -138,6 +138,41 @@ func TestCreateVariable_Audit(t *testing.T) {
 	mock.ExpectationsWereMet()
 }
 
+func TestCreateVariable_ClearType(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+
+	assert.Nil(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	dao := VariableDAOImpl{}
+	dao.Db = gormDB
+
+	mock.MatchExpectationsInOrder(false)
+
+	v := getVariable()
+
+	rows1 := sqlmock.NewRows([]string{"id", "scope", "name", "value", "description", "environment_id", "secret", "type"}).
+		AddRow(v.ID, v.Scope, v.Name, v.Value, v.Description, v.EnvironmentID, v.Secret, model.VariableTypeList)
+
+	mock.ExpectQuery(`SELECT (.*) FROM "variables" WHERE (.*) ORDER BY (.*) ASC LIMIT 1`).
+		WithArgs(v.Scope, v.Name, v.EnvironmentID).
+		WillReturnRows(rows1)
+
+	mock.ExpectExec(`UPDATE "variables" SET (.*) WHERE (.*)`).
+		WithArgs(AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, "", v.ID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	audit, updated, err := dao.CreateVariable(v)
+	assert.Nil(t, err)
+	assert.NotNil(t, audit)
+	assert.True(t, updated)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
 func TestCreateVariable_AuditSaveError(t *testing.T) {
 
 	db, mock, err := sqlmock.New()
@@ -162,7 +197,7 @@ func TestCreateVariable_AuditSaveError(t *testing.T) {
 		WillReturnRows(rows1)
 
 	mock.ExpectExec(`UPDATE "variables" SET (.*) WHERE (.*)`).
-		WithArgs(AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.ID).
+		WithArgs(AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.Type, v.ID).
 		WillReturnError(errors.New("mock error"))
 
 	audit, updated, err := dao.CreateVariable(v)
@@ -196,7 +231,7 @@ func TestCreateVariableWithDefaultValue(t *testing.T) {
 		WillReturnError(gorm.ErrRecordNotFound)
 
 	mock.ExpectQuery(`INSERT INTO "variables"`).
-		WithArgs(999, AnyTime{}, AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID).
+		WithArgs(999, AnyTime{}, AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.Type).
 		WillReturnRows(rows)
 
 	audit, updated, err := dao.CreateVariableWithDefaultValue(v)
@@ -443,6 +478,46 @@ func TestDeleteVariable(t *testing.T) {
 	mock.ExpectationsWereMet()
 }
 
+func TestDeleteVariables(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	dao := VariableDAOImpl{}
+	dao.Db = gormDB
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "variables" WHERE (.*)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM "variables" WHERE (.*)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = dao.DeleteVariables([]int{1, 2})
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteVariables_Rollback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	dao := VariableDAOImpl{}
+	dao.Db = gormDB
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "variables" WHERE (.*)`).
+		WillReturnError(errors.New("mock error"))
+	mock.ExpectRollback()
+
+	err = dao.DeleteVariables([]int{1, 2})
+	assert.Error(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
 func TestDeleteVariableByEnvironmentID(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err)
@@ -513,3 +588,101 @@ func TestGetVarImageTagByEnvsAndScopes(t *testing.T) {
 
 	mock.ExpectationsWereMet()
 }
+
+func TestEditVariables(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+
+	assert.Nil(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	dao := VariableDAOImpl{}
+	dao.Db = gormDB
+
+	v := getVariable()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "variables" SET (.*) WHERE (.*)`).
+		WithArgs(AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.Type, v.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = dao.EditVariables([]model.Variable{v})
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestEditVariables_Rollback(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+
+	assert.Nil(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	dao := VariableDAOImpl{}
+	dao.Db = gormDB
+
+	v := getVariable()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "variables" SET (.*) WHERE (.*)`).
+		WillReturnError(errors.New("mock error"))
+	mock.ExpectRollback()
+
+	err = dao.EditVariables([]model.Variable{v, v})
+
+	assert.Error(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreVariables(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+
+	assert.Nil(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	dao := VariableDAOImpl{}
+	dao.Db = gormDB
+
+	v := getVariable()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "variables" SET (.*) WHERE (.*)`).
+		WithArgs(AnyTime{}, nil, v.Scope, v.Name, v.Value, v.Secret, v.Description, v.EnvironmentID, v.Type, v.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM "variables" WHERE (.*)`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = dao.RestoreVariables([]model.Variable{v}, []int{2})
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreVariables_Rollback(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+
+	assert.Nil(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	defer gormDB.Close()
+
+	dao := VariableDAOImpl{}
+	dao.Db = gormDB
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "variables" WHERE (.*)`).WillReturnError(errors.New("mock error"))
+	mock.ExpectRollback()
+
+	err = dao.RestoreVariables([]model.Variable{}, []int{2, 3})
+
+	assert.Error(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -45,6 +45,8 @@ type Repositories struct {
 	WebHookDAO             repository.WebHookDAOInterface
 	DeploymentDAO          repository.DeploymentDAOInterface
 	RequestDeploymentDAO   repository.RequestDeploymentDAOInterface
+	RewriteRuleSetDAO      repository.RewriteRuleSetDAOInterface
+	VariableRevisionDAO    repository.VariableRevisionDAOInterface
 }
 
 //AppContext AppContext
@@ -93,8 +95,13 @@ func defineRotes(r *mux.Router, appContext *AppContext) {
 
 	r.HandleFunc("/variables", appContext.editVariable).Methods("POST")
 	r.HandleFunc("/variables/copy-value", appContext.copyVariableValue).Methods("POST")
+	r.HandleFunc("/variables/replace", appContext.replaceVariables).Methods("POST")
+	r.HandleFunc("/variables/cleanup", appContext.cleanupVariables).Methods("POST")
+	r.HandleFunc("/variables/search", appContext.searchVariables).Methods("GET")
 	r.HandleFunc("/variables/{envId}", appContext.getVariables).Methods("GET")
 	r.HandleFunc("/variables/delete/{id}", appContext.deleteVariable).Methods("DELETE")
+	r.HandleFunc("/variables/{id}/history", appContext.listVariableHistory).Methods("GET")
+	r.HandleFunc("/variables/{id}/reveal", appContext.revealSecret).Methods("GET")
 	r.HandleFunc("/deletePod", appContext.deletePod).Methods("DELETE")
 
 	r.HandleFunc("/variables/edit", appContext.editVariable).Methods("POST")
@@ -110,6 +117,13 @@ func defineRotes(r *mux.Router, appContext *AppContext) {
 	r.HandleFunc("/revision", appContext.revision).Methods("POST")
 
 	r.HandleFunc("/environments/duplicate/{id}", appContext.duplicateEnvironments).Methods("GET")
+	r.HandleFunc("/environments/{id}/drift", appContext.environmentDrift).Methods("GET")
+	r.HandleFunc("/environments/{id}/variableHistory", appContext.listEnvironmentVariableHistory).Methods("GET")
+	r.HandleFunc("/environments/{id}/variables/restore", appContext.restoreEnvironmentVariables).Methods("POST")
+	r.HandleFunc("/environments/{id}/variables/import", appContext.importVariables).Methods("POST")
+	r.HandleFunc("/environments/{id}/variables/validate", appContext.validateEnvironmentInterpolation).Methods("GET")
+	r.HandleFunc("/environments/{id}/variables/interpolate", appContext.interpolateValue).Methods("POST")
+	r.HandleFunc("/environments/{id}/effectiveVariables", appContext.listEffectiveVariables).Methods("GET")
 
 	r.HandleFunc("/repositories", appContext.listRepositories).Methods("GET")
 	r.HandleFunc("/repositories", appContext.newRepository).Methods("POST")
@@ -117,6 +131,7 @@ func defineRotes(r *mux.Router, appContext *AppContext) {
 
 	r.HandleFunc("/deleteHelmRelease", appContext.deleteHelmRelease).Methods("DELETE")
 	r.HandleFunc("/helmDryRun", appContext.helmDryRun).Methods("POST")
+	r.HandleFunc("/helmManifestDiff", appContext.helmManifestDiff).Methods("POST")
 
 	r.HandleFunc("/solutions", appContext.listSolution).Methods("GET")
 	r.HandleFunc("/solutions", appContext.newSolution).Methods("POST")
@@ -127,13 +142,19 @@ func defineRotes(r *mux.Router, appContext *AppContext) {
 	r.HandleFunc("/products", appContext.newProduct).Methods("POST")
 	r.HandleFunc("/products/edit", appContext.editProduct).Methods("POST")
 	r.HandleFunc("/products/{id}", appContext.deleteProduct).Methods("DELETE")
+	r.HandleFunc("/products/{id}/export", appContext.exportProduct).Methods("GET")
+	r.HandleFunc("/products/import", appContext.importProduct).Methods("POST")
 
 	r.HandleFunc("/productVersions", appContext.listProductVersions).Methods("GET")
 	r.HandleFunc("/productVersions", appContext.newProductVersion).Methods("POST")
+	r.HandleFunc("/productVersions/releaseNotes", appContext.releaseNotes).Methods("GET")
+	r.HandleFunc("/productVersions/releaseNotes/notify", appContext.notifyReleaseNotes).Methods("POST")
 	r.HandleFunc("/productVersions/edit", appContext.editProductVersion).Methods("POST")
 	r.HandleFunc("/productVersions/{id}", appContext.deleteProductVersion).Methods("DELETE")
 	r.HandleFunc("/productVersions/lock/{id}", appContext.lockProductVersion).Methods("GET")
 	r.HandleFunc("/productVersions/unlock/{id}", appContext.unlockProductVersion).Methods("GET")
+	r.HandleFunc("/productVersions/{id}/transition", appContext.transitionProductVersion).Methods("POST")
+	r.HandleFunc("/productVersions/{id}/hotfix", appContext.newHotFixVersion).Methods("POST")
 
 	r.HandleFunc("/productVersionServices", appContext.listProductVersionServices).Methods("GET")
 	r.HandleFunc("/productVersionServices", appContext.newProductVersionService).Methods("POST")
@@ -182,10 +203,17 @@ func defineRotes(r *mux.Router, appContext *AppContext) {
 	r.HandleFunc("/variablerules/edit", appContext.editVariableRule).Methods("POST")
 	r.HandleFunc("/variablerules/{id}", appContext.deleteVariableRule).Methods("DELETE")
 
+	r.HandleFunc("/rewriteRuleSets", appContext.listRewriteRuleSets).Methods("GET")
+	r.HandleFunc("/rewriteRuleSets", appContext.newRewriteRuleSet).Methods("POST")
+	r.HandleFunc("/rewriteRuleSets/edit", appContext.editRewriteRuleSet).Methods("POST")
+	r.HandleFunc("/rewriteRuleSets/preview", appContext.previewRewriteRuleSet).Methods("POST")
+	r.HandleFunc("/rewriteRuleSets/{id}", appContext.deleteRewriteRuleSet).Methods("DELETE")
+
 	r.HandleFunc("/validateVariables", appContext.validateVariables).Methods("POST")
 	r.HandleFunc("/validateEnvVars/{envId}", appContext.validateEnvironmentVariables).Methods("POST")
 
 	r.HandleFunc("/compare-environments", appContext.compareEnvironments).Methods("POST")
+	r.HandleFunc("/compare-environments/matrix", appContext.compareEnvironmentsMatrix).Methods("POST")
 	r.HandleFunc("/compare-environments/save-query", appContext.saveCompareEnvQuery).Methods("POST")
 	r.HandleFunc("/compare-environments/load-queries", appContext.loadCompareEnvQueries).Methods("GET")
 	r.HandleFunc("/compare-environments/delete-query/{id}", appContext.deleteCompareEnvQuery).Methods("DELETE")
@@ -208,6 +236,7 @@ func defineRotes(r *mux.Router, appContext *AppContext) {
 
 	r.HandleFunc("/requestDeployments", appContext.listRequestDeployments).Methods("GET")
 	r.HandleFunc("/requestDeployments/{id}", appContext.listDeployments).Methods("GET")
+	r.HandleFunc("/requestDeployments/{id}/rollback", appContext.rollbackRequestDeployment).Methods("POST")
 
 	r.HandleFunc("/health", appContext.healthRabbit).Methods("GET")
 
@@ -378,6 +407,21 @@ func (appContext *AppContext) hasEnvironmentRole(principal model.Principal, envI
 	return authorized, nil
 }
 
+//hasAnyEnvironmentRole tells whether the principal has a role in any of the environments it can access,
+//for the operations that are not bound to an environment
+func (appContext *AppContext) hasAnyEnvironmentRole(principal model.Principal, role string) (bool, error) {
+	environments, err := appContext.Repositories.EnvironmentDAO.GetAllEnvironments(principal.Email)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range environments {
+		if auth, err := appContext.hasEnvironmentRole(principal, e.ID, role); err == nil && auth {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (appContext *AppContext) rootHandler(w http.ResponseWriter, r *http.Request) {
 	data := map[string]string{
 		"service": "TENKAI",
@@ -170,21 +170,22 @@ func (appContext *AppContext) applyFilters(payload model.CompareEnvironments,
 		fieldName = v.TargetName
 	}
 
-	if len(payload.CustomFields) == 0 {
+	if matchesCustomFields(payload.CustomFields, fieldName) {
 		resp.List = append(resp.List, v)
-	} else {
-		filterMatch := false
-		for _, filter := range payload.CustomFields {
-			if fieldFilter(filter.FilterType)(fieldName, filter.FilterValue) {
-				filterMatch = true
-				break
-			}
-		}
+	}
+}
 
-		if filterMatch {
-			resp.List = append(resp.List, v)
+//matchesCustomFields tells whether a field name matches any of the custom filters, or there are none
+func matchesCustomFields(customFields []model.FilterField, fieldName string) bool {
+	if len(customFields) == 0 {
+		return true
+	}
+	for _, filter := range customFields {
+		if fn := fieldFilter(filter.FilterType); fn != nil && fn(fieldName, filter.FilterValue) {
+			return true
 		}
 	}
+	return false
 }
 
 func fieldFilter(filterType string) myFn {
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+//compareEnvironmentsMatrix compares the variables of several environments at once, returning for each
+//scope and variable name the value in every environment. With onlyDifferences only the variables that
+//differ or are missing somewhere are returned.
+func (appContext *AppContext) compareEnvironmentsMatrix(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	var payload model.CompareEnvironments
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	onlyDifferences, _ := strconv.ParseBool(r.URL.Query().Get("onlyDifferences"))
+
+	if len(payload.EnvironmentIDs) < 2 {
+		http.Error(w, "Choose at least two environments", http.StatusBadRequest)
+		return
+	}
+	if len(payload.OnlyFields) > 0 && len(payload.ExceptFields) > 0 {
+		http.Error(w, "Choose only one kind of filter fields: only or except", http.StatusBadRequest)
+		return
+	}
+	if len(payload.OnlyCharts) > 0 && len(payload.ExceptCharts) > 0 {
+		http.Error(w, "Choose only one kind of filter charts: only or except", http.StatusBadRequest)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		for _, id := range payload.EnvironmentIDs {
+			hasPermission, err := appContext.hasEnvironmentRole(principal, uint(id), "ACTION_DEPLOY")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !hasPermission {
+				http.Error(w, global.AccessDenied, http.StatusUnauthorized)
+				return
+			}
+		}
+	}
+
+	resp := model.CompareEnvsMatrixResponse{
+		Environments: make([]model.CompareEnvsMatrixEnvironment, 0, len(payload.EnvironmentIDs)),
+		List:         make([]model.CompareEnvsMatrixRow, 0),
+	}
+	envVars := make([]map[string]map[string]model.Variable, 0, len(payload.EnvironmentIDs))
+	for _, id := range payload.EnvironmentIDs {
+		environment, err := appContext.Repositories.EnvironmentDAO.GetByID(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Environments = append(resp.Environments, model.CompareEnvsMatrixEnvironment{ID: id, Name: environment.Name})
+		envVars = append(envVars, toMap(variables))
+	}
+
+	for _, key := range matrixKeys(payload, envVars) {
+		row := appContext.matrixRow(payload.EnvironmentIDs, envVars, key[0], key[1])
+		if onlyDifferences && !row.Different && !row.Missing {
+			continue
+		}
+		resp.List = append(resp.List, row)
+	}
+
+	data, _ := json.Marshal(resp)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//matrixKeys returns the scope and name of every variable of the environments that passes the filters,
+//sorted by scope and name
+func matrixKeys(filter model.CompareEnvironments, envVars []map[string]map[string]model.Variable) [][2]string {
+	found := make(map[[2]string]bool)
+	keys := make([][2]string, 0)
+	for _, scopes := range envVars {
+		for scope, variables := range scopes {
+			if shouldIgnoreChart(filter, scope) {
+				continue
+			}
+			for name := range variables {
+				key := [2]string{scope, name}
+				if found[key] || shouldIgnoreVar(filter, name) || !matchesCustomFields(filter.CustomFields, name) {
+					continue
+				}
+				found[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+//matrixRow collects the values of a variable in each environment. Secrets are compared decrypted
+//but returned masked.
+func (appContext *AppContext) matrixRow(environmentIDs []int, envVars []map[string]map[string]model.Variable,
+	scope string, name string) model.CompareEnvsMatrixRow {
+
+	row := model.CompareEnvsMatrixRow{Scope: scope, Name: name,
+		Values: make([]model.CompareEnvsMatrixValue, 0, len(environmentIDs))}
+
+	var first *string
+	for i, id := range environmentIDs {
+		variable, ok := envVars[i][scope][name]
+		if !ok {
+			row.Missing = true
+			row.Values = append(row.Values, model.CompareEnvsMatrixValue{EnvironmentID: id, Missing: true})
+			continue
+		}
+
+		value := appContext.decryptVariable(variable).Value
+		if first == nil {
+			first = &value
+		} else if *first != value {
+			row.Different = true
+		}
+		row.Values = append(row.Values, model.CompareEnvsMatrixValue{EnvironmentID: id,
+			VarID: fmt.Sprint(variable.ID), Value: maskedValue(variable), Secret: variable.Secret})
+	}
+	return row
+}
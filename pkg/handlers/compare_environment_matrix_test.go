@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getMatrixAppContext() *AppContext {
+	appContext := AppContext{}
+
+	mockEnvDao := &mockRepo.EnvironmentDAOInterface{}
+	for id, name := range map[int]string{888: "dev", 999: "qa", 777: "prod"} {
+		env := model.Environment{Name: name, Namespace: name}
+		env.ID = uint(id)
+		mockEnvDao.On("GetByID", id).Return(&env, nil)
+	}
+	appContext.Repositories.EnvironmentDAO = mockEnvDao
+
+	var prodVars []model.Variable
+	prodVars = append(prodVars, mockVar(771, 777, "repo/chart1", "f1", "equal"))
+	prodVars = append(prodVars, mockVar(772, 777, "repo/chart2", "f1", "equal"))
+	prodVars = append(prodVars, mockVar(773, 777, "global", "user", "equal"))
+	prodVars = append(prodVars, mockVar(774, 777, "global", "foo", "not-equal-1"))
+
+	mockVarDao := &mockRepo.VariableDAOInterface{}
+	mockVarDao.On("GetAllVariablesByEnvironment", 888).Return(mockSourceEnvs(), nil)
+	mockVarDao.On("GetAllVariablesByEnvironment", 999).Return(mockTargetVars(), nil)
+	mockVarDao.On("GetAllVariablesByEnvironment", 777).Return(prodVars, nil)
+	appContext.Repositories.VariableDAO = mockVarDao
+
+	return &appContext
+}
+
+func doCompareEnvironmentsMatrix(appContext *AppContext, url string, p model.CompareEnvironments,
+	principal model.Principal) (*httptest.ResponseRecorder, model.CompareEnvsMatrixResponse) {
+
+	req, _ := http.NewRequest("POST", url, payload(p))
+	pSe, _ := json.Marshal(principal)
+	req.Header.Set("principal", string(pSe))
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(appContext.compareEnvironmentsMatrix).ServeHTTP(rr, req)
+
+	var resp model.CompareEnvsMatrixResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func TestCompareEnvironmentsMatrix(t *testing.T) {
+	appContext := getMatrixAppContext()
+
+	rr, resp := doCompareEnvironmentsMatrix(appContext, "/compare-environments/matrix",
+		model.CompareEnvironments{EnvironmentIDs: []int{888, 999, 777}}, replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, []model.CompareEnvsMatrixEnvironment{{ID: 888, Name: "dev"}, {ID: 999, Name: "qa"},
+		{ID: 777, Name: "prod"}}, resp.Environments)
+	assert.Equal(t, 11, len(resp.List))
+
+	foo := resp.List[0]
+	assert.Equal(t, "global", foo.Scope)
+	assert.Equal(t, "foo", foo.Name)
+	assert.True(t, foo.Different)
+	assert.False(t, foo.Missing)
+	assert.Equal(t, []model.CompareEnvsMatrixValue{
+		{EnvironmentID: 888, VarID: "888", Value: "not-equal-1"},
+		{EnvironmentID: 999, VarID: "998", Value: "not-equal-2"},
+		{EnvironmentID: 777, VarID: "774", Value: "not-equal-1"},
+	}, foo.Values)
+
+	pass := resp.List[1]
+	assert.Equal(t, "pass", pass.Name)
+	assert.False(t, pass.Different)
+	assert.True(t, pass.Missing)
+	assert.True(t, pass.Values[1].Missing)
+
+	user := resp.List[3]
+	assert.Equal(t, "user", user.Name)
+	assert.False(t, user.Different)
+	assert.False(t, user.Missing)
+
+	rr, resp = doCompareEnvironmentsMatrix(appContext, "/compare-environments/matrix?onlyDifferences=true",
+		model.CompareEnvironments{EnvironmentIDs: []int{888, 999, 777}}, replaceAdmin)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, 8, len(resp.List))
+}
+
+func TestCompareEnvironmentsMatrix_Filters(t *testing.T) {
+	appContext := getMatrixAppContext()
+
+	p := model.CompareEnvironments{EnvironmentIDs: []int{888, 999, 777}, OnlyCharts: []string{"repo/chart1"},
+		ExceptFields: []string{"f2"}, CustomFields: []model.FilterField{{FilterType: "StartsWith", FilterValue: "f"}}}
+	rr, resp := doCompareEnvironmentsMatrix(appContext, "/compare-environments/matrix", p, replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, 3, len(resp.List))
+	assert.Equal(t, "f1", resp.List[0].Name)
+	assert.Equal(t, "f3", resp.List[1].Name)
+	assert.Equal(t, "f4", resp.List[2].Name)
+	assert.True(t, resp.List[2].Different)
+	assert.True(t, resp.List[2].Missing)
+}
+
+func TestCompareEnvironmentsMatrix_Invalid(t *testing.T) {
+	appContext := getMatrixAppContext()
+
+	for _, p := range []model.CompareEnvironments{
+		{EnvironmentIDs: []int{888}},
+		{EnvironmentIDs: []int{888, 999}, OnlyFields: []string{"f1"}, ExceptFields: []string{"f2"}},
+		{EnvironmentIDs: []int{888, 999}, OnlyCharts: []string{"repo/chart1"}, ExceptCharts: []string{"global"}},
+	} {
+		rr, _ := doCompareEnvironmentsMatrix(appContext, "/compare-environments/matrix", p, replaceAdmin)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	}
+}
+
+func TestCompareEnvironmentsMatrix_AccessDenied(t *testing.T) {
+	appContext := getMatrixAppContext()
+
+	user := mockUser()
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", user.Email).Return(user, nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	secOper := mockSecurityOperations()
+	noPolicies := model.SecurityOperation{Name: "NONE"}
+	mockUserEnvRoleDAO := &mockRepo.UserEnvironmentRoleDAOInterface{}
+	mockUserEnvRoleDAO.On("GetRoleByUserAndEnvironment", user, uint(888)).Return(&secOper, nil)
+	mockUserEnvRoleDAO.On("GetRoleByUserAndEnvironment", user, mock.Anything).Return(&noPolicies, nil)
+	appContext.Repositories.UserEnvironmentRoleDAO = mockUserEnvRoleDAO
+
+	rr, _ := doCompareEnvironmentsMatrix(appContext, "/compare-environments/matrix",
+		model.CompareEnvironments{EnvironmentIDs: []int{888, 999}}, model.Principal{Email: user.Email})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+
+	rr, _ = doCompareEnvironmentsMatrix(appContext, "/compare-environments/matrix",
+		model.CompareEnvironments{EnvironmentIDs: []int{888}}, model.Principal{Email: user.Email})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+//checkDeployRules validates the variables a deploy installs in an environment. Error severity
+//violations block the deploy, unless an admin overrides them with a reason and the environment
+//policy allows it.
+func (appContext *AppContext) checkDeployRules(principal model.Principal, environment *model.Environment,
+	variables []model.Variable, overrideReason string) (*model.DeployRulesCheck, error) {
+
+	rules, err := appContext.Repositories.VariableRuleDAO.ListVariableRules()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := appContext.validate(int(environment.ID), variables, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &model.DeployRulesCheck{
+		Environment: environment.Name,
+		Violations:  make([]model.InvalidVariable, 0),
+		Warnings:    make([]model.InvalidVariable, 0),
+	}
+	for _, iv := range result.InvalidVariables {
+		if iv.Severity == model.ValueRuleSeverityError {
+			check.Violations = append(check.Violations, iv)
+		} else {
+			check.Warnings = append(check.Warnings, iv)
+		}
+	}
+
+	if len(check.Violations) > 0 && overrideReason != "" && environment.RulesPolicy != model.RulesPolicyStrict &&
+		util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		check.OverrideReason = overrideReason
+	}
+	return check, nil
+}
+
+//checkReleaseRules checks the rules of the variables of the releases deployed to an environment
+func (appContext *AppContext) checkReleaseRules(principal model.Principal, environment *model.Environment,
+	deployables []model.InstallPayload, overrideReason string) (*model.DeployRulesCheck, error) {
+
+	variables := make([]model.Variable, 0)
+	for _, deployable := range deployables {
+		releaseVariables, err := appContext.getEffectiveVariables(environment, releaseSearchTerm(deployable))
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, releaseVariables...)
+	}
+	return appContext.checkDeployRules(principal, environment, variables, overrideReason)
+}
+
+//isBlocked tells if a deploy must not start because of its rules checks
+func isBlocked(checks []model.DeployRulesCheck) bool {
+	for _, check := range checks {
+		if len(check.Violations) > 0 && check.OverrideReason == "" {
+			return true
+		}
+	}
+	return false
+}
+
+//auditRulesOverride records the reason of the admins deploying over rule violations
+func (appContext *AppContext) auditRulesOverride(r *http.Request, principal model.Principal,
+	checks []model.DeployRulesCheck) {
+
+	for _, check := range checks {
+		if check.OverrideReason == "" {
+			continue
+		}
+		auditValues := make(map[string]string)
+		auditValues["environment"] = check.Environment
+		auditValues["reason"] = check.OverrideReason
+		auditValues["violations"] = strconv.Itoa(len(check.Violations))
+		appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "overrideVariableRules", auditValues)
+	}
+}
+
+//writeRulesResult writes the rules checks of a deploy, as an error when the deploy is blocked
+func writeRulesResult(w http.ResponseWriter, checks []model.DeployRulesCheck) {
+	result := model.DeployRulesResult{Blocked: isBlocked(checks), Checks: checks}
+	data, _ := json.Marshal(result)
+	if result.Blocked {
+		w.WriteHeader(http.StatusBadRequest)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.Write(data)
+}
+
+func validateRulesPolicy(env model.Environment) error {
+	if env.RulesPolicy != "" && !util.Contains(model.RulesPolicies, env.RulesPolicy) {
+		return errors.New("Invalid rules policy " + env.RulesPolicy)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getDeployRule(severity string) model.VariableRule {
+	rule := getVarRule("username", "NotOneOf", "user")
+	rule.ValueRules[0].Severity = severity
+	return rule
+}
+
+func getDeployRulesAppContext(rules ...model.VariableRule) (*AppContext, *mockRepo.UserDAOInterface) {
+	appContext := AppContext{}
+	mockGetByID(&appContext)
+	mockGetAllVariablesByEnvironmentAndScope(&appContext)
+	mockListVariableRules(&appContext, rules...)
+	mockConventionInterface(&appContext)
+
+	mockHelmSvc := mockUpgrade(&appContext)
+	mockHelmSvc.On("SearchCharts", mock.Anything, false).Return(getCharts())
+	mockHelmSvc.On("GetTemplate", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]byte(`{"app":{"myvar":"myvalue"}}`), nil)
+
+	mockConfigDAO := &mockRepo.ConfigDAOInterface{}
+	mockConfigDAO.On("GetConfigByName", "commonValuesConfigMapChart").Return(model.ConfigMap{}, nil)
+	appContext.Repositories.ConfigDAO = mockConfigDAO
+
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", mock.Anything).Return(mockUser(), nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	mockUserEnvRoleDAO := &mockRepo.UserEnvironmentRoleDAOInterface{}
+	secOper := mockSecurityOperations()
+	mockUserEnvRoleDAO.On("GetRoleByUserAndEnvironment", mock.Anything, mock.Anything).Return(&secOper, nil)
+	appContext.Repositories.UserEnvironmentRoleDAO = mockUserEnvRoleDAO
+
+	mockRequestDeploymentDAO := &mockRepo.RequestDeploymentDAOInterface{}
+	mockRequestDeploymentDAO.On("CreateRequestDeployment", mock.Anything).Return(1, nil)
+	appContext.Repositories.RequestDeploymentDAO = mockRequestDeploymentDAO
+
+	mockDeploymentDAO := &mockRepo.DeploymentDAOInterface{}
+	mockDeploymentDAO.On("CreateDeployment", mock.Anything).Return(1, nil)
+	appContext.Repositories.DeploymentDAO = mockDeploymentDAO
+	appContext.RabbitImpl = getMockRabbitMQ()
+
+	return &appContext, mockUserDAO
+}
+
+func doInstall(appContext *AppContext, url string) (*httptest.ResponseRecorder, model.DeployRulesResult) {
+	req, _ := http.NewRequest("POST", url, getInstallPayload())
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(appContext.install).ServeHTTP(rr, req)
+
+	var result model.DeployRulesResult
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	return rr, result
+}
+
+func TestInstall_BlockedByRules(t *testing.T) {
+	appContext, mockUserDAO := getDeployRulesAppContext(getDeployRule(model.ValueRuleSeverityError))
+
+	rr, result := doInstall(appContext, "/install")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	assert.True(t, result.Blocked)
+	assert.Equal(t, 1, len(result.Checks[0].Violations))
+	assert.Equal(t, "username", result.Checks[0].Violations[0].Name)
+	mockUserDAO.AssertNotCalled(t, "FindByEmail", mock.Anything)
+}
+
+func TestInstall_RulesWarnings(t *testing.T) {
+	appContext, _ := getDeployRulesAppContext(getDeployRule(model.ValueRuleSeverityWarning))
+
+	rr, result := doInstall(appContext, "/install")
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+	assert.False(t, result.Blocked)
+	assert.Empty(t, result.Checks[0].Violations)
+	assert.Equal(t, 1, len(result.Checks[0].Warnings))
+}
+
+func TestInstall_RulesOverride(t *testing.T) {
+	appContext, _ := getDeployRulesAppContext(getDeployRule(""))
+	auditValues := map[string]string{"environment": "bar", "reason": "hotfix", "violations": "1"}
+	mockAudit := mockDoAudit(appContext, "overrideVariableRules", auditValues)
+
+	rr, result := doInstall(appContext, "/install?overrideReason=hotfix")
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+	assert.False(t, result.Blocked)
+	assert.Equal(t, "hotfix", result.Checks[0].OverrideReason)
+	mockAudit.AssertNumberOfCalls(t, "DoAudit", 1)
+}
+
+func TestCheckDeployRules_Override(t *testing.T) {
+	appContext := AppContext{}
+	mockListVariableRules(&appContext, getDeployRule(model.ValueRuleSeverityError))
+	variables := []model.Variable{mockGlobalVariable()}
+	admin := model.Principal{Email: "beta@alfa.com", Roles: []string{"tenkai-admin"}}
+	user := model.Principal{Email: "beta@alfa.com", Roles: []string{"tenkai-user"}}
+
+	tests := []struct {
+		principal model.Principal
+		policy    string
+		reason    string
+		blocked   bool
+	}{
+		{admin, "", "", true},
+		{admin, "", "hotfix", false},
+		{admin, model.RulesPolicyAdminOverride, "hotfix", false},
+		{admin, model.RulesPolicyStrict, "hotfix", true},
+		{user, "", "hotfix", true},
+	}
+
+	for _, tt := range tests {
+		environment := mockGetEnv()
+		environment.RulesPolicy = tt.policy
+		check, err := appContext.checkDeployRules(tt.principal, &environment, variables, tt.reason)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.blocked, isBlocked([]model.DeployRulesCheck{*check}), tt.policy+" "+tt.reason)
+	}
+}
+
+func TestPromote_BlockedByRules(t *testing.T) {
+	appContext := getPromoteAppContext()
+	mockListVariableRules(appContext, getDeployRule(model.ValueRuleSeverityError))
+
+	rr := doPromote(appContext, "/promote?mode=full&srcEnvID=91&targetEnvID=92")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	var result model.DeployRulesResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.True(t, result.Blocked)
+	appContext.Repositories.VariableDAO.(*mockRepo.VariableDAOInterface).
+		AssertNotCalled(t, "CreateVariable", mock.Anything)
+}
+
+func TestEditEnvironment_InvalidRulesPolicy(t *testing.T) {
+	appContext := AppContext{}
+	environment := mockGetEnv()
+	environment.RulesPolicy = "never"
+
+	req, _ := http.NewRequest("POST", "/environments/edit", payload(model.DataElement{Data: environment}))
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(appContext.editEnvironment).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+}
@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+type liveRelease struct {
+	Name         string
+	Chart        string
+	ChartVersion string
+	ImageTag     string
+}
+
+func (appContext *AppContext) environmentDrift(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	has, err := appContext.hasAccess(principal.Email, id)
+	if err != nil || !has {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	productID, _ := strconv.Atoi(r.URL.Query().Get("productId"))
+
+	environment, err := appContext.Repositories.EnvironmentDAO.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result, err := appContext.driftReport(environment, productID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//driftReport compares what runs in the namespace with the variables, product version
+//and deployments stored for the environment
+func (appContext *AppContext) driftReport(environment *model.Environment, productID int) (*model.DriftReport, error) {
+
+	report := &model.DriftReport{
+		EnvironmentID:     environment.ID,
+		Environment:       environment.Name,
+		ProductVersion:    environment.ProductVersion,
+		UnknownReleases:   make([]model.DriftRelease, 0),
+		MissingReleases:   make([]model.DriftRelease, 0),
+		VersionMismatches: make([]model.DriftVersionMismatch, 0),
+		ValueDifferences:  make([]model.DriftValueDifference, 0),
+	}
+
+	kubeConfig := appContext.ConventionInterface.GetKubeConfigFileName(environment.Group, environment.Name)
+
+	releases, err := appContext.getLiveReleases(kubeConfig, environment.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	variables, err := appContext.getEffectiveVariables(environment, "")
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := appContext.Repositories.DeploymentDAO.ListLastSuccessfulDeployments(int(environment.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := appContext.getEnvironmentProductServices(environment, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	globalVariables := make([]model.Variable, 0)
+	variablesByChart := make(map[string][]model.Variable)
+	variablesByGcm := make(map[string][]model.Variable)
+	for _, v := range variables {
+		if v.Scope == "global" {
+			globalVariables = append(globalVariables, appContext.decryptVariable(v))
+			continue
+		}
+		//variables of gcm releases are scoped by release, not by chart
+		if strings.Contains(v.Scope, "gcm") {
+			variablesByGcm[v.Scope] = append(variablesByGcm[v.Scope], v)
+			continue
+		}
+		chart := chartNameOf(v.Scope)
+		variablesByChart[chart] = append(variablesByChart[chart], v)
+	}
+
+	deploymentsByChart := make(map[string]model.Deployment)
+	for _, d := range deployments {
+		//deployments come newest first, keep the latest of each chart
+		if _, ok := deploymentsByChart[chartNameOf(d.Chart)]; !ok {
+			deploymentsByChart[chartNameOf(d.Chart)] = d
+		}
+	}
+
+	servicesByChart := make(map[string]model.ProductVersionService)
+	for _, s := range services {
+		servicesByChart[chartNameOf(splitSrvNameIfNeeded(s.ServiceName))] = s
+	}
+
+	deployed := make(map[string]bool)
+	for _, release := range releases {
+		deployed[release.Chart] = true
+
+		chartVariables, hasVariables := variablesByChart[release.Chart]
+		if scope := strings.TrimSuffix(release.Name, "-"+environment.Namespace); strings.Contains(scope, "gcm") {
+			chartVariables, hasVariables = variablesByGcm[scope]
+		}
+		deployment, hasDeployment := deploymentsByChart[release.Chart]
+		service, hasService := servicesByChart[release.Chart]
+
+		if !hasVariables && !hasDeployment && !hasService {
+			report.UnknownReleases = append(report.UnknownReleases, model.DriftRelease{
+				Name: release.Name, Chart: release.Chart, ChartVersion: release.ChartVersion,
+			})
+			continue
+		}
+
+		if hasService {
+			report.VersionMismatches = appendVersionMismatch(report.VersionMismatches, release, "chartVersion",
+				"productVersion", splitChartVersion(service.ServiceName), release.ChartVersion)
+			report.VersionMismatches = appendVersionMismatch(report.VersionMismatches, release, "imageTag",
+				"productVersion", service.DockerImageTag, release.ImageTag)
+		}
+
+		if hasDeployment {
+			report.VersionMismatches = appendVersionMismatch(report.VersionMismatches, release, "chartVersion",
+				"deployment", deployment.ChartVersion, release.ChartVersion)
+			report.VersionMismatches = appendVersionMismatch(report.VersionMismatches, release, "imageTag",
+				"deployment", deployment.DockerVersion, release.ImageTag)
+		}
+
+		if hasVariables {
+			differences, err := appContext.diffReleaseValues(kubeConfig, release, chartVariables,
+				globalVariables, environment)
+			if err != nil {
+				return nil, err
+			}
+			report.ValueDifferences = append(report.ValueDifferences, differences...)
+		}
+	}
+
+	for _, s := range services {
+		serviceName := splitSrvNameIfNeeded(s.ServiceName)
+		if !deployed[chartNameOf(serviceName)] {
+			report.MissingReleases = append(report.MissingReleases, model.DriftRelease{
+				Name:         chartNameOf(serviceName) + "-" + environment.Namespace,
+				Chart:        serviceName,
+				ChartVersion: splitChartVersion(s.ServiceName),
+			})
+		}
+	}
+
+	return report, nil
+}
+
+//getLiveReleases lists the releases of a namespace with the image tag their pods are running
+func (appContext *AppContext) getLiveReleases(kubeConfig string, namespace string) ([]liveRelease, error) {
+
+	result := make([]liveRelease, 0)
+
+	list, err := appContext.HelmServiceAPI.ListHelmDeployments(kubeConfig, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return result, nil
+	}
+
+	pods, err := appContext.HelmServiceAPI.GetPods(kubeConfig, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list.Releases))
+	for _, e := range list.Releases {
+		names = append(names, e.Name)
+	}
+	tags := getReleaseImageTags(pods, names)
+
+	for _, e := range list.Releases {
+		release := liveRelease{Name: e.Name, Chart: e.Chart, ImageTag: tags[e.Name]}
+		if lastHifen := strings.LastIndex(e.Chart, "-"); lastHifen > -1 {
+			release.Chart = e.Chart[:lastHifen]
+			release.ChartVersion = e.Chart[lastHifen+1:]
+		}
+		result = append(result, release)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+//getEnvironmentProductServices returns the services of the product version the environment is on.
+//Environments deployed before the version id was kept are looked up by the version name.
+func (appContext *AppContext) getEnvironmentProductServices(environment *model.Environment,
+	productID int) ([]model.ProductVersionService, error) {
+
+	if environment.ProductVersionID > 0 {
+		version, err := appContext.Repositories.ProductDAO.ListProductVersionsByID(int(environment.ProductVersionID))
+		if err != nil {
+			return nil, err
+		}
+		if productID > 0 && version.ProductID != productID {
+			return nil, nil
+		}
+		return appContext.Repositories.ProductDAO.ListProductsVersionServices(int(version.ID))
+	}
+
+	if environment.ProductVersion == "" {
+		return nil, nil
+	}
+
+	products, err := appContext.Repositories.ProductDAO.ListProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, product := range products {
+		if productID > 0 && int(product.ID) != productID {
+			continue
+		}
+		versions, err := appContext.Repositories.ProductDAO.ListProductsVersions(int(product.ID))
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			if version.Version == environment.ProductVersion {
+				return appContext.Repositories.ProductDAO.ListProductsVersionServices(int(version.ID))
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+//diffReleaseValues compares the stored variables of a chart with the values supplied to its release
+func (appContext *AppContext) diffReleaseValues(kubeConfig string, release liveRelease, variables []model.Variable,
+	globalVariables []model.Variable, environment *model.Environment) ([]model.DriftValueDifference, error) {
+
+	result := make([]model.DriftValueDifference, 0)
+
+	values, err := appContext.HelmServiceAPI.Get(kubeConfig, release.Name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	live := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(values), &live); err != nil {
+		return nil, err
+	}
+	flatValues := make(map[string]string)
+	flattenValues("", live, flatValues)
+
+	interpolator := appContext.newInterpolator(environment, globalVariables)
+
+	for _, v := range variables {
+		if v.Name == "" || v.Value == "" {
+			continue
+		}
+		key := normalizeVariableName(v.Name)
+		expanded := interpolate(interpolator, interpolationOrigin(v), appContext.decryptVariable(v).Value)
+		expected := expanded.Value
+		if expected == emptyValue {
+			expected = ""
+		}
+		actual, ok := flatValues[key]
+		if v.Type == model.VariableTypeList || v.Type == model.VariableTypeMap {
+			//list and map values are compared as a whole, not by their flattened keys
+			actual, ok = liveValueJSON(live, key)
+			if typed, err := typedValue(v.Type, expected); err == nil {
+				data, _ := json.Marshal(typed)
+				expected = string(data)
+			}
+		}
+		if ok && actual == expected {
+			continue
+		}
+
+		difference := model.DriftValueDifference{
+			Release:  release.Name,
+			Scope:    v.Scope,
+			Name:     v.Name,
+			Secret:   v.Secret || expanded.Secret,
+			Expected: expected,
+			Actual:   actual,
+		}
+		if difference.Secret {
+			difference.Expected = secretMask
+			difference.Actual = secretMask
+		}
+		result = append(result, difference)
+	}
+
+	return result, nil
+}
+
+func (appContext *AppContext) decryptVariable(variable model.Variable) model.Variable {
+	if !variable.Secret {
+		return variable
+	}
+	byteValues, _ := hex.DecodeString(variable.Value)
+	value, err := util.Decrypt(byteValues, appContext.Configuration.App.Passkey)
+	if err == nil {
+		variable.Value = string(value)
+	}
+	return variable
+}
+
+//flattenValues converts a values document into the keys used by helm --set
+func flattenValues(prefix string, value interface{}, result map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flattenValues(key, item, result)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenValues(fmt.Sprintf("%s[%d]", prefix, i), item, result)
+		}
+	case nil:
+		result[prefix] = ""
+	case float64:
+		result[prefix] = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		result[prefix] = fmt.Sprint(v)
+	}
+}
+
+//getReleaseImageTags returns the image tag of the first pod of each release,
+//a pod belongs to the release with the longest name prefixing the pod name
+func getReleaseImageTags(pods []model.Pod, releaseNames []string) map[string]string {
+	tags := make(map[string]string)
+	for _, pod := range pods {
+		owner := ""
+		for _, name := range releaseNames {
+			if strings.HasPrefix(pod.Name, name+"-") && len(name) > len(owner) {
+				owner = name
+			}
+		}
+		if owner == "" || tags[owner] != "" {
+			continue
+		}
+		image := pod.Image[strings.LastIndex(pod.Image, "/")+1:]
+		tags[owner] = "latest"
+		if idx := strings.LastIndex(image, ":"); idx > -1 {
+			tags[owner] = image[idx+1:]
+		}
+	}
+	return tags
+}
+
+//chartNameOf removes the repository from a chart or variable scope
+func chartNameOf(chart string) string {
+	return chart[strings.Index(chart, "/")+1:]
+}
+
+func appendVersionMismatch(mismatches []model.DriftVersionMismatch, release liveRelease, field string,
+	source string, expected string, actual string) []model.DriftVersionMismatch {
+
+	if expected == "" || actual == "" || expected == actual {
+		return mismatches
+	}
+	return append(mismatches, model.DriftVersionMismatch{
+		Release:  release.Name,
+		Chart:    release.Chart,
+		Field:    field,
+		Source:   source,
+		Expected: expected,
+		Actual:   actual,
+	})
+}
+
+//liveValueJSON returns as JSON the value at a dotted key of the values of a release
+func liveValueJSON(values map[string]interface{}, key string) (string, bool) {
+	var current interface{} = values
+	for _, name := range strings.Split(key, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if current, ok = object[name]; !ok {
+			return "", false
+		}
+	}
+	data, err := json.Marshal(current)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
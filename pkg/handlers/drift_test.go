@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	helmapi "github.com/softplan/tenkai-api/pkg/service/_helm"
+	mockSvc "github.com/softplan/tenkai-api/pkg/service/_helm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getDriftAppContext() *AppContext {
+	appContext := AppContext{}
+
+	env := mockGetEnv()
+	env.ProductVersion = "1.0.0"
+	mockEnvDao := &mockRepo.EnvironmentDAOInterface{}
+	mockEnvDao.On("GetByID", 999).Return(&env, nil)
+	mockEnvDao.On("GetAllEnvironments", "beta@alfa.com").Return([]model.Environment{env}, nil)
+	appContext.Repositories.EnvironmentDAO = mockEnvDao
+
+	mockConventionInterface(&appContext)
+
+	releases := helmapi.HelmListResult{Releases: []helmapi.ListRelease{
+		{Name: "foo-dev", Chart: "foo-0.2.0"},
+		{Name: "foo-dev-worker", Chart: "foo-worker-0.1.0"},
+		{Name: "manual-dev", Chart: "manual-1.0.0"},
+	}}
+	pods := []model.Pod{
+		{Name: "foo-dev-worker-5d4f-x1", Image: "registry:5000/foo-worker:0.1.0"},
+		{Name: "foo-dev-7c9b-z2", Image: "registry:5000/foo:1.2.0"},
+		{Name: "manual-dev-1a2b-y3", Image: "manual"},
+	}
+	mockHelmSvc := &mockSvc.HelmServiceInterface{}
+	mockHelmSvc.On("ListHelmDeployments", "./config/foo_bar", "dev").Return(&releases, nil)
+	mockHelmSvc.On("GetPods", "./config/foo_bar", "dev").Return(pods, nil)
+	mockHelmSvc.On("Get", "./config/foo_bar", "foo-dev", 0).
+		Return("app:\n  url: http://foo.dev\n  user: admin\nimage:\n  tag: 1.2.0\n", nil)
+	appContext.HelmServiceAPI = mockHelmSvc
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 999).Return([]model.Variable{
+		{Scope: "global", Name: "host", Value: "foo.qa"},
+		{Scope: "repo/foo", Name: "url", Value: "http://${host}"},
+		{Scope: "repo/foo", Name: "user", Value: "admin"},
+		{Scope: "repo/foo", Name: "image.tag", Value: "1.2.0"},
+		{Scope: "repo/foo", Name: "timeout", Value: "30"},
+	}, nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	deployment := model.Deployment{Chart: "repo/foo", ChartVersion: "0.2.0", DockerVersion: "1.1.0", Success: true}
+	mockDeploymentDAO := &mockRepo.DeploymentDAOInterface{}
+	older := model.Deployment{Chart: "repo/foo", ChartVersion: "0.1.0", DockerVersion: "1.0.0", Success: true}
+	mockDeploymentDAO.On("ListLastSuccessfulDeployments", 999).Return([]model.Deployment{deployment, older}, nil)
+	appContext.Repositories.DeploymentDAO = mockDeploymentDAO
+
+	product := model.Product{Name: "product"}
+	product.ID = 1
+	version := model.ProductVersion{ProductID: 1, Version: "1.0.0"}
+	version.ID = 10
+	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProducts").Return([]model.Product{product}, nil)
+	mockProductDAO.On("ListProductsVersions", 1).Return([]model.ProductVersion{version}, nil)
+	mockProductDAO.On("ListProductsVersionServices", 10).Return([]model.ProductVersionService{
+		{ServiceName: "repo/foo - 0.1.0", DockerImageTag: "1.2.0"},
+		{ServiceName: "repo/bar - 0.1.0", DockerImageTag: "2.0.0"},
+	}, nil)
+	appContext.Repositories.ProductDAO = mockProductDAO
+
+	return &appContext
+}
+
+func TestEnvironmentDrift(t *testing.T) {
+	appContext := getDriftAppContext()
+
+	req, err := http.NewRequest("GET", "/environments/999/drift", nil)
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/drift", appContext.environmentDrift).Methods("GET")
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+
+	var report model.DriftReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+
+	assert.Equal(t, 2, len(report.UnknownReleases))
+	assert.Equal(t, "foo-dev-worker", report.UnknownReleases[0].Name)
+	assert.Equal(t, "manual-dev", report.UnknownReleases[1].Name)
+
+	assert.Equal(t, 1, len(report.MissingReleases))
+	assert.Equal(t, "bar-dev", report.MissingReleases[0].Name)
+
+	assert.Equal(t, 2, len(report.VersionMismatches))
+	assert.Equal(t, model.DriftVersionMismatch{Release: "foo-dev", Chart: "foo", Field: "chartVersion",
+		Source: "productVersion", Expected: "0.1.0", Actual: "0.2.0"}, report.VersionMismatches[0])
+	assert.Equal(t, model.DriftVersionMismatch{Release: "foo-dev", Chart: "foo", Field: "imageTag",
+		Source: "deployment", Expected: "1.1.0", Actual: "1.2.0"}, report.VersionMismatches[1])
+
+	assert.Equal(t, 2, len(report.ValueDifferences))
+	assert.Equal(t, "url", report.ValueDifferences[0].Name)
+	assert.Equal(t, "http://foo.qa", report.ValueDifferences[0].Expected)
+	assert.Equal(t, "http://foo.dev", report.ValueDifferences[0].Actual)
+	assert.Equal(t, "timeout", report.ValueDifferences[1].Name)
+	assert.Equal(t, "", report.ValueDifferences[1].Actual)
+}
+
+func getDriftReport(t *testing.T, appContext *AppContext) model.DriftReport {
+	req, err := http.NewRequest("GET", "/environments/999/drift", nil)
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/drift", appContext.environmentDrift).Methods("GET")
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+
+	var report model.DriftReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	return report
+}
+
+func TestEnvironmentDrift_ProductVersionID(t *testing.T) {
+	appContext := getDriftAppContext()
+	environment, _ := appContext.Repositories.EnvironmentDAO.GetByID(999)
+	environment.ProductVersionID = 20
+
+	version := model.ProductVersion{ProductID: 2, Version: "1.0.0"}
+	version.ID = 20
+	mockProductDAO := appContext.Repositories.ProductDAO.(*mockRepo.ProductDAOInterface)
+	mockProductDAO.On("ListProductVersionsByID", 20).Return(&version, nil)
+	mockProductDAO.On("ListProductsVersionServices", 20).Return([]model.ProductVersionService{
+		{ServiceName: "repo/foo - 0.2.0", DockerImageTag: "1.2.0"},
+	}, nil)
+
+	report := getDriftReport(t, appContext)
+
+	assert.Empty(t, report.MissingReleases)
+	assert.Equal(t, 1, len(report.VersionMismatches))
+	assert.Equal(t, "deployment", report.VersionMismatches[0].Source)
+	mockProductDAO.AssertNotCalled(t, "ListProducts")
+}
+
+func TestEnvironmentDrift_GcmAndTypedValues(t *testing.T) {
+	appContext := getDriftAppContext()
+
+	mockHelmSvc := appContext.HelmServiceAPI.(*mockSvc.HelmServiceInterface)
+	mockHelmSvc.ExpectedCalls[0].ReturnArguments = mock.Arguments{&helmapi.HelmListResult{
+		Releases: []helmapi.ListRelease{
+			{Name: "foo-dev", Chart: "foo-0.2.0"},
+			{Name: "foo-gcm-dev", Chart: "gcm-0.1.0"},
+		}}, nil}
+	mockHelmSvc.ExpectedCalls[2].ReturnArguments = mock.Arguments{
+		"app:\n  url: http://foo.qa\n  user: admin\n  timeout: 30\n  hosts:\n  - a\n  - b\n" +
+			"  limits:\n    cpu: 1\n", nil}
+	mockHelmSvc.On("Get", "./config/foo_bar", "foo-gcm-dev", 0).Return("app:\n  key: live\n", nil)
+
+	mockVariableDAO := appContext.Repositories.VariableDAO.(*mockRepo.VariableDAOInterface)
+	mockVariableDAO.ExpectedCalls[0].ReturnArguments = mock.Arguments{[]model.Variable{
+		{Scope: "global", Name: "host", Value: "foo.qa"},
+		{Scope: "repo/foo", Name: "url", Value: "http://${host}"},
+		{Scope: "repo/foo", Name: "hosts", Value: "[a, b]", Type: model.VariableTypeList},
+		{Scope: "repo/foo", Name: "limits", Value: "cpu: 2", Type: model.VariableTypeMap},
+		{Scope: "foo-gcm", Name: "key", Value: "stored"},
+	}, nil}
+
+	report := getDriftReport(t, appContext)
+
+	assert.Equal(t, 2, len(report.ValueDifferences))
+	assert.Equal(t, model.DriftValueDifference{Release: "foo-dev", Scope: "repo/foo", Name: "limits",
+		Expected: `{"cpu":2}`, Actual: `{"cpu":1}`}, report.ValueDifferences[0])
+	assert.Equal(t, model.DriftValueDifference{Release: "foo-gcm-dev", Scope: "foo-gcm", Name: "key",
+		Expected: "stored", Actual: "live"}, report.ValueDifferences[1])
+}
+
+func TestEnvironmentDriftAccessDenied(t *testing.T) {
+	appContext := AppContext{}
+	mockEnvDao := &mockRepo.EnvironmentDAOInterface{}
+	mockEnvDao.On("GetAllEnvironments", mock.Anything).Return([]model.Environment{}, nil)
+	appContext.Repositories.EnvironmentDAO = mockEnvDao
+
+	req, err := http.NewRequest("GET", "/environments/999/drift", nil)
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/drift", appContext.environmentDrift).Methods("GET")
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestFlattenValues(t *testing.T) {
+	result := make(map[string]string)
+	flattenValues("", map[string]interface{}{
+		"app":   map[string]interface{}{"port": float64(8080), "max": float64(1000000), "enabled": true},
+		"istio": map[string]interface{}{"gateways": []interface{}{"gw"}},
+	}, result)
+
+	assert.Equal(t, "8080", result["app.port"])
+	assert.Equal(t, "1000000", result["app.max"])
+	assert.Equal(t, "true", result["app.enabled"])
+	assert.Equal(t, "gw", result["istio.gateways[0]"])
+}
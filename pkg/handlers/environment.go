@@ -44,6 +44,16 @@ func (appContext *AppContext) deleteEnvironment(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	hasChildren, err := appContext.hasChildEnvironments(env.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if hasChildren {
+		http.Error(w, "Environment is the parent of other environments", http.StatusBadRequest)
+		return
+	}
+
 	if err := appContext.Repositories.EnvironmentDAO.DeleteEnvironment(*env); err != nil {
 		log.Println("Error deleting environment: ", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -76,6 +86,15 @@ func (appContext *AppContext) editEnvironment(w http.ResponseWriter, r *http.Req
 
 	env := payload.Data
 
+	if err := appContext.validateEnvironmentParent(env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateRulesPolicy(env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	result, err := appContext.Repositories.EnvironmentDAO.GetByID(int(env.ID))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -126,37 +145,46 @@ func (appContext *AppContext) duplicateEnvironments(w http.ResponseWriter, r *ht
 		return
 	}
 
-	var env model.Environment
-	env.Namespace = environment.Namespace
-	env.Name = environment.Name + "-Copy"
-	env.Group = environment.Group
-	env.CACertificate = environment.CACertificate
-	env.Token = environment.Token
-	env.ClusterURI = environment.ClusterURI
-	env.Gateway = environment.Gateway
+	env := duplicatedEnvironment(*environment)
+
+	ruleSetID, err := extractRewriteRuleSetID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rewriter, err := appContext.newVariableRewriter(ruleSetID, environment, env)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	createEnvironmentFile(env.Name, env.Token, appContext.K8sConfigPath+env.Group+"_"+env.Name,
 		env.CACertificate, env.ClusterURI, env.Namespace)
 
 	var envID int
-	if envID, err = appContext.Repositories.EnvironmentDAO.CreateEnvironment(env); err != nil {
+	if envID, err = appContext.Repositories.EnvironmentDAO.CreateEnvironment(*env); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	var newVariable *model.Variable
 	for _, variable := range variables {
+		variable = rewriter.rewrite(variable)
 		newVariable = &model.Variable{}
 		newVariable.Name = variable.Name
 		newVariable.EnvironmentID = envID
 		newVariable.Value = variable.Value
 		newVariable.Description = variable.Description
 		newVariable.Scope = variable.Scope
+		newVariable.Secret = variable.Secret
+		newVariable.Type = variable.Type
 
 		if _, _, err := appContext.Repositories.VariableDAO.CreateVariable(*newVariable); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		appContext.recordVariableRevision(principal.Email, model.VariableCreated, *newVariable, "")
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -179,6 +207,15 @@ func (appContext *AppContext) addEnvironments(w http.ResponseWriter, r *http.Req
 
 	env := payload.Data
 
+	if err := appContext.validateEnvironmentParent(env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateRulesPolicy(env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	createEnvironmentFile(env.Name, env.Token, appContext.K8sConfigPath+env.Group+"_"+env.Name,
 		env.CACertificate, env.ClusterURI, env.Namespace)
 
@@ -229,6 +266,11 @@ func (appContext *AppContext) export(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if format := r.URL.Query().Get("format"); format != "" && format != exportFormatText {
+		appContext.exportEnvironment(w, r, id, format)
+		return
+	}
+
 	var variables []model.Variable
 	if variables, err = appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(id); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
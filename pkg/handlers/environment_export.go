@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+	"golang.org/x/crypto/scrypt"
+)
+
+const exportFormatText = "text"
+const exportFormatJSON = "json"
+const exportFormatValues = "values"
+const exportFormatZip = "zip"
+
+//exportPassphraseHeader carries the passphrase secrets are encrypted with on export and decrypted with on import
+const exportPassphraseHeader = "X-Export-Passphrase"
+
+//encryptedValue matches ENC[salt:data], or ENC[data] for exports made before the key was salted
+var encryptedValue = regexp.MustCompile(`^ENC\[(?:([0-9a-f]+):)?([0-9a-f]+)\]$`)
+
+//exportEnvironment writes the structured export formats, the plain text one is kept by export
+func (appContext *AppContext) exportEnvironment(w http.ResponseWriter, r *http.Request, id int, format string) {
+
+	if format != exportFormatJSON && format != exportFormatValues && format != exportFormatZip {
+		http.Error(w, "Invalid format: "+format, http.StatusBadRequest)
+		return
+	}
+
+	scope := r.URL.Query().Get("scope")
+	if format == exportFormatValues && scope == "" {
+		http.Error(w, "Scope is required to export a values file", http.StatusBadRequest)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	has, err := appContext.hasAccess(principal.Email, id)
+	if err != nil || !has {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	environment, err := appContext.Repositories.EnvironmentDAO.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	export, err := appContext.buildEnvironmentExport(environment, variables, r.Header.Get(exportPassphraseHeader))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data []byte
+	switch format {
+	case exportFormatJSON:
+		data, _ = json.MarshalIndent(export, "", "  ")
+		w.Header().Set(global.ContentType, global.JSONContentType)
+		w.Header().Set("Content-Disposition", "attachment; filename="+environment.Name+".json")
+	case exportFormatValues:
+		for _, s := range export.Scopes {
+			if s.Scope == scope {
+				data, err = buildValuesFile(s.Variables)
+			}
+		}
+		if data == nil && err == nil {
+			http.Error(w, "No variables found for scope "+scope, http.StatusNotFound)
+			return
+		}
+		w.Header().Set(global.ContentType, "application/x-yaml; charset=UTF-8")
+		w.Header().Set("Content-Disposition", "attachment; filename="+chartNameOf(scope)+".yaml")
+	case exportFormatZip:
+		data, err = buildValuesZip(export.Scopes)
+		w.Header().Set(global.ContentType, "application/zip")
+		w.Header().Set("Content-Disposition", "attachment; filename="+environment.Name+".zip")
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//buildEnvironmentExport groups the variables by scope. Secrets are decrypted and encrypted again
+//under the passphrase, or omitted when there is no passphrase.
+func (appContext *AppContext) buildEnvironmentExport(environment *model.Environment, variables []model.Variable,
+	passphrase string) (*model.EnvironmentExport, error) {
+
+	export := &model.EnvironmentExport{
+		Name:           environment.Name,
+		Group:          environment.Group,
+		Namespace:      environment.Namespace,
+		Gateway:        environment.Gateway,
+		ProductVersion: environment.ProductVersion,
+		Scopes:         make([]model.VariableScopeExport, 0),
+	}
+	secrets := newExportSecrets(passphrase)
+
+	sort.SliceStable(variables, func(i, j int) bool {
+		if variables[i].Scope != variables[j].Scope {
+			return variables[i].Scope < variables[j].Scope
+		}
+		return variables[i].Name < variables[j].Name
+	})
+
+	for _, v := range variables {
+		item := model.VariableExport{Name: v.Name, Value: v.Value, Secret: v.Secret, Description: v.Description,
+			Type: v.Type}
+		if v.Secret {
+			if passphrase == "" {
+				export.OmittedSecrets = append(export.OmittedSecrets, v.Scope+"/"+v.Name)
+				continue
+			}
+			value, err := secrets.encrypt(appContext.decryptVariable(v).Value)
+			if err != nil {
+				return nil, err
+			}
+			item.Value = value
+		}
+
+		last := len(export.Scopes) - 1
+		if last < 0 || export.Scopes[last].Scope != v.Scope {
+			export.Scopes = append(export.Scopes, model.VariableScopeExport{Scope: v.Scope})
+			last++
+		}
+		export.Scopes[last].Variables = append(export.Scopes[last].Variables, item)
+	}
+
+	return export, nil
+}
+
+//buildValuesFile writes the variables as the Helm values they are installed with
+func buildValuesFile(variables []model.VariableExport) ([]byte, error) {
+	values := make(map[string]interface{})
+	for _, v := range variables {
+		current := values
+		keys := strings.Split(normalizeVariableName(v.Name), ".")
+		for _, key := range keys[:len(keys)-1] {
+			next, ok := current[key].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				current[key] = next
+			}
+			current = next
+		}
+		current[keys[len(keys)-1]] = v.Value
+	}
+	return yaml.Marshal(values)
+}
+
+//buildValuesZip writes one values file per chart, named after the scope so the archive can be imported back
+func buildValuesZip(scopes []model.VariableScopeExport) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	for _, s := range scopes {
+		data, err := buildValuesFile(s.Variables)
+		if err != nil {
+			return nil, err
+		}
+		f, err := archive.Create(s.Scope + ".yaml")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//exportSecrets encrypts the exported secrets with a key derived from the passphrase by scrypt. Each export
+//has its own salt, kept in every value so it can be decrypted alone; derived keys are cached by salt.
+type exportSecrets struct {
+	passphrase string
+	salt       []byte
+	keys       map[string][]byte
+}
+
+func newExportSecrets(passphrase string) *exportSecrets {
+	return &exportSecrets{passphrase: passphrase, keys: make(map[string][]byte)}
+}
+
+func (e *exportSecrets) key(salt []byte) ([]byte, error) {
+	if key, ok := e.keys[string(salt)]; ok {
+		return key, nil
+	}
+	key, err := scrypt.Key([]byte(e.passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	e.keys[string(salt)] = key
+	return key, nil
+}
+
+func (e *exportSecrets) encrypt(value string) (string, error) {
+	if e.salt == nil {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		e.salt = salt
+	}
+	key, err := e.key(e.salt)
+	if err != nil {
+		return "", err
+	}
+	data, err := util.EncryptWithKey([]byte(value), key)
+	if err != nil {
+		return "", err
+	}
+	return "ENC[" + hex.EncodeToString(e.salt) + ":" + hex.EncodeToString(data) + "]", nil
+}
+
+//decrypt tells if the value was encrypted on export and returns it decrypted
+func (e *exportSecrets) decrypt(value string) (string, bool, error) {
+	match := encryptedValue.FindStringSubmatch(value)
+	if match == nil {
+		return value, false, nil
+	}
+	if e.passphrase == "" {
+		return "", true, errors.New("The file has encrypted secrets, the export passphrase is required")
+	}
+
+	data, _ := hex.DecodeString(match[2])
+	var decrypted []byte
+	var err error
+	if match[1] == "" {
+		decrypted, err = util.Decrypt(data, e.passphrase)
+	} else {
+		salt, _ := hex.DecodeString(match[1])
+		var key []byte
+		if key, err = e.key(salt); err == nil {
+			decrypted, err = util.DecryptWithKey(data, key)
+		}
+	}
+	if err != nil {
+		return "", true, errors.New("Unable to decrypt secrets, check the export passphrase")
+	}
+	return string(decrypted), true, nil
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/configs"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/softplan/tenkai-api/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getExportAppContext() *AppContext {
+	appContext := AppContext{}
+	appContext.Configuration = &configs.Configuration{App: configs.App{Passkey: "qwert"}}
+	mockEnvDao := mockGetByID(&appContext)
+	mockEnvDao.On("GetAllEnvironments", "beta@alfa.com").Return([]model.Environment{mockGetEnv()}, nil)
+
+	password := hex.EncodeToString(util.Encrypt([]byte("s3cr3t"), "qwert"))
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 999).Return([]model.Variable{
+		{Scope: "repo/foo", Name: "url", Value: "dev.host"},
+		{Scope: "repo/bar", Name: "image.tag", Value: "1.0.0"},
+		{Scope: "repo/foo", Name: "db.password", Value: password, Secret: true},
+		{Scope: "repo/foo", Name: "db.user", Value: "admin"},
+	}, nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	return &appContext
+}
+
+func doExport(appContext *AppContext, query string, passphrase string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/environments/export/999?"+query, nil)
+	mockPrincipal(req)
+	if passphrase != "" {
+		req.Header.Set(exportPassphraseHeader, passphrase)
+	}
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/export/{id}", appContext.export).Methods("GET")
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestExportEnvironment_JSON(t *testing.T) {
+	appContext := getExportAppContext()
+
+	rr := doExport(appContext, "format=json", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var export model.EnvironmentExport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &export))
+	assert.Equal(t, "bar", export.Name)
+	assert.Equal(t, []model.VariableScopeExport{
+		{Scope: "repo/bar", Variables: []model.VariableExport{{Name: "image.tag", Value: "1.0.0"}}},
+		{Scope: "repo/foo", Variables: []model.VariableExport{{Name: "db.user", Value: "admin"},
+			{Name: "url", Value: "dev.host"}}},
+	}, export.Scopes)
+	assert.Equal(t, []string{"repo/foo/db.password"}, export.OmittedSecrets)
+}
+
+func TestExportEnvironment_JSONWithPassphrase(t *testing.T) {
+	appContext := getExportAppContext()
+
+	rr := doExport(appContext, "format=json", "export-key")
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var export model.EnvironmentExport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &export))
+	assert.Empty(t, export.OmittedSecrets)
+
+	secret := export.Scopes[1].Variables[0]
+	assert.Equal(t, "db.password", secret.Name)
+	assert.True(t, secret.Secret)
+
+	assert.Regexp(t, `^ENC\[[0-9a-f]{32}:[0-9a-f]+\]$`, secret.Value)
+	value, encrypted, err := newExportSecrets("export-key").decrypt(secret.Value)
+	assert.NoError(t, err)
+	assert.True(t, encrypted)
+	assert.Equal(t, "s3cr3t", value)
+
+	_, _, err = newExportSecrets("wrong").decrypt(secret.Value)
+	assert.Error(t, err)
+	_, _, err = newExportSecrets("").decrypt(secret.Value)
+	assert.Error(t, err)
+}
+
+func TestExportSecrets_SaltedAndLegacy(t *testing.T) {
+	first, _ := newExportSecrets("export-key").encrypt("s3cr3t")
+	second, _ := newExportSecrets("export-key").encrypt("s3cr3t")
+	assert.NotEqual(t, first[:37], second[:37])
+
+	legacy := "ENC[" + hex.EncodeToString(util.Encrypt([]byte("s3cr3t"), "export-key")) + "]"
+	value, encrypted, err := newExportSecrets("export-key").decrypt(legacy)
+	assert.NoError(t, err)
+	assert.True(t, encrypted)
+	assert.Equal(t, "s3cr3t", value)
+}
+
+func TestExportEnvironment_Values(t *testing.T) {
+	appContext := getExportAppContext()
+
+	rr := doExport(appContext, "format=values&scope=repo/foo", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, "attachment; filename=foo.yaml", rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "app:\n  db:\n    user: admin\n  url: dev.host\n", rr.Body.String())
+
+	rr = doExport(appContext, "format=values", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+
+	rr = doExport(appContext, "format=values&scope=repo/other", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "Response should be 404.")
+}
+
+func TestExportEnvironment_ZipRoundTrip(t *testing.T) {
+	appContext := getExportAppContext()
+
+	rr := doExport(appContext, "format=zip", "export-key")
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	body := rr.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(archive.File))
+	assert.Equal(t, "repo/bar.yaml", archive.File[0].Name)
+
+	rc, _ := archive.File[0].Open()
+	data, _ := ioutil.ReadAll(rc)
+	assert.Equal(t, "image:\n  tag: 1.0.0\n", string(data))
+
+	options := variableImport{format: importFormatZip, passphrase: "export-key"}
+	variables, ignored, err := parseImportedVariables(options, body)
+	assert.NoError(t, err)
+	assert.Empty(t, ignored)
+	assert.Equal(t, []model.Variable{
+		{Scope: "repo/bar", Name: "image.tag", Value: "1.0.0"},
+		{Scope: "repo/foo", Name: "db.password", Value: "s3cr3t", Secret: true},
+		{Scope: "repo/foo", Name: "db.user", Value: "admin"},
+		{Scope: "repo/foo", Name: "url", Value: "dev.host"},
+	}, variables)
+
+	options.passphrase = ""
+	_, _, err = parseImportedVariables(options, body)
+	assert.EqualError(t, err, "The file has encrypted secrets, the export passphrase is required")
+}
+
+func TestExportEnvironment_ImportJSON(t *testing.T) {
+	appContext := getExportAppContext()
+	rr := doExport(appContext, "format=json", "export-key")
+
+	variables, _, err := parseImportedVariables(variableImport{format: importFormatJSON, scope: "repo/foo",
+		passphrase: "export-key"}, rr.Body.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(variables))
+	assert.Equal(t, model.Variable{Scope: "repo/foo", Name: "db.password", Value: "s3cr3t", Secret: true}, variables[0])
+}
+
+func TestExportEnvironment_AccessDenied(t *testing.T) {
+	appContext := getExportAppContext()
+	mockGetAllEnvironmentsError(appContext)
+
+	rr := doExport(appContext, "format=json", "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+	appContext.Repositories.VariableDAO.(*mockRepo.VariableDAOInterface).
+		AssertNotCalled(t, "GetAllVariablesByEnvironment", mock.Anything)
+}
+
+func TestExportEnvironment_InvalidFormat(t *testing.T) {
+	appContext := getExportAppContext()
+
+	rr := doExport(appContext, "format=xml", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+//maxEnvironmentChain is the deepest an inheritance chain can be, the environment included
+const maxEnvironmentChain = 10
+
+//listEffectiveVariables lists the variables an environment resolves through its ancestors,
+//with the environment each value comes from
+func (appContext *AppContext) listEffectiveVariables(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	has, err := appContext.hasAccess(principal.Email, id)
+	if err != nil || !has {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	environment, err := appContext.Repositories.EnvironmentDAO.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	chain, err := appContext.environmentChain(environment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	chainVariables, err := appContext.loadChainVariables(chain, r.URL.Query().Get("scope"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := model.EffectiveVariablesResult{
+		Chain:     make([]model.VariableSource, 0),
+		Variables: make([]model.EffectiveVariable, 0),
+	}
+	index := make(map[string]int)
+	for i, env := range chain {
+		source := model.VariableSource{EnvironmentID: env.ID, Environment: env.Name}
+		result.Chain = append(result.Chain, source)
+
+		for _, v := range chainVariables[i] {
+			value := v.Value
+			if v.Secret {
+				value = secretMask
+			}
+
+			key := v.Scope + "/" + v.Name
+			if pos, ok := index[key]; ok {
+				overridden := source
+				overridden.Value = value
+				result.Variables[pos].Overrides = append(result.Variables[pos].Overrides, overridden)
+				continue
+			}
+
+			index[key] = len(result.Variables)
+			result.Variables = append(result.Variables, model.EffectiveVariable{
+				Scope:       v.Scope,
+				Name:        v.Name,
+				Value:       value,
+				Secret:      v.Secret,
+				Description: v.Description,
+				Source:      source,
+				Overrides:   make([]model.VariableSource, 0),
+			})
+		}
+	}
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//environmentChain returns the environment followed by its parent, the parent of its parent and so on
+func (appContext *AppContext) environmentChain(environment *model.Environment) ([]model.Environment, error) {
+	chain := []model.Environment{*environment}
+	visited := map[uint]bool{environment.ID: true}
+
+	for parentID := environment.ParentID; parentID != 0; {
+		if visited[parentID] {
+			return nil, errors.New("Environment inheritance cycle on " + environment.Name)
+		}
+		if len(chain) == maxEnvironmentChain {
+			return nil, errors.New("Environment inheritance deeper than " + strconv.Itoa(maxEnvironmentChain))
+		}
+
+		parent, err := appContext.Repositories.EnvironmentDAO.GetByID(int(parentID))
+		if err != nil {
+			return nil, errors.New("Invalid parent environment " + strconv.Itoa(int(parentID)) + " - " + err.Error())
+		}
+
+		chain = append(chain, *parent)
+		visited[parentID] = true
+		parentID = parent.ParentID
+	}
+
+	return chain, nil
+}
+
+//validateEnvironmentParent checks that the parent exists and that it does not inherit from env
+func (appContext *AppContext) validateEnvironmentParent(env model.Environment) error {
+	if env.ParentID == 0 {
+		return nil
+	}
+	if env.ParentID == env.ID {
+		return errors.New("An environment can not inherit from itself")
+	}
+	_, err := appContext.environmentChain(&env)
+	return err
+}
+
+//loadChainVariables loads the variables of each environment of the chain. An empty scope loads every scope.
+func (appContext *AppContext) loadChainVariables(chain []model.Environment, scope string) ([][]model.Variable, error) {
+	result := make([][]model.Variable, 0, len(chain))
+	for _, env := range chain {
+		var variables []model.Variable
+		var err error
+		if scope == "" {
+			variables, err = appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(env.ID))
+		} else {
+			variables, err = appContext.Repositories.VariableDAO.GetAllVariablesByEnvironmentAndScope(int(env.ID), scope)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, variables)
+	}
+	return result, nil
+}
+
+//getEffectiveVariables merges the variables of the environment with the ones of its ancestors.
+//A variable of the environment overrides the one of its parent, which overrides the one of its own parent.
+func (appContext *AppContext) getEffectiveVariables(environment *model.Environment, scope string) ([]model.Variable, error) {
+	chain, err := appContext.environmentChain(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	chainVariables, err := appContext.loadChainVariables(chain, scope)
+	if err != nil {
+		return nil, err
+	}
+	if len(chainVariables) == 1 {
+		return chainVariables[0], nil
+	}
+
+	variables := make([]model.Variable, 0)
+	found := make(map[string]bool)
+	for _, list := range chainVariables {
+		for _, v := range list {
+			key := v.Scope + "/" + v.Name
+			if !found[key] {
+				found[key] = true
+				variables = append(variables, v)
+			}
+		}
+	}
+	return variables, nil
+}
+
+//hasChildEnvironments tells if an environment is the parent of another one
+func (appContext *AppContext) hasChildEnvironments(id uint) (bool, error) {
+	environments, err := appContext.Repositories.EnvironmentDAO.GetAllEnvironments("")
+	if err != nil {
+		return false, err
+	}
+	for _, env := range environments {
+		if env.ParentID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
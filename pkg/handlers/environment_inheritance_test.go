@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/configs"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getInheritanceAppContext() (*AppContext, *mockRepo.EnvironmentDAOInterface) {
+	appContext := AppContext{}
+	appContext.Configuration = &configs.Configuration{App: configs.App{Passkey: "qwert"}}
+
+	child := mockGetEnv()
+	child.ParentID = 10
+	parent := model.Environment{Name: "staging", ParentID: 20}
+	parent.ID = 10
+	base := model.Environment{Name: "base"}
+	base.ID = 20
+
+	mockEnvDao := &mockRepo.EnvironmentDAOInterface{}
+	mockEnvDao.On("GetByID", 999).Return(&child, nil)
+	mockEnvDao.On("GetByID", 10).Return(&parent, nil)
+	mockEnvDao.On("GetByID", 20).Return(&base, nil)
+	mockEnvDao.On("GetAllEnvironments", "beta@alfa.com").Return([]model.Environment{child}, nil)
+	mockEnvDao.On("GetAllEnvironments", "").Return([]model.Environment{child, parent, base}, nil)
+	appContext.Repositories.EnvironmentDAO = mockEnvDao
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 999, "repo/foo").Return([]model.Variable{
+		{EnvironmentID: 999, Scope: "repo/foo", Name: "url", Value: "bar.host"},
+	}, nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 10, "repo/foo").Return([]model.Variable{
+		{EnvironmentID: 10, Scope: "repo/foo", Name: "url", Value: "staging.host"},
+		{EnvironmentID: 10, Scope: "repo/foo", Name: "password", Value: "a1b2", Secret: true},
+	}, nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 20, "repo/foo").Return([]model.Variable{
+		{EnvironmentID: 20, Scope: "repo/foo", Name: "url", Value: "base.host"},
+		{EnvironmentID: 20, Scope: "repo/foo", Name: "timeout", Value: "30"},
+	}, nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	return &appContext, mockEnvDao
+}
+
+func TestGetEffectiveVariables(t *testing.T) {
+	appContext, _ := getInheritanceAppContext()
+	environment, _ := appContext.Repositories.EnvironmentDAO.GetByID(999)
+
+	variables, err := appContext.getEffectiveVariables(environment, "repo/foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Variable{
+		{EnvironmentID: 999, Scope: "repo/foo", Name: "url", Value: "bar.host"},
+		{EnvironmentID: 10, Scope: "repo/foo", Name: "password", Value: "a1b2", Secret: true},
+		{EnvironmentID: 20, Scope: "repo/foo", Name: "timeout", Value: "30"},
+	}, variables)
+}
+
+func TestGetEffectiveVariables_Cycle(t *testing.T) {
+	appContext, mockEnvDao := getInheritanceAppContext()
+	looping := model.Environment{Name: "base", ParentID: 999}
+	looping.ID = 20
+	mockEnvDao.ExpectedCalls = nil
+	child := mockGetEnv()
+	child.ParentID = 20
+	mockEnvDao.On("GetByID", 20).Return(&looping, nil)
+
+	_, err := appContext.getEffectiveVariables(&child, "repo/foo")
+	assert.EqualError(t, err, "Environment inheritance cycle on bar")
+}
+
+func TestListEffectiveVariables(t *testing.T) {
+	appContext, _ := getInheritanceAppContext()
+
+	req, _ := http.NewRequest("GET", "/environments/999/effectiveVariables?scope=repo/foo", nil)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/effectiveVariables", appContext.listEffectiveVariables).Methods("GET")
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var result model.EffectiveVariablesResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, []model.VariableSource{{EnvironmentID: 999, Environment: "bar"},
+		{EnvironmentID: 10, Environment: "staging"}, {EnvironmentID: 20, Environment: "base"}}, result.Chain)
+	assert.Equal(t, []model.EffectiveVariable{
+		{Scope: "repo/foo", Name: "url", Value: "bar.host", Source: model.VariableSource{EnvironmentID: 999, Environment: "bar"},
+			Overrides: []model.VariableSource{{EnvironmentID: 10, Environment: "staging", Value: "staging.host"},
+				{EnvironmentID: 20, Environment: "base", Value: "base.host"}}},
+		{Scope: "repo/foo", Name: "password", Value: "******", Secret: true,
+			Source: model.VariableSource{EnvironmentID: 10, Environment: "staging"}, Overrides: []model.VariableSource{}},
+		{Scope: "repo/foo", Name: "timeout", Value: "30", Source: model.VariableSource{EnvironmentID: 20, Environment: "base"},
+			Overrides: []model.VariableSource{}},
+	}, result.Variables)
+}
+
+func TestEditEnvironment_InvalidParent(t *testing.T) {
+	appContext, mockEnvDao := getInheritanceAppContext()
+	mockEnvDao.On("EditEnvironment", mock.Anything).Return(nil)
+
+	for _, parentID := range []uint{999, 10} {
+		var element model.DataElement
+		element.Data = mockGetEnv()
+		element.Data.ParentID = parentID
+		if parentID == 10 {
+			element.Data.ID = 20
+		}
+
+		req, _ := http.NewRequest("POST", "/environments/edit", payload(element))
+		mockPrincipal(req)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(appContext.editEnvironment).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	}
+	mockEnvDao.AssertNotCalled(t, "EditEnvironment", mock.Anything)
+}
+
+func TestDeleteEnvironment_HasChildren(t *testing.T) {
+	appContext, mockEnvDao := getInheritanceAppContext()
+
+	req, _ := http.NewRequest("DELETE", "/environments/delete/10", nil)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/delete/{id}", appContext.deleteEnvironment).Methods("DELETE")
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	mockEnvDao.AssertNotCalled(t, "DeleteEnvironment", mock.Anything)
+}
@@ -70,6 +70,7 @@ func TestDeleteEnvironment(t *testing.T) {
 
 	env := mockGetEnv()
 	mockEnvDAO := mockGetByID(&appContext)
+	mockEnvDAO.On("GetAllEnvironments", "").Return([]model.Environment{env}, nil)
 	mockEnvDAO.On("DeleteEnvironment", env).Return(nil)
 
 	req, err := http.NewRequest("DELETE", "/environments/delete/999", nil)
@@ -145,6 +146,7 @@ func TestDeleteEnvironment_DeleteEnvironmentError(t *testing.T) {
 
 	env := mockGetEnv()
 	mockEnvDAO := mockGetByID(&appContext)
+	mockEnvDAO.On("GetAllEnvironments", "").Return([]model.Environment{env}, nil)
 	mockEnvDAO.On("DeleteEnvironment", env).Return(errors.New("some error"))
 
 	req, err := http.NewRequest("DELETE", "/environments/delete/999", nil)
@@ -273,6 +275,7 @@ func TestDuplicateEnvironments(t *testing.T) {
 	mockVariableDAO.On("CreateVariable", mock.Anything).Return(nil, true, nil)
 
 	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockVariableRevisionDAO := mockCreateVariableRevision(&appContext)
 
 	req, err := http.NewRequest("GET", "/environments/duplicate/999", nil)
 	assert.NoError(t, err)
@@ -291,6 +294,7 @@ func TestDuplicateEnvironments(t *testing.T) {
 	mockVariableDAO.AssertNumberOfCalls(t, "GetAllVariablesByEnvironment", 1)
 	mockEnvDAO.AssertNumberOfCalls(t, "CreateEnvironment", 1)
 	mockVariableDAO.AssertNumberOfCalls(t, "CreateVariable", 2)
+	mockVariableRevisionDAO.AssertNumberOfCalls(t, "CreateVariableRevision", 2)
 	assert.Equal(t, http.StatusCreated, rr.Code, "Response should be Created.")
 }
 
@@ -446,7 +450,7 @@ func TestGetEnvironments(t *testing.T) {
 	assert.Contains(t, response, `"ca_certificate":"my-certificate"`)
 	assert.Contains(t, response, `"token":"kubeconfig-user-ph111:abbkdd57t68tq2lppg6lwb65sb69282jhsmh3ndwn4vhjtt8blmhh2"`)
 	assert.Contains(t, response, `"namespace":"dev","gateway":"my-gateway.istio-system.svc.cluster.local"`)
-	assert.Contains(t, response, `"productVersion":"","currentRelease":""}]}`)
+	assert.Contains(t, response, `"productVersion":"","productVersionId":0,"currentRelease":"","parentId":0,"rulesPolicy":""}]}`)
 }
 
 func TestGetEnvironments_AccessDenied(t *testing.T) {
@@ -556,7 +560,7 @@ func TestGetAllEnvironments(t *testing.T) {
 	assert.Contains(t, response, `"ca_certificate":"my-certificate"`)
 	assert.Contains(t, response, `"token":"kubeconfig-user-ph111:abbkdd57t68tq2lppg6lwb65sb69282jhsmh3ndwn4vhjtt8blmhh2"`)
 	assert.Contains(t, response, `"namespace":"dev","gateway":"my-gateway.istio-system.svc.cluster.local"`)
-	assert.Contains(t, response, `"productVersion":"","currentRelease":""}]}`)
+	assert.Contains(t, response, `"productVersion":"","productVersionId":0,"currentRelease":"","parentId":0,"rulesPolicy":""}]}`)
 }
 
 func TestGetAllEnvironments_GetAllEnvError(t *testing.T) {
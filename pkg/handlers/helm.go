@@ -8,8 +8,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
-	"time"
 
 	"github.com/softplan/tenkai-api/pkg/constraints"
 	"github.com/softplan/tenkai-api/pkg/global"
@@ -18,6 +18,7 @@ import (
 
 	"strings"
 
+	"github.com/ghodss/yaml"
 	"github.com/gorilla/mux"
 	"github.com/softplan/tenkai-api/pkg/dbms/model"
 
@@ -420,6 +421,27 @@ func (appContext *AppContext) multipleInstall(w http.ResponseWriter, r *http.Req
 		environments = append(environments, environment)
 	}
 
+	if err := appContext.checkDeployableProductVersion(payload.ProductVersionID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	checks := make([]model.DeployRulesCheck, 0)
+	for _, environment := range environments {
+		check, err := appContext.checkReleaseRules(principal, environment, payload.Deployables,
+			r.URL.Query().Get("overrideReason"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		checks = append(checks, *check)
+	}
+	if isBlocked(checks) {
+		writeRulesResult(w, checks)
+		return
+	}
+	appContext.auditRulesOverride(r, principal, checks)
+
 	user, err := appContext.Repositories.UserDAO.FindByEmail(principal.Email)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
@@ -443,7 +465,7 @@ func (appContext *AppContext) multipleInstall(w http.ResponseWriter, r *http.Req
 
 		for _, element := range payload.Deployables {
 			if err = appContext.updateImageTagBeforeInstallProduct(payload.ProductVersionID,
-				int(environment.ID), element.Chart); err != nil {
+				int(environment.ID), element.Chart, principal.Email); err != nil {
 				http.Error(w, err.Error(), 500)
 				return
 			}
@@ -468,6 +490,7 @@ func (appContext *AppContext) multipleInstall(w http.ResponseWriter, r *http.Req
 				return
 			}
 			environment.ProductVersion = pv.Version
+			environment.ProductVersionID = pv.ID
 			if err := appContext.Repositories.EnvironmentDAO.EditEnvironment(*environment); err != nil {
 				http.Error(w, err.Error(), 501)
 				return
@@ -477,7 +500,7 @@ func (appContext *AppContext) multipleInstall(w http.ResponseWriter, r *http.Req
 				pv.ProductID, environment.Namespace, pv.Version)
 		}
 	}
-	w.WriteHeader(http.StatusOK)
+	writeRulesResult(w, checks)
 }
 
 func (appContext *AppContext) triggerProductDeploymentWebhook(
@@ -512,7 +535,8 @@ func (appContext *AppContext) triggerProductDeploymentWebhook(
 	}
 }
 
-func (appContext *AppContext) updateImageTagBeforeInstallProduct(productVersionID int, envID int, chart string) error {
+func (appContext *AppContext) updateImageTagBeforeInstallProduct(productVersionID int, envID int, chart string,
+	user string) error {
 	if productVersionID > 0 {
 		pvs, err := appContext.Repositories.ProductDAO.ListProductsVersionServices(productVersionID)
 		if err != nil {
@@ -522,10 +546,14 @@ func (appContext *AppContext) updateImageTagBeforeInstallProduct(productVersionI
 		if varImgTag.ID > 0 {
 			for _, pvsvc := range pvs {
 				if varImgTag.Scope == strings.Split(pvsvc.ServiceName, " - ")[0] {
+					oldValue := varImgTag.Value
 					varImgTag.Value = pvsvc.DockerImageTag
 					if err := appContext.Repositories.VariableDAO.EditVariable(varImgTag); err != nil {
 						return err
 					}
+					if oldValue != varImgTag.Value {
+						appContext.recordVariableRevision(user, model.VariableUpdated, varImgTag, oldValue)
+					}
 				}
 			}
 		}
@@ -576,6 +604,18 @@ func (appContext *AppContext) install(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	check, err := appContext.checkReleaseRules(principal, environment, deployables, r.URL.Query().Get("overrideReason"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checks := []model.DeployRulesCheck{*check}
+	if isBlocked(checks) {
+		writeRulesResult(w, checks)
+		return
+	}
+	appContext.auditRulesOverride(r, principal, checks)
+
 	user, err := appContext.Repositories.UserDAO.FindByEmail(principal.Email)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
@@ -597,7 +637,7 @@ func (appContext *AppContext) install(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	w.WriteHeader(http.StatusOK)
+	writeRulesResult(w, checks)
 
 }
 
@@ -632,125 +672,132 @@ func (appContext *AppContext) helmDryRun(w http.ResponseWriter, r *http.Request)
 
 }
 
-func (appContext *AppContext) getArgsWithHelmDefault(variables []model.Variable, helmVars map[string]interface{}, globalVariables []model.Variable, environment *model.Environment) []string {
+func (appContext *AppContext) simpleInstall(environment *model.Environment, installPayload model.InstallPayload, out *bytes.Buffer, dryRun bool, helmCommandOnly bool, userID string, requestDeploymentID int) (string, error) {
 
-	var args []string
-	var keys []string
-	for i, item := range variables {
-		if item.Secret {
-			byteValues, _ := hex.DecodeString(item.Value)
-			value, err := util.Decrypt(byteValues, appContext.Configuration.App.Passkey)
-			if err == nil {
-				variables[i].Value = string(value)
-			}
-		}
-		if len(item.Name) > 0 && len(item.Value) > 0 {
-			value := replace(item.Value, *environment, globalVariables)
-			if value != "" {
-				keys = append(keys, normalizeVariableName(item.Name))
-			}
-			if value == "T_EMPTY" {
-				value = ""
-			}
-			args = append(args, normalizeVariableName(item.Name)+"="+value)
-		}
+	//WARNING - VERIFY IF CONFIG FILE EXISTS !!! This is the cause of  u.client.ReleaseHistory fail sometimes.
+
+	variables, err := appContext.getEffectiveVariables(environment, releaseSearchTerm(installPayload))
+	if err != nil {
+		return "", err
 	}
+	globalVariables := appContext.getGlobalVariables(environment)
 
-	dt := time.Now()
-	args = append(args, "app.dateHour="+dt.String())
-	keys = append(keys, "app.dateHour")
+	values, secrets, err := appContext.releaseValues(environment, installPayload.Chart, installPayload.ChartVersion,
+		variables, globalVariables)
+	if err != nil {
+		return "", err
+	}
 
-	for key, value := range helmVars {
-		if !util.Contains(keys, normalizeVariableName(key)) {
-			svalue, ok := value.(string)
-			if ok {
-				args = append(args, normalizeVariableName(key)+"="+replace(svalue, *environment, globalVariables))
-			}
-		}
+	document, err := yaml.Marshal(values)
+	if err != nil {
+		return "", err
 	}
+	name := installPayload.Name + "-" + environment.Namespace
+	kubeConfig := appContext.ConventionInterface.GetKubeConfigFileName(environment.Group, environment.Name)
 
-	return args
-}
+	if !helmCommandOnly {
 
-func (appContext *AppContext) simpleInstall(environment *model.Environment, installPayload model.InstallPayload, out *bytes.Buffer, dryRun bool, helmCommandOnly bool, userID string, requestDeploymentID int) (string, error) {
+		upgradeRequest := helmapi.UpgradeRequest{}
+		upgradeRequest.Kubeconfig = kubeConfig
+		upgradeRequest.Namespace = environment.Namespace
+		upgradeRequest.ChartVersion = installPayload.ChartVersion
+		upgradeRequest.Chart = installPayload.Chart
+		upgradeRequest.Values = string(document)
+		upgradeRequest.Dryrun = dryRun
+		upgradeRequest.Release = name
 
-	//WARNING - VERIFY IF CONFIG FILE EXISTS !!! This is the cause of  u.client.ReleaseHistory fail sometimes.
+		if dryRun {
+			return appContext.doMaskedUpgrade(upgradeRequest, out, secrets)
+		}
 
-	searchTerm := installPayload.Chart
-	if strings.Index(installPayload.Name, "gcm") > -1 {
-		searchTerm = installPayload.Name
-	}
-	variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironmentAndScope(int(environment.ID), searchTerm)
-	globalVariables := appContext.getGlobalVariables(int(environment.ID))
+		deployment := model.Deployment{}
+		deployment.EnvironmentID = environment.ID
+		deployment.RequestDeploymentID = uint(requestDeploymentID)
+		deployment.Chart = installPayload.Chart
+		deployment.Processed = false
+		deployment.ChartVersion = installPayload.ChartVersion
+		deployment.DockerVersion = getDockerVersionFromVariables(variables)
+		deployment.ReleaseName = name
+		deployment.PreviousProductVersion = environment.ProductVersion
+		deployment.PreviousProductVersionID = environment.ProductVersionID
+		deploymentID, _ := appContext.Repositories.DeploymentDAO.CreateDeployment(deployment)
+
+		//The consumer of the queue only reads the variables
+		upgradeRequest.Variables = helmSetArgs(values)
+
+		queuePayload := rabbitmq.PayloadRabbit{
+			UpgradeRequest: upgradeRequest,
+			Name:           environment.Name,
+			Token:          environment.Token,
+			Filename:       appContext.K8sConfigPath + environment.Group + "_" + environment.Name,
+			CACertificate:  environment.CACertificate,
+			ClusterURI:     environment.ClusterURI,
+			Namespace:      environment.Namespace,
+			DeploymentID:   uint(deploymentID),
+		}
 
-	helmVars, err := appContext.getHelmChartAppVars(installPayload.Chart, installPayload.ChartVersion)
+		queuePayloadJSON, _ := json.Marshal(queuePayload)
+
+		err := appContext.RabbitImpl.Publish(
+			appContext.RabbitMQChannel,
+			"",
+			rabbitmq.InstallQueue,
+			false,
+			false,
+			amqp.Publishing{
+				ContentType: "application/json",
+				Body:        queuePayloadJSON,
+			},
+		)
+		return "", err
+	}
+	masked, err := yaml.Marshal(maskValues(values, secrets))
 	if err != nil {
 		return "", err
 	}
-	args := appContext.getArgsWithHelmDefault(variables, helmVars, globalVariables, environment)
+	return getHelmMessage(name, masked, environment, installPayload.Chart), nil
+}
 
-	//Add Default Gateway
-	if len(environment.Gateway) > 0 {
-		args = append(args, "istio.virtualservices.gateways[0]="+environment.Gateway)
+//helmSetArgs lists a values document as helm --set arguments
+func helmSetArgs(values map[string]interface{}) []string {
+	flatValues := make(map[string]string)
+	flattenValues("", values, flatValues)
+	args := make([]string, 0, len(flatValues))
+	for key, value := range flatValues {
+		args = append(args, key+"="+value)
 	}
+	sort.Strings(args)
+	return args
+}
 
-	if err == nil {
-		name := installPayload.Name + "-" + environment.Namespace
-		kubeConfig := appContext.ConventionInterface.GetKubeConfigFileName(environment.Group, environment.Name)
-
-		if !helmCommandOnly {
-
-			upgradeRequest := helmapi.UpgradeRequest{}
-			upgradeRequest.Kubeconfig = kubeConfig
-			upgradeRequest.Namespace = environment.Namespace
-			upgradeRequest.ChartVersion = installPayload.ChartVersion
-			upgradeRequest.Chart = installPayload.Chart
-			upgradeRequest.Variables = args
-			upgradeRequest.Dryrun = dryRun
-			upgradeRequest.Release = name
-
-			if dryRun {
-				return appContext.doUpgrade(upgradeRequest, out)
-			}
+//releaseValues builds the values document a release installs, with the secret values it holds
+func (appContext *AppContext) releaseValues(environment *model.Environment, chart string, chartVersion string,
+	variables []model.Variable, globalVariables []model.Variable) (map[string]interface{}, *secretSet, error) {
 
-			deployment := model.Deployment{}
-			deployment.EnvironmentID = environment.ID
-			deployment.RequestDeploymentID = uint(requestDeploymentID)
-			deployment.Chart = installPayload.Chart
-			deployment.Processed = false
-			deployment.ChartVersion = installPayload.ChartVersion
-			deployment.DockerVersion = getDockerVersionFromVariables(variables)
-			deploymentID, _ := appContext.Repositories.DeploymentDAO.CreateDeployment(deployment)
-
-			queuePayload := rabbitmq.PayloadRabbit{
-				UpgradeRequest: upgradeRequest,
-				Name:           environment.Name,
-				Token:          environment.Token,
-				Filename:       appContext.K8sConfigPath + environment.Group + "_" + environment.Name,
-				CACertificate:  environment.CACertificate,
-				ClusterURI:     environment.ClusterURI,
-				Namespace:      environment.Namespace,
-				DeploymentID:   uint(deploymentID),
-			}
+	helmVars, err := appContext.getHelmChartAppVars(chart, chartVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, secrets, err := appContext.getValuesWithHelmDefault(variables, helmVars, globalVariables, environment)
+	if err != nil {
+		return nil, nil, err
+	}
 
-			queuePayloadJSON, _ := json.Marshal(queuePayload)
-
-			err := appContext.RabbitImpl.Publish(
-				appContext.RabbitMQChannel,
-				"",
-				rabbitmq.InstallQueue,
-				false,
-				false,
-				amqp.Publishing{
-					ContentType: "application/json",
-					Body:        queuePayloadJSON,
-				},
-			)
-			return "", err
+	//Add Default Gateway
+	if len(environment.Gateway) > 0 {
+		if err := setValue(values, "istio.virtualservices.gateways[0]", environment.Gateway); err != nil {
+			return nil, nil, err
 		}
-		return getHelmMessage(name, args, environment, installPayload.Chart), nil
 	}
-	return "", nil
+	return values, secrets, nil
+}
+
+//releaseSearchTerm is the scope of the variables of a release
+func releaseSearchTerm(installPayload model.InstallPayload) string {
+	if strings.Index(installPayload.Name, "gcm") > -1 {
+		return installPayload.Name
+	}
+	return installPayload.Chart
 }
 
 func getDockerVersionFromVariables(vars []model.Variable) string {
@@ -787,30 +834,17 @@ func (appContext *AppContext) doUpgrade(upgradeRequest helmapi.UpgradeRequest, o
 	return "", nil
 }
 
-func getHelmMessage(name string, args []string, environment *model.Environment, chart string) string {
-	var message string
-
-	message = "helm upgrade --install " + name + " \\\n"
-
-	for _, e := range args {
-		message = message + " --set \"" + e + "\" " + " \\\n"
-	}
-	message = message + " " + chart + " --namespace=" + environment.Namespace
-	return message
-}
+//doMaskedUpgrade runs an upgrade whose output is shown to the user, so secrets are masked in it
+func (appContext *AppContext) doMaskedUpgrade(upgradeRequest helmapi.UpgradeRequest, out *bytes.Buffer,
+	secrets *secretSet) (string, error) {
 
-func replace(value string, environment model.Environment, variables []model.Variable) string {
-	newValue := strings.Replace(value, "${NAMESPACE}", environment.Namespace, -1)
-	keywords := util.GetReplacebleKeyName(newValue)
-	for _, keyword := range keywords {
-		for _, element := range variables {
-			if element.Name == keyword {
-				newValue = strings.Replace(newValue, "${"+element.Name+"}", element.Value, -1)
-				break
-			}
-		}
+	upgradeOut := &bytes.Buffer{}
+	result, err := appContext.doUpgrade(upgradeRequest, upgradeOut)
+	out.WriteString(maskSecrets(upgradeOut.String(), secrets))
+	if err != nil {
+		return "", errors.New(maskSecrets(err.Error(), secrets))
 	}
-	return newValue
+	return result, nil
 }
 
 func normalizeVariableName(value string) string {
@@ -820,8 +854,8 @@ func normalizeVariableName(value string) string {
 	return "app." + value
 }
 
-func (appContext *AppContext) getGlobalVariables(id int) []model.Variable {
-	variables, _ := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironmentAndScope(id, "global")
+func (appContext *AppContext) getGlobalVariables(environment *model.Environment) []model.Variable {
+	variables, _ := appContext.getEffectiveVariables(environment, "global")
 
 	for i, e := range variables {
 		if e.Secret {
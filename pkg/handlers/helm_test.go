@@ -601,10 +601,10 @@ func TestGetHelmCommand(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
 
 	response := string(rr.Body.Bytes())
-	assert.Contains(t, response, "helm upgrade --install my-chart-dev")
-	assert.Contains(t, response, "--set \"app.username=user")
-	assert.Contains(t, response, "istio.virtualservices.gateways[0]=my-gateway.istio-system.svc.cluster.local")
-	assert.Contains(t, response, "repo/my-chart - 0.1.0 --namespace=dev")
+	assert.Contains(t, response, "cat > my-chart-dev-values.yaml <<'EOF'\n")
+	assert.Contains(t, response, "  username: user\n")
+	assert.Contains(t, response, "    gateways:\n    - my-gateway.istio-system.svc.cluster.local\n")
+	assert.Contains(t, response, "helm upgrade --install my-chart-dev -f my-chart-dev-values.yaml repo/my-chart - 0.1.0 --namespace=dev")
 }
 
 func TestGetHelmCommand_UnmarshalPayloadError(t *testing.T) {
@@ -725,6 +725,8 @@ func TestMultipleInstall(t *testing.T) {
 	mockProductDAO.On("FindProductByID", 999).Return(product, nil)
 	appContext.Repositories.ProductDAO = mockProductDAO
 
+	mockListVariableRules(&appContext)
+
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(appContext.multipleInstall)
 
@@ -734,7 +736,7 @@ func TestMultipleInstall(t *testing.T) {
 
 	mockEnvDao.AssertNumberOfCalls(t, "GetByID", 1)
 	mockConvention.AssertNumberOfCalls(t, "GetKubeConfigFileName", 1)
-	mockVariableDAO.AssertNumberOfCalls(t, "GetAllVariablesByEnvironmentAndScope", 2)
+	mockVariableDAO.AssertNumberOfCalls(t, "GetAllVariablesByEnvironmentAndScope", 3)
 	mockHelmSvc.AssertNumberOfCalls(t, "Upgrade", 0)
 	mockAudit.AssertNumberOfCalls(t, "DoAudit", 1)
 
@@ -812,13 +814,15 @@ func TestInstall(t *testing.T) {
 
 	appContext.RabbitImpl = getMockRabbitMQ()
 
+	mockListVariableRules(&appContext)
+
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(appContext.install)
 	handler.ServeHTTP(rr, req)
 
 	mockEnvDao.AssertNumberOfCalls(t, "GetByID", 1)
 	mockConvention.AssertNumberOfCalls(t, "GetKubeConfigFileName", 1)
-	mockVariableDAO.AssertNumberOfCalls(t, "GetAllVariablesByEnvironmentAndScope", 2)
+	mockVariableDAO.AssertNumberOfCalls(t, "GetAllVariablesByEnvironmentAndScope", 3)
 	mockHelmSvc.AssertNumberOfCalls(t, "Upgrade", 0)
 
 	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/util"
+	"k8s.io/helm/pkg/strvals"
+)
+
+//emptyValue is stored in place of an empty string, which would otherwise mean the variable is unset
+const emptyValue = "T_EMPTY"
+
+//getValuesWithHelmDefault builds the values document of a release from the variables,
+//completed with the app values of the chart that have no variable. It also tells the secrets
+//in the document, which must not be shown to the user.
+func (appContext *AppContext) getValuesWithHelmDefault(variables []model.Variable, helmVars map[string]interface{},
+	globalVariables []model.Variable, environment *model.Environment) (map[string]interface{}, *secretSet, error) {
+
+	values := make(map[string]interface{})
+	secrets := newSecretSet()
+	var keys []string
+	interpolator := appContext.newInterpolator(environment, globalVariables)
+	for _, item := range variables {
+		if len(item.Name) == 0 || len(item.Value) == 0 {
+			continue
+		}
+		item = appContext.decryptVariable(item)
+		result := interpolate(interpolator, interpolationOrigin(item), item.Value)
+		value := result.Value
+		key := normalizeVariableName(item.Name)
+		if value != "" {
+			keys = append(keys, key)
+		}
+
+		var typed interface{} = ""
+		if value != emptyValue {
+			var err error
+			if typed, err = typedValue(item.Type, value); err != nil {
+				return nil, nil, errors.New("Invalid value for " + item.Scope + "/" + item.Name + ": " + err.Error())
+			}
+		}
+		if item.Secret || result.Secret {
+			secrets.add(key, value, typed)
+		}
+		if err := setValue(values, key, typed); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := setValue(values, "app.dateHour", time.Now().String()); err != nil {
+		return nil, nil, err
+	}
+	keys = append(keys, "app.dateHour")
+
+	for key, value := range helmVars {
+		if !util.Contains(keys, normalizeVariableName(key)) {
+			svalue, ok := value.(string)
+			if ok {
+				result := interpolate(interpolator, "", svalue)
+				if result.Secret {
+					secrets.add(normalizeVariableName(key), result.Value, nil)
+				}
+				if err := setValue(values, normalizeVariableName(key), inferredValue(result.Value)); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	return values, secrets, nil
+}
+
+//setValue sets the value at a key written as in helm --set (a.b, a.b[0])
+func setValue(values map[string]interface{}, key string, value interface{}) error {
+	return strvals.ParseIntoFile(key+"=-", values, func([]rune) (interface{}, error) {
+		return value, nil
+	})
+}
+
+//typedValue converts a value to the type of its variable
+func typedValue(variableType string, value string) (interface{}, error) {
+	switch variableType {
+	case "":
+		return inferredValue(value), nil
+	case model.VariableTypeString, model.VariableTypeMultiline:
+		return value, nil
+	case model.VariableTypeInt:
+		return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	case model.VariableTypeFloat:
+		return strconv.ParseFloat(strings.TrimSpace(value), 64)
+	case model.VariableTypeBool:
+		return strconv.ParseBool(strings.TrimSpace(value))
+	case model.VariableTypeList:
+		var list []interface{}
+		if err := yaml.Unmarshal([]byte(value), &list); err != nil {
+			return nil, errors.New("not a list - " + err.Error())
+		}
+		return list, nil
+	case model.VariableTypeMap:
+		var object map[string]interface{}
+		if err := yaml.Unmarshal([]byte(value), &object); err != nil {
+			return nil, errors.New("not a map - " + err.Error())
+		}
+		return object, nil
+	}
+	return nil, errors.New("unknown type " + variableType)
+}
+
+//inferredValue types an untyped value the way helm --set does
+func inferredValue(value string) interface{} {
+	switch {
+	case strings.EqualFold(value, "true"):
+		return true
+	case strings.EqualFold(value, "false"):
+		return false
+	case strings.EqualFold(value, "null"):
+		return nil
+	case value == "0":
+		return int64(0)
+	case len(value) > 0 && value[0] != '0':
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	}
+	return value
+}
+
+//validateVariableType checks the value of a typed variable. Values holding references are only
+//checked on install, once interpolated.
+func validateVariableType(variable model.Variable) error {
+	if variable.Type == "" {
+		return nil
+	}
+	if !util.Contains(model.VariableTypes, variable.Type) {
+		return errors.New("Invalid type " + variable.Type + " for " + variable.Name)
+	}
+	if variable.Value == "" || variable.Value == emptyValue || strings.Contains(variable.Value, "${") {
+		return nil
+	}
+	if _, err := typedValue(variable.Type, variable.Value); err != nil {
+		return errors.New("Invalid value for " + variable.Name + ": " + err.Error())
+	}
+	return nil
+}
+
+//getHelmMessage writes the helm command equivalent to an install, with the values in a file
+func getHelmMessage(name string, values []byte, environment *model.Environment, chart string) string {
+	file := name + "-values.yaml"
+	message := "cat > " + file + " <<'EOF'\n" + string(values) + "EOF\n"
+	message = message + "helm upgrade --install " + name + " -f " + file + " " + chart +
+		" --namespace=" + environment.Namespace
+	return message
+}
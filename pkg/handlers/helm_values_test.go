@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/softplan/tenkai-api/pkg/configs"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTypedValue(t *testing.T) {
+	tests := []struct {
+		variableType string
+		value        string
+		want         interface{}
+		wantErr      bool
+	}{
+		{"", "true", true, false},
+		{"", "8080", int64(8080), false},
+		{"", "0800", "0800", false},
+		{"", "a,b=c", "a,b=c", false},
+		{"string", "true", "true", false},
+		{"string", "8080", "8080", false},
+		{"multiline", "a\nb", "a\nb", false},
+		{"int", " 42 ", int64(42), false},
+		{"int", "4.2", nil, true},
+		{"float", "4.2", 4.2, false},
+		{"bool", "FALSE", false, false},
+		{"bool", "yes", nil, true},
+		{"list", "[a, 1]", []interface{}{"a", float64(1)}, false},
+		{"list", "- a\n- b\n", []interface{}{"a", "b"}, false},
+		{"list", "a: b", nil, true},
+		{"map", `{"a": {"b": [1]}}`, map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{float64(1)}}}, false},
+		{"map", "[a]", nil, true},
+		{"date", "2020", nil, true},
+	}
+
+	for _, tt := range tests {
+		value, err := typedValue(tt.variableType, tt.value)
+		if tt.wantErr {
+			assert.Error(t, err, tt.variableType+" "+tt.value)
+			continue
+		}
+		assert.NoError(t, err, tt.variableType+" "+tt.value)
+		assert.Equal(t, tt.want, value, tt.variableType+" "+tt.value)
+	}
+}
+
+func TestValidateVariableType(t *testing.T) {
+	assert.NoError(t, validateVariableType(model.Variable{Name: "port", Value: "abc"}))
+	assert.NoError(t, validateVariableType(model.Variable{Name: "port", Value: "8080", Type: "int"}))
+	assert.NoError(t, validateVariableType(model.Variable{Name: "port", Value: "${port}", Type: "int"}))
+	assert.NoError(t, validateVariableType(model.Variable{Name: "port", Value: "T_EMPTY", Type: "int"}))
+	assert.EqualError(t, validateVariableType(model.Variable{Name: "port", Value: "8080", Type: "long"}),
+		"Invalid type long for port")
+	assert.Error(t, validateVariableType(model.Variable{Name: "port", Value: "abc", Type: "int"}))
+}
+
+func TestGetValuesWithHelmDefault(t *testing.T) {
+	appContext := AppContext{}
+	appContext.Configuration = &configs.Configuration{App: configs.App{Passkey: "qwert"}}
+	environment := mockGetEnv()
+
+	variables := []model.Variable{
+		{Scope: "repo/foo", Name: "hosts", Value: "a.com,b.com", Type: model.VariableTypeString},
+		{Scope: "repo/foo", Name: "replicas", Value: "3", Type: model.VariableTypeInt},
+		{Scope: "repo/foo", Name: "version", Value: "1", Type: model.VariableTypeString},
+		{Scope: "repo/foo", Name: "args", Value: `["--a=1", "--b"]`, Type: model.VariableTypeList},
+		{Scope: "repo/foo", Name: "empty", Value: "T_EMPTY"},
+		{Scope: "repo/foo", Name: "image.tag", Value: "1.0.0"},
+		{Scope: "repo/foo", Name: "env[0].name", Value: "LANG"},
+	}
+
+	values, _, err := appContext.getValuesWithHelmDefault(variables, map[string]interface{}{"replicas": "1", "debug": "false"},
+		nil, &environment)
+	assert.NoError(t, err)
+
+	app := values["app"].(map[string]interface{})
+	assert.Equal(t, "a.com,b.com", app["hosts"])
+	assert.Equal(t, int64(3), app["replicas"])
+	assert.Equal(t, "1", app["version"])
+	assert.Equal(t, []interface{}{"--a=1", "--b"}, app["args"])
+	assert.Equal(t, "", app["empty"])
+	assert.Equal(t, false, app["debug"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "LANG"}}, app["env"])
+	assert.Equal(t, "1.0.0", values["image"].(map[string]interface{})["tag"])
+
+	document, err := yaml.Marshal(values)
+	assert.NoError(t, err)
+	assert.Contains(t, string(document), "version: \"1\"\n")
+
+	variables = append(variables, model.Variable{Scope: "repo/foo", Name: "port", Value: "http", Type: model.VariableTypeInt})
+	_, _, err = appContext.getValuesWithHelmDefault(variables, nil, nil, &environment)
+	assert.EqualError(t, err, "Invalid value for repo/foo/port: strconv.ParseInt: parsing \"http\": invalid syntax")
+}
+
+func TestEditVariable_InvalidType(t *testing.T) {
+	appContext := AppContext{}
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	var element model.DataVariableElement
+	element.Data = model.Variable{EnvironmentID: 999, Scope: "repo/foo", Name: "replicas", Value: "three", Type: "int"}
+
+	req, _ := http.NewRequest("POST", "/variables/edit", payload(element))
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(appContext.editVariable).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	mockVariableDAO.AssertNotCalled(t, "EditVariable", mock.Anything)
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+func (appContext *AppContext) newHotFixVersion(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	baseID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.HotFixRequest
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if strings.TrimSpace(payload.Version) == "" {
+		http.Error(w, "Hotfix version is required", http.StatusBadRequest)
+		return
+	}
+
+	base, err := appContext.Repositories.ProductDAO.ListProductVersionsByID(baseID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if base.State != "" && base.State != model.ProductVersionReleased && base.State != model.ProductVersionDeprecated {
+		http.Error(w, "Product version is "+base.State+", hotfixes can only be created from released versions",
+			http.StatusBadRequest)
+		return
+	}
+
+	versions, err := appContext.Repositories.ProductDAO.ListProductsVersions(base.ProductID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, v := range versions {
+		if v.Version == payload.Version {
+			http.Error(w, "Product version "+payload.Version+" already exists", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := appContext.proposeHotFix(base, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !payload.DryRun {
+		for _, s := range result.Services {
+			if s.TagNotFound {
+				http.Error(w, "No hotfix tag found for "+s.ServiceName, http.StatusBadRequest)
+				return
+			}
+		}
+
+		if result.ProductVersionID, err = appContext.saveHotFixVersion(base, result); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		auditValues := make(map[string]string)
+		auditValues["productVersionId"] = strconv.Itoa(result.ProductVersionID)
+		auditValues["productVersion"] = result.Version
+		auditValues["baseVersionId"] = strconv.Itoa(baseID)
+		auditValues["baseVersion"] = base.Version
+		auditValues["fixedServices"] = strings.Join(fixedHotFixServices(result.Services), ",")
+		appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "newHotFixVersion", auditValues)
+
+		go appContext.triggerNewReleaseWebhook(base.ProductID, result.Version, result.ProductVersionID)
+	}
+
+	data, _ := json.Marshal(result)
+	if payload.DryRun {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	w.Write(data)
+}
+
+//proposeHotFix keeps the tags of the base version, replacing the ones of the selected services
+//by the given tag or by the next hotfix tag found in the registry
+func (appContext *AppContext) proposeHotFix(base *model.ProductVersion,
+	payload model.HotFixRequest) (*model.HotFixResponse, error) {
+
+	services, err := appContext.Repositories.ProductDAO.ListProductsVersionServices(int(base.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]string)
+	for _, s := range payload.Services {
+		selected[splitSrvNameIfNeeded(s.ServiceName)] = s.DockerImageTag
+	}
+
+	scheme, err := appContext.getVersionScheme(base.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.HotFixResponse{
+		DryRun:        payload.DryRun,
+		BaseVersionID: int(base.ID),
+		BaseVersion:   base.Version,
+		Version:       payload.Version,
+		Services:      make([]model.HotFixService, 0),
+	}
+
+	for _, s := range services {
+		name := splitSrvNameIfNeeded(s.ServiceName)
+		item := model.HotFixService{
+			ServiceName:    s.ServiceName,
+			BaseImageTag:   s.DockerImageTag,
+			DockerImageTag: s.DockerImageTag,
+		}
+
+		tag, ok := selected[name]
+		if ok {
+			delete(selected, name)
+			item.Fixed = true
+			if tag == "" {
+				tag, err = appContext.verifyNewVersion(name, s.DockerImageTag, payload.Version, true, scheme)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if tag == "" {
+				item.TagNotFound = true
+			} else {
+				item.DockerImageTag = tag
+			}
+		}
+
+		result.Services = append(result.Services, item)
+	}
+
+	if len(selected) > 0 {
+		unknown := make([]string, 0)
+		for name := range selected {
+			unknown = append(unknown, name)
+		}
+		sort.Strings(unknown)
+		return nil, errors.New("Services not part of product version " + base.Version + ": " + strings.Join(unknown, ", "))
+	}
+
+	return result, nil
+}
+
+//saveHotFixVersion creates the hotfix version released and ready to be deployed, with its services
+func (appContext *AppContext) saveHotFixVersion(base *model.ProductVersion, result *model.HotFixResponse) (int, error) {
+
+	pv := model.ProductVersion{
+		ProductID:     base.ProductID,
+		Date:          time.Now(),
+		Version:       result.Version,
+		HotFix:        true,
+		Locked:        true,
+		State:         model.ProductVersionReleased,
+		BaseVersionID: int(base.ID),
+	}
+
+	services := make([]model.ProductVersionService, 0, len(result.Services))
+	for _, s := range result.Services {
+		services = append(services, model.ProductVersionService{
+			ServiceName:    s.ServiceName,
+			DockerImageTag: s.DockerImageTag,
+		})
+	}
+
+	return appContext.Repositories.ProductDAO.CreateProductVersionWithServices(pv, services)
+}
+
+func fixedHotFixServices(services []model.HotFixService) []string {
+	fixed := make([]string, 0)
+	for _, s := range services {
+		if s.Fixed {
+			fixed = append(fixed, s.ServiceName+"="+s.DockerImageTag)
+		}
+	}
+	return fixed
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getHotFixAppContext(state string) (*AppContext, *mockRepo.ProductDAOInterface) {
+	appContext := AppContext{}
+
+	base := model.ProductVersion{ProductID: 1, Version: "20.1.1-1", State: state}
+	base.ID = 10
+
+	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 10).Return(&base, nil)
+	mockProductDAO.On("ListProductsVersions", 1).Return([]model.ProductVersion{base}, nil)
+	mockProductDAO.On("ListProductsVersionServices", 10).Return([]model.ProductVersionService{
+		{ServiceName: "repo/foo - 0.1.0", DockerImageTag: "20.1.1-15.5"},
+		{ServiceName: "repo/bar - 0.1.0", DockerImageTag: "20.1.1-3"},
+		{ServiceName: "repo/baz - 0.2.0", DockerImageTag: "20.1.1-7"},
+	}, nil)
+	mockProductDAO.On("ListProductsVersionServices", 20).Return([]model.ProductVersionService{}, nil)
+	mockProductDAO.On("FindProductByID", 1).Return(model.Product{Name: "My Product"}, nil)
+	mockProductDAO.On("CreateProductVersionWithServices", mock.Anything, mock.Anything).Return(20, nil)
+	appContext.Repositories.ProductDAO = mockProductDAO
+
+	mockWebHookDAO := &mockRepo.WebHookDAOInterface{}
+	mockWebHookDAO.On("ListWebHooksByEnvAndType", -1, "HOOK_NEW_RELEASE").Return([]model.WebHook{}, nil)
+	appContext.Repositories.WebHookDAO = mockWebHookDAO
+
+	appContext.ChartImageCache.Store("repo/foo", "myrepo.com/foo")
+	mockGetDockerTagsWithDate(&appContext, getTagResponse("20.1.1-15.6"))
+
+	return &appContext, mockProductDAO
+}
+
+func doNewHotFixVersion(appContext *AppContext, request model.HotFixRequest) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/productVersions/10/hotfix", payload(request))
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/productVersions/{id}/hotfix", appContext.newHotFixVersion).Methods("POST")
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestNewHotFixVersion(t *testing.T) {
+	appContext, mockProductDAO := getHotFixAppContext(model.ProductVersionReleased)
+
+	auditValues := map[string]string{"productVersionId": "20", "productVersion": "20.1.1-1.1",
+		"baseVersionId": "10", "baseVersion": "20.1.1-1",
+		"fixedServices": "repo/foo - 0.1.0=20.1.1-15.6,repo/baz - 0.2.0=20.1.1-7.1"}
+	mockAudit := mockDoAudit(appContext, "newHotFixVersion", auditValues)
+
+	rr := doNewHotFixVersion(appContext, model.HotFixRequest{Version: "20.1.1-1.1", Services: []model.HotFixServiceRequest{
+		{ServiceName: "repo/foo"},
+		{ServiceName: "repo/baz - 0.2.0", DockerImageTag: "20.1.1-7.1"},
+	}})
+	assert.Equal(t, http.StatusCreated, rr.Code, "Response should be Created.")
+
+	var result model.HotFixResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, 20, result.ProductVersionID)
+	assert.Equal(t, []model.HotFixService{
+		{ServiceName: "repo/foo - 0.1.0", BaseImageTag: "20.1.1-15.5", DockerImageTag: "20.1.1-15.6", Fixed: true},
+		{ServiceName: "repo/bar - 0.1.0", BaseImageTag: "20.1.1-3", DockerImageTag: "20.1.1-3"},
+		{ServiceName: "repo/baz - 0.2.0", BaseImageTag: "20.1.1-7", DockerImageTag: "20.1.1-7.1", Fixed: true},
+	}, result.Services)
+
+	mockProductDAO.AssertCalled(t, "CreateProductVersionWithServices", mock.MatchedBy(func(pv model.ProductVersion) bool {
+		return pv.ProductID == 1 && pv.Version == "20.1.1-1.1" && pv.HotFix && pv.Locked &&
+			pv.State == model.ProductVersionReleased && pv.BaseVersionID == 10
+	}), mock.Anything)
+	var services []model.ProductVersionService
+	for _, call := range mockProductDAO.Calls {
+		if call.Method == "CreateProductVersionWithServices" {
+			services = call.Arguments.Get(1).([]model.ProductVersionService)
+		}
+	}
+	assert.Equal(t, 3, len(services))
+	assert.Equal(t, model.ProductVersionService{ServiceName: "repo/bar - 0.1.0", DockerImageTag: "20.1.1-3"}, services[1])
+	mockAudit.AssertNumberOfCalls(t, "DoAudit", 1)
+}
+
+func TestNewHotFixVersion_DryRunTagNotFound(t *testing.T) {
+	appContext, mockProductDAO := getHotFixAppContext("")
+	mockGetDockerTagsWithDate(appContext, getTagResponse("20.1.2-0"))
+
+	rr := doNewHotFixVersion(appContext, model.HotFixRequest{Version: "20.1.1-1.1", DryRun: true,
+		Services: []model.HotFixServiceRequest{{ServiceName: "repo/foo"}}})
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var result model.HotFixResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.True(t, result.DryRun)
+	assert.True(t, result.Services[0].TagNotFound)
+	assert.Equal(t, "20.1.1-15.5", result.Services[0].DockerImageTag)
+
+	mockProductDAO.AssertNotCalled(t, "CreateProductVersionWithServices", mock.Anything, mock.Anything)
+
+	rr = doNewHotFixVersion(appContext, model.HotFixRequest{Version: "20.1.1-1.1",
+		Services: []model.HotFixServiceRequest{{ServiceName: "repo/foo"}}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	mockProductDAO.AssertNotCalled(t, "CreateProductVersionWithServices", mock.Anything, mock.Anything)
+}
+
+func TestNewHotFixVersion_BaseNotReleased(t *testing.T) {
+	appContext, mockProductDAO := getHotFixAppContext(model.ProductVersionCandidate)
+
+	rr := doNewHotFixVersion(appContext, model.HotFixRequest{Version: "20.1.1-1.1"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	mockProductDAO.AssertNotCalled(t, "CreateProductVersionWithServices", mock.Anything, mock.Anything)
+}
+
+func TestNewHotFixVersion_UnknownService(t *testing.T) {
+	appContext, _ := getHotFixAppContext(model.ProductVersionReleased)
+
+	rr := doNewHotFixVersion(appContext, model.HotFixRequest{Version: "20.1.1-1.1",
+		Services: []model.HotFixServiceRequest{{ServiceName: "repo/other"}}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	assert.Contains(t, rr.Body.String(), "repo/other")
+}
+
+func TestNewHotFixVersion_VersionExists(t *testing.T) {
+	appContext, _ := getHotFixAppContext(model.ProductVersionReleased)
+
+	rr := doNewHotFixVersion(appContext, model.HotFixRequest{Version: "20.1.1-1"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	assert.Contains(t, rr.Body.String(), "already exists")
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+var diffableKinds = []string{"Deployment", "Service", "ConfigMap", "VirtualService"}
+
+var manifestSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+type manifestResource struct {
+	Kind    string
+	Name    string
+	Content string
+}
+
+type manifestHeader struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+}
+
+func (appContext *AppContext) helmManifestDiff(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+	var payload model.InstallPayload
+
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), 501)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	has, err := appContext.hasAccess(principal.Email, payload.EnvironmentID)
+	if err != nil || !has {
+		http.Error(w, errors.New("Access Denied in this environment").Error(), http.StatusUnauthorized)
+		return
+	}
+
+	//Locate Environment
+	environment, err := appContext.Repositories.EnvironmentDAO.GetByID(payload.EnvironmentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	out := &bytes.Buffer{}
+	if _, err = appContext.simpleInstall(environment, payload, out, true, false, "", -1); err != nil {
+		http.Error(w, err.Error(), 501)
+		return
+	}
+
+	releaseName := payload.Name + "-" + environment.Namespace
+	kubeConfig := appContext.ConventionInterface.GetKubeConfigFileName(environment.Group, environment.Name)
+
+	live, err := appContext.HelmServiceAPI.GetManifest(kubeConfig, releaseName, 0)
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		live = ""
+	}
+
+	secrets, err := appContext.releaseSecrets(environment, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := diffManifests(maskSecrets(live, secrets), extractManifest(out.String()))
+	result.Release = releaseName
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//extractManifest returns the rendered manifest printed by a dry-run upgrade
+func extractManifest(output string) string {
+	idx := strings.LastIndex(output, "MANIFEST:\n")
+	if idx < 0 {
+		return ""
+	}
+	manifest := output[idx+len("MANIFEST:\n"):]
+	if end := strings.Index(manifest, "\nRelease \""); end > -1 {
+		manifest = manifest[:end]
+	}
+	return manifest
+}
+
+//splitManifest splits a multi document manifest into the resources we are able to diff
+func splitManifest(manifest string) map[string]manifestResource {
+	resources := make(map[string]manifestResource)
+	for _, doc := range manifestSeparator.Split(manifest, -1) {
+		content := strings.TrimSpace(doc)
+		if content == "" {
+			continue
+		}
+		var header manifestHeader
+		if err := yaml.Unmarshal([]byte(content), &header); err != nil {
+			continue
+		}
+		if !util.Contains(diffableKinds, header.Kind) {
+			continue
+		}
+		resources[header.Kind+"/"+header.Metadata.Name] = manifestResource{
+			Kind:    header.Kind,
+			Name:    header.Metadata.Name,
+			Content: content + "\n",
+		}
+	}
+	return resources
+}
+
+func diffManifests(live string, pending string) model.ManifestDiffResult {
+	result := model.ManifestDiffResult{
+		Added:   make([]model.ManifestResourceDiff, 0),
+		Removed: make([]model.ManifestResourceDiff, 0),
+		Changed: make([]model.ManifestResourceDiff, 0),
+	}
+
+	liveResources := splitManifest(live)
+	pendingResources := splitManifest(pending)
+
+	for _, key := range sortedResourceKeys(pendingResources) {
+		newRes := pendingResources[key]
+		oldRes, ok := liveResources[key]
+		if !ok {
+			result.Added = append(result.Added, resourceDiff(newRes, "", newRes.Content))
+			continue
+		}
+		if oldRes.Content != newRes.Content {
+			result.Changed = append(result.Changed, resourceDiff(newRes, oldRes.Content, newRes.Content))
+		}
+	}
+
+	for _, key := range sortedResourceKeys(liveResources) {
+		if _, ok := pendingResources[key]; !ok {
+			oldRes := liveResources[key]
+			result.Removed = append(result.Removed, resourceDiff(oldRes, oldRes.Content, ""))
+		}
+	}
+
+	return result
+}
+
+func sortedResourceKeys(resources map[string]manifestResource) []string {
+	keys := make([]string, 0, len(resources))
+	for k := range resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func resourceDiff(res manifestResource, from string, to string) model.ManifestResourceDiff {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "live/" + res.Kind + "/" + res.Name,
+		ToFile:   "pending/" + res.Kind + "/" + res.Name,
+		Context:  3,
+	})
+	return model.ManifestResourceDiff{Kind: res.Kind, Name: res.Name, Diff: diff}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/softplan/tenkai-api/pkg/configs"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	mockSvc "github.com/softplan/tenkai-api/pkg/service/_helm/mocks"
+	"github.com/softplan/tenkai-api/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const liveManifest = `
+---
+# Source: foo/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: my-foo
+spec:
+  ports:
+  - port: 80
+---
+# Source: foo/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-foo
+spec:
+  replicas: 1
+---
+# Source: foo/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-foo-old
+`
+
+const pendingManifest = `
+---
+# Source: foo/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: my-foo
+spec:
+  ports:
+  - port: 80
+---
+# Source: foo/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-foo
+spec:
+  replicas: 3
+---
+# Source: foo/templates/virtualservice.yaml
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: my-foo
+---
+# Source: foo/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: my-foo
+`
+
+func TestDiffManifests(t *testing.T) {
+	result := diffManifests(liveManifest, pendingManifest)
+
+	assert.Equal(t, 1, len(result.Added))
+	assert.Equal(t, "VirtualService", result.Added[0].Kind)
+
+	assert.Equal(t, 1, len(result.Removed))
+	assert.Equal(t, "ConfigMap", result.Removed[0].Kind)
+	assert.Equal(t, "my-foo-old", result.Removed[0].Name)
+
+	assert.Equal(t, 1, len(result.Changed))
+	assert.Equal(t, "Deployment", result.Changed[0].Kind)
+	assert.Contains(t, result.Changed[0].Diff, "-  replicas: 1")
+	assert.Contains(t, result.Changed[0].Diff, "+  replicas: 3")
+}
+
+func TestExtractManifest(t *testing.T) {
+	output := "REVISION: 2\nMANIFEST:\n" + pendingManifest + "\nRelease \"my-foo-dev\" has been upgraded.\n"
+	assert.Equal(t, pendingManifest, extractManifest(output))
+	assert.Equal(t, "", extractManifest("UPGRADE FAILED"))
+}
+
+func getManifestDiffAppContext(liveErr error) (*AppContext, *mockSvc.HelmServiceInterface) {
+	appContext := AppContext{}
+	mockEnvDaoWithLotOfThings(&appContext)
+	mockGetAllVariablesByEnvironmentAndScope(&appContext)
+	mockConventionInterface(&appContext)
+	mockVariableRevisionDAO := mockCreateVariableRevision(&appContext)
+	mockVariableRevisionDAO.On("ListEnvironmentVariableRevisions", mock.Anything, mock.Anything).
+		Return([]model.VariableRevision{}, nil)
+
+	mockHelmSvc := &mockSvc.HelmServiceInterface{}
+	mockHelmSvc.On("SearchCharts", mock.Anything, false).Return(getCharts())
+	mockHelmSvc.On("GetTemplate", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]byte(`{"app":{"myvar":"myvalue"}}`), nil)
+	mockHelmSvc.On("Upgrade", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		out := args.Get(1).(*bytes.Buffer)
+		out.WriteString("MANIFEST:\n" + pendingManifest)
+	}).Return(nil)
+	mockHelmSvc.On("GetManifest", "./config/foo_bar", "my-foo-dev", 0).Return(liveManifest, liveErr)
+	appContext.HelmServiceAPI = mockHelmSvc
+
+	return &appContext, mockHelmSvc
+}
+
+func TestHelmManifestDiff(t *testing.T) {
+	req, err := http.NewRequest("POST", "/helmManifestDiff", getInstallPayload())
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	appContext, mockHelmSvc := getManifestDiffAppContext(nil)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.helmManifestDiff)
+	handler.ServeHTTP(rr, req)
+
+	mockHelmSvc.AssertNumberOfCalls(t, "Upgrade", 1)
+	mockHelmSvc.AssertNumberOfCalls(t, "GetManifest", 1)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+
+	var result model.ManifestDiffResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, "my-foo-dev", result.Release)
+	assert.Equal(t, 1, len(result.Added))
+	assert.Equal(t, 1, len(result.Removed))
+	assert.Equal(t, 1, len(result.Changed))
+}
+
+func TestHelmManifestDiffNewRelease(t *testing.T) {
+	req, err := http.NewRequest("POST", "/helmManifestDiff", getInstallPayload())
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	appContext, _ := getManifestDiffAppContext(errors.New(`release: "my-foo-dev" not found`))
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.helmManifestDiff)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+
+	var result model.ManifestDiffResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, 3, len(result.Added))
+	assert.Equal(t, 0, len(result.Removed))
+}
+
+func TestHelmManifestDiffLiveError(t *testing.T) {
+	req, err := http.NewRequest("POST", "/helmManifestDiff", getInstallPayload())
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	appContext, _ := getManifestDiffAppContext(errors.New("some error"))
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.helmManifestDiff)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestHelmManifestDiffMasksPreviousSecrets(t *testing.T) {
+	req, err := http.NewRequest("POST", "/helmManifestDiff", getInstallPayload())
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	appContext, mockHelmSvc := getManifestDiffAppContext(nil)
+	appContext.Configuration = &configs.Configuration{App: configs.App{Passkey: "qwert"}}
+	old := hex.EncodeToString(util.Encrypt([]byte("0ld-s3cr3t"), "qwert"))
+	mockVariableRevisionDAO := appContext.Repositories.VariableRevisionDAO.(*mockRepo.VariableRevisionDAOInterface)
+	mockVariableRevisionDAO.ExpectedCalls[1].ReturnArguments = mock.Arguments{
+		[]model.VariableRevision{{Scope: "foo", Name: "password", OldValue: old, Secret: true}}, nil}
+	live := strings.Replace(liveManifest, "  replicas: 1", "  replicas: 1\n  token: 0ld-s3cr3t", 1)
+	for _, call := range mockHelmSvc.ExpectedCalls {
+		if call.Method == "GetManifest" {
+			call.ReturnArguments = mock.Arguments{live, nil}
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.helmManifestDiff)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+	assert.NotContains(t, rr.Body.String(), "0ld-s3cr3t")
+
+	var result model.ManifestDiffResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, 1, len(result.Changed))
+	assert.Contains(t, result.Changed[0].Diff, "-  token: '******'")
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ghodss/yaml"
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+const importConflictSkip = "skip"
+const importConflictOverwrite = "overwrite"
+const importConflictRename = "rename"
+
+func (appContext *AppContext) exportProduct(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	versionID, _ := strconv.Atoi(r.URL.Query().Get("versionId"))
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "yaml" {
+		http.Error(w, "Invalid format: "+format, http.StatusBadRequest)
+		return
+	}
+
+	export, err := appContext.buildProductExport(id, versionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, _ := json.MarshalIndent(export, "", "  ")
+	if format == "yaml" {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(global.ContentType, "application/x-yaml; charset=UTF-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=product.yaml")
+	} else {
+		w.Header().Set(global.ContentType, global.JSONContentType)
+		w.Header().Set("Content-Disposition", "attachment; filename=product.json")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//buildProductExport exports a product with all its versions, or only the given one
+func (appContext *AppContext) buildProductExport(productID int, versionID int) (*model.ProductExport, error) {
+
+	product, err := appContext.Repositories.ProductDAO.FindProductByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := appContext.Repositories.ProductDAO.ListProductsVersions(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &model.ProductExport{
+		Name:             product.Name,
+		ValidateReleases: product.ValidateReleases,
+		VersionScheme:    product.VersionScheme,
+		VersionPattern:   product.VersionPattern,
+		Versions:         make([]model.ProductVersionExport, 0),
+	}
+
+	for _, version := range versions {
+		if versionID > 0 && int(version.ID) != versionID {
+			continue
+		}
+
+		services, err := appContext.Repositories.ProductDAO.ListProductsVersionServices(int(version.ID))
+		if err != nil {
+			return nil, err
+		}
+
+		item := model.ProductVersionExport{
+			Version:  version.Version,
+			Date:     version.Date,
+			Locked:   version.Locked,
+			HotFix:   version.HotFix,
+			State:    version.State,
+			Services: make([]model.ProductVersionServiceExport, 0),
+		}
+		for _, s := range services {
+			item.Services = append(item.Services, model.ProductVersionServiceExport{
+				Chart:          splitSrvNameIfNeeded(s.ServiceName),
+				ChartVersion:   splitChartVersion(s.ServiceName),
+				DockerImageTag: s.DockerImageTag,
+				Notes:          s.Notes,
+			})
+		}
+		export.Versions = append(export.Versions, item)
+	}
+
+	if versionID > 0 && len(export.Versions) == 0 {
+		return nil, errors.New("Product version not found")
+	}
+
+	return export, nil
+}
+
+func (appContext *AppContext) importProduct(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	onConflict := r.URL.Query().Get("onConflict")
+	if onConflict == "" {
+		onConflict = importConflictSkip
+	}
+	if onConflict != importConflictSkip && onConflict != importConflictOverwrite && onConflict != importConflictRename {
+		http.Error(w, "Invalid onConflict: "+onConflict, http.StatusBadRequest)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	body, err := util.GetHTTPBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//JSON documents are valid YAML, so both formats are accepted here
+	var payload model.ProductExport
+	if err := yaml.Unmarshal(body, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if payload.Name == "" {
+		http.Error(w, "Product name is required", http.StatusBadRequest)
+		return
+	}
+
+	report, err := appContext.doImportProduct(payload, onConflict, dryRun)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !dryRun {
+		auditValues := make(map[string]string)
+		auditValues["product"] = payload.Name
+		auditValues["onConflict"] = onConflict
+		auditValues["versions"] = strconv.Itoa(len(report.Versions))
+		appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "importProduct", auditValues)
+	}
+
+	data, _ := json.Marshal(report)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//doImportProduct imports the versions of a product, resolving conflicts with existing versions
+//of the same name. Everything is saved at once; on a dry run nothing is written and the report tells
+//what would be done.
+func (appContext *AppContext) doImportProduct(payload model.ProductExport, onConflict string,
+	dryRun bool) (*model.ProductImportReport, error) {
+
+	report := &model.ProductImportReport{
+		DryRun:   dryRun,
+		Product:  payload.Name,
+		Versions: make([]model.ProductImportResult, 0),
+	}
+
+	products, err := appContext.Repositories.ProductDAO.ListProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	productID := 0
+	for _, p := range products {
+		if p.Name == payload.Name {
+			productID = int(p.ID)
+			break
+		}
+	}
+
+	data := model.ProductImport{Product: model.Product{
+		Name:             payload.Name,
+		ValidateReleases: payload.ValidateReleases,
+		VersionScheme:    payload.VersionScheme,
+		VersionPattern:   payload.VersionPattern,
+	}}
+
+	existing := make(map[string]model.ProductVersion)
+	if productID == 0 {
+		report.ProductCreated = true
+	} else {
+		data.Product.ID = uint(productID)
+		versions, err := appContext.Repositories.ProductDAO.ListProductsVersions(productID)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			existing[v.Version] = v
+		}
+	}
+
+	notes := make(map[string]int)
+	for _, version := range payload.Versions {
+		result := model.ProductImportResult{
+			Version:    version.Version,
+			ImportedAs: version.Version,
+			Action:     "create",
+			Services:   len(version.Services),
+		}
+
+		current, conflict := existing[version.Version]
+		if conflict {
+			result.Conflict = true
+			result.Action = onConflict
+			if onConflict == importConflictRename {
+				result.ImportedAs = renameImportedVersion(version.Version, existing)
+			}
+			if onConflict == importConflictOverwrite {
+				if err := checkProductVersionEditable(&current); err != nil {
+					result.Action = importConflictSkip
+					result.Reason = err.Error()
+				}
+			}
+		}
+
+		if result.Action == importConflictSkip {
+			result.ImportedAs = ""
+			report.Versions = append(report.Versions, result)
+			continue
+		}
+
+		existing[result.ImportedAs] = model.ProductVersion{Version: result.ImportedAs}
+
+		//Imported versions start as draft, they are released through the lifecycle of this instance
+		pv := model.ProductVersion{
+			Date:    version.Date,
+			Version: result.ImportedAs,
+			HotFix:  version.HotFix,
+			State:   model.ProductVersionDraft,
+		}
+		if result.Action == importConflictOverwrite {
+			pv.Model = current.Model
+			pv.State = current.State
+		}
+
+		item := model.ProductVersionImport{Version: pv, Services: make([]model.ProductVersionService, 0)}
+		for _, s := range version.Services {
+			serviceName := s.Chart
+			if s.ChartVersion != "" {
+				serviceName = s.Chart + " - " + s.ChartVersion
+			}
+			item.Services = append(item.Services, model.ProductVersionService{
+				ServiceName:    serviceName,
+				DockerImageTag: s.DockerImageTag,
+				Notes:          s.Notes,
+			})
+			if err := appContext.importServiceNotes(&data, notes, serviceName, s.Notes,
+				result.Action == importConflictOverwrite); err != nil {
+				return nil, err
+			}
+		}
+		data.Versions = append(data.Versions, item)
+
+		report.Versions = append(report.Versions, result)
+	}
+
+	if !dryRun {
+		if _, err := appContext.Repositories.ProductDAO.ImportProduct(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+//importServiceNotes adds the notes of a service to the import unless it already has some. Service notes
+//are kept by chart name without version. added indexes the notes already in the import.
+func (appContext *AppContext) importServiceNotes(data *model.ProductImport, added map[string]int,
+	serviceName string, text string, overwrite bool) error {
+
+	if text == "" {
+		return nil
+	}
+	serviceName = splitSrvNameIfNeeded(serviceName)
+
+	if i, ok := added[serviceName]; ok {
+		if overwrite {
+			data.Notes[i].Text = text
+		}
+		return nil
+	}
+
+	notes, err := appContext.Repositories.NotesDAO.GetByServiceName(serviceName)
+	if err != nil {
+		return err
+	}
+
+	if notes.ID == 0 {
+		notes = &model.Notes{ServiceName: serviceName, Text: text}
+	} else if overwrite && notes.Text != text {
+		notes.Text = text
+	} else {
+		return nil
+	}
+	added[serviceName] = len(data.Notes)
+	data.Notes = append(data.Notes, *notes)
+	return nil
+}
+
+func renameImportedVersion(version string, existing map[string]model.ProductVersion) string {
+	name := version + "-imported"
+	for i := 2; ; i++ {
+		if _, ok := existing[name]; !ok {
+			return name
+		}
+		name = version + "-imported-" + strconv.Itoa(i)
+	}
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/gorilla/mux"
+	mockAud "github.com/softplan/tenkai-api/pkg/audit/mocks"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getExportProductDAO() *mockRepo.ProductDAOInterface {
+	product := model.Product{Name: "My Product", ValidateReleases: true}
+	product.ID = 1
+	first := model.ProductVersion{ProductID: 1, Version: "1.0.0", Locked: true}
+	first.ID = 10
+	second := model.ProductVersion{ProductID: 1, Version: "1.0.1", HotFix: true}
+	second.ID = 11
+
+	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("FindProductByID", 1).Return(product, nil)
+	mockProductDAO.On("ListProducts").Return([]model.Product{product}, nil)
+	mockProductDAO.On("ListProductsVersions", 1).Return([]model.ProductVersion{first, second}, nil)
+	mockProductDAO.On("ListProductsVersionServices", 10).Return([]model.ProductVersionService{
+		{ServiceName: "repo/foo - 0.1.0", DockerImageTag: "1.0.0", Notes: "some notes"},
+	}, nil)
+	mockProductDAO.On("ListProductsVersionServices", 11).Return([]model.ProductVersionService{
+		{ServiceName: "repo/foo - 0.1.1", DockerImageTag: "1.0.1"},
+	}, nil)
+	return mockProductDAO
+}
+
+func doExportProduct(appContext *AppContext, url string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", url, nil)
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/products/{id}/export", appContext.exportProduct).Methods("GET")
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestExportProduct(t *testing.T) {
+	appContext := AppContext{}
+	appContext.Repositories.ProductDAO = getExportProductDAO()
+
+	rr := doExportProduct(&appContext, "/products/1/export")
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var export model.ProductExport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &export))
+	assert.Equal(t, "My Product", export.Name)
+	assert.True(t, export.ValidateReleases)
+	assert.Equal(t, 2, len(export.Versions))
+	assert.True(t, export.Versions[0].Locked)
+	assert.True(t, export.Versions[1].HotFix)
+	assert.Equal(t, model.ProductVersionServiceExport{Chart: "repo/foo", ChartVersion: "0.1.0",
+		DockerImageTag: "1.0.0", Notes: "some notes"}, export.Versions[0].Services[0])
+}
+
+func TestExportProductVersionYaml(t *testing.T) {
+	appContext := AppContext{}
+	appContext.Repositories.ProductDAO = getExportProductDAO()
+
+	rr := doExportProduct(&appContext, "/products/1/export?versionId=11&format=yaml")
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Contains(t, rr.Body.String(), "version: 1.0.1")
+
+	var export model.ProductExport
+	assert.NoError(t, yaml.Unmarshal(rr.Body.Bytes(), &export))
+	assert.Equal(t, 1, len(export.Versions))
+	assert.Equal(t, "1.0.1", export.Versions[0].Services[0].DockerImageTag)
+}
+
+func TestExportProductVersionNotFound(t *testing.T) {
+	appContext := AppContext{}
+	appContext.Repositories.ProductDAO = getExportProductDAO()
+
+	rr := doExportProduct(&appContext, "/products/1/export?versionId=99")
+	assert.Equal(t, http.StatusInternalServerError, rr.Code, "Response should be 500.")
+}
+
+func getImportDocument() []byte {
+	return []byte(`name: My Product
+versions:
+- version: 1.0.0
+  services:
+  - chart: repo/foo
+    chartVersion: 0.2.0
+    dockerImageTag: 2.0.0
+    notes: imported notes
+- version: 2.0.0
+  services:
+  - chart: repo/bar
+    chartVersion: 0.1.0
+    dockerImageTag: 1.0.0
+`)
+}
+
+func doImportProduct(appContext *AppContext, url string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(getImportDocument()))
+	mockPrincipal(req)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.importProduct)
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func getImportAppContext() (*AppContext, *mockRepo.ProductDAOInterface, *mockRepo.NotesDAOInterface) {
+	appContext := AppContext{}
+	mockProductDAO := getExportProductDAO()
+	mockProductDAO.On("ImportProduct", mock.Anything).Return(1, nil)
+	appContext.Repositories.ProductDAO = mockProductDAO
+
+	mockNotesDAO := &mockRepo.NotesDAOInterface{}
+	mockNotesDAO.On("GetByServiceName", mock.Anything).Return(&model.Notes{}, nil)
+	appContext.Repositories.NotesDAO = mockNotesDAO
+
+	mockAudit := &mockAud.AuditingInterface{}
+	mockAudit.On("DoAudit", mock.Anything, mock.Anything, "beta@alfa.com", "importProduct", mock.Anything)
+	appContext.Auditing = mockAudit
+
+	return &appContext, mockProductDAO, mockNotesDAO
+}
+
+func TestImportProductDryRun(t *testing.T) {
+	appContext, mockProductDAO, _ := getImportAppContext()
+
+	rr := doImportProduct(appContext, "/products/import?dryRun=true&onConflict=rename")
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var report model.ProductImportReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	assert.False(t, report.ProductCreated)
+	assert.Equal(t, []model.ProductImportResult{
+		{Version: "1.0.0", ImportedAs: "1.0.0-imported", Action: "rename", Conflict: true, Services: 1},
+		{Version: "2.0.0", ImportedAs: "2.0.0", Action: "create", Services: 1},
+	}, report.Versions)
+
+	mockProductDAO.AssertNotCalled(t, "ImportProduct", mock.Anything)
+	appContext.Auditing.(*mockAud.AuditingInterface).AssertNotCalled(t, "DoAudit",
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImportProductSkip(t *testing.T) {
+	appContext, mockProductDAO, _ := getImportAppContext()
+
+	rr := doImportProduct(appContext, "/products/import")
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var report model.ProductImportReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, "skip", report.Versions[0].Action)
+
+	data := mockProductDAO.Calls[len(mockProductDAO.Calls)-1].Arguments.Get(0).(model.ProductImport)
+	assert.Equal(t, uint(1), data.Product.ID)
+	assert.Equal(t, []model.ProductVersionImport{{
+		Version:  model.ProductVersion{Version: "2.0.0", State: model.ProductVersionDraft},
+		Services: []model.ProductVersionService{{ServiceName: "repo/bar - 0.1.0", DockerImageTag: "1.0.0"}},
+	}}, data.Versions)
+	assert.Empty(t, data.Notes)
+}
+
+func TestImportProductOverwrite(t *testing.T) {
+	appContext, mockProductDAO, _ := getImportAppContext()
+	unlocked := model.ProductVersion{ProductID: 1, Version: "1.0.0", State: model.ProductVersionDraft}
+	unlocked.ID = 10
+	mockProductDAO.ExpectedCalls[2].ReturnArguments = []interface{}{[]model.ProductVersion{unlocked}, nil}
+
+	rr := doImportProduct(appContext, "/products/import?onConflict=overwrite")
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	data := mockProductDAO.Calls[len(mockProductDAO.Calls)-1].Arguments.Get(0).(model.ProductImport)
+	assert.Equal(t, 2, len(data.Versions))
+	assert.Equal(t, uint(10), data.Versions[0].Version.ID)
+	assert.Equal(t, []model.ProductVersionService{{ServiceName: "repo/foo - 0.2.0", DockerImageTag: "2.0.0",
+		Notes: "imported notes"}}, data.Versions[0].Services)
+	assert.Equal(t, []model.Notes{{ServiceName: "repo/foo", Text: "imported notes"}}, data.Notes)
+}
+
+func TestImportProductOverwriteLocked(t *testing.T) {
+	appContext, mockProductDAO, _ := getImportAppContext()
+
+	rr := doImportProduct(appContext, "/products/import?onConflict=overwrite")
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var report model.ProductImportReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, "skip", report.Versions[0].Action)
+	assert.Equal(t, pvLockMsg, report.Versions[0].Reason)
+
+	data := mockProductDAO.Calls[len(mockProductDAO.Calls)-1].Arguments.Get(0).(model.ProductImport)
+	assert.Equal(t, 1, len(data.Versions))
+	assert.Equal(t, "2.0.0", data.Versions[0].Version.Version)
+}
+
+func TestImportProductInvalidConflict(t *testing.T) {
+	appContext, _, _ := getImportAppContext()
+
+	rr := doImportProduct(appContext, "/products/import?onConflict=merge")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+}
+
+func TestRenameImportedVersion(t *testing.T) {
+	existing := map[string]model.ProductVersion{"1.0.0": {}, "1.0.0-imported": {}}
+	assert.Equal(t, "1.0.0-imported-2", renameImportedVersion("1.0.0", existing))
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/softplan/tenkai-api/pkg/dbms/model"
 	analyser "github.com/softplan/tenkai-api/pkg/service/analyser"
+	"github.com/softplan/tenkai-api/pkg/service/versioning"
 	"github.com/softplan/tenkai-api/pkg/util"
 )
 
@@ -35,6 +36,11 @@ func (appContext *AppContext) newProduct(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if _, err := versioning.New(payload.VersionScheme, payload.VersionPattern); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if _, err := appContext.Repositories.ProductDAO.CreateProduct(payload); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -53,6 +59,11 @@ func (appContext *AppContext) editProduct(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if _, err := versioning.New(payload.VersionScheme, payload.VersionPattern); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if err := appContext.Repositories.ProductDAO.EditProduct(payload); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -102,6 +113,7 @@ func (appContext *AppContext) newProductVersion(w http.ResponseWriter, r *http.R
 	}
 
 	payload.Date = time.Now()
+	payload.State = model.ProductVersionDraft
 
 	productVersionID, err := appContext.Repositories.ProductDAO.CreateProductVersionCopying(payload)
 	if err != nil {
@@ -168,7 +180,19 @@ func (appContext *AppContext) editProductVersion(w http.ResponseWriter, r *http.
 		return
 	}
 
+	current, err := appContext.Repositories.ProductDAO.ListProductVersionsByID(int(payload.ID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if current.State != "" && current.State != model.ProductVersionDraft {
+		http.Error(w, "Product version is "+current.State+", only draft versions can be changed", http.StatusBadRequest)
+		return
+	}
+
 	payload.Date = time.Now()
+	payload.State = current.State
 
 	if err := appContext.Repositories.ProductDAO.EditProductVersion(payload); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -186,9 +210,19 @@ func (appContext *AppContext) deleteProductVersion(w http.ResponseWriter, r *htt
 	id, _ := strconv.Atoi(sl)
 	w.Header().Set(global.ContentType, global.JSONContentType)
 
+	pv, err := appContext.Repositories.ProductDAO.ListProductVersionsByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if pv.State != "" && pv.State != model.ProductVersionDraft {
+		http.Error(w, "Product version is "+pv.State+", only draft versions can be deleted", http.StatusBadRequest)
+		return
+	}
+
 	// Deletes ProductVersionServices
 	childs := &model.ProductVersionServiceRequestReponse{}
-	var err error
 	if childs.List, err = appContext.Repositories.ProductDAO.ListProductsVersionServices(id); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -225,8 +259,8 @@ func (appContext *AppContext) newProductVersionService(w http.ResponseWriter, r
 		return
 	}
 
-	if pv.Locked {
-		http.Error(w, pvLockMsg, http.StatusBadRequest)
+	if err := checkProductVersionEditable(pv); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -264,13 +298,13 @@ func (appContext *AppContext) editProductVersionService(w http.ResponseWriter, r
 		return
 	}
 
-	if err := appContext.Repositories.ProductDAO.EditProductVersionService(payload); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := checkProductVersionEditable(pv); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if pv.Locked {
-		http.Error(w, pvLockMsg, http.StatusBadRequest)
+	if err := appContext.Repositories.ProductDAO.EditProductVersionService(payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -296,8 +330,8 @@ func (appContext *AppContext) deleteProductVersionService(w http.ResponseWriter,
 		return
 	}
 
-	if pv.Locked {
-		http.Error(w, pvLockMsg, http.StatusInternalServerError)
+	if err := checkProductVersionEditable(pv); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -376,6 +410,11 @@ func (appContext *AppContext) unlockProductVersion(w http.ResponseWriter, r *htt
 		return
 	}
 
+	if pv.State != "" && pv.State != model.ProductVersionDraft {
+		http.Error(w, "Product version is "+pv.State+", only draft versions can be unlocked", http.StatusBadRequest)
+		return
+	}
+
 	pv.Locked = false
 
 	if err := appContext.Repositories.ProductDAO.EditProductVersion(*pv); err != nil {
@@ -411,6 +450,12 @@ func (appContext *AppContext) listProductVersionServices(w http.ResponseWriter,
 		return
 	}
 
+	scheme, err := appContext.getVersionScheme(pv.ProductID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	wg := new(sync.WaitGroup)
 
 	helmCharts := make(map[string][]model.SearchResult)
@@ -433,7 +478,7 @@ func (appContext *AppContext) listProductVersionServices(w http.ResponseWriter,
 				searchResult []model.SearchResult, productVersion string, hotFix bool) {
 
 				defer wg.Done()
-				version, _ := appContext.verifyNewVersion(splitSrvNameIfNeeded(serviceName), tag, productVersion, hotFix)
+				version, _ := appContext.verifyNewVersion(splitSrvNameIfNeeded(serviceName), tag, productVersion, hotFix, scheme)
 				result.List[index].LatestVersion = version
 				result.List[index].ChartLatestVersion = appContext.getChartLatestVersion(serviceName, searchResult)
 			}(wg, serviceName, tag, index, helmCharts[helmRepo], pv.Version, pv.HotFix)
@@ -536,7 +581,7 @@ func (appContext *AppContext) isDifferent(v1 bool, v2 bool, v3 bool) bool {
 }
 
 func (appContext *AppContext) verifyNewVersion(serviceName string,
-	dockerImageTag string, productVersion string, hotFix bool) (string, error) {
+	dockerImageTag string, productVersion string, hotFix bool, scheme versioning.Scheme) (string, error) {
 
 	var payload model.ListDockerTagsRequest
 	var err error
@@ -553,198 +598,26 @@ func (appContext *AppContext) verifyNewVersion(serviceName string,
 		return "", err
 	}
 
-	var currentDate time.Time
-	majorList := make([]model.TagResponse, 0)
-
-	currentDate = getCreateDateOfCurrentTag(result.TagResponse, dockerImageTag)
+	currentDate := getCreateDateOfCurrentTag(result.TagResponse, dockerImageTag)
 
 	//Get all tags created after current tag
+	candidates := make([]string, 0)
 	for _, e := range result.TagResponse {
 		if e.Created.After(currentDate) {
-			majorList = append(majorList, e)
+			candidates = append(candidates, e.Tag)
 		}
 	}
 
-	finalList := make([]model.TagResponse, 0)
-
-	//Filter based on version tag
-	for _, latest := range majorList {
-		if hotFix {
-			// If product is a hotFix, so ignore product version and consider only the service version
-			currentMajor := appContext.getMajorVersionOfHotfix(dockerImageTag)
-			latestMajor := appContext.getMajorVersionOfHotfix(latest.Tag)
-
-			if currentMajor == latestMajor {
-				currentMinor := appContext.getMinorVersionOfHotfix(dockerImageTag)
-				latestMinor := appContext.getMinorVersionOfHotfix(latest.Tag)
-
-				if latestMinor > currentMinor {
-					finalList = append(finalList, latest)
-				}
-			} else {
-				continue
-			}
-		} else {
-
-			// Avoid to compare different major versions
-			productMajor := appContext.getMajorVersion(productVersion)
-			latestMajor := appContext.getMajorVersion(latest.Tag)
-			if productMajor != latestMajor {
-				continue
-			}
-
-			// Avoid to compare different minor versions
-			splited := strings.Split(latest.Tag, productMajor)
-			if len(splited) == 2 {
-				minVer := strings.Split(normalize(splited[1]), ".")
-				if len(minVer) > 2 {
-					fmt.Println("Ignoring: " + serviceName + " - " + latest.Tag)
-					continue
-				}
-			}
-
-			latestMinor := appContext.getMinorVersion(latest.Tag)
-			productMinor := appContext.getMinorVersion(productVersion)
-
-			currentIsRC := isReleasCandidate(dockerImageTag)
-			latestIsRC := isReleasCandidate(latest.Tag)
-			productIsRC := isReleasCandidate(productVersion)
-
-			// If product and latest are RC and current version isn't RC
-			if latestIsRC && productIsRC && !currentIsRC {
-				if latestMinor > productMinor {
-					finalList = append(finalList, latest)
-				}
-			} else {
-				// Avoid to compare a release candidate with a version
-				if latestIsRC != productIsRC {
-					continue
-				}
-
-				curMinor := appContext.getMinorVersion(dockerImageTag)
-				curMajor := appContext.getMajorVersion(dockerImageTag)
-
-				// If currentTag's majorVersion < productVersion (it occurs when productVersion is a copy of other productVersion)
-				if majorVersionToInt(curMajor) < majorVersionToInt(productMajor) {
-					finalList = append(finalList, latest)
-				} else {
-					if latestMinor > curMinor {
-						finalList = append(finalList, latest)
-					}
-				}
-			}
-
-		}
-	}
-
-	var lastResult string
-	if len(finalList) > 0 {
-		e := finalList[len(finalList)-1]
-		lastResult = e.Tag
-	}
-	return lastResult, nil
-}
-
-func majorVersionToInt(version string) int {
-	v := strings.ReplaceAll(version, ".", "")
-	v = strings.ReplaceAll(v, "-", "")
-	v = strings.ReplaceAll(v, "RC", "")
-
-	var err error
-	var value int
-	if value, err = strconv.Atoi(v); err != nil {
-		return 0
-	}
-	return value
-}
-
-func isReleasCandidate(version string) bool {
-	return strings.Contains(version, "RC")
-}
-
-func (appContext *AppContext) getMajorVersion(version string) string {
-	major := ""
-	foundMajor := false
-
-	for i := len(version) - 1; i >= 0; i-- {
-		v := string(version[i])
-
-		if foundMajor {
-			major = v + major
-		} else {
-			if v == "." || v == "-" {
-				foundMajor = true
-			}
-		}
-	}
-
-	return major
-}
-
-func (appContext *AppContext) getMajorVersionOfHotfix(version string) string {
-
-	dotCount := strings.Split(version, ".")
-
-	if len(dotCount)-1 == 2 {
-		return version
-	}
-
-	major := ""
-	foundMajor := false
-	for i := len(version) - 1; i >= 0; i-- {
-		v := string(version[i])
-
-		if foundMajor {
-			major = v + major
-		} else {
-			if v == "." {
-				foundMajor = true
-			}
-		}
-	}
-	return major
+	return versioning.LatestCompatible(scheme, dockerImageTag, productVersion, hotFix, candidates), nil
 }
 
-func (appContext *AppContext) getMinorVersion(version string) int {
-	minor := ""
-	minorInt := -1
-
-	for i := len(version) - 1; i >= 0; i-- {
-		v := string(version[i])
-
-		if v != "." && v != "-" {
-			minor = v + minor
-		} else {
-			minorInt, _ = strconv.Atoi(minor)
-			return minorInt
-		}
-	}
-
-	return -1
-}
-
-func (appContext *AppContext) getMinorVersionOfHotfix(version string) int {
-
-	dotCount := strings.Split(version, ".")
-	if len(dotCount)-1 == 2 {
-		return -1
-	}
-
-	minor := ""
-	minorInt := -1
-
-	for i := len(version) - 1; i >= 0; i-- {
-		v := string(version[i])
-
-		if v != "." && v != "-" {
-			minor = v + minor
-		} else {
-			minorInt, _ = strconv.Atoi(minor)
-			return minorInt
-		}
+//getVersionScheme returns the version scheme configured for the product
+func (appContext *AppContext) getVersionScheme(productID int) (versioning.Scheme, error) {
+	product, err := appContext.Repositories.ProductDAO.FindProductByID(productID)
+	if err != nil {
+		return nil, err
 	}
-
-	return -1
+	return versioning.New(product.VersionScheme, product.VersionPattern)
 }
 
 func (appContext *AppContext) validateVersion(productVersion string, currentVersion string) bool {
@@ -12,10 +12,13 @@ import (
 	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
 	mockSvc "github.com/softplan/tenkai-api/pkg/service/_helm/mocks"
 	"github.com/softplan/tenkai-api/pkg/service/docker/mocks"
+	"github.com/softplan/tenkai-api/pkg/service/versioning"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+var legacyScheme, _ = versioning.New(versioning.Legacy, "")
+
 func TestSplitSrvNameIfNeeded(t *testing.T) {
 	assert.Equal(t, "repo/my-chart", splitSrvNameIfNeeded("repo/my-chart - 0.1.0"))
 	assert.Equal(t, "repo/my-chart", splitSrvNameIfNeeded("repo/my-chart"))
@@ -295,6 +298,7 @@ func TestEditProductVersion(t *testing.T) {
 
 	pv := getProductVersionWithoutID(0)
 	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 0).Return(&pv, nil)
 	mockProductDAO.On("EditProductVersion", mock.Anything).Return(nil)
 	appContext.Repositories.ProductDAO = mockProductDAO
 
@@ -323,6 +327,7 @@ func TestEditProductVersion_Error(t *testing.T) {
 
 	pv := getProductVersionWithoutID(0)
 	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 0).Return(&pv, nil)
 	mockProductDAO.On("EditProductVersion", mock.Anything).
 		Return(errors.New("Some error"))
 	appContext.Repositories.ProductDAO = mockProductDAO
@@ -346,6 +351,7 @@ func TestDeleteProductVersion(t *testing.T) {
 	childs := getProductVersionSvcReqResp()
 
 	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 999).Return(&model.ProductVersion{}, nil)
 	mockProductDAO.On("ListProductsVersionServices", 999).Return(childs.List, nil)
 	mockProductDAO.On("DeleteProductVersionService", 888).Return(nil)
 	mockProductDAO.On("DeleteProductVersion", 999).Return(nil)
@@ -370,6 +376,7 @@ func TestDeleteProductVersion_ListProductsVersionServicesError(t *testing.T) {
 	appContext := AppContext{}
 
 	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 999).Return(&model.ProductVersion{}, nil)
 	mockProductDAO.On("ListProductsVersionServices", 999).Return(nil, errors.New("Some error"))
 	appContext.Repositories.ProductDAO = mockProductDAO
 
@@ -392,6 +399,7 @@ func TestDeleteProductVersion_DeleteProductVersionServiceError(t *testing.T) {
 	childs := getProductVersionSvcReqResp()
 
 	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 999).Return(&model.ProductVersion{}, nil)
 	mockProductDAO.On("ListProductsVersionServices", 999).Return(childs.List, nil)
 	mockProductDAO.On("DeleteProductVersionService", 888).Return(errors.New("Some error"))
 	appContext.Repositories.ProductDAO = mockProductDAO
@@ -416,6 +424,7 @@ func TestDeleteProductVersion_DeleteProductVersionError(t *testing.T) {
 	childs := getProductVersionSvcReqResp()
 
 	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 999).Return(&model.ProductVersion{}, nil)
 	mockProductDAO.On("ListProductsVersionServices", 999).Return(childs.List, nil)
 	mockProductDAO.On("DeleteProductVersionService", 888).Return(nil)
 	mockProductDAO.On("DeleteProductVersion", 999).Return(errors.New("Some error"))
@@ -743,6 +752,7 @@ func TestListProductVersionServices(t *testing.T) {
 
 	pv := getProductVersionWithoutID(0)
 	mockProductDAO.On("ListProductVersionsByID", mock.Anything).Return(&pv, nil)
+	mockProductDAO.On("FindProductByID", pv.ProductID).Return(model.Product{}, nil)
 	appContext.Repositories.ProductDAO = mockProductDAO
 
 	mockHelmSvc := &mockSvc.HelmServiceInterface{}
@@ -1085,7 +1095,7 @@ func TestEditProductVersionService_ProductVersionLocked(t *testing.T) {
 	handler.ServeHTTP(rr, req)
 
 	mockProductDAO.AssertNumberOfCalls(t, "ListProductVersionsByID", 1)
-	mockProductDAO.AssertNumberOfCalls(t, "EditProductVersionService", 1)
+	mockProductDAO.AssertNumberOfCalls(t, "EditProductVersionService", 0)
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
 }
@@ -1261,7 +1271,7 @@ func TestVerifyNewVersion_1(t *testing.T) {
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse("19.0.2-0"))
 
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1277,7 +1287,7 @@ func TestVerifyNewVersion_2(t *testing.T) {
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse("19.0.1-1"))
 
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1293,7 +1303,7 @@ func TestVerifyNewVersion_3(t *testing.T) {
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse("20.1.0-RC-2"))
 
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "20.1.0-RC-1", "20.1.0-RC-1", false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "20.1.0-RC-1", "20.1.0-RC-1", false, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1309,7 +1319,7 @@ func TestVerifyNewVersion_4(t *testing.T) {
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse("20.1.0-0.1"))
 
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "20.1.0-0", "20.1.0-0", false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "20.1.0-0", "20.1.0-0", false, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1328,7 +1338,7 @@ func TestVerifyNewVersion_5(t *testing.T) {
 	svcCurrentVersion := "20.2.0-RC-9"
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse(latest))
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, false, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1348,7 +1358,7 @@ func TestVerifyNewVersionHotfix_6(t *testing.T) {
 	hotFix := true
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse(latest))
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, hotFix)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, hotFix, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1368,7 +1378,7 @@ func TestVerifyNewVersionHotfix_7(t *testing.T) {
 	hotFix := true
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse(latest))
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, hotFix)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, hotFix, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1388,7 +1398,7 @@ func TestVerifyNewVersion_8(t *testing.T) {
 	hotFix := false
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse(latest))
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, hotFix)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, hotFix, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1408,7 +1418,7 @@ func TestVerifyNewVersionHotfix_9(t *testing.T) {
 	hotFix := true
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse(latest))
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, hotFix)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, hotFix, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1428,7 +1438,7 @@ func TestVerifyNewVersionHotfix_10(t *testing.T) {
 	hotFix := true
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse(latest))
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, hotFix)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", svcCurrentVersion, productVersion, hotFix, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1437,66 +1447,38 @@ func TestVerifyNewVersionHotfix_10(t *testing.T) {
 	mockDockerSvc.AssertNumberOfCalls(t, "GetDockerTagsWithDate", 1)
 }
 
-func TestGetMajorVersion(t *testing.T) {
+func TestVerifyNewVersionSemVer(t *testing.T) {
 	appContext := AppContext{}
 
-	v1 := appContext.getMajorVersion("20.1.1-0")
-	assert.Equal(t, "20.1.1", v1)
-
-	v2 := appContext.getMajorVersion("20.1.1-0.1")
-	assert.Equal(t, "20.1.1-0", v2)
-
-	v3 := appContext.getMajorVersion("20.1.1-RC-0")
-	assert.Equal(t, "20.1.1-RC", v3)
-}
+	appContext.ChartImageCache.Store("repo/my-chart - 0.1.0", "myrepo.com/my-chart")
 
-func TestGetMajorVersionOfHotfix1(t *testing.T) {
-	appContext := AppContext{}
-	v1 := appContext.getMajorVersionOfHotfix("20.1.1-0")
-	assert.Equal(t, "20.1.1-0", v1)
-}
+	tags := &model.ListDockerTagsResult{}
+	for i, tag := range []string{"1.2.0", "1.10.0", "2.0.0", "1.3.0-rc.1", "1.4.1"} {
+		tags.TagResponse = append(tags.TagResponse, model.TagResponse{
+			Tag: tag, Created: time.Date(2019, time.January, i+1, 0, 0, 0, 0, time.UTC)})
+	}
+	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, tags)
 
-func TestGetMajorVersionOfHotfix2(t *testing.T) {
-	appContext := AppContext{}
-	v2 := appContext.getMajorVersionOfHotfix("20.1.1-0.1")
-	assert.Equal(t, "20.1.1-0", v2)
-}
+	scheme, _ := versioning.New(versioning.SemVer, "")
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "1.2.0", "1.0.0", false, scheme)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.10.0", version)
 
-func TestGetMajorVersionOfHotfix3(t *testing.T) {
-	appContext := AppContext{}
-	v2 := appContext.getMajorVersionOfHotfix("20.1.1")
-	assert.Equal(t, "20.1.1", v2)
+	mockDockerSvc.AssertNumberOfCalls(t, "GetDockerTagsWithDate", 1)
 }
 
-func TestGetMinorVersion(t *testing.T) {
+func TestNewProduct_InvalidVersionScheme(t *testing.T) {
 	appContext := AppContext{}
 
-	v1 := appContext.getMinorVersion("20.1.1-0")
-	assert.Equal(t, 0, v1)
-
-	v2 := appContext.getMinorVersion("20.1.1-0.10")
-	assert.Equal(t, 10, v2)
-
-	v3 := appContext.getMinorVersion("20.1.1-RC-01")
-	assert.Equal(t, 01, v3)
-}
-
-func TestGetMinorVersionOfHotfix1(t *testing.T) {
-	appContext := AppContext{}
-	v1 := appContext.getMinorVersionOfHotfix("20.1.1-0")
-	assert.Equal(t, -1, v1)
-}
+	product := model.Product{Name: "my-product", VersionScheme: "regex", VersionPattern: "("}
+	req, err := http.NewRequest("POST", "/products", payload(product))
+	assert.NoError(t, err)
 
-func TestGetMinorVersionOfHotfix2(t *testing.T) {
-	appContext := AppContext{}
-	v2 := appContext.getMinorVersionOfHotfix("20.1.1-0.10")
-	assert.Equal(t, 10, v2)
-}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.newProduct)
+	handler.ServeHTTP(rr, req)
 
-func TestGetMinorVersionOfHotfix3(t *testing.T) {
-	appContext := AppContext{}
-	v2 := appContext.getMinorVersionOfHotfix("20.1.1")
-	assert.Equal(t, -1, v2)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
 }
 
 func TestDiff(t *testing.T) {
@@ -1528,7 +1510,7 @@ func TestVerifyNewVersion_NotOk_1(t *testing.T) {
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse("20.1.1-0"))
 
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "20.1.0-0", "20.1.0-0", false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "20.1.0-0", "20.1.0-0", false, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1549,7 +1531,7 @@ func TestVerifyNewVersion_NotOk_2(t *testing.T) {
 	bytes := []byte("{\"image\":{\"repository\":\"myrepo.com/my-chart\"}}")
 	mockHelmSvc.On("GetValues", "repo/my-chart - 0.1.0", "0").Return(bytes, nil)
 
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -1568,7 +1550,7 @@ func TestVerifyNewVersion_NotOk_Error(t *testing.T) {
 
 	mockHelmSvc.On("GetValues", mock.Anything, mock.Anything).Return(nil, errors.New("some error"))
 
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false, legacyScheme)
 	assert.Error(t, err)
 	assert.NotNil(t, version)
 
@@ -1586,7 +1568,7 @@ func TestVerifyNewVersion_NotOk_UnmarshalError(t *testing.T) {
 	bytes := []byte(`["foo":"baz"]`)
 	mockHelmSvc.On("GetValues", "repo/my-chart - 0.1.0", "0").Return(bytes, nil)
 
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false, legacyScheme)
 	assert.Error(t, err)
 	assert.NotNil(t, version)
 	assert.Equal(t, "", version)
@@ -1602,7 +1584,7 @@ func TestVerifyNewVersion_GetDockerTagsWithDateError(t *testing.T) {
 		Return(nil, errors.New("some error"))
 	appContext.DockerServiceAPI = mockDockerSvc
 
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false, legacyScheme)
 	assert.Error(t, err)
 	assert.NotNil(t, version)
 	assert.Equal(t, "", version)
@@ -1617,7 +1599,7 @@ func TestVerifyNewVersion_NoNewVersion(t *testing.T) {
 
 	mockDockerSvc := mockGetDockerTagsWithDate(&appContext, getTagResponse("19.0.1-0"))
 
-	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false)
+	version, err := appContext.verifyNewVersion("repo/my-chart - 0.1.0", "19.0.1-0", "19.0.1-0", false, legacyScheme)
 	assert.NoError(t, err)
 	assert.NotNil(t, version)
 
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+//productVersionTransitions lists the states each state may move to. Versions without
+//state were created before the lifecycle existed and may adopt any of the first states.
+var productVersionTransitions = map[string][]string{
+	"":                             {model.ProductVersionDraft, model.ProductVersionCandidate, model.ProductVersionReleased},
+	model.ProductVersionDraft:      {model.ProductVersionCandidate},
+	model.ProductVersionCandidate:  {model.ProductVersionDraft, model.ProductVersionReleased},
+	model.ProductVersionReleased:   {model.ProductVersionDeprecated},
+	model.ProductVersionDeprecated: {model.ProductVersionReleased, model.ProductVersionRetired},
+}
+
+//productVersionAdminStates are the states only a tenkai admin may move a version to
+var productVersionAdminStates = []string{
+	model.ProductVersionReleased, model.ProductVersionDeprecated, model.ProductVersionRetired,
+}
+
+func (appContext *AppContext) transitionProductVersion(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+	principal := util.GetPrincipal(r)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.ProductVersionTransition
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pv, err := appContext.Repositories.ProductDAO.ListProductVersionsByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fromState := pv.State
+	if !util.Contains(productVersionTransitions[fromState], payload.State) {
+		http.Error(w, "Product version can't move from "+productVersionStateName(fromState)+
+			" to "+productVersionStateName(payload.State), http.StatusBadRequest)
+		return
+	}
+
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		if util.Contains(productVersionAdminStates, payload.State) {
+			http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+			return
+		}
+		if auth, err := appContext.hasAnyEnvironmentRole(principal, "ACTION_DEPLOY"); err != nil || !auth {
+			http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	pv.State = payload.State
+	pv.Locked = payload.State != model.ProductVersionDraft
+
+	if err := appContext.Repositories.ProductDAO.EditProductVersion(*pv); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	auditValues := make(map[string]string)
+	auditValues["productVersionId"] = strconv.Itoa(id)
+	auditValues["productVersion"] = pv.Version
+	auditValues["fromState"] = productVersionStateName(fromState)
+	auditValues["toState"] = pv.State
+	auditValues["reason"] = payload.Reason
+	appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "productVersionTransition", auditValues)
+
+	appContext.triggerProductVersionStateWebhook(*pv, fromState, principal.Email, payload.Reason)
+
+	data, _ := json.Marshal(pv)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (appContext *AppContext) triggerProductVersionStateWebhook(pv model.ProductVersion, fromState string,
+	user string, reason string) {
+
+	webHooks, err := appContext.Repositories.WebHookDAO.ListWebHooksByEnvAndType(-1, "HOOK_PRODUCT_VERSION_STATE")
+	if err != nil || len(webHooks) == 0 {
+		return
+	}
+
+	product, err := appContext.Repositories.ProductDAO.FindProductByID(pv.ProductID)
+	if err != nil {
+		global.Logger.Error(global.AppFields{global.Function: "triggerProductVersionStateWebhook"},
+			"Error trying to find product: "+err.Error())
+		return
+	}
+
+	for _, hook := range webHooks {
+		var p model.WebHookProductVersionStatePostPayload
+		p.ProductName = product.Name
+		p.Release = pv.Version
+		p.FromState = productVersionStateName(fromState)
+		p.ToState = pv.State
+		p.User = user
+		p.Reason = reason
+		p.AdditionalData = hook.AdditionalData
+		payloadStr, _ := json.Marshal(p)
+		resp, err := http.Post(hook.URL, "application/json", bytes.NewBuffer(payloadStr))
+		if err != nil {
+			global.Logger.Error(global.AppFields{global.Function: "triggerProductVersionStateWebhook"},
+				"Error trying to post to webhook "+hook.URL+": "+err.Error())
+			continue
+		}
+		resp.Body.Close()
+	}
+}
+
+//checkProductVersionEditable returns an error when the version or its services can't be changed.
+//Only draft versions are editable; versions without state keep the lock based behaviour.
+func checkProductVersionEditable(pv *model.ProductVersion) error {
+	if pv.Locked {
+		return errors.New(pvLockMsg)
+	}
+	if pv.State != "" && pv.State != model.ProductVersionDraft {
+		return errors.New("Product version is " + pv.State + ", only draft versions can be changed")
+	}
+	return nil
+}
+
+//checkDeployableProductVersion refuses versions that were not released when the product validates releases
+func (appContext *AppContext) checkDeployableProductVersion(productVersionID int) error {
+	if productVersionID <= 0 {
+		return nil
+	}
+
+	pv, err := appContext.Repositories.ProductDAO.ListProductVersionsByID(productVersionID)
+	if err != nil {
+		return err
+	}
+
+	if pv.State == "" || pv.State == model.ProductVersionReleased {
+		return nil
+	}
+
+	product, err := appContext.Repositories.ProductDAO.FindProductByID(pv.ProductID)
+	if err != nil {
+		return err
+	}
+
+	if product.ValidateReleases {
+		return errors.New("Product version " + pv.Version + " is " + pv.State + ", only released versions can be deployed")
+	}
+	return nil
+}
+
+func productVersionStateName(state string) string {
+	if state == "" {
+		return "legacy"
+	}
+	return state
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	mockAud "github.com/softplan/tenkai-api/pkg/audit/mocks"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func doTransitionProductVersion(appContext *AppContext, state string, principal bool) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/productVersions/999/transition",
+		payload(model.ProductVersionTransition{State: state, Reason: "QA approved"}))
+	if principal {
+		mockPrincipal(req)
+	} else {
+		pSe, _ := json.Marshal(getPrincipal())
+		req.Header.Set("principal", string(pSe))
+	}
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/productVersions/{id}/transition", appContext.transitionProductVersion).Methods("POST")
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func getTransitionProductDAO(state string) *mockRepo.ProductDAOInterface {
+	pv := getProductVersionWithoutID(0)
+	pv.ID = 999
+	pv.State = state
+
+	var product model.Product
+	product.ID = 999
+	product.Name = "My Product"
+
+	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 999).Return(&pv, nil)
+	mockProductDAO.On("EditProductVersion", mock.Anything).Return(nil)
+	mockProductDAO.On("FindProductByID", pv.ProductID).Return(product, nil)
+	return mockProductDAO
+}
+
+func mockTransitionRoles(appContext *AppContext, secOper model.SecurityOperation) {
+	env := mockGetEnv()
+	mockEnvDao := &mockRepo.EnvironmentDAOInterface{}
+	mockEnvDao.On("GetAllEnvironments", "xpto").Return([]model.Environment{env}, nil)
+	appContext.Repositories.EnvironmentDAO = mockEnvDao
+
+	user := model.User{Email: "xpto"}
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", "xpto").Return(user, nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	mockUserEnvRoleDAO := &mockRepo.UserEnvironmentRoleDAOInterface{}
+	mockUserEnvRoleDAO.On("GetRoleByUserAndEnvironment", user, env.ID).Return(&secOper, nil)
+	appContext.Repositories.UserEnvironmentRoleDAO = mockUserEnvRoleDAO
+}
+
+func TestTransitionProductVersion(t *testing.T) {
+	appContext := AppContext{}
+	mockProductDAO := getTransitionProductDAO(model.ProductVersionCandidate)
+	appContext.Repositories.ProductDAO = mockProductDAO
+
+	var received model.WebHookProductVersionStatePostPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	hook := mockWebHook()
+	hook.URL = server.URL
+	mockWebHookDAO := &mockRepo.WebHookDAOInterface{}
+	mockWebHookDAO.On("ListWebHooksByEnvAndType", -1, "HOOK_PRODUCT_VERSION_STATE").Return([]model.WebHook{hook}, nil)
+	appContext.Repositories.WebHookDAO = mockWebHookDAO
+
+	auditValues := map[string]string{"productVersionId": "999", "productVersion": "19.0.1-0",
+		"fromState": "candidate", "toState": "released", "reason": "QA approved"}
+	mockAudit := mockDoAudit(&appContext, "productVersionTransition", auditValues)
+
+	rr := doTransitionProductVersion(&appContext, model.ProductVersionReleased, true)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	mockProductDAO.AssertCalled(t, "EditProductVersion", mock.MatchedBy(func(pv model.ProductVersion) bool {
+		return pv.State == model.ProductVersionReleased && pv.Locked
+	}))
+	mockAudit.AssertNumberOfCalls(t, "DoAudit", 1)
+
+	assert.Equal(t, "My Product", received.ProductName)
+	assert.Equal(t, "candidate", received.FromState)
+	assert.Equal(t, "released", received.ToState)
+	assert.Equal(t, "beta@alfa.com", received.User)
+}
+
+func TestTransitionProductVersion_InvalidTransition(t *testing.T) {
+	appContext := AppContext{}
+	mockProductDAO := getTransitionProductDAO(model.ProductVersionDraft)
+	appContext.Repositories.ProductDAO = mockProductDAO
+
+	rr := doTransitionProductVersion(&appContext, model.ProductVersionReleased, true)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	assert.Contains(t, rr.Body.String(), "can't move from draft to released")
+	mockProductDAO.AssertNotCalled(t, "EditProductVersion", mock.Anything)
+}
+
+func TestTransitionProductVersion_AccessDenied(t *testing.T) {
+	appContext := AppContext{}
+	mockProductDAO := getTransitionProductDAO(model.ProductVersionCandidate)
+	appContext.Repositories.ProductDAO = mockProductDAO
+
+	rr := doTransitionProductVersion(&appContext, model.ProductVersionReleased, false)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+	mockProductDAO.AssertNotCalled(t, "EditProductVersion", mock.Anything)
+}
+
+func TestTransitionProductVersion_LegacyToDraft(t *testing.T) {
+	appContext := AppContext{}
+	mockProductDAO := getTransitionProductDAO("")
+	appContext.Repositories.ProductDAO = mockProductDAO
+	mockTransitionRoles(&appContext, mockSecurityOperations())
+
+	mockWebHookDAO := &mockRepo.WebHookDAOInterface{}
+	mockWebHookDAO.On("ListWebHooksByEnvAndType", -1, "HOOK_PRODUCT_VERSION_STATE").Return([]model.WebHook{}, nil)
+	appContext.Repositories.WebHookDAO = mockWebHookDAO
+
+	auditValues := map[string]string{"productVersionId": "999", "productVersion": "19.0.1-0",
+		"fromState": "legacy", "toState": "draft", "reason": "QA approved"}
+	mockAudit := &mockAud.AuditingInterface{}
+	mockAudit.On("DoAudit", mock.Anything, mock.Anything, "xpto", "productVersionTransition", auditValues)
+	appContext.Auditing = mockAudit
+
+	rr := doTransitionProductVersion(&appContext, model.ProductVersionDraft, false)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	mockProductDAO.AssertCalled(t, "EditProductVersion", mock.MatchedBy(func(pv model.ProductVersion) bool {
+		return pv.State == model.ProductVersionDraft && !pv.Locked
+	}))
+}
+
+func TestTransitionProductVersion_WithoutDeployRole(t *testing.T) {
+	appContext := AppContext{}
+	mockProductDAO := getTransitionProductDAO(model.ProductVersionDraft)
+	appContext.Repositories.ProductDAO = mockProductDAO
+	mockTransitionRoles(&appContext, model.SecurityOperation{Name: "NONE"})
+
+	rr := doTransitionProductVersion(&appContext, model.ProductVersionCandidate, false)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+	mockProductDAO.AssertNotCalled(t, "EditProductVersion", mock.Anything)
+}
+
+func TestCheckProductVersionEditable(t *testing.T) {
+	tests := []struct {
+		state    string
+		locked   bool
+		editable bool
+	}{
+		{"", false, true},
+		{"", true, false},
+		{model.ProductVersionDraft, false, true},
+		{model.ProductVersionDraft, true, false},
+		{model.ProductVersionCandidate, false, false},
+		{model.ProductVersionReleased, false, false},
+	}
+
+	for _, tt := range tests {
+		pv := model.ProductVersion{State: tt.state, Locked: tt.locked}
+		assert.Equal(t, tt.editable, checkProductVersionEditable(&pv) == nil, tt.state)
+	}
+}
+
+func TestCheckDeployableProductVersion(t *testing.T) {
+	tests := []struct {
+		state            string
+		validateReleases bool
+		deployable       bool
+	}{
+		{"", true, true},
+		{model.ProductVersionReleased, true, true},
+		{model.ProductVersionCandidate, true, false},
+		{model.ProductVersionDeprecated, true, false},
+		{model.ProductVersionCandidate, false, true},
+	}
+
+	for _, tt := range tests {
+		appContext := AppContext{}
+		pv := model.ProductVersion{ProductID: 1, Version: "1.0.0", State: tt.state}
+		mockProductDAO := &mockRepo.ProductDAOInterface{}
+		mockProductDAO.On("ListProductVersionsByID", 777).Return(&pv, nil)
+		mockProductDAO.On("FindProductByID", 1).Return(model.Product{ValidateReleases: tt.validateReleases}, nil)
+		appContext.Repositories.ProductDAO = mockProductDAO
+
+		err := appContext.checkDeployableProductVersion(777)
+		assert.Equal(t, tt.deployable, err == nil, tt.state)
+	}
+
+	appContext := AppContext{}
+	assert.NoError(t, appContext.checkDeployableProductVersion(0))
+}
+
+func TestDeleteProductVersion_NotDraft(t *testing.T) {
+	appContext := AppContext{}
+
+	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 999).
+		Return(&model.ProductVersion{State: model.ProductVersionReleased}, nil)
+	appContext.Repositories.ProductDAO = mockProductDAO
+
+	req, err := http.NewRequest("DELETE", "/productVersions/999", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/productVersions/{id}", appContext.deleteProductVersion).Methods("DELETE")
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	mockProductDAO.AssertNotCalled(t, "DeleteProductVersion", mock.Anything)
+}
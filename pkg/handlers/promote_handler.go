@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -85,6 +87,26 @@ func (appContext *AppContext) retrieveSrcAndTargetEnv(w http.ResponseWriter, pri
 
 }
 
+//goAsync runs the long running part of a request in background
+var goAsync = func(fn func()) {
+	go fn()
+}
+
+type promotion struct {
+	mode                string
+	kubeConfig          string
+	srcEnvironment      *model.Environment
+	targetEnvironment   *model.Environment
+	toPurge             []releaseToDeploy
+	toDeploy            []releaseToDeploy
+	principal           model.Principal
+	user                model.User
+	requestDeploymentID int
+	removeOrphans       bool
+	exclude             []string
+	rewriter            *variableRewriter
+}
+
 func (appContext *AppContext) promote(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set(global.ContentType, global.JSONContentType)
@@ -108,105 +130,482 @@ func (appContext *AppContext) promote(w http.ResponseWriter, r *http.Request) {
 
 	kubeConfig := appContext.ConventionInterface.GetKubeConfigFileName(srcEnvironment.Group, srcEnvironment.Name)
 
-	if mode == "full" {
+	toPurge, err := retrieveReleasesToPurge(appContext.HelmServiceAPI, kubeConfig, targetEnvironment.Namespace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		err = appContext.deleteEnvironmentVariables(targetEnvironment.ID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	toDeploy, err := retrieveReleasesToDeploy(appContext.HelmServiceAPI, kubeConfig, srcEnvironment.Namespace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		err = appContext.copyEnvironmentVariablesFromSrcToTarget(srcEnvironment.ID, targetEnvironment.ID)
-		if err != nil {
+	p := promotion{
+		mode:              mode,
+		kubeConfig:        kubeConfig,
+		srcEnvironment:    srcEnvironment,
+		targetEnvironment: targetEnvironment,
+		toPurge:           toPurge,
+		toDeploy:          toDeploy,
+		principal:         principal,
+	}
+
+	ruleSetID, err := extractRewriteRuleSetID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if p.rewriter, err = appContext.newVariableRewriter(ruleSetID, srcEnvironment, targetEnvironment); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if mode == "sync" {
+		p.removeOrphans, _ = strconv.ParseBool(r.URL.Query().Get("removeOrphans"))
+		p.exclude = extractExclusions(r)
+		if err = appContext.reconcileReleases(&p); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+	}
 
-	} else {
-
-		err = appContext.copyImageAndTagFromSrcToTarget(srcEnvironment.ID, targetEnvironment.ID)
+	if plan, _ := strconv.ParseBool(r.URL.Query().Get("plan")); plan {
+		result, err := appContext.planPromotion(p)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		data, _ := json.Marshal(result)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+		return
+	}
 
+	if err := appContext.checkDeployableProductVersion(int(srcEnvironment.ProductVersionID)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	toPurge, err := retrieveReleasesToPurge(appContext.HelmServiceAPI, kubeConfig, targetEnvironment.Namespace)
+	check, err := appContext.checkPromotionRules(p, r.URL.Query().Get("overrideReason"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	checks := []model.DeployRulesCheck{*check}
+	if isBlocked(checks) {
+		writeRulesResult(w, checks)
+		return
+	}
+	appContext.auditRulesOverride(r, principal, checks)
 
-	toDeploy, err := retrieveReleasesToDeploy(appContext.HelmServiceAPI, kubeConfig, srcEnvironment.Namespace)
-	if err != nil {
+	if p.user, err = appContext.Repositories.UserDAO.FindByEmail(principal.Email); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	appContext.doIt(kubeConfig, targetEnvironment, toPurge, toDeploy, principal)
+	requestDeployment := model.RequestDeployment{}
+	requestDeployment.Success = false
+	requestDeployment.Processed = false
+	requestDeployment.UserID = p.user.ID
+	if p.requestDeploymentID, err = appContext.Repositories.RequestDeploymentDAO.
+		CreateRequestDeployment(requestDeployment); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	auditValues := make(map[string]string)
 	auditValues["sourceEnvironment"] = srcEnvironment.Name
 	auditValues["targetEnvironment"] = targetEnvironment.Name
 	auditValues["mode"] = mode
+	auditValues["requestDeploymentID"] = strconv.Itoa(p.requestDeploymentID)
 
 	appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "promote", auditValues)
 
-	w.WriteHeader(http.StatusOK)
+	goAsync(func() {
+		appContext.runPromotion(p)
+	})
+
+	result := model.PromoteResult{RequestDeploymentID: p.requestDeploymentID}
+	if len(check.Violations) > 0 || len(check.Warnings) > 0 {
+		result.Rules = check
+	}
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(data)
 
 }
 
-func (appContext *AppContext) doIt(kubeConfig string, targetEnvironment *model.Environment, toPurge []releaseToDeploy, toDeploy []releaseToDeploy, principal model.Principal) error {
+//checkPromotionRules checks the rules of the variables the promotion copies, in the target environment
+func (appContext *AppContext) checkPromotionRules(p promotion, overrideReason string) (*model.DeployRulesCheck, error) {
+	srcVariables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(p.srcEnvironment.ID))
+	if err != nil {
+		return nil, err
+	}
 
-	logFields := global.AppFields{global.Function: "doIt - promoting", "target": targetEnvironment.Name}
+	variables := make([]model.Variable, 0)
+	for _, v := range srcVariables {
+		if p.includes(v) {
+			variables = append(variables, p.rewriter.rewrite(v))
+		}
+	}
+	return appContext.checkDeployRules(p.principal, p.targetEnvironment, variables, overrideReason)
+}
 
-	global.Logger.Info(logFields, "purgeAll")
-	err := appContext.purgeAll(kubeConfig, toPurge)
+//planPromotion returns what a promotion would do without changing anything
+func (appContext *AppContext) planPromotion(p promotion) (*model.PromotePlan, error) {
+
+	plan := &model.PromotePlan{
+		Mode:              p.mode,
+		SourceEnvironment: p.srcEnvironment.Name,
+		TargetEnvironment: p.targetEnvironment.Name,
+		ReleasesToPurge:   make([]model.PromoteRelease, 0),
+		ReleasesToDeploy:  make([]model.PromoteRelease, 0),
+		VariablesToDelete: make([]model.PromoteVariable, 0),
+		VariablesToCopy:   make([]model.PromoteVariable, 0),
+		VariablesToChange: make([]model.PromoteVariable, 0),
+		SchemaViolations:  make([]model.InvalidVariable, 0),
+	}
+
+	for _, e := range p.toPurge {
+		plan.ReleasesToPurge = append(plan.ReleasesToPurge, model.PromoteRelease{Name: e.Name, Chart: e.Chart})
+	}
+
+	for _, e := range p.toDeploy {
+		plan.ReleasesToDeploy = append(plan.ReleasesToDeploy, model.PromoteRelease{
+			Name:         e.Name + "-" + p.targetEnvironment.Namespace,
+			Chart:        e.Chart,
+			ChartVersion: e.ChartVersion,
+		})
+	}
+
+	srcVariables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(p.srcEnvironment.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	targetVariables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(p.targetEnvironment.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	targetByKey := make(map[string]model.Variable)
+	for _, v := range targetVariables {
+		targetByKey[v.Scope+"/"+v.Name] = v
+	}
+
+	srcKeys := make(map[string]bool)
+	promoted := make([]model.Variable, 0)
+	for _, v := range srcVariables {
+		if !p.includes(v) {
+			continue
+		}
+		v = p.rewriter.rewrite(v)
+		key := v.Scope + "/" + v.Name
+		srcKeys[key] = true
+		promoted = append(promoted, v)
+
+		change := model.PromoteVariable{Scope: v.Scope, Name: v.Name, Secret: v.Secret,
+			NewValue: maskedValue(v)}
+		target, ok := targetByKey[key]
+		if !ok {
+			plan.VariablesToCopy = append(plan.VariablesToCopy, change)
+		} else if target.Value != v.Value || target.Secret != v.Secret {
+			change.OldValue = maskedValue(target)
+			plan.VariablesToChange = append(plan.VariablesToChange, change)
+		}
+	}
+
+	for _, v := range targetVariables {
+		if srcKeys[v.Scope+"/"+v.Name] {
+			continue
+		}
+		if p.mode == "full" {
+			plan.VariablesToDelete = append(plan.VariablesToDelete,
+				model.PromoteVariable{Scope: v.Scope, Name: v.Name, Secret: v.Secret, OldValue: maskedValue(v)})
+		} else {
+			promoted = append(promoted, v)
+		}
+	}
+
+	if plan.SchemaViolations, err = appContext.planSchemaViolations(p, promoted); err != nil {
+		return nil, err
+	}
+
+	sortPromoteVariables(plan.VariablesToDelete)
+	sortPromoteVariables(plan.VariablesToCopy)
+	sortPromoteVariables(plan.VariablesToChange)
+
+	return plan, nil
+}
+
+//planSchemaViolations validates the releases a promotion deploys against their values.schema.json,
+//with the variables the target environment has once promoted
+func (appContext *AppContext) planSchemaViolations(p promotion,
+	promoted []model.Variable) ([]model.InvalidVariable, error) {
+
+	result := make([]model.InvalidVariable, 0)
+	globalVariables := scopeVariables(promoted, "global")
+	for _, e := range p.toDeploy {
+		schema, err := appContext.getValuesSchema(e.Chart, e.ChartVersion)
+		if err != nil {
+			return nil, err
+		}
+		if schema == nil {
+			continue
+		}
+		variables := scopeVariables(promoted, releaseSearchTerm(convertPayload(e)))
+		violations, err := appContext.validateValuesSchema(schema, p.targetEnvironment, e.Chart, e.ChartVersion,
+			variables, globalVariables)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, violations...)
+	}
+	return result, nil
+}
+
+func scopeVariables(variables []model.Variable, scope string) []model.Variable {
+	result := make([]model.Variable, 0)
+	for _, v := range variables {
+		if v.Scope == scope {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func sortPromoteVariables(variables []model.PromoteVariable) {
+	sort.Slice(variables, func(i, j int) bool {
+		if variables[i].Scope == variables[j].Scope {
+			return variables[i].Name < variables[j].Name
+		}
+		return variables[i].Scope < variables[j].Scope
+	})
+}
+
+func isImageVariable(name string) bool {
+	return name == "image.tag" || name == "image.repository"
+}
+
+//includes tells if a source variable is copied to the target by the promotion mode
+func (p promotion) includes(variable model.Variable) bool {
+	switch p.mode {
+	case "full":
+		return true
+	case "sync":
+		return !p.excludes(variable)
+	default:
+		return isImageVariable(variable.Name)
+	}
+}
+
+//excludes tells if a variable is a target specific override that sync must keep
+func (p promotion) excludes(variable model.Variable) bool {
+	for _, e := range p.exclude {
+		if e == variable.Name || e == variable.Scope+"/"+variable.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func extractExclusions(r *http.Request) []string {
+	result := make([]string, 0)
+	for _, param := range r.URL.Query()["exclude"] {
+		for _, e := range strings.Split(param, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				result = append(result, e)
+			}
+		}
+	}
+	return result
+}
+
+//reconcileReleases keeps only the releases a sync promotion has to touch: the ones missing
+//in the target or with a different chart version or image tag, plus the orphans when requested
+func (appContext *AppContext) reconcileReleases(p *promotion) error {
+
+	targetReleases, err := retrieveReleasesToDeploy(appContext.HelmServiceAPI, p.kubeConfig, p.targetEnvironment.Namespace)
 	if err != nil {
 		return err
 	}
 
-	global.Logger.Info(logFields, "findByEmail")
-	user, err := appContext.Repositories.UserDAO.FindByEmail(principal.Email)
+	srcVariables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(p.srcEnvironment.ID))
 	if err != nil {
 		return err
 	}
 
-	global.Logger.Info(logFields, "getModelRepository")
-	repository, err := appContext.getModelRepositoryDefault(principal)
+	targetVariables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(p.targetEnvironment.ID))
 	if err != nil {
 		return err
 	}
 
-	out := &bytes.Buffer{}
+	targetByName := make(map[string]releaseToDeploy)
+	for _, e := range targetReleases {
+		targetByName[e.Name] = e
+	}
 
-	requestDeployment := model.RequestDeployment{}
-	requestDeployment.Success = false
-	requestDeployment.Processed = false
-	requestDeployment.UserID = user.ID
-	requestDeploymentID, err := appContext.Repositories.RequestDeploymentDAO.CreateRequestDeployment(requestDeployment)
+	srcNames := make(map[string]bool)
+	toDeploy := make([]releaseToDeploy, 0)
+	for _, e := range p.toDeploy {
+		srcNames[e.Name] = true
+		target, ok := targetByName[e.Name]
+		if !ok || target.ChartVersion != e.ChartVersion {
+			toDeploy = append(toDeploy, e)
+			continue
+		}
+		srcTag, found := getImageTagOfChart(srcVariables, e.Chart)
+		if found && !p.excludes(srcTag) && srcTag.Value != getImageTagValueOfChart(targetVariables, e.Chart) {
+			toDeploy = append(toDeploy, e)
+		}
+	}
+
+	toPurge := make([]releaseToDeploy, 0)
+	if p.removeOrphans {
+		for _, e := range targetReleases {
+			if !srcNames[e.Name] {
+				toPurge = append(toPurge, releaseToDeploy{Name: e.Name + "-" + p.targetEnvironment.Namespace, Chart: e.Chart})
+			}
+		}
+	}
+
+	p.toDeploy = toDeploy
+	p.toPurge = toPurge
+	return nil
+}
+
+func getImageTagOfChart(variables []model.Variable, chart string) (model.Variable, bool) {
+	for _, v := range variables {
+		if v.Name == "image.tag" && (v.Scope == chart || strings.HasSuffix(v.Scope, "/"+chart)) {
+			return v, true
+		}
+	}
+	return model.Variable{}, false
+}
+
+func getImageTagValueOfChart(variables []model.Variable, chart string) string {
+	v, _ := getImageTagOfChart(variables, chart)
+	return v.Value
+}
 
-	for _, e := range toDeploy {
+//runPromotion copies the variables, purges and deploys the releases of a promotion,
+//recording every failure in the request deployment
+func (appContext *AppContext) runPromotion(p promotion) {
+
+	logFields := global.AppFields{global.Function: "runPromotion", "target": p.targetEnvironment.Name}
+
+	var err error
+	switch p.mode {
+	case "full":
+		if err = appContext.deleteEnvironmentVariables(p.targetEnvironment.ID, p.principal.Email); err == nil {
+			err = appContext.copyEnvironmentVariablesFromSrcToTarget(p.srcEnvironment.ID, p.targetEnvironment.ID,
+				p.rewriter, p.principal.Email)
+		}
+	case "sync":
+		err = appContext.mergeEnvironmentVariables(p)
+	default:
+		err = appContext.copyImageAndTagFromSrcToTarget(p.srcEnvironment.ID, p.targetEnvironment.ID,
+			p.rewriter, p.principal.Email)
+	}
+
+	if err != nil {
+		global.Logger.Error(logFields, "copy variables - error: "+err.Error())
+		appContext.finishPromotion(p, []string{"copy variables: " + err.Error()}, 0)
+		return
+	}
+
+	failures, published := appContext.doIt(p)
+	appContext.finishPromotion(p, failures, published)
+}
+
+//doIt purges and deploys the releases, returning the failures and how many deployments were queued
+func (appContext *AppContext) doIt(p promotion) ([]string, int) {
+
+	logFields := global.AppFields{global.Function: "doIt - promoting", "target": p.targetEnvironment.Name}
+	failures := make([]string, 0)
+
+	global.Logger.Info(logFields, "purgeAll")
+	failures = append(failures, appContext.purgeAll(p)...)
+
+	global.Logger.Info(logFields, "getModelRepository")
+	repository, err := appContext.getModelRepositoryDefault(p.principal)
+	if err != nil {
+		global.Logger.Error(logFields, "getModelRepository - error: "+err.Error())
+		return append(failures, "default repository: "+err.Error()), 0
+	}
+
+	out := &bytes.Buffer{}
+	published := 0
+
+	for _, e := range p.toDeploy {
 		installPayload := convertPayload(e)
 		installPayload.Chart = addRepoPrefix(installPayload.Chart, repository)
-		installPayload.EnvironmentID = int(targetEnvironment.ID)
-
+		installPayload.EnvironmentID = int(p.targetEnvironment.ID)
+
+		_, err := appContext.simpleInstall(
+			p.targetEnvironment,
+			installPayload,
+			out,
+			false,
+			false,
+			fmt.Sprint(p.user.ID),
+			p.requestDeploymentID,
+		)
 		if err != nil {
-			global.Logger.Info(logFields, "helmInstall - error: "+err.Error())
-		} else {
-			appContext.simpleInstall(
-				targetEnvironment,
-				installPayload,
-				out,
-				false,
-				false,
-				fmt.Sprint(user.ID),
-				requestDeploymentID,
-			)
+			global.Logger.Error(logFields, "helmInstall - error: "+err.Error())
+			failures = append(failures, e.Name+": "+err.Error())
+			appContext.recordPromotionFailure(p, installPayload.Chart, installPayload.ChartVersion,
+				installPayload.Name+"-"+p.targetEnvironment.Namespace, err)
+			continue
 		}
+		published++
 	}
-	return nil
+	return failures, published
+}
+
+//recordPromotionFailure stores a release that could not be purged or deployed as a processed
+//deployment, so the request deployment is not closed as successful when the queued ones succeed
+func (appContext *AppContext) recordPromotionFailure(p promotion, chart string, chartVersion string,
+	releaseName string, err error) {
+
+	deployment := model.Deployment{}
+	deployment.EnvironmentID = p.targetEnvironment.ID
+	deployment.RequestDeploymentID = uint(p.requestDeploymentID)
+	deployment.Chart = chart
+	deployment.ChartVersion = chartVersion
+	deployment.ReleaseName = releaseName
+	deployment.PreviousProductVersion = p.targetEnvironment.ProductVersion
+	deployment.PreviousProductVersionID = p.targetEnvironment.ProductVersionID
+	deployment.Processed = true
+	deployment.Success = false
+	deployment.Message = err.Error()
+	appContext.Repositories.DeploymentDAO.CreateDeployment(deployment)
+}
+
+//finishPromotion writes the failure summary. When nothing was queued nobody else
+//will close the request deployment, so it is closed here.
+func (appContext *AppContext) finishPromotion(p promotion, failures []string, published int) {
+	if len(failures) == 0 && published > 0 {
+		return
+	}
+
+	rd, err := appContext.Repositories.RequestDeploymentDAO.GetRequestDeploymentByID(p.requestDeploymentID)
+	if err != nil {
+		global.Logger.Error(global.AppFields{global.Function: "finishPromotion"}, err.Error())
+		return
+	}
+
+	rd.Success = len(failures) == 0
+	if len(failures) > 0 {
+		rd.Message = fmt.Sprintf("%d failure(s) promoting %s to %s: %s", len(failures),
+			p.srcEnvironment.Name, p.targetEnvironment.Name, strings.Join(failures, "; "))
+	}
+	if published == 0 {
+		rd.Processed = true
+	}
+	appContext.Repositories.RequestDeploymentDAO.EditRequestDeployment(rd)
 }
 
 func addRepoPrefix(chart string, repository model.Repository) string {
@@ -225,22 +624,36 @@ func convertPayload(e releaseToDeploy) model.InstallPayload {
 	return p
 }
 
-func (appContext *AppContext) purgeAll(kubeConfig string, envs []releaseToDeploy) error {
-	for _, e := range envs {
-		err := appContext.HelmServiceAPI.DeleteHelmRelease(kubeConfig, e.Name, true)
+func (appContext *AppContext) purgeAll(p promotion) []string {
+	failures := make([]string, 0)
+	for _, e := range p.toPurge {
+		err := appContext.HelmServiceAPI.DeleteHelmRelease(p.kubeConfig, e.Name, true)
 		if err != nil {
-			return err
+			failures = append(failures, "purge "+e.Name+": "+err.Error())
+			appContext.recordPromotionFailure(p, e.Chart, e.ChartVersion, e.Name, err)
 		}
 	}
-	return nil
+	return failures
 }
 
-func (appContext *AppContext) deleteEnvironmentVariables(envID uint) error {
-	err := appContext.Repositories.VariableDAO.DeleteVariableByEnvironmentID(int(envID))
-	return err
+func (appContext *AppContext) deleteEnvironmentVariables(envID uint, user string) error {
+	variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(envID))
+	if err != nil {
+		return err
+	}
+
+	if err := appContext.Repositories.VariableDAO.DeleteVariableByEnvironmentID(int(envID)); err != nil {
+		return err
+	}
+
+	for _, variable := range variables {
+		appContext.recordVariableRevision(user, model.VariableDeleted, variable, variable.Value)
+	}
+	return nil
 }
 
-func (appContext *AppContext) copyEnvironmentVariablesFromSrcToTarget(srcEnvID uint, targetEnvID uint) error {
+func (appContext *AppContext) copyEnvironmentVariablesFromSrcToTarget(srcEnvID uint, targetEnvID uint,
+	rewriter *variableRewriter, user string) error {
 
 	variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(srcEnvID))
 	if err != nil {
@@ -249,23 +662,85 @@ func (appContext *AppContext) copyEnvironmentVariablesFromSrcToTarget(srcEnvID u
 
 	var newVariable *model.Variable
 	for _, variable := range variables {
+		variable = rewriter.rewrite(variable)
 		newVariable = &model.Variable{}
 		newVariable.Name = variable.Name
 		newVariable.EnvironmentID = int(targetEnvID)
 		newVariable.Value = variable.Value
 		newVariable.Description = variable.Description
 		newVariable.Scope = variable.Scope
+		newVariable.Secret = variable.Secret
+		newVariable.Type = variable.Type
 
-		if _, _, err := appContext.Repositories.VariableDAO.CreateVariable(*newVariable); err != nil {
+		auditValues, updated, err := appContext.Repositories.VariableDAO.CreateVariable(*newVariable)
+		if err != nil {
 			return err
 		}
+		appContext.recordSavedVariable(user, *newVariable, auditValues, updated)
 	}
 
 	return nil
 
 }
 
-func (appContext *AppContext) copyImageAndTagFromSrcToTarget(srcEnvID uint, targetEnvID uint) error {
+//mergeEnvironmentVariables copies only missing or changed variables, keeping the excluded ones untouched
+func (appContext *AppContext) mergeEnvironmentVariables(p promotion) error {
+
+	srcVariables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(p.srcEnvironment.ID))
+	if err != nil {
+		return err
+	}
+
+	targetVariables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(p.targetEnvironment.ID))
+	if err != nil {
+		return err
+	}
+
+	targetByKey := make(map[string]model.Variable)
+	for _, v := range targetVariables {
+		targetByKey[v.Scope+"/"+v.Name] = v
+	}
+
+	for _, variable := range srcVariables {
+		if !p.includes(variable) {
+			continue
+		}
+		variable = p.rewriter.rewrite(variable)
+
+		target, ok := targetByKey[variable.Scope+"/"+variable.Name]
+		if !ok {
+			newVariable := model.Variable{}
+			newVariable.Name = variable.Name
+			newVariable.EnvironmentID = int(p.targetEnvironment.ID)
+			newVariable.Value = variable.Value
+			newVariable.Description = variable.Description
+			newVariable.Scope = variable.Scope
+			newVariable.Secret = variable.Secret
+			newVariable.Type = variable.Type
+			if _, _, err := appContext.Repositories.VariableDAO.CreateVariable(newVariable); err != nil {
+				return err
+			}
+			appContext.recordVariableRevision(p.principal.Email, model.VariableCreated, newVariable, "")
+			continue
+		}
+
+		if target.Value != variable.Value || target.Secret != variable.Secret || target.Type != variable.Type {
+			oldValue := target.Value
+			target.Value = variable.Value
+			target.Secret = variable.Secret
+			target.Type = variable.Type
+			if err := appContext.Repositories.VariableDAO.EditVariable(target); err != nil {
+				return err
+			}
+			appContext.recordVariableRevision(p.principal.Email, model.VariableUpdated, target, oldValue)
+		}
+	}
+
+	return nil
+}
+
+func (appContext *AppContext) copyImageAndTagFromSrcToTarget(srcEnvID uint, targetEnvID uint,
+	rewriter *variableRewriter, user string) error {
 
 	variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(srcEnvID))
 	if err != nil {
@@ -277,16 +752,21 @@ func (appContext *AppContext) copyImageAndTagFromSrcToTarget(srcEnvID uint, targ
 
 		if variable.Name == "image.tag" || variable.Name == "image.repository" {
 
+			variable = rewriter.rewrite(variable)
 			newVariable = &model.Variable{}
 			newVariable.Name = variable.Name
 			newVariable.EnvironmentID = int(targetEnvID)
 			newVariable.Value = variable.Value
 			newVariable.Description = variable.Description
 			newVariable.Scope = variable.Scope
+			newVariable.Secret = variable.Secret
+			newVariable.Type = variable.Type
 
-			if _, _, err := appContext.Repositories.VariableDAO.CreateVariable(*newVariable); err != nil {
+			auditValues, updated, err := appContext.Repositories.VariableDAO.CreateVariable(*newVariable)
+			if err != nil {
 				return err
 			}
+			appContext.recordSavedVariable(user, *newVariable, auditValues, updated)
 
 		}
 	}
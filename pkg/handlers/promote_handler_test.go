@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -12,9 +14,18 @@ import (
 	"github.com/softplan/tenkai-api/pkg/dbms/model"
 	"github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
 	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	mockRabbit "github.com/softplan/tenkai-api/pkg/rabbitmq/mocks"
+	helmapi "github.com/softplan/tenkai-api/pkg/service/_helm"
+	mockSvc "github.com/softplan/tenkai-api/pkg/service/_helm/mocks"
 )
 
-func doTest(t *testing.T, mode string) {
+func init() {
+	goAsync = func(fn func()) {
+		fn()
+	}
+}
+
+func getPromoteAppContext() *AppContext {
 
 	appContext := AppContext{}
 
@@ -49,6 +60,8 @@ func doTest(t *testing.T, mode string) {
 	appContext.Repositories.DeploymentDAO = mockDeploymentDAO
 	appContext.Repositories.EnvironmentDAO = mockEnvDao
 	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockCreateVariableRevision(&appContext)
+	mockListVariableRules(&appContext)
 	appContext.HelmServiceAPI = mockHelmSvc
 	appContext.Auditing = auditSvc
 	appContext.RabbitImpl = getMockRabbitMQ()
@@ -58,19 +71,34 @@ func doTest(t *testing.T, mode string) {
 	mockUserDAO.On("FindByEmail", mock.Anything).Return(user, nil)
 	appContext.Repositories.UserDAO = mockUserDAO
 
-	url := "/promote?mode=" + mode + "&srcEnvID=91&targetEnvID=92"
-	req, err := http.NewRequest("GET", url, nil)
-	assert.NoError(t, err)
-	assert.NotNil(t, req)
+	return &appContext
+}
 
+func doPromote(appContext *AppContext, url string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", url, nil)
 	mockPrincipal(req)
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(appContext.promote)
 	handler.ServeHTTP(rr, req)
+	return rr
+}
 
-	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+func doTest(t *testing.T, mode string) {
+
+	appContext := getPromoteAppContext()
+
+	rr := doPromote(appContext, "/promote?mode="+mode+"&srcEnvID=91&targetEnvID=92")
 
+	assert.Equal(t, http.StatusAccepted, rr.Code, "Response is not Accepted.")
+
+	var result model.PromoteResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, 1, result.RequestDeploymentID)
+
+	mockHelmSvc := appContext.HelmServiceAPI.(*mockSvc.HelmServiceInterface)
+	mockHelmSvc.AssertNumberOfCalls(t, "DeleteHelmRelease", 1)
+	mockHelmSvc.AssertNumberOfCalls(t, "Upgrade", 0)
 }
 
 func TestPromoteFull(t *testing.T) {
@@ -81,6 +109,97 @@ func TestPromotePartial(t *testing.T) {
 	doTest(t, "partial")
 }
 
+func TestPromotePlan(t *testing.T) {
+	appContext := getPromoteAppContext()
+
+	srcVariable := mockVariable()
+	srcVariable.Value = "new-password"
+	srcTag := model.Variable{Scope: "bar", Name: "image.tag", Value: "1.0.1"}
+	targetTag := model.Variable{Scope: "bar", Name: "image.tag", Value: "1.0.0"}
+	targetOnly := model.Variable{Scope: "bar", Name: "onlyInTarget", Value: "x"}
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 999).
+		Return([]model.Variable{srcVariable, srcTag}, nil).Once()
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 999).
+		Return([]model.Variable{targetTag, targetOnly}, nil).Once()
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	rr := doPromote(appContext, "/promote?mode=full&plan=true&srcEnvID=91&targetEnvID=92")
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+
+	var plan model.PromotePlan
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &plan))
+	assert.Equal(t, 1, len(plan.ReleasesToPurge))
+	assert.Equal(t, 1, len(plan.ReleasesToDeploy))
+	assert.Equal(t, "tjusuarios-master-dev", plan.ReleasesToDeploy[0].Name)
+	assert.Equal(t, "password", plan.VariablesToCopy[0].Name)
+	assert.Equal(t, "1.0.0", plan.VariablesToChange[0].OldValue)
+	assert.Equal(t, "1.0.1", plan.VariablesToChange[0].NewValue)
+	assert.Equal(t, "onlyInTarget", plan.VariablesToDelete[0].Name)
+	assert.Empty(t, plan.SchemaViolations)
+
+	mockVariableDAO.AssertNotCalled(t, "CreateVariable", mock.Anything)
+	appContext.HelmServiceAPI.(*mockSvc.HelmServiceInterface).
+		AssertNotCalled(t, "DeleteHelmRelease", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPromoteWithFailures(t *testing.T) {
+	appContext := getPromoteAppContext()
+
+	mockHelmSvc := &mockSvc.HelmServiceInterface{}
+	hlr := helmapi.HelmListResult{Releases: []helmapi.ListRelease{{Name: "foo-dev", Chart: "foo-0.1.0"}}}
+	mockHelmSvc.On("ListHelmDeployments", mock.Anything, mock.Anything).Return(&hlr, nil)
+	mockHelmSvc.On("DeleteHelmRelease", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("purge error"))
+	mockHelmSvc.On("GetRepositories").Return(nil, errors.New("repository error"))
+	appContext.HelmServiceAPI = mockHelmSvc
+
+	var rd model.RequestDeployment
+	mockRequestDeploymentDAO := &mockRepo.RequestDeploymentDAOInterface{}
+	mockRequestDeploymentDAO.On("CreateRequestDeployment", mock.Anything).Return(1, nil)
+	mockRequestDeploymentDAO.On("GetRequestDeploymentByID", 1).Return(model.RequestDeployment{}, nil)
+	mockRequestDeploymentDAO.On("EditRequestDeployment", mock.Anything).Run(func(args mock.Arguments) {
+		rd = args.Get(0).(model.RequestDeployment)
+	}).Return(nil)
+	appContext.Repositories.RequestDeploymentDAO = mockRequestDeploymentDAO
+
+	rr := doPromote(appContext, "/promote?mode=partial&srcEnvID=91&targetEnvID=92")
+
+	assert.Equal(t, http.StatusAccepted, rr.Code, "Response is not Accepted.")
+	assert.True(t, rd.Processed)
+	assert.False(t, rd.Success)
+	assert.Contains(t, rd.Message, "purge error")
+	assert.Contains(t, rd.Message, "repository error")
+
+	mockDeploymentDAO := appContext.Repositories.DeploymentDAO.(*mockRepo.DeploymentDAOInterface)
+	mockDeploymentDAO.AssertNumberOfCalls(t, "CreateDeployment", 1)
+	purged := mockDeploymentDAO.Calls[0].Arguments.Get(0).(model.Deployment)
+	assert.Equal(t, "foo-dev", purged.ReleaseName)
+	assert.Equal(t, uint(1), purged.RequestDeploymentID)
+	assert.True(t, purged.Processed)
+	assert.False(t, purged.Success)
+	assert.Equal(t, "purge error", purged.Message)
+}
+
+func TestPromote_NotReleasedVersion(t *testing.T) {
+	appContext := getPromoteAppContext()
+	env := mockGetEnv()
+	env.ProductVersionID = 999
+	appContext.Repositories.EnvironmentDAO.(*mockRepo.EnvironmentDAOInterface).ExpectedCalls[0].
+		ReturnArguments = []interface{}{&env, nil}
+	appContext.Repositories.ProductDAO = getTransitionProductDAO(model.ProductVersionCandidate)
+	appContext.Repositories.ProductDAO.(*mockRepo.ProductDAOInterface).ExpectedCalls[2].
+		ReturnArguments = []interface{}{model.Product{ValidateReleases: true}, nil}
+
+	rr := doPromote(appContext, "/promote?mode=full&srcEnvID=91&targetEnvID=92")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	assert.Contains(t, rr.Body.String(), "only released versions can be deployed")
+	appContext.Repositories.RequestDeploymentDAO.(*mockRepo.RequestDeploymentDAOInterface).
+		AssertNotCalled(t, "CreateRequestDeployment", mock.Anything)
+}
+
 func TestPromote_Unauthorized(t *testing.T) {
 	appContext := AppContext{}
 
@@ -125,3 +244,125 @@ func TestPromote_WithoutSrcEnvID(t *testing.T) {
 func TestPromote_WithoutTargetEnvID(t *testing.T) {
 	doTestParamsError(t, "/promote?mode=full&srcEnvID=91")
 }
+
+func getSyncAppContext() (*AppContext, *mockRepo.VariableDAOInterface, *mockSvc.HelmServiceInterface) {
+	appContext := getPromoteAppContext()
+
+	src := mockGetEnv()
+	src.ID = 91
+	src.Namespace = "dev"
+	target := mockGetEnv()
+	target.ID = 92
+	target.Namespace = "qa"
+	mockEnvDao := &mockRepo.EnvironmentDAOInterface{}
+	mockEnvDao.On("GetByID", 91).Return(&src, nil)
+	mockEnvDao.On("GetByID", 92).Return(&target, nil)
+	mockEnvDao.On("GetAllEnvironments", mock.Anything).Return([]model.Environment{src, target}, nil)
+	appContext.Repositories.EnvironmentDAO = mockEnvDao
+
+	srcReleases := helmapi.HelmListResult{Releases: []helmapi.ListRelease{
+		{Name: "foo-dev", Chart: "foo-0.2.0"},
+		{Name: "bar-dev", Chart: "bar-0.1.0"},
+		{Name: "baz-dev", Chart: "baz-0.1.0"},
+		{Name: "new-dev", Chart: "new-0.1.0"},
+	}}
+	targetReleases := helmapi.HelmListResult{Releases: []helmapi.ListRelease{
+		{Name: "foo-qa", Chart: "foo-0.1.0"},
+		{Name: "bar-qa", Chart: "bar-0.1.0"},
+		{Name: "baz-qa", Chart: "baz-0.1.0"},
+		{Name: "old-qa", Chart: "old-0.1.0"},
+	}}
+	mockHelmSvc := &mockSvc.HelmServiceInterface{}
+	mockHelmSvc.On("ListHelmDeployments", mock.Anything, "dev").Return(&srcReleases, nil)
+	mockHelmSvc.On("ListHelmDeployments", mock.Anything, "qa").Return(&targetReleases, nil)
+	mockHelmSvc.On("DeleteHelmRelease", mock.Anything, mock.Anything, true).Return(nil)
+	mockHelmSvc.On("GetRepositories").Return([]model.Repository{{Name: "alfa.beta"}}, nil)
+	mockHelmSvc.On("GetTemplate", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]byte(`{"app":{"myvar":"myvalue"}}`), nil)
+	appContext.HelmServiceAPI = mockHelmSvc
+
+	srcVariables := []model.Variable{
+		{Scope: "alfa.beta/bar", Name: "image.tag", Value: "1.1.0"},
+		{Scope: "alfa.beta/baz", Name: "image.tag", Value: "2.0.0"},
+		{Scope: "alfa.beta/foo", Name: "password", Value: "abc", Secret: true},
+		{Scope: "alfa.beta/foo", Name: "url", Value: "dev.host"},
+	}
+	targetVariables := []model.Variable{
+		{Scope: "alfa.beta/bar", Name: "image.tag", Value: "1.0.0"},
+		{Scope: "alfa.beta/baz", Name: "image.tag", Value: "2.0.0"},
+		{Scope: "alfa.beta/foo", Name: "url", Value: "qa.host"},
+		{Scope: "alfa.beta/foo", Name: "onlyInTarget", Value: "x"},
+	}
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 91).Return(srcVariables, nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 92).Return(targetVariables, nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", mock.Anything, mock.Anything).Return([]model.Variable{}, nil)
+	mockVariableDAO.On("CreateVariable", mock.Anything).Return(nil, true, nil)
+	mockVariableDAO.On("EditVariable", mock.Anything).Return(nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockCreateVariableRevision(appContext)
+
+	return appContext, mockVariableDAO, mockHelmSvc
+}
+
+func TestPromoteSyncPlan(t *testing.T) {
+	appContext, mockVariableDAO, _ := getSyncAppContext()
+
+	rr := doPromote(appContext, "/promote?mode=sync&plan=true&srcEnvID=91&targetEnvID=92&removeOrphans=true&exclude=alfa.beta/foo/url")
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+
+	var plan model.PromotePlan
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &plan))
+
+	deployed := make([]string, 0)
+	for _, e := range plan.ReleasesToDeploy {
+		deployed = append(deployed, e.Name)
+	}
+	assert.Equal(t, []string{"foo-qa", "bar-qa", "new-qa"}, deployed)
+	assert.Equal(t, 1, len(plan.ReleasesToPurge))
+	assert.Equal(t, "old-qa", plan.ReleasesToPurge[0].Name)
+
+	assert.Equal(t, 1, len(plan.VariablesToCopy))
+	assert.Equal(t, "password", plan.VariablesToCopy[0].Name)
+	assert.True(t, plan.VariablesToCopy[0].Secret)
+	assert.Equal(t, "******", plan.VariablesToCopy[0].NewValue)
+	assert.Equal(t, 1, len(plan.VariablesToChange))
+	assert.Equal(t, "image.tag", plan.VariablesToChange[0].Name)
+	assert.Equal(t, 0, len(plan.VariablesToDelete))
+
+	mockVariableDAO.AssertNotCalled(t, "CreateVariable", mock.Anything)
+}
+
+func TestPromoteSync(t *testing.T) {
+	appContext, mockVariableDAO, mockHelmSvc := getSyncAppContext()
+
+	rr := doPromote(appContext, "/promote?mode=sync&srcEnvID=91&targetEnvID=92&exclude=url")
+
+	assert.Equal(t, http.StatusAccepted, rr.Code, "Response is not Accepted.")
+
+	mockHelmSvc.AssertNotCalled(t, "DeleteHelmRelease", mock.Anything, mock.Anything, mock.Anything)
+	mockVariableDAO.AssertNotCalled(t, "DeleteVariableByEnvironmentID", mock.Anything)
+	mockVariableDAO.AssertNumberOfCalls(t, "CreateVariable", 1)
+	mockVariableDAO.AssertCalled(t, "CreateVariable", model.Variable{
+		Scope: "alfa.beta/foo", Name: "password", Value: "abc", Secret: true, EnvironmentID: 92,
+	})
+	mockVariableDAO.AssertNumberOfCalls(t, "EditVariable", 1)
+	appContext.RabbitImpl.(*mockRabbit.RabbitInterface).AssertNumberOfCalls(t, "Publish", 3)
+}
+
+func TestCopyEnvironmentVariablesKeepsSecret(t *testing.T) {
+	appContext := AppContext{}
+	secret := model.Variable{Scope: "bar", Name: "password", Value: "abc", Secret: true, EnvironmentID: 91}
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 91).Return([]model.Variable{secret}, nil)
+	mockVariableDAO.On("CreateVariable", mock.Anything).Return(nil, true, nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockCreateVariableRevision(&appContext)
+
+	assert.NoError(t, appContext.copyEnvironmentVariablesFromSrcToTarget(91, 92, nil, "beta@alfa.com"))
+
+	secret.EnvironmentID = 92
+	mockVariableDAO.AssertCalled(t, "CreateVariable", secret)
+}
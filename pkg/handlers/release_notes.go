@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+const releaseNotesHTML = `<h1>{{.Product}} {{.ToVersion}}</h1>
+<p>Changes since {{.FromVersion}}</p>
+{{define "services"}}<table>
+<tr><th>Service</th><th>Image tag</th><th>Chart version</th><th>Notes</th><th>Service notes</th></tr>
+{{range .}}<tr><td>{{.ServiceName}}</td><td>{{versions .FromImageTag .ToImageTag}}</td><td>{{versions .FromChartVersion .ToChartVersion}}</td><td>{{.Notes}}</td><td>{{.ServiceNotes}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Added}}<h2>Added</h2>
+{{template "services" .Added}}{{end}}{{if .Changed}}<h2>Changed</h2>
+{{template "services" .Changed}}{{end}}{{if .Removed}}<h2>Removed</h2>
+{{template "services" .Removed}}{{end}}`
+
+var releaseNotesTemplate = template.Must(template.New("releaseNotes").
+	Funcs(template.FuncMap{"versions": formatVersionChange}).Parse(releaseNotesHTML))
+
+func (appContext *AppContext) releaseNotes(w http.ResponseWriter, r *http.Request) {
+
+	fromID, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	toID, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "markdown" && format != "html" {
+		http.Error(w, "Invalid format: "+format, http.StatusBadRequest)
+		return
+	}
+
+	notes, _, err := appContext.buildReleaseNotes(fromID, toID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch format {
+	case "markdown":
+		w.Header().Set(global.ContentType, "text/markdown; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(renderReleaseNotesMarkdown(notes)))
+	case "html":
+		out := &bytes.Buffer{}
+		if err := releaseNotesTemplate.Execute(out, notes); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(global.ContentType, "text/html; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out.Bytes())
+	default:
+		data, _ := json.Marshal(notes)
+		w.Header().Set(global.ContentType, global.JSONContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}
+}
+
+//notifyReleaseNotes sends the release notes between two product versions to the HOOK_NEW_RELEASE webhooks
+func (appContext *AppContext) notifyReleaseNotes(w http.ResponseWriter, r *http.Request) {
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		if auth, _ := appContext.hasAnyEnvironmentRole(principal, "ACTION_DEPLOY"); !auth {
+			http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	fromID, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	toID, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	notes, productID, err := appContext.buildReleaseNotes(fromID, toID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := appContext.postReleaseNotes(notes, productID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	auditValues := make(map[string]string)
+	auditValues["product"] = notes.Product
+	auditValues["fromVersion"] = notes.FromVersion
+	auditValues["toVersion"] = notes.ToVersion
+	appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "notifyReleaseNotes", auditValues)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+//buildReleaseNotes compares the services of two versions of the same product
+func (appContext *AppContext) buildReleaseNotes(fromID int, toID int) (model.ReleaseNotes, int, error) {
+
+	var notes model.ReleaseNotes
+
+	from, err := appContext.Repositories.ProductDAO.ListProductVersionsByID(fromID)
+	if err != nil {
+		return notes, 0, err
+	}
+
+	to, err := appContext.Repositories.ProductDAO.ListProductVersionsByID(toID)
+	if err != nil {
+		return notes, 0, err
+	}
+
+	if from.ProductID != to.ProductID {
+		return notes, 0, errors.New("Product versions belong to different products")
+	}
+
+	product, err := appContext.Repositories.ProductDAO.FindProductByID(to.ProductID)
+	if err != nil {
+		return notes, 0, err
+	}
+
+	fromServices, err := appContext.Repositories.ProductDAO.ListProductsVersionServices(fromID)
+	if err != nil {
+		return notes, 0, err
+	}
+
+	toServices, err := appContext.Repositories.ProductDAO.ListProductsVersionServices(toID)
+	if err != nil {
+		return notes, 0, err
+	}
+
+	notes.Product = product.Name
+	notes.FromVersion = from.Version
+	notes.ToVersion = to.Version
+	notes.Added = make([]model.ReleaseNotesService, 0)
+	notes.Removed = make([]model.ReleaseNotesService, 0)
+	notes.Changed = make([]model.ReleaseNotesService, 0)
+
+	fromByName := make(map[string]model.ProductVersionService)
+	for _, s := range fromServices {
+		fromByName[splitSrvNameIfNeeded(s.ServiceName)] = s
+	}
+
+	toByName := make(map[string]bool)
+	for _, s := range toServices {
+		name := splitSrvNameIfNeeded(s.ServiceName)
+		toByName[name] = true
+
+		item := model.ReleaseNotesService{
+			ServiceName:    name,
+			ToImageTag:     s.DockerImageTag,
+			ToChartVersion: splitChartVersion(s.ServiceName),
+		}
+
+		previous, ok := fromByName[name]
+		if !ok {
+			item.Notes, item.ServiceNotes = appContext.getServiceNotes(s)
+			notes.Added = append(notes.Added, item)
+			continue
+		}
+
+		item.FromImageTag = previous.DockerImageTag
+		item.FromChartVersion = splitChartVersion(previous.ServiceName)
+		if item.FromImageTag != item.ToImageTag || item.FromChartVersion != item.ToChartVersion {
+			item.Notes, item.ServiceNotes = appContext.getServiceNotes(s)
+			notes.Changed = append(notes.Changed, item)
+		}
+	}
+
+	for _, s := range fromServices {
+		name := splitSrvNameIfNeeded(s.ServiceName)
+		if !toByName[name] {
+			notes.Removed = append(notes.Removed, model.ReleaseNotesService{
+				ServiceName:      name,
+				FromImageTag:     s.DockerImageTag,
+				FromChartVersion: splitChartVersion(s.ServiceName),
+			})
+		}
+	}
+
+	sortReleaseNotesServices(notes.Added)
+	sortReleaseNotesServices(notes.Removed)
+	sortReleaseNotesServices(notes.Changed)
+
+	return notes, to.ProductID, nil
+}
+
+//getServiceNotes returns the notes of the product version service and the notes registered for
+//the service, which are kept by chart name without version
+func (appContext *AppContext) getServiceNotes(service model.ProductVersionService) (string, string) {
+	notes, err := appContext.Repositories.NotesDAO.GetByServiceName(splitSrvNameIfNeeded(service.ServiceName))
+	if err != nil || notes == nil {
+		return service.Notes, ""
+	}
+	return service.Notes, notes.Text
+}
+
+func sortReleaseNotesServices(services []model.ReleaseNotesService) {
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ServiceName < services[j].ServiceName
+	})
+}
+
+func formatVersionChange(from string, to string) string {
+	if from == "" {
+		return to
+	}
+	if to == "" || from == to {
+		return from
+	}
+	return from + " -> " + to
+}
+
+func renderReleaseNotesMarkdown(notes model.ReleaseNotes) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s %s\n\nChanges since %s\n", notes.Product, notes.ToVersion, notes.FromVersion))
+
+	sections := []struct {
+		title    string
+		services []model.ReleaseNotesService
+	}{
+		{"Added", notes.Added},
+		{"Changed", notes.Changed},
+		{"Removed", notes.Removed},
+	}
+
+	for _, section := range sections {
+		if len(section.services) == 0 {
+			continue
+		}
+		sb.WriteString("\n## " + section.title + "\n\n")
+		sb.WriteString("| Service | Image tag | Chart version | Notes | Service notes |\n")
+		sb.WriteString("|---|---|---|---|---|\n")
+		for _, s := range section.services {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n", s.ServiceName,
+				formatVersionChange(s.FromImageTag, s.ToImageTag),
+				formatVersionChange(s.FromChartVersion, s.ToChartVersion), markdownCell(s.Notes),
+				markdownCell(s.ServiceNotes)))
+		}
+	}
+
+	return sb.String()
+}
+
+func markdownCell(text string) string {
+	return strings.Replace(strings.Replace(text, "\n", "<br>", -1), "|", "\\|", -1)
+}
+
+//postReleaseNotes sends the release notes to the HOOK_NEW_RELEASE webhooks
+func (appContext *AppContext) postReleaseNotes(notes model.ReleaseNotes, productID int) error {
+
+	webHooks, err := appContext.Repositories.WebHookDAO.ListWebHooksByEnvAndType(-1, "HOOK_NEW_RELEASE")
+	if err != nil {
+		return err
+	}
+
+	markdown := renderReleaseNotesMarkdown(notes)
+	for _, hook := range webHooks {
+		var p model.WebHookReleaseNotesPostPayload
+		p.ProductName = notes.Product
+		p.Release = notes.ToVersion
+		p.AdditionalData = hook.AdditionalData
+		p.ReleaseNotes = notes
+		p.Markdown = markdown
+		payloadStr, _ := json.Marshal(p)
+		resp, err := http.Post(hook.URL, "application/json", bytes.NewBuffer(payloadStr))
+		if err != nil {
+			global.Logger.Error(global.AppFields{global.Function: "postReleaseNotes", "productID": productID},
+				"Error trying to post to webhook "+hook.URL+": "+err.Error())
+			continue
+		}
+		resp.Body.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func getReleaseNotesAppContext() *AppContext {
+	appContext := AppContext{}
+
+	from := model.ProductVersion{ProductID: 1, Version: "1.0.0"}
+	from.ID = 10
+	to := model.ProductVersion{ProductID: 1, Version: "1.1.0"}
+	to.ID = 11
+	product := model.Product{Name: "My Product"}
+	product.ID = 1
+
+	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 10).Return(&from, nil)
+	mockProductDAO.On("ListProductVersionsByID", 11).Return(&to, nil)
+	mockProductDAO.On("FindProductByID", 1).Return(product, nil)
+	mockProductDAO.On("ListProductsVersionServices", 10).Return([]model.ProductVersionService{
+		{ServiceName: "repo/foo - 0.1.0", DockerImageTag: "1.0.0"},
+		{ServiceName: "repo/bar - 0.1.0", DockerImageTag: "2.0.0"},
+		{ServiceName: "repo/old - 0.1.0", DockerImageTag: "3.0.0"},
+	}, nil)
+	mockProductDAO.On("ListProductsVersionServices", 11).Return([]model.ProductVersionService{
+		{ServiceName: "repo/foo - 0.2.0", DockerImageTag: "1.1.0", Notes: "Fixes login | logout"},
+		{ServiceName: "repo/bar - 0.1.0", DockerImageTag: "2.0.0"},
+		{ServiceName: "repo/new - 0.1.0", DockerImageTag: "1.0.0"},
+	}, nil)
+	appContext.Repositories.ProductDAO = mockProductDAO
+
+	mockNotesDAO := &mockRepo.NotesDAOInterface{}
+	mockNotesDAO.On("GetByServiceName", "repo/new").Return(&model.Notes{Text: "First release"}, nil)
+	mockNotesDAO.On("GetByServiceName", "repo/foo").Return(&model.Notes{Text: "Login service"}, nil)
+	appContext.Repositories.NotesDAO = mockNotesDAO
+
+	return &appContext
+}
+
+func TestReleaseNotes(t *testing.T) {
+	appContext := getReleaseNotesAppContext()
+
+	req, err := http.NewRequest("GET", "/productVersions/releaseNotes?from=10&to=11", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.releaseNotes)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var notes model.ReleaseNotes
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &notes))
+	assert.Equal(t, "My Product", notes.Product)
+	assert.Equal(t, "1.0.0", notes.FromVersion)
+	assert.Equal(t, "1.1.0", notes.ToVersion)
+
+	assert.Equal(t, []model.ReleaseNotesService{
+		{ServiceName: "repo/new", ToImageTag: "1.0.0", ToChartVersion: "0.1.0", ServiceNotes: "First release"},
+	}, notes.Added)
+	assert.Equal(t, []model.ReleaseNotesService{
+		{ServiceName: "repo/old", FromImageTag: "3.0.0", FromChartVersion: "0.1.0"},
+	}, notes.Removed)
+	assert.Equal(t, []model.ReleaseNotesService{
+		{ServiceName: "repo/foo", FromImageTag: "1.0.0", ToImageTag: "1.1.0",
+			FromChartVersion: "0.1.0", ToChartVersion: "0.2.0", Notes: "Fixes login | logout",
+			ServiceNotes: "Login service"},
+	}, notes.Changed)
+}
+
+func TestReleaseNotesMarkdown(t *testing.T) {
+	appContext := getReleaseNotesAppContext()
+
+	req, err := http.NewRequest("GET", "/productVersions/releaseNotes?from=10&to=11&format=markdown", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.releaseNotes)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	body := rr.Body.String()
+	assert.Contains(t, body, "# My Product 1.1.0")
+	assert.Contains(t, body, "| repo/foo | 1.0.0 -> 1.1.0 | 0.1.0 -> 0.2.0 | Fixes login \\| logout | Login service |")
+	assert.Contains(t, body, "## Removed")
+}
+
+func TestReleaseNotesHTML(t *testing.T) {
+	appContext := getReleaseNotesAppContext()
+
+	req, err := http.NewRequest("GET", "/productVersions/releaseNotes?from=10&to=11&format=html", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.releaseNotes)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, "text/html; charset=UTF-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "<td>repo/new</td>")
+}
+
+func TestReleaseNotesNotify(t *testing.T) {
+	appContext := getReleaseNotesAppContext()
+
+	var received model.WebHookReleaseNotesPostPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	hook := mockWebHook()
+	hook.URL = server.URL
+	mockWebHookDAO := &mockRepo.WebHookDAOInterface{}
+	mockWebHookDAO.On("ListWebHooksByEnvAndType", -1, "HOOK_NEW_RELEASE").Return([]model.WebHook{hook}, nil)
+	appContext.Repositories.WebHookDAO = mockWebHookDAO
+
+	auditSvc := mockDoAudit(appContext, "notifyReleaseNotes",
+		map[string]string{"product": "My Product", "fromVersion": "1.0.0", "toVersion": "1.1.0"})
+
+	req, err := http.NewRequest("POST", "/productVersions/releaseNotes/notify?from=10&to=11", nil)
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.notifyReleaseNotes)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, "1.1.0", received.Release)
+	assert.Equal(t, 1, len(received.ReleaseNotes.Changed))
+	assert.Contains(t, received.Markdown, "## Added")
+	auditSvc.AssertNumberOfCalls(t, "DoAudit", 1)
+}
+
+func TestReleaseNotesNotifyAccessDenied(t *testing.T) {
+	appContext := getReleaseNotesAppContext()
+	mockWebHookDAO := &mockRepo.WebHookDAOInterface{}
+	appContext.Repositories.WebHookDAO = mockWebHookDAO
+
+	user := mockUser()
+	env := model.Environment{Name: "bar"}
+	env.ID = 999
+	mockEnvDao := &mockRepo.EnvironmentDAOInterface{}
+	mockEnvDao.On("GetAllEnvironments", user.Email).Return([]model.Environment{env}, nil)
+	appContext.Repositories.EnvironmentDAO = mockEnvDao
+
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", user.Email).Return(user, nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	noPolicies := model.SecurityOperation{Name: "NONE"}
+	mockUserEnvRoleDAO := &mockRepo.UserEnvironmentRoleDAOInterface{}
+	mockUserEnvRoleDAO.On("GetRoleByUserAndEnvironment", user, uint(999)).Return(&noPolicies, nil)
+	appContext.Repositories.UserEnvironmentRoleDAO = mockUserEnvRoleDAO
+
+	req, err := http.NewRequest("POST", "/productVersions/releaseNotes/notify?from=10&to=11", nil)
+	assert.NoError(t, err)
+	pSe, _ := json.Marshal(model.Principal{Email: user.Email})
+	req.Header.Set("principal", string(pSe))
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.notifyReleaseNotes)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+	mockWebHookDAO.AssertNotCalled(t, "ListWebHooksByEnvAndType", -1, "HOOK_NEW_RELEASE")
+}
+
+func TestReleaseNotesDifferentProducts(t *testing.T) {
+	appContext := AppContext{}
+	from := model.ProductVersion{ProductID: 1}
+	to := model.ProductVersion{ProductID: 2}
+	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 10).Return(&from, nil)
+	mockProductDAO.On("ListProductVersionsByID", 11).Return(&to, nil)
+	appContext.Repositories.ProductDAO = mockProductDAO
+
+	req, err := http.NewRequest("GET", "/productVersions/releaseNotes?from=10&to=11", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.releaseNotes)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code, "Response should be 500.")
+}
+
+func TestReleaseNotesInvalidFormat(t *testing.T) {
+	appContext := AppContext{}
+
+	req, err := http.NewRequest("GET", "/productVersions/releaseNotes?from=10&to=11&format=pdf", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.releaseNotes)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	helmapi "github.com/softplan/tenkai-api/pkg/service/_helm"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+func (appContext *AppContext) rollbackRequestDeployment(w http.ResponseWriter, r *http.Request) {
+
+	principal := util.GetPrincipal(r)
+	isAdmin := util.Contains(principal.Roles, constraints.TenkaiAdmin)
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revertProductVersion, _ := strconv.ParseBool(r.URL.Query().Get("revertProductVersion"))
+	revertImageTags, _ := strconv.ParseBool(r.URL.Query().Get("revertImageTags"))
+
+	if _, err = appContext.Repositories.RequestDeploymentDAO.GetRequestDeploymentByID(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deployments, err := appContext.Repositories.DeploymentDAO.ListDeploymentsByRequestDeploymentID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	environments := make(map[uint]*model.Environment)
+	for _, d := range deployments {
+		if _, ok := environments[d.EnvironmentID]; ok {
+			continue
+		}
+		environment, err := appContext.Repositories.EnvironmentDAO.GetByID(int(d.EnvironmentID))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !isAdmin {
+			auth, _ := appContext.hasEnvironmentRole(principal, environment.ID, "ACTION_DEPLOY")
+			if !auth {
+				http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+		environments[d.EnvironmentID] = environment
+	}
+
+	user, err := appContext.Repositories.UserDAO.FindByEmail(principal.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	requestDeployment := model.RequestDeployment{}
+	requestDeployment.UserID = user.ID
+	requestDeploymentID, err := appContext.Repositories.RequestDeploymentDAO.CreateRequestDeployment(requestDeployment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := model.RequestDeploymentRollbackResult{
+		RequestDeploymentID: requestDeploymentID,
+		Success:             true,
+		Deployments:         make([]model.Deployment, 0),
+	}
+
+	done := make(map[string]bool)
+	for i := len(deployments) - 1; i >= 0; i-- {
+		environment := environments[deployments[i].EnvironmentID]
+		releaseName := getDeploymentReleaseName(deployments[i], environment)
+		key := strconv.Itoa(int(environment.ID)) + "/" + releaseName
+		if done[key] {
+			continue
+		}
+		done[key] = true
+
+		deployment := appContext.rollbackDeployment(deployments[i], environment, releaseName, revertImageTags,
+			principal.Email)
+		deployment.RequestDeploymentID = uint(requestDeploymentID)
+		if _, err := appContext.Repositories.DeploymentDAO.CreateDeployment(deployment); err != nil {
+			global.Logger.Error(global.AppFields{global.Function: "rollbackRequestDeployment"}, err.Error())
+		}
+
+		result.Success = result.Success && deployment.Success
+		result.Deployments = append(result.Deployments, deployment)
+	}
+
+	if revertProductVersion {
+		if err := appContext.revertProductVersions(deployments, environments); err != nil {
+			result.Success = false
+			global.Logger.Error(global.AppFields{global.Function: "rollbackRequestDeployment"}, err.Error())
+		}
+	}
+
+	requestDeployment.ID = uint(requestDeploymentID)
+	requestDeployment.Processed = true
+	requestDeployment.Success = result.Success
+	if err := appContext.Repositories.RequestDeploymentDAO.EditRequestDeployment(requestDeployment); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	auditValues := make(map[string]string)
+	auditValues["requestDeploymentID"] = strconv.Itoa(id)
+	auditValues["rollbackRequestDeploymentID"] = strconv.Itoa(requestDeploymentID)
+	auditValues["success"] = strconv.FormatBool(result.Success)
+	appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "rollbackRequestDeployment", auditValues)
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//getDeploymentReleaseName returns the helm release a deployment was made to
+func getDeploymentReleaseName(deployment model.Deployment, environment *model.Environment) string {
+	if deployment.ReleaseName != "" {
+		return deployment.ReleaseName
+	}
+	chart := deployment.Chart[strings.Index(deployment.Chart, "/")+1:]
+	return chart + "-" + environment.Namespace
+}
+
+//getRevisionBefore returns the latest revision deployed before the given time
+func getRevisionBefore(history helmapi.ReleaseHistory, before time.Time) (int, bool) {
+	revision := 0
+	limit := before.Truncate(time.Second)
+	for _, h := range history {
+		updated, err := time.ParseInLocation(time.ANSIC, h.Updated, time.Local)
+		if err != nil {
+			continue
+		}
+		if updated.Before(limit) && int(h.Revision) > revision {
+			revision = int(h.Revision)
+		}
+	}
+	return revision, revision > 0
+}
+
+func (appContext *AppContext) rollbackDeployment(src model.Deployment, environment *model.Environment,
+	releaseName string, revertImageTag bool, user string) model.Deployment {
+
+	deployment := model.Deployment{}
+	deployment.EnvironmentID = environment.ID
+	deployment.Chart = src.Chart
+	deployment.ReleaseName = releaseName
+	deployment.PreviousProductVersion = environment.ProductVersion
+	deployment.Processed = true
+
+	kubeConfig := appContext.ConventionInterface.GetKubeConfigFileName(environment.Group, environment.Name)
+
+	history, err := appContext.HelmServiceAPI.GetHelmReleaseHistory(kubeConfig, releaseName)
+	if err != nil {
+		deployment.Message = err.Error()
+		return deployment
+	}
+
+	revision, ok := getRevisionBefore(history, src.CreatedAt)
+	if !ok {
+		deployment.Message = "There is no revision of " + releaseName + " before the request deployment"
+		return deployment
+	}
+
+	if err := appContext.HelmServiceAPI.RollbackRelease(kubeConfig, releaseName, revision); err != nil {
+		deployment.Message = err.Error()
+		return deployment
+	}
+
+	deployment.Success = true
+	deployment.Message = fmt.Sprintf("Rolled back to revision %d", revision)
+
+	if revertImageTag {
+		tag, err := appContext.revertImageTag(kubeConfig, releaseName, revision, int(environment.ID),
+			src.Chart, user)
+		if err != nil {
+			deployment.Success = false
+			deployment.Message = deployment.Message + ", but image.tag was not reverted: " + err.Error()
+			return deployment
+		}
+		deployment.DockerVersion = tag
+	}
+
+	return deployment
+}
+
+//revertImageTag sets the image.tag variable of the chart to the tag used by a release revision
+func (appContext *AppContext) revertImageTag(kubeConfig string, releaseName string, revision int,
+	envID int, chart string, user string) (string, error) {
+
+	values, err := appContext.HelmServiceAPI.Get(kubeConfig, releaseName, revision)
+	if err != nil {
+		return "", err
+	}
+
+	var result map[string]interface{}
+	if err := yaml.Unmarshal([]byte(values), &result); err != nil {
+		return "", err
+	}
+
+	image, ok := result["image"].(map[string]interface{})
+	if !ok || image["tag"] == nil {
+		return "", nil
+	}
+	tag := fmt.Sprint(image["tag"])
+
+	variable, err := appContext.Repositories.VariableDAO.GetVarImageTagByEnvAndScope(envID, chart)
+	if err != nil || variable.ID == 0 || variable.Value == tag {
+		return tag, nil
+	}
+
+	oldValue := variable.Value
+	variable.Value = tag
+	if err := appContext.Repositories.VariableDAO.EditVariable(variable); err != nil {
+		return tag, err
+	}
+	appContext.recordVariableRevision(user, model.VariableUpdated, variable, oldValue)
+	return tag, nil
+}
+
+//revertProductVersions restores the product version each environment had before the request
+func (appContext *AppContext) revertProductVersions(deployments []model.Deployment,
+	environments map[uint]*model.Environment) error {
+
+	reverted := make(map[uint]bool)
+	for _, d := range deployments {
+		if reverted[d.EnvironmentID] {
+			continue
+		}
+		reverted[d.EnvironmentID] = true
+
+		environment := environments[d.EnvironmentID]
+		if environment.ProductVersion == d.PreviousProductVersion &&
+			environment.ProductVersionID == d.PreviousProductVersionID {
+			continue
+		}
+		environment.ProductVersion = d.PreviousProductVersion
+		environment.ProductVersionID = d.PreviousProductVersionID
+		if err := appContext.Repositories.EnvironmentDAO.EditEnvironment(*environment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
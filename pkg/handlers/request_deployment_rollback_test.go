@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	mockAud "github.com/softplan/tenkai-api/pkg/audit/mocks"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	helmapi "github.com/softplan/tenkai-api/pkg/service/_helm"
+	mockSvc "github.com/softplan/tenkai-api/pkg/service/_helm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getRollbackHistory(requestTime time.Time) helmapi.ReleaseHistory {
+	return helmapi.ReleaseHistory{
+		{Revision: 3, Updated: requestTime.Add(time.Minute).Format(time.ANSIC), Status: "DEPLOYED"},
+		{Revision: 2, Updated: requestTime.Add(-time.Hour).Format(time.ANSIC), Status: "SUPERSEDED"},
+		{Revision: 1, Updated: requestTime.Add(-48 * time.Hour).Format(time.ANSIC), Status: "SUPERSEDED"},
+	}
+}
+
+func getRollbackDeployments(requestTime time.Time) []model.Deployment {
+	first := model.Deployment{}
+	first.ID = 1
+	first.CreatedAt = requestTime
+	first.EnvironmentID = 999
+	first.Chart = "repo/foo"
+	first.ReleaseName = "foo-dev"
+	first.PreviousProductVersion = "1.0.0"
+	first.PreviousProductVersionID = 4
+
+	second := model.Deployment{}
+	second.ID = 2
+	second.CreatedAt = requestTime
+	second.EnvironmentID = 999
+	second.Chart = "repo/bar"
+	second.PreviousProductVersion = "1.0.0"
+	second.PreviousProductVersionID = 4
+
+	return []model.Deployment{first, second}
+}
+
+func getRollbackAppContext(deployments []model.Deployment) *AppContext {
+	appContext := AppContext{}
+
+	mockEnvDao := &mockRepo.EnvironmentDAOInterface{}
+	env := mockGetEnv()
+	env.ProductVersion = "2.0.0"
+	env.ProductVersionID = 5
+	mockEnvDao.On("GetByID", 999).Return(&env, nil)
+	mockEnvDao.On("EditEnvironment", mock.Anything).Return(nil)
+	appContext.Repositories.EnvironmentDAO = mockEnvDao
+
+	mockConventionInterface(&appContext)
+
+	mockRequestDeploymentDAO := &mockRepo.RequestDeploymentDAOInterface{}
+	mockRequestDeploymentDAO.On("GetRequestDeploymentByID", 17).Return(model.RequestDeployment{}, nil)
+	mockRequestDeploymentDAO.On("CreateRequestDeployment", mock.Anything).Return(18, nil)
+	mockRequestDeploymentDAO.On("EditRequestDeployment", mock.Anything).Return(nil)
+	appContext.Repositories.RequestDeploymentDAO = mockRequestDeploymentDAO
+
+	mockDeploymentDAO := &mockRepo.DeploymentDAOInterface{}
+	mockDeploymentDAO.On("ListDeploymentsByRequestDeploymentID", 17).Return(deployments, nil)
+	mockDeploymentDAO.On("CreateDeployment", mock.Anything).Return(1, nil)
+	appContext.Repositories.DeploymentDAO = mockDeploymentDAO
+
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", mock.Anything).Return(mockUser(), nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	variable := model.Variable{Scope: "repo/foo", Name: "image.tag", Value: "1.1.0"}
+	variable.ID = 5
+	mockVariableDAO.On("GetVarImageTagByEnvAndScope", 999, mock.Anything).Return(variable, nil)
+	mockVariableDAO.On("EditVariable", mock.Anything).Return(nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockCreateVariableRevision(&appContext)
+
+	mockAudit := &mockAud.AuditingInterface{}
+	mockAudit.On("DoAudit", mock.Anything, mock.Anything, "beta@alfa.com", "rollbackRequestDeployment", mock.Anything)
+	appContext.Auditing = mockAudit
+
+	return &appContext
+}
+
+func doRollbackRequestDeployment(appContext *AppContext, url string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", url, nil)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/requestDeployments/{id}/rollback", appContext.rollbackRequestDeployment).Methods("POST")
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRollbackRequestDeployment(t *testing.T) {
+	requestTime := time.Now()
+	appContext := getRollbackAppContext(getRollbackDeployments(requestTime))
+
+	mockHelmSvc := &mockSvc.HelmServiceInterface{}
+	mockHelmSvc.On("GetHelmReleaseHistory", "./config/foo_bar", mock.Anything).Return(getRollbackHistory(requestTime), nil)
+	mockHelmSvc.On("RollbackRelease", "./config/foo_bar", mock.Anything, 2).Return(nil)
+	mockHelmSvc.On("Get", "./config/foo_bar", mock.Anything, 2).Return("image:\n  tag: 1.0.0\n", nil)
+	appContext.HelmServiceAPI = mockHelmSvc
+
+	rr := doRollbackRequestDeployment(appContext, "/requestDeployments/17/rollback?revertProductVersion=true&revertImageTags=true")
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+
+	var result model.RequestDeploymentRollbackResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.True(t, result.Success)
+	assert.Equal(t, 18, result.RequestDeploymentID)
+	assert.Equal(t, 2, len(result.Deployments))
+	assert.Equal(t, "bar-dev", result.Deployments[0].ReleaseName)
+	assert.Equal(t, "foo-dev", result.Deployments[1].ReleaseName)
+	assert.Equal(t, "1.0.0", result.Deployments[1].DockerVersion)
+
+	mockHelmSvc.AssertCalled(t, "RollbackRelease", "./config/foo_bar", "bar-dev", 2)
+	mockHelmSvc.AssertCalled(t, "RollbackRelease", "./config/foo_bar", "foo-dev", 2)
+	appContext.Repositories.VariableDAO.(*mockRepo.VariableDAOInterface).AssertNumberOfCalls(t, "EditVariable", 2)
+	appContext.Repositories.VariableRevisionDAO.(*mockRepo.VariableRevisionDAOInterface).
+		AssertNumberOfCalls(t, "CreateVariableRevision", 2)
+	mockEnvDao := appContext.Repositories.EnvironmentDAO.(*mockRepo.EnvironmentDAOInterface)
+	mockEnvDao.AssertNumberOfCalls(t, "EditEnvironment", 1)
+	reverted := mockEnvDao.Calls[len(mockEnvDao.Calls)-1].Arguments.Get(0).(model.Environment)
+	assert.Equal(t, "1.0.0", reverted.ProductVersion)
+	assert.Equal(t, uint(4), reverted.ProductVersionID)
+}
+
+func TestRollbackRequestDeploymentNoPreviousRevision(t *testing.T) {
+	requestTime := time.Now()
+	appContext := getRollbackAppContext(getRollbackDeployments(requestTime)[:1])
+
+	history := helmapi.ReleaseHistory{
+		{Revision: 1, Updated: requestTime.Add(time.Minute).Format(time.ANSIC), Status: "DEPLOYED"},
+	}
+	mockHelmSvc := &mockSvc.HelmServiceInterface{}
+	mockHelmSvc.On("GetHelmReleaseHistory", "./config/foo_bar", "foo-dev").Return(history, nil)
+	appContext.HelmServiceAPI = mockHelmSvc
+
+	rr := doRollbackRequestDeployment(appContext, "/requestDeployments/17/rollback")
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
+
+	var result model.RequestDeploymentRollbackResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.False(t, result.Success)
+	assert.False(t, result.Deployments[0].Success)
+	mockHelmSvc.AssertNotCalled(t, "RollbackRelease", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRollbackRequestDeploymentRollbackError(t *testing.T) {
+	requestTime := time.Now()
+	appContext := getRollbackAppContext(getRollbackDeployments(requestTime)[:1])
+
+	mockHelmSvc := &mockSvc.HelmServiceInterface{}
+	mockHelmSvc.On("GetHelmReleaseHistory", "./config/foo_bar", "foo-dev").Return(getRollbackHistory(requestTime), nil)
+	mockHelmSvc.On("RollbackRelease", "./config/foo_bar", "foo-dev", 2).Return(errors.New("some error"))
+	appContext.HelmServiceAPI = mockHelmSvc
+
+	rr := doRollbackRequestDeployment(appContext, "/requestDeployments/17/rollback")
+
+	var result model.RequestDeploymentRollbackResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.False(t, result.Success)
+	assert.Equal(t, "some error", result.Deployments[0].Message)
+}
+
+func TestRollbackRequestDeploymentNotFound(t *testing.T) {
+	appContext := AppContext{}
+	mockRequestDeploymentDAO := &mockRepo.RequestDeploymentDAOInterface{}
+	mockRequestDeploymentDAO.On("GetRequestDeploymentByID", 17).Return(model.RequestDeployment{}, errors.New("record not found"))
+	appContext.Repositories.RequestDeploymentDAO = mockRequestDeploymentDAO
+
+	rr := doRollbackRequestDeployment(&appContext, "/requestDeployments/17/rollback")
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestGetRevisionBefore(t *testing.T) {
+	requestTime := time.Now()
+	revision, ok := getRevisionBefore(getRollbackHistory(requestTime), requestTime)
+	assert.True(t, ok)
+	assert.Equal(t, 2, revision)
+
+	_, ok = getRevisionBefore(getRollbackHistory(requestTime), requestTime.Add(-72*time.Hour))
+	assert.False(t, ok)
+}
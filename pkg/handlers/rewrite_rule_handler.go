@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+const rewriteTypeLiteral = "Literal"
+const rewriteTypeRegEx = "RegEx"
+
+type compiledRewriteRule struct {
+	search      *regexp.Regexp
+	literal     string
+	replacement string
+	chart       *regexp.Regexp
+	variable    *regexp.Regexp
+}
+
+//variableRewriter rewrites variable values copied from a source to a target environment
+type variableRewriter struct {
+	rules   []compiledRewriteRule
+	passkey string
+}
+
+func (appContext *AppContext) newRewriteRuleSet(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var payload model.RewriteRuleSet
+
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := compileRewriteRules(payload, nil, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := appContext.Repositories.RewriteRuleSetDAO.CreateRewriteRuleSet(payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (appContext *AppContext) editRewriteRuleSet(w http.ResponseWriter, r *http.Request) {
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var payload model.RewriteRuleSet
+
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := compileRewriteRules(payload, nil, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := appContext.Repositories.RewriteRuleSetDAO.EditRewriteRuleSet(payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (appContext *AppContext) deleteRewriteRuleSet(w http.ResponseWriter, r *http.Request) {
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	w.Header().Set(global.ContentType, global.JSONContentType)
+	if err := appContext.Repositories.RewriteRuleSetDAO.DeleteRewriteRuleSet(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (appContext *AppContext) listRewriteRuleSets(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+	result := &model.RewriteRuleSetResponse{}
+	var err error
+	if result.List, err = appContext.Repositories.RewriteRuleSetDAO.ListRewriteRuleSets(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//previewRewriteRuleSet shows the value of each source variable before and after rewriting.
+//Without a target environment the preview assumes a duplication of the source.
+func (appContext *AppContext) previewRewriteRuleSet(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var payload model.RewritePreviewRequest
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	srcEnvironment, err := appContext.Repositories.EnvironmentDAO.GetByID(payload.SourceEnvironmentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	targetEnvironment := duplicatedEnvironment(*srcEnvironment)
+	if payload.TargetEnvironmentID > 0 {
+		if targetEnvironment, err = appContext.Repositories.EnvironmentDAO.GetByID(payload.TargetEnvironmentID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	rewriter, err := appContext.newVariableRewriter(payload.RewriteRuleSetID, srcEnvironment, targetEnvironment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(payload.SourceEnvironmentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := model.RewritePreviewResult{List: make([]model.RewrittenVariable, 0)}
+	for _, v := range variables {
+		if payload.Scope != "" && v.Scope != payload.Scope {
+			continue
+		}
+		before, after := rewriter.preview(v)
+		result.List = append(result.List, model.RewrittenVariable{
+			Scope:   v.Scope,
+			Name:    v.Name,
+			Secret:  v.Secret,
+			Before:  before,
+			After:   after,
+			Changed: before != after,
+		})
+	}
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//newVariableRewriter loads a rule set, returning a nil rewriter when no rule set was selected
+func (appContext *AppContext) newVariableRewriter(ruleSetID int, src *model.Environment,
+	target *model.Environment) (*variableRewriter, error) {
+
+	if ruleSetID <= 0 {
+		return nil, nil
+	}
+
+	ruleSet, err := appContext.Repositories.RewriteRuleSetDAO.GetRewriteRuleSetByID(ruleSetID)
+	if err != nil {
+		return nil, err
+	}
+
+	rewriter, err := compileRewriteRules(ruleSet, src, target)
+	if err != nil {
+		return nil, err
+	}
+	if appContext.Configuration != nil {
+		rewriter.passkey = appContext.Configuration.App.Passkey
+	}
+	return rewriter, nil
+}
+
+//extractRewriteRuleSetID reads the optional rewriteRuleSetID query parameter
+func extractRewriteRuleSetID(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("rewriteRuleSetID")
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+//rewritePlaceholders returns the placeholders available in searches and replacements
+func rewritePlaceholders(src *model.Environment, target *model.Environment) map[string]string {
+	placeholders := make(map[string]string)
+	if src != nil {
+		placeholders["${SOURCE_NAMESPACE}"] = src.Namespace
+		placeholders["${SOURCE_ENVIRONMENT}"] = src.Name
+		placeholders["${SOURCE_GATEWAY}"] = src.Gateway
+	}
+	if target != nil {
+		placeholders["${TARGET_NAMESPACE}"] = target.Namespace
+		placeholders["${TARGET_ENVIRONMENT}"] = target.Name
+		placeholders["${TARGET_GATEWAY}"] = target.Gateway
+	}
+	return placeholders
+}
+
+func expandPlaceholders(value string, placeholders map[string]string, quote bool) string {
+	for k, v := range placeholders {
+		if quote {
+			v = regexp.QuoteMeta(v)
+		}
+		value = strings.Replace(value, k, v, -1)
+	}
+	return value
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+func compileRewriteRules(ruleSet model.RewriteRuleSet, src *model.Environment,
+	target *model.Environment) (*variableRewriter, error) {
+
+	placeholders := rewritePlaceholders(src, target)
+	rewriter := &variableRewriter{rules: make([]compiledRewriteRule, 0)}
+
+	for _, rule := range ruleSet.RewriteRules {
+		if rule.Search == "" {
+			return nil, errors.New("Rewrite rule without search value")
+		}
+
+		compiled := compiledRewriteRule{replacement: expandPlaceholders(rule.Replacement, placeholders, false)}
+
+		var err error
+		switch rule.Type {
+		case rewriteTypeRegEx:
+			if compiled.search, err = regexp.Compile(expandPlaceholders(rule.Search, placeholders, true)); err != nil {
+				return nil, err
+			}
+		case rewriteTypeLiteral, "":
+			compiled.literal = expandPlaceholders(rule.Search, placeholders, false)
+		default:
+			return nil, errors.New("Invalid rewrite rule type: " + rule.Type)
+		}
+
+		if compiled.chart, err = compilePattern(rule.ChartPattern); err != nil {
+			return nil, err
+		}
+		if compiled.variable, err = compilePattern(rule.VariablePattern); err != nil {
+			return nil, err
+		}
+
+		rewriter.rules = append(rewriter.rules, compiled)
+	}
+
+	return rewriter, nil
+}
+
+//rewriteValue applies, in order, every rule whose scope matches the variable
+func (rw *variableRewriter) rewriteValue(variable model.Variable, value string) string {
+	for _, rule := range rw.rules {
+		if rule.chart != nil && !rule.chart.MatchString(variable.Scope) {
+			continue
+		}
+		if rule.variable != nil && !rule.variable.MatchString(variable.Name) {
+			continue
+		}
+		if rule.search != nil {
+			value = rule.search.ReplaceAllString(value, rule.replacement)
+		} else if rule.literal != "" {
+			value = strings.Replace(value, rule.literal, rule.replacement, -1)
+		}
+	}
+	return value
+}
+
+//rewrite returns the variable with its value rewritten, secrets are decrypted before
+//rewriting and encrypted again only when they change
+func (rw *variableRewriter) rewrite(variable model.Variable) model.Variable {
+	if rw == nil {
+		return variable
+	}
+
+	if !variable.Secret {
+		variable.Value = rw.rewriteValue(variable, variable.Value)
+		return variable
+	}
+
+	plain, ok := rw.decrypt(variable.Value)
+	if !ok {
+		return variable
+	}
+	if rewritten := rw.rewriteValue(variable, plain); rewritten != plain {
+		variable.Value = hex.EncodeToString(util.Encrypt([]byte(rewritten), rw.passkey))
+	}
+	return variable
+}
+
+//preview returns the value before and after rewriting, secrets are masked
+func (rw *variableRewriter) preview(variable model.Variable) (string, string) {
+	if rw == nil {
+		if variable.Secret {
+			return "******", "******"
+		}
+		return variable.Value, variable.Value
+	}
+	if !variable.Secret {
+		return variable.Value, rw.rewrite(variable).Value
+	}
+	plain, ok := rw.decrypt(variable.Value)
+	if !ok || rw.rewriteValue(variable, plain) == plain {
+		return "******", "******"
+	}
+	return "******", "****** (rewritten)"
+}
+
+func (rw *variableRewriter) decrypt(value string) (string, bool) {
+	byteValues, err := hex.DecodeString(value)
+	if err != nil {
+		return "", false
+	}
+	plain, err := util.Decrypt(byteValues, rw.passkey)
+	if err != nil {
+		return "", false
+	}
+	return string(plain), true
+}
+
+//duplicatedEnvironment returns the environment created by duplicateEnvironments, before it is saved
+func duplicatedEnvironment(environment model.Environment) *model.Environment {
+	var env model.Environment
+	env.Namespace = environment.Namespace
+	env.Name = environment.Name + "-Copy"
+	env.Group = environment.Group
+	env.CACertificate = environment.CACertificate
+	env.Token = environment.Token
+	env.ClusterURI = environment.ClusterURI
+	env.Gateway = environment.Gateway
+	env.ParentID = environment.ParentID
+	env.RulesPolicy = environment.RulesPolicy
+	return &env
+}
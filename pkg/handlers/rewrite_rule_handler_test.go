@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/configs"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/softplan/tenkai-api/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getRewriteRuleSet() model.RewriteRuleSet {
+	ruleSet := model.RewriteRuleSet{Name: "dev to qa"}
+	ruleSet.ID = 5
+	ruleSet.RewriteRules = []*model.RewriteRule{
+		{Type: "Literal", Search: "${SOURCE_NAMESPACE}.host", Replacement: "${TARGET_NAMESPACE}.host"},
+		{Type: "RegEx", Search: `jdbc:postgresql://([a-z]+)-${SOURCE_NAMESPACE}/`,
+			Replacement: "jdbc:postgresql://$1-${TARGET_NAMESPACE}/", VariablePattern: "^db\\."},
+		{Type: "Literal", Search: "abc", Replacement: "xyz", ChartPattern: "/foo$"},
+	}
+	return ruleSet
+}
+
+func mockGetRewriteRuleSet(appContext *AppContext) *mockRepo.RewriteRuleSetDAOInterface {
+	mockRewriteDAO := &mockRepo.RewriteRuleSetDAOInterface{}
+	mockRewriteDAO.On("GetRewriteRuleSetByID", 5).Return(getRewriteRuleSet(), nil)
+	appContext.Repositories.RewriteRuleSetDAO = mockRewriteDAO
+	return mockRewriteDAO
+}
+
+func TestVariableRewriter(t *testing.T) {
+	src := &model.Environment{Name: "dev", Namespace: "dev"}
+	target := &model.Environment{Name: "qa", Namespace: "qa"}
+	rewriter, err := compileRewriteRules(getRewriteRuleSet(), src, target)
+	assert.NoError(t, err)
+
+	url := rewriter.rewrite(model.Variable{Scope: "repo/bar", Name: "url", Value: "http://dev.host/api"})
+	assert.Equal(t, "http://qa.host/api", url.Value)
+
+	db := rewriter.rewrite(model.Variable{Scope: "repo/bar", Name: "db.url", Value: "jdbc:postgresql://pg-dev/app"})
+	assert.Equal(t, "jdbc:postgresql://pg-qa/app", db.Value)
+
+	notDb := rewriter.rewrite(model.Variable{Scope: "repo/bar", Name: "url2", Value: "jdbc:postgresql://pg-dev/app"})
+	assert.Equal(t, "jdbc:postgresql://pg-dev/app", notDb.Value)
+
+	scoped := rewriter.rewrite(model.Variable{Scope: "repo/foo", Name: "x", Value: "abc"})
+	assert.Equal(t, "xyz", scoped.Value)
+
+	outOfScope := rewriter.rewrite(model.Variable{Scope: "repo/bar", Name: "x", Value: "abc"})
+	assert.Equal(t, "abc", outOfScope.Value)
+
+	var nilRewriter *variableRewriter
+	assert.Equal(t, "abc", nilRewriter.rewrite(model.Variable{Value: "abc"}).Value)
+}
+
+func TestVariableRewriterSecret(t *testing.T) {
+	src := &model.Environment{Name: "dev", Namespace: "dev"}
+	target := &model.Environment{Name: "qa", Namespace: "qa"}
+	rewriter, err := compileRewriteRules(getRewriteRuleSet(), src, target)
+	assert.NoError(t, err)
+	rewriter.passkey = "some-key"
+
+	secret := model.Variable{Scope: "repo/bar", Name: "url", Secret: true,
+		Value: hex.EncodeToString(util.Encrypt([]byte("dev.host"), "some-key"))}
+
+	result := rewriter.rewrite(secret)
+	assert.True(t, result.Secret)
+	assert.NotEqual(t, secret.Value, result.Value)
+
+	plain, _ := rewriter.decrypt(result.Value)
+	assert.Equal(t, "qa.host", plain)
+
+	before, after := rewriter.preview(secret)
+	assert.Equal(t, "******", before)
+	assert.NotEqual(t, before, after)
+}
+
+func TestCompileRewriteRulesError(t *testing.T) {
+	ruleSet := model.RewriteRuleSet{RewriteRules: []*model.RewriteRule{{Type: "RegEx", Search: "(dev"}}}
+	_, err := compileRewriteRules(ruleSet, nil, nil)
+	assert.Error(t, err)
+
+	ruleSet = model.RewriteRuleSet{RewriteRules: []*model.RewriteRule{{Type: "Other", Search: "dev"}}}
+	_, err = compileRewriteRules(ruleSet, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestNewRewriteRuleSet(t *testing.T) {
+	appContext := AppContext{}
+	mockRewriteDAO := &mockRepo.RewriteRuleSetDAOInterface{}
+	mockRewriteDAO.On("CreateRewriteRuleSet", mock.Anything).Return(1, nil)
+	appContext.Repositories.RewriteRuleSetDAO = mockRewriteDAO
+
+	payloadStr, _ := json.Marshal(getRewriteRuleSet())
+	req, err := http.NewRequest("POST", "/rewriteRuleSets", bytes.NewBuffer(payloadStr))
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.newRewriteRuleSet)
+	handler.ServeHTTP(rr, req)
+
+	mockRewriteDAO.AssertNumberOfCalls(t, "CreateRewriteRuleSet", 1)
+	assert.Equal(t, http.StatusCreated, rr.Code, "Response should be Created.")
+}
+
+func TestNewRewriteRuleSetInvalidRule(t *testing.T) {
+	appContext := AppContext{}
+	mockRewriteDAO := &mockRepo.RewriteRuleSetDAOInterface{}
+	appContext.Repositories.RewriteRuleSetDAO = mockRewriteDAO
+
+	ruleSet := model.RewriteRuleSet{RewriteRules: []*model.RewriteRule{{Type: "RegEx", Search: "(dev"}}}
+	payloadStr, _ := json.Marshal(ruleSet)
+	req, err := http.NewRequest("POST", "/rewriteRuleSets", bytes.NewBuffer(payloadStr))
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.newRewriteRuleSet)
+	handler.ServeHTTP(rr, req)
+
+	mockRewriteDAO.AssertNotCalled(t, "CreateRewriteRuleSet", mock.Anything)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+}
+
+func TestListRewriteRuleSets(t *testing.T) {
+	appContext := AppContext{}
+	mockRewriteDAO := &mockRepo.RewriteRuleSetDAOInterface{}
+	mockRewriteDAO.On("ListRewriteRuleSets").Return([]model.RewriteRuleSet{getRewriteRuleSet()}, nil)
+	appContext.Repositories.RewriteRuleSetDAO = mockRewriteDAO
+
+	req, err := http.NewRequest("GET", "/rewriteRuleSets", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.listRewriteRuleSets)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Contains(t, rr.Body.String(), `"name":"dev to qa"`)
+}
+
+func TestDeleteRewriteRuleSet(t *testing.T) {
+	appContext := AppContext{}
+	mockRewriteDAO := &mockRepo.RewriteRuleSetDAOInterface{}
+	mockRewriteDAO.On("DeleteRewriteRuleSet", 5).Return(nil)
+	appContext.Repositories.RewriteRuleSetDAO = mockRewriteDAO
+
+	req, err := http.NewRequest("DELETE", "/rewriteRuleSets/5", nil)
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/rewriteRuleSets/{id}", appContext.deleteRewriteRuleSet).Methods("DELETE")
+	r.ServeHTTP(rr, req)
+
+	mockRewriteDAO.AssertNumberOfCalls(t, "DeleteRewriteRuleSet", 1)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+}
+
+func TestPreviewRewriteRuleSet(t *testing.T) {
+	appContext, _, _ := getSyncAppContext()
+	appContext.Configuration = &configs.Configuration{}
+	mockGetRewriteRuleSet(appContext)
+
+	payload := model.RewritePreviewRequest{RewriteRuleSetID: 5, SourceEnvironmentID: 91, TargetEnvironmentID: 92}
+	payloadStr, _ := json.Marshal(payload)
+	req, err := http.NewRequest("POST", "/rewriteRuleSets/preview", bytes.NewBuffer(payloadStr))
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(appContext.previewRewriteRuleSet)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var result model.RewritePreviewResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, 4, len(result.List))
+	assert.Equal(t, "url", result.List[3].Name)
+	assert.Equal(t, "dev.host", result.List[3].Before)
+	assert.Equal(t, "qa.host", result.List[3].After)
+	assert.True(t, result.List[3].Changed)
+	assert.False(t, result.List[0].Changed)
+	assert.Equal(t, "******", result.List[2].Before)
+}
+
+func TestPromoteSyncWithRewriteRuleSet(t *testing.T) {
+	appContext, mockVariableDAO, _ := getSyncAppContext()
+	appContext.Configuration = &configs.Configuration{}
+	mockGetRewriteRuleSet(appContext)
+
+	rr := doPromote(appContext, "/promote?mode=sync&srcEnvID=91&targetEnvID=92&rewriteRuleSetID=5")
+
+	assert.Equal(t, http.StatusAccepted, rr.Code, "Response is not Accepted.")
+
+	// url is rewritten from dev.host to qa.host, which the target already has
+	mockVariableDAO.AssertNumberOfCalls(t, "EditVariable", 1)
+}
+
+func TestDuplicateEnvironmentsWithRewriteRuleSet(t *testing.T) {
+	appContext := AppContext{}
+	appContext.K8sConfigPath = "/tmp/"
+	appContext.Configuration = &configs.Configuration{}
+	mockGetRewriteRuleSet(&appContext)
+
+	mockEnvDAO := mockGetByID(&appContext)
+	mockEnvDAO.On("CreateEnvironment", mock.Anything).Return(1, nil)
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 999).
+		Return([]model.Variable{{Scope: "repo/foo", Name: "token", Value: "abc"}}, nil)
+	mockVariableDAO.On("CreateVariable", mock.Anything).Return(nil, true, nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockCreateVariableRevision(&appContext)
+
+	req, err := http.NewRequest("GET", "/environments/duplicate/999?rewriteRuleSetID=5", nil)
+	assert.NoError(t, err)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/duplicate/{id}", appContext.duplicateEnvironments).Methods("GET")
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code, "Response should be Created.")
+	mockVariableDAO.AssertCalled(t, "CreateVariable", model.Variable{Scope: "repo/foo", Name: "token", Value: "xyz", EnvironmentID: 1})
+}
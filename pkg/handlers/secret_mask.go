@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+//revealSecret returns the decrypted value of a secret variable. Secrets are masked everywhere else,
+//so every reveal is audited.
+func (appContext *AppContext) revealSecret(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	variable, err := appContext.Repositories.VariableDAO.GetByID(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		auth, _ := appContext.hasEnvironmentRole(principal, uint(variable.EnvironmentID), "ACTION_REVEAL_SECRET")
+		if !auth {
+			http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if !variable.Secret {
+		http.Error(w, errors.New("Variable is not a secret").Error(), http.StatusBadRequest)
+		return
+	}
+
+	environment, err := appContext.Repositories.EnvironmentDAO.GetByID(variable.EnvironmentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	auditValues := make(map[string]string)
+	auditValues["environment"] = environment.Name
+	auditValues["scope"] = variable.Scope
+	auditValues["variableName"] = variable.Name
+	appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "revealSecret", auditValues)
+
+	result := model.RevealedSecret{
+		ID:    variable.ID,
+		Scope: variable.Scope,
+		Name:  variable.Name,
+		Value: appContext.decryptVariable(*variable).Value,
+	}
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//yamlSecretMask is the mask of a yaml scalar, quoted as a bare * starts an alias
+const yamlSecretMask = "'" + secretMask + "'"
+
+//secretSet is what must not be shown of a release: the keys of its values holding secrets, and the
+//secret texts to find them once rendered in manifests
+type secretSet struct {
+	keys   map[string]bool
+	values []string
+}
+
+func newSecretSet() *secretSet {
+	return &secretSet{keys: make(map[string]bool), values: make([]string, 0)}
+}
+
+//add registers the secret at a key of the values, with the texts of a list or map value
+func (s *secretSet) add(key string, value string, typed interface{}) {
+	s.keys[key] = true
+	s.values = append(s.values, secretValues(value, typed)...)
+}
+
+//texts are the secret values to look for, in clear or base64 encoded as in a kubernetes secret
+func (s *secretSet) texts() map[string]bool {
+	texts := make(map[string]bool)
+	for _, value := range s.values {
+		if value != "" && value != emptyValue {
+			texts[value] = true
+			texts[base64.StdEncoding.EncodeToString([]byte(value))] = true
+		}
+	}
+	return texts
+}
+
+//releaseSecrets lists the secrets installed with a release. The values the secret variables had
+//before are included, as they may still be deployed.
+func (appContext *AppContext) releaseSecrets(environment *model.Environment,
+	installPayload model.InstallPayload) (*secretSet, error) {
+
+	variables, err := appContext.getEffectiveVariables(environment, releaseSearchTerm(installPayload))
+	if err != nil {
+		return nil, err
+	}
+	helmVars, err := appContext.getHelmChartAppVars(installPayload.Chart, installPayload.ChartVersion)
+	if err != nil {
+		return nil, err
+	}
+	_, secrets, err := appContext.getValuesWithHelmDefault(variables, helmVars,
+		appContext.getGlobalVariables(environment), environment)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, scope := range []string{releaseSearchTerm(installPayload), "global"} {
+		revisions, err := appContext.Repositories.VariableRevisionDAO.ListEnvironmentVariableRevisions(
+			int(environment.ID), scope)
+		if err != nil {
+			return nil, err
+		}
+		for _, rev := range revisions {
+			if !rev.Secret {
+				continue
+			}
+			for _, value := range []string{rev.OldValue, rev.NewValue} {
+				if value != "" {
+					previous := appContext.decryptVariable(model.Variable{Value: value, Secret: true})
+					secrets.values = append(secrets.values, previous.Value)
+				}
+			}
+		}
+	}
+	return secrets, nil
+}
+
+//maskSecrets masks a yaml text, such as a manifest or the output of helm: the values of Secret resources
+//and the scalars whose whole value is a secret
+func maskSecrets(text string, secrets *secretSet) string {
+	texts := secrets.texts()
+	lines := strings.Split(text, "\n")
+
+	isSecret := false
+	inData := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if manifestSeparator.MatchString(line) || i == 0 {
+			isSecret = secretDocument(lines[i:])
+			inData = false
+		}
+		if line != "" && line[0] != ' ' && line[0] != '-' {
+			header := strings.TrimSpace(line)
+			inData = isSecret && (header == "data:" || header == "stringData:")
+			if inData {
+				continue
+			}
+		}
+
+		prefix, value := splitYAMLScalar(line)
+		switch {
+		case inData && value != "":
+			lines[i] = prefix + yamlSecretMask
+		case inData && strings.TrimSpace(line) != "" && !strings.Contains(strings.TrimSpace(line)+" ", ": "):
+			lines[i] = line[:len(line)-len(strings.TrimLeft(line, " "))] + secretMask
+		case value != "" && texts[strings.Trim(value, `"'`)]:
+			lines[i] = prefix + yamlSecretMask
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+//secretDocument tells if the yaml document starting at the first line is a kubernetes Secret
+func secretDocument(lines []string) bool {
+	for i, line := range lines {
+		if i > 0 && manifestSeparator.MatchString(line) {
+			return false
+		}
+		if strings.TrimSpace(line) == "kind: Secret" && line[0] == 'k' {
+			return true
+		}
+	}
+	return false
+}
+
+//splitYAMLScalar splits a "key: value" or "- value" line before its value, the value is empty when
+//the line has no scalar
+func splitYAMLScalar(line string) (string, string) {
+	rest := strings.TrimLeft(line, " ")
+	listItem := strings.HasPrefix(rest, "- ")
+	if listItem {
+		rest = strings.TrimLeft(rest[2:], " ")
+	}
+	if idx := strings.Index(rest, ": "); idx > 0 {
+		rest = rest[idx+2:]
+	} else if !listItem {
+		return line, ""
+	}
+	value := strings.TrimSpace(rest)
+	if value == "|" || value == ">" || value == "|-" || value == ">-" {
+		return line, ""
+	}
+	return line[:len(line)-len(rest)], value
+}
+
+//maskValues returns a copy of a values document with the values at the secret keys masked
+func maskValues(value interface{}, secrets *secretSet) interface{} {
+	return maskValuesAt(value, "", secrets)
+}
+
+func maskValuesAt(value interface{}, path string, secrets *secretSet) interface{} {
+	if value != nil && secrets.keys[path] {
+		return secretMask
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			masked[key] = maskValuesAt(item, child, secrets)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, item := range v {
+			masked[i] = maskValuesAt(item, path+"["+strconv.Itoa(i)+"]", secrets)
+		}
+		return masked
+	}
+	return value
+}
+
+//secretValues lists the values to mask for a secret variable, the texts of a list or map value included
+func secretValues(value string, typed interface{}) []string {
+	result := []string{value}
+	switch v := typed.(type) {
+	case map[string]interface{}:
+		for _, item := range v {
+			result = append(result, secretValues("", item)...)
+		}
+	case []interface{}:
+		for _, item := range v {
+			result = append(result, secretValues("", item)...)
+		}
+	case string:
+		result = append(result, v)
+	default:
+		if v != nil {
+			result = append(result, fmt.Sprint(v))
+		}
+	}
+	return result
+}
+
+//maskedValue is the value of a variable as shown in plans and reports
+func maskedValue(variable model.Variable) string {
+	if variable.Secret {
+		return secretMask
+	}
+	return variable.Value
+}
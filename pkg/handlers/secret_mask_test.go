@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/configs"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/softplan/tenkai-api/pkg/rabbitmq"
+	helmapi "github.com/softplan/tenkai-api/pkg/service/_helm"
+	"github.com/softplan/tenkai-api/pkg/service/_helm/mocks"
+	"github.com/softplan/tenkai-api/pkg/util"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getSecretAppContext() (*AppContext, *mockRepo.VariableDAOInterface) {
+	appContext := AppContext{}
+	appContext.Configuration = &configs.Configuration{App: configs.App{Passkey: "qwert"}}
+	mockGetByID(&appContext)
+	mockConventionInterface(&appContext)
+
+	password := model.Variable{Scope: "foo", Name: "password", Value: hex.EncodeToString(util.Encrypt([]byte("s3cr3t"),
+		"qwert")), Secret: true, EnvironmentID: 999}
+	password.ID = 10
+	variables := []model.Variable{
+		password,
+		{Scope: "foo", Name: "url", Value: "db://admin:${foo:password}@db", EnvironmentID: 999},
+		{Scope: "foo", Name: "user", Value: "admin", EnvironmentID: 999},
+	}
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 999, "foo").Return(variables, nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 999, "global").Return([]model.Variable{}, nil)
+	mockVariableDAO.On("GetByID", uint(10)).Return(&password, nil)
+	mockVariableDAO.On("GetByID", uint(11)).Return(&variables[2], nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	mockHelmSvc := &mocks.HelmServiceInterface{}
+	mockHelmSvc.On("GetTemplate", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]byte(`{"app":{}}`), nil)
+	mockHelmSvc.On("SearchCharts", mock.Anything, false).Return(getCharts())
+	mockHelmSvc.On("Upgrade", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*bytes.Buffer).WriteString("MANIFEST:\npassword: czNjcjN0\nurl: db://admin:s3cr3t@db\n")
+	}).Return(nil)
+	appContext.HelmServiceAPI = mockHelmSvc
+
+	return &appContext, mockVariableDAO
+}
+
+func getTestSecretSet() *secretSet {
+	secrets := newSecretSet()
+	secrets.add("app.password", "s3cr3t", "s3cr3t")
+	secrets.add("app.pin", "1234", int64(1234))
+	secrets.add("app.hosts", "[a, b]", []interface{}{"a", "b"})
+	secrets.add("app.empty", emptyValue, "")
+	return secrets
+}
+
+func TestMaskSecrets(t *testing.T) {
+	text := `user: admin
+password: s3cr3t
+token: "czNjcjN0"
+other: s3cr3t-2
+hosts:
+  - a
+  - c
+---
+kind: Secret
+data:
+  password: Zm9v
+  key: |
+    multi
+    line
+type: Opaque`
+	assert.Equal(t, `user: admin
+password: '******'
+token: '******'
+other: s3cr3t-2
+hosts:
+  - '******'
+  - c
+---
+kind: Secret
+data:
+  password: '******'
+  key: |
+    ******
+    ******
+type: Opaque`, maskSecrets(text, getTestSecretSet()))
+
+	configMap := "kind: ConfigMap\ndata:\n  name: foo\n  password: s3cr3t\n"
+	assert.Equal(t, "kind: ConfigMap\ndata:\n  name: foo\n  password: '******'\n",
+		maskSecrets(configMap, getTestSecretSet()))
+}
+
+func TestMaskSecrets_ShortSecret(t *testing.T) {
+	secrets := newSecretSet()
+	secrets.add("app.flag", "a", "a")
+	text := "name: a\napp: tenkai-api\nimage: a:1.0\n"
+	assert.Equal(t, "name: '******'\napp: tenkai-api\nimage: a:1.0\n", maskSecrets(text, secrets))
+	assert.Equal(t, text, maskSecrets(text, newSecretSet()))
+}
+
+func TestMaskValues(t *testing.T) {
+	values := map[string]interface{}{
+		"app": map[string]interface{}{
+			"password": "s3cr3t",
+			"pin":      int64(1234),
+			"hosts":    []interface{}{"a", "b"},
+			"replicas": int64(1234),
+			"name":     "s3cr3t",
+			"empty":    nil,
+		},
+	}
+	masked := maskValues(values, getTestSecretSet())
+	assert.Equal(t, map[string]interface{}{
+		"app": map[string]interface{}{
+			"password": "******",
+			"pin":      "******",
+			"hosts":    "******",
+			"replicas": int64(1234),
+			"name":     "s3cr3t",
+			"empty":    nil,
+		},
+	}, masked)
+	assert.Equal(t, "s3cr3t", values["app"].(map[string]interface{})["password"])
+}
+
+func TestSimpleInstall_MasksHelmCommand(t *testing.T) {
+	appContext, _ := getSecretAppContext()
+	environment := mockGetEnv()
+
+	command, err := appContext.simpleInstall(&environment, model.InstallPayload{Chart: "foo", Name: "my-foo"},
+		&bytes.Buffer{}, false, true, "", -1)
+	assert.NoError(t, err)
+	assert.NotContains(t, command, "s3cr3t")
+	assert.Contains(t, command, "  password: '******'\n")
+	assert.Contains(t, command, "  url: '******'\n")
+	assert.Contains(t, command, "  user: admin\n")
+}
+
+func TestSimpleInstall_MasksDryRun(t *testing.T) {
+	appContext, _ := getSecretAppContext()
+	environment := mockGetEnv()
+
+	out := &bytes.Buffer{}
+	_, err := appContext.simpleInstall(&environment, model.InstallPayload{Chart: "foo", Name: "my-foo"},
+		out, true, false, "", -1)
+	assert.NoError(t, err)
+	assert.Equal(t, "MANIFEST:\npassword: '******'\nurl: '******'\n", out.String())
+
+	mockHelmSvc := appContext.HelmServiceAPI.(*mocks.HelmServiceInterface)
+	for _, call := range mockHelmSvc.Calls {
+		if call.Method == "Upgrade" {
+			assert.Contains(t, call.Arguments.Get(0).(helmapi.UpgradeRequest).Values, "password: s3cr3t\n")
+		}
+	}
+	mockHelmSvc.AssertNumberOfCalls(t, "Upgrade", 1)
+}
+
+func TestSimpleInstall_PublishesVariables(t *testing.T) {
+	appContext, _ := getSecretAppContext()
+	environment := mockGetEnv()
+	mockRabbitMQ := getMockRabbitMQ()
+	appContext.RabbitImpl = mockRabbitMQ
+	mockDeploymentDAO := &mockRepo.DeploymentDAOInterface{}
+	mockDeploymentDAO.On("CreateDeployment", mock.Anything).Return(1, nil)
+	appContext.Repositories.DeploymentDAO = mockDeploymentDAO
+
+	_, err := appContext.simpleInstall(&environment, model.InstallPayload{Chart: "foo", Name: "my-foo"},
+		&bytes.Buffer{}, false, false, "", 1)
+	assert.NoError(t, err)
+
+	mockRabbitMQ.AssertNumberOfCalls(t, "Publish", 1)
+	var published rabbitmq.PayloadRabbit
+	body := mockRabbitMQ.Calls[0].Arguments.Get(5).(amqp.Publishing).Body
+	assert.NoError(t, json.Unmarshal(body, &published))
+	assert.Contains(t, published.UpgradeRequest.Variables, "app.password=s3cr3t")
+	assert.Contains(t, published.UpgradeRequest.Variables, "app.url=db://admin:s3cr3t@db")
+	assert.Contains(t, published.UpgradeRequest.Variables, "app.user=admin")
+	assert.Contains(t, published.UpgradeRequest.Values, "user: admin\n")
+}
+
+func doRevealSecret(appContext *AppContext, id string, principal model.Principal) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/variables/"+id+"/reveal", nil)
+	pSe, _ := json.Marshal(principal)
+	req.Header.Set("principal", string(pSe))
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/variables/{id}/reveal", appContext.revealSecret).Methods("GET")
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRevealSecret(t *testing.T) {
+	appContext, _ := getSecretAppContext()
+	mockAudit := mockDoAudit(appContext, "revealSecret",
+		map[string]string{"environment": "bar", "scope": "foo", "variableName": "password"})
+
+	rr := doRevealSecret(appContext, "10", model.Principal{Email: "beta@alfa.com", Roles: []string{"tenkai-admin"}})
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, `{"id":10,"scope":"foo","name":"password","value":"s3cr3t"}`, rr.Body.String())
+	mockAudit.AssertNumberOfCalls(t, "DoAudit", 1)
+}
+
+func TestRevealSecret_AccessDenied(t *testing.T) {
+	appContext, _ := getSecretAppContext()
+	user := mockUser()
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", user.Email).Return(user, nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	secOper := mockSecurityOperations()
+	mockUserEnvRoleDAO := &mockRepo.UserEnvironmentRoleDAOInterface{}
+	mockUserEnvRoleDAO.On("GetRoleByUserAndEnvironment", user, uint(999)).Return(&secOper, nil)
+	appContext.Repositories.UserEnvironmentRoleDAO = mockUserEnvRoleDAO
+
+	rr := doRevealSecret(appContext, "10", model.Principal{Email: "beta@alfa.com"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+
+	secOper.Policies = append(secOper.Policies, "ACTION_REVEAL_SECRET")
+	mockAudit := mockDoAudit(appContext, "revealSecret",
+		map[string]string{"environment": "bar", "scope": "foo", "variableName": "password"})
+	rr = doRevealSecret(appContext, "10", model.Principal{Email: "beta@alfa.com"})
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	mockAudit.AssertNumberOfCalls(t, "DoAudit", 1)
+}
+
+func TestRevealSecret_NotSecret(t *testing.T) {
+	appContext, _ := getSecretAppContext()
+
+	rr := doRevealSecret(appContext, "11", model.Principal{Email: "beta@alfa.com", Roles: []string{"tenkai-admin"}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+}
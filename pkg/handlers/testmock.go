@@ -129,6 +129,15 @@ func mockVariableDAOWithLotOfThings(appContext *AppContext) *mockRepo.VariableDA
 	return mockVariableDAO
 }
 
+func mockCreateVariableRevision(appContext *AppContext) *mockRepo.VariableRevisionDAOInterface {
+	mockVariableRevisionDAO := &mockRepo.VariableRevisionDAOInterface{}
+	mockVariableRevisionDAO.On("CreateVariableRevision", mock.Anything).Return(1, nil)
+
+	appContext.Repositories.VariableRevisionDAO = mockVariableRevisionDAO
+
+	return mockVariableRevisionDAO
+}
+
 func mockGetAllVariablesByEnvironment(appContext *AppContext) *mockRepo.VariableDAOInterface {
 	var variables []model.Variable
 	variables = append(variables, mockGlobalVariable())
@@ -461,3 +470,11 @@ func mockWebHook() model.WebHook {
 	item.EnvironmentID = 999
 	return item
 }
+
+//mockListVariableRules mocks the variable rules checked on deploy, to be used only for testing.
+func mockListVariableRules(appContext *AppContext, rules ...model.VariableRule) *mockRepo.VariableRuleDAOInterface {
+	mockVariableRuleDAO := &mockRepo.VariableRuleDAOInterface{}
+	mockVariableRuleDAO.On("ListVariableRules").Return(rules, nil)
+	appContext.Repositories.VariableRuleDAO = mockVariableRuleDAO
+	return mockVariableRuleDAO
+}
@@ -87,6 +87,7 @@ func TestValidateNewVariablesBeforeInstallOK(t *testing.T) {
 	data, _ := json.Marshal(appVars)
 	mockHelmSvc := &mockSvc.HelmServiceInterface{}
 	mockHelmSvc.On("GetTemplate", mock.Anything, mock.Anything, mock.Anything, "values").Return(data, nil)
+	mockHelmSvc.On("GetTemplate", mock.Anything, mock.Anything, mock.Anything, "schema").Return(nil, nil)
 
 	mockEnv := mocks.EnvironmentDAOInterface{}
 	mockEnv.On("GetByID", mock.Anything).Return(nil, nil)
@@ -22,6 +22,7 @@ func (appContext *AppContext) validateVariables(w http.ResponseWriter, r *http.R
 	type Payload struct {
 		EnvironmentID int    `json:"environmentId"`
 		Scope         string `json:"scope"`
+		ChartVersion  string `json:"chartVersion"`
 	}
 
 	var payload Payload
@@ -43,17 +44,46 @@ func (appContext *AppContext) validateVariables(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	ivr, err := appContext.validate(vars, vrs)
+	ivr, err := appContext.validate(payload.EnvironmentID, vars, vrs)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if payload.Scope != "" && payload.Scope != "global" {
+		schemaViolations, err := appContext.validateChartSchema(payload.EnvironmentID, payload.Scope, payload.ChartVersion)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ivr.InvalidVariables = append(ivr.InvalidVariables, schemaViolations...)
+	}
+
 	data, _ := json.Marshal(ivr)
 	w.WriteHeader(http.StatusOK)
 	w.Write(data)
 }
 
+//validateChartSchema validates the variables of a chart in an environment against the chart values.schema.json
+func (appContext *AppContext) validateChartSchema(environmentID int, chart string,
+	chartVersion string) ([]model.InvalidVariable, error) {
+
+	schema, err := appContext.getValuesSchema(chart, chartVersion)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+	environment, err := appContext.Repositories.EnvironmentDAO.GetByID(environmentID)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := appContext.getEffectiveVariables(environment, chart)
+	if err != nil {
+		return nil, err
+	}
+	return appContext.validateValuesSchema(schema, environment, chart, chartVersion, variables,
+		appContext.getGlobalVariables(environment))
+}
+
 func (appContext *AppContext) validateEnvironmentVariables(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set(global.ContentType, global.JSONContentType)
@@ -74,7 +104,7 @@ func (appContext *AppContext) validateEnvironmentVariables(w http.ResponseWriter
 		return
 	}
 
-	ivr, err := appContext.validate(vars, vrs)
+	ivr, err := appContext.validate(envID, vars, vrs)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -85,19 +115,30 @@ func (appContext *AppContext) validateEnvironmentVariables(w http.ResponseWriter
 	w.Write(data)
 }
 
-func (appContext *AppContext) validate(variables []model.Variable,
+func (appContext *AppContext) validate(environmentID int, variables []model.Variable,
 	varRules []model.VariableRule) (*model.InvalidVariablesResult, error) {
 
 	result := &model.InvalidVariablesResult{}
 	result.InvalidVariables = []model.InvalidVariable{}
+	context := &ruleContext{appContext: appContext, environmentID: environmentID}
 
 	for _, varRule := range varRules {
 		for _, valueRule := range varRule.ValueRules {
+			validation := context.validationFn(valueRule.Type)
+			if validation == nil {
+				log.Println("Unknown rule type", valueRule.Type)
+				continue
+			}
 			for _, variable := range variables {
 
-				if varRuleAppliesToVar(varRule.Name, variable.Name) {
+				applies, err := context.applies(varRule, variable)
+				if err != nil {
+					return nil, err
+				}
+				if applies {
 
-					if valid := validationFn(valueRule.Type)(varRule, valueRule, variable); !valid {
+					variable = appContext.decryptVariable(variable)
+					if valid := validation(varRule, valueRule, variable); !valid {
 						invalidVar := createResult(varRule, valueRule, variable)
 						result.InvalidVariables = append(result.InvalidVariables, invalidVar)
 					}
@@ -118,6 +159,15 @@ func validationFn(validator string) fn {
 	m["StartsWith"] = startsWith
 	m["EndsWith"] = endsWith
 	m["RegEx"] = regEx
+	m["OneOf"] = oneOf
+	m["NotOneOf"] = notOneOf
+	m["Range"] = numericRange
+	m["URL"] = isURL
+	m["Hostname"] = isHostname
+	m["Email"] = isEmail
+	m["MinLength"] = minLength
+	m["MaxLength"] = maxLength
+	m["JSONSchema"] = matchesJSONSchema
 
 	return m[validator]
 }
@@ -168,13 +218,17 @@ func createResult(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable
 	var iv model.InvalidVariable
 	iv.Scope = v.Scope
 	iv.Name = v.Name
-	iv.Value = v.Value
+	iv.Value = maskedValue(v)
 	iv.VariableRule = vrr.Name
 	iv.RuleType = vlr.Type
 	iv.ValueRule = vlr.Value
+	iv.Severity = vlr.Severity
+	if iv.Severity == "" {
+		iv.Severity = model.ValueRuleSeverityError
+	}
 	return iv
 }
 
 func logMsg(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable, result bool) {
-	log.Print("Variable ", v.Name, "='", v.Value, "' ", vlr.Type, " '", vlr.Value, "'? ", strconv.FormatBool(result))
+	log.Print("Variable ", v.Name, "='", maskedValue(v), "' ", vlr.Type, " '", vlr.Value, "'? ", strconv.FormatBool(result))
 }
@@ -46,7 +46,7 @@ func TestValidateVariables_NotEmpty(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code, "Response should be ok.")
 
 	r := string(rr.Body.Bytes())
-	assert.Equal(t, r, `{"InvalidVariables":[{"scope":"global","name":"dbUsername","value":"","variableRule":"dbUsername","ruleType":"NotEmpty","valueRule":""}]}`)
+	assert.Equal(t, r, `{"InvalidVariables":[{"scope":"global","name":"dbUsername","value":"","variableRule":"dbUsername","ruleType":"NotEmpty","valueRule":"","severity":"error"}]}`)
 }
 
 func TestValidateVariables_VariabeRule_UsingRegex_NotEmpty(t *testing.T) {
@@ -82,7 +82,7 @@ func TestValidateVariables_VariabeRule_UsingRegex_NotEmpty(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code, "Response should be ok.")
 
 	r := string(rr.Body.Bytes())
-	assert.Equal(t, r, `{"InvalidVariables":[{"scope":"global","name":"dbUsername","value":"","variableRule":"dbUser.+","ruleType":"NotEmpty","valueRule":""}]}`)
+	assert.Equal(t, r, `{"InvalidVariables":[{"scope":"global","name":"dbUsername","value":"","variableRule":"dbUser.+","ruleType":"NotEmpty","valueRule":"","severity":"error"}]}`)
 }
 
 func TestValidateVariables_VariabeRule_UsingRegex_NotMatch(t *testing.T) {
@@ -133,7 +133,7 @@ func TestValidateNotEmpty(t *testing.T) {
 	var vrs []model.VariableRule
 	vrs = append(vrs, getVarRule("dbUsername", "NotEmpty", ""))
 
-	ivr, err := appContext.validate(vars, vrs)
+	ivr, err := appContext.validate(999, vars, vrs)
 	assert.Nil(t, err)
 	assert.NotNil(t, ivr)
 	assert.NotEmpty(t, ivr.InvalidVariables)
@@ -152,7 +152,7 @@ func TestValidateStartsWith(t *testing.T) {
 	var vrs []model.VariableRule
 	vrs = append(vrs, getVarRule("urlapiFoo", "StartsWith", "http://"))
 
-	ivr, err := appContext.validate(vars, vrs)
+	ivr, err := appContext.validate(999, vars, vrs)
 	assert.Nil(t, err)
 	assert.NotNil(t, ivr)
 	assert.NotEmpty(t, ivr.InvalidVariables)
@@ -171,7 +171,7 @@ func TestValidateEndsWith(t *testing.T) {
 	var vrs []model.VariableRule
 	vrs = append(vrs, getVarRule("urlApolloServer", "EndsWith", "/api/graphql"))
 
-	ivr, err := appContext.validate(vars, vrs)
+	ivr, err := appContext.validate(999, vars, vrs)
 	assert.Nil(t, err)
 	assert.NotNil(t, ivr)
 	assert.NotEmpty(t, ivr.InvalidVariables)
@@ -190,7 +190,7 @@ func TestValidateRegEx(t *testing.T) {
 	var vrs []model.VariableRule
 	vrs = append(vrs, getVarRule("authTypes", "RegEx", "OpenID|Internals"))
 
-	ivr, err := appContext.validate(vars, vrs)
+	ivr, err := appContext.validate(999, vars, vrs)
 	assert.Nil(t, err)
 	assert.NotNil(t, ivr)
 	assert.NotEmpty(t, ivr.InvalidVariables)
@@ -232,7 +232,7 @@ func TestValidateEnvironmentVariables(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code, "Response should be ok.")
 
 	res := string(rr.Body.Bytes())
-	assert.Equal(t, res, `{"InvalidVariables":[{"scope":"global","name":"dbUsername","value":"","variableRule":"dbUsername","ruleType":"NotEmpty","valueRule":""}]}`)
+	assert.Equal(t, res, `{"InvalidVariables":[{"scope":"global","name":"dbUsername","value":"","variableRule":"dbUsername","ruleType":"NotEmpty","valueRule":"","severity":"error"}]}`)
 }
 
 func TestValidateEnvironmentVariables_Error1(t *testing.T) {
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ghodss/yaml"
+	"github.com/santhosh-tekuri/jsonschema"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/service/interpolation"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+//ruleContext holds what the rules need to know about the environment being validated.
+//It is only loaded when a rule needs it.
+type ruleContext struct {
+	appContext    *AppContext
+	environmentID int
+	environment   *model.Environment
+	productID     *uint
+	interpolator  *interpolation.Interpolator
+}
+
+func (c *ruleContext) getEnvironment() (*model.Environment, error) {
+	if c.environment == nil {
+		environment, err := c.appContext.Repositories.EnvironmentDAO.GetByID(c.environmentID)
+		if err != nil {
+			return nil, err
+		}
+		c.environment = environment
+	}
+	return c.environment, nil
+}
+
+//getProductID returns the product of the version installed in the environment, 0 if there is none
+func (c *ruleContext) getProductID() (uint, error) {
+	if c.productID != nil {
+		return *c.productID, nil
+	}
+	environment, err := c.getEnvironment()
+	if err != nil {
+		return 0, err
+	}
+
+	var productID uint
+	if environment.ProductVersionID > 0 {
+		pv, err := c.appContext.Repositories.ProductDAO.ListProductVersionsByID(int(environment.ProductVersionID))
+		if err != nil {
+			return 0, err
+		}
+		productID = uint(pv.ProductID)
+	}
+	c.productID = &productID
+	return productID, nil
+}
+
+//applies tells if a variable rule covers a variable of the environment
+func (c *ruleContext) applies(vrr model.VariableRule, v model.Variable) (bool, error) {
+	if !varRuleAppliesToVar(vrr.Name, v.Name) {
+		return false, nil
+	}
+	if vrr.ChartPattern != "" && !varRuleAppliesToVar(vrr.ChartPattern, v.Scope) {
+		return false, nil
+	}
+	if vrr.EnvironmentID == 0 && vrr.EnvironmentGroup == "" && vrr.ProductID == 0 {
+		return true, nil
+	}
+
+	environment, err := c.getEnvironment()
+	if err != nil {
+		return false, err
+	}
+	if vrr.EnvironmentID > 0 && vrr.EnvironmentID != environment.ID {
+		return false, nil
+	}
+	if vrr.EnvironmentGroup != "" && vrr.EnvironmentGroup != environment.Group {
+		return false, nil
+	}
+	if vrr.ProductID > 0 {
+		productID, err := c.getProductID()
+		if err != nil {
+			return false, err
+		}
+		return productID == vrr.ProductID, nil
+	}
+	return true, nil
+}
+
+func (c *ruleContext) validationFn(validator string) fn {
+	if validator == "Reference" {
+		return c.references
+	}
+	return validationFn(validator)
+}
+
+//references checks that the value points to other variables, all of them existing in the environment
+func (c *ruleContext) references(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable) bool {
+	if c.interpolator == nil {
+		environment, err := c.getEnvironment()
+		if err != nil {
+			log.Println("Error loading environment", err)
+			return false
+		}
+		c.interpolator = c.appContext.newInterpolator(environment, c.appContext.getGlobalVariables(environment))
+	}
+	expanded := c.interpolator.Expand(interpolationOrigin(v), v.Value)
+	result := strings.Contains(v.Value, "${") && expanded.Err() == nil
+	logMsg(vrr, vlr, v, result)
+	return result
+}
+
+func oneOf(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable) bool {
+	result := util.Contains(ruleValues(vlr.Value), v.Value)
+	logMsg(vrr, vlr, v, result)
+	return result
+}
+
+func notOneOf(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable) bool {
+	result := !util.Contains(ruleValues(vlr.Value), v.Value)
+	logMsg(vrr, vlr, v, result)
+	return result
+}
+
+//numericRange checks a number against a rule written min..max, where either bound may be left out
+func numericRange(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable) bool {
+	min, max, err := parseRange(vlr.Value)
+	if err != nil {
+		log.Println("Error in range rule", err)
+		return false
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(v.Value), 64)
+	result := err == nil && (min == nil || value >= *min) && (max == nil || value <= *max)
+	logMsg(vrr, vlr, v, result)
+	return result
+}
+
+func isURL(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable) bool {
+	u, err := url.ParseRequestURI(v.Value)
+	result := err == nil && u.Scheme != "" && u.Host != ""
+	logMsg(vrr, vlr, v, result)
+	return result
+}
+
+func isHostname(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable) bool {
+	result := v.Value != "" && jsonschema.Formats["hostname"](v.Value)
+	logMsg(vrr, vlr, v, result)
+	return result
+}
+
+func isEmail(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable) bool {
+	result := jsonschema.Formats["email"](v.Value)
+	logMsg(vrr, vlr, v, result)
+	return result
+}
+
+func minLength(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable) bool {
+	length, err := strconv.Atoi(strings.TrimSpace(vlr.Value))
+	if err != nil {
+		log.Println("Error in length rule", err)
+		return false
+	}
+	result := utf8.RuneCountInString(v.Value) >= length
+	logMsg(vrr, vlr, v, result)
+	return result
+}
+
+func maxLength(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable) bool {
+	length, err := strconv.Atoi(strings.TrimSpace(vlr.Value))
+	if err != nil {
+		log.Println("Error in length rule", err)
+		return false
+	}
+	result := utf8.RuneCountInString(v.Value) <= length
+	logMsg(vrr, vlr, v, result)
+	return result
+}
+
+//matchesJSONSchema validates the value, read as YAML or JSON, against the schema of the rule
+func matchesJSONSchema(vrr model.VariableRule, vlr *model.ValueRule, v model.Variable) bool {
+	schema, err := jsonschema.CompileString("rule.json", vlr.Value)
+	if err != nil {
+		log.Println("Error in JSON schema rule", err)
+		return false
+	}
+	document, err := schemaDocument(v)
+	if err != nil {
+		logMsg(vrr, vlr, v, false)
+		return false
+	}
+	result := schema.ValidateInterface(document) == nil
+	logMsg(vrr, vlr, v, result)
+	return result
+}
+
+//schemaDocument decodes a value the way it is installed, string variables are kept as strings
+func schemaDocument(v model.Variable) (interface{}, error) {
+	if v.Type == model.VariableTypeString || v.Type == model.VariableTypeMultiline {
+		return v.Value, nil
+	}
+	data, err := yaml.YAMLToJSON([]byte(v.Value))
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.DecodeJSON(bytes.NewReader(data))
+}
+
+//ruleValues splits the comma separated values of a rule
+func ruleValues(value string) []string {
+	values := strings.Split(value, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
+
+func parseRange(value string) (*float64, *float64, error) {
+	bounds := strings.Split(value, "..")
+	if len(bounds) != 2 {
+		return nil, nil, errors.New("range must be written min..max")
+	}
+	var result [2]*float64
+	for i, bound := range bounds {
+		bound = strings.TrimSpace(bound)
+		if bound == "" {
+			continue
+		}
+		number, err := strconv.ParseFloat(bound, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		result[i] = &number
+	}
+	return result[0], result[1], nil
+}
+
+//validateValueRule checks a value rule before it is saved
+func validateValueRule(rule model.ValueRule) error {
+	if (&ruleContext{}).validationFn(rule.Type) == nil {
+		return errors.New("Invalid rule type " + rule.Type)
+	}
+	if rule.Severity != "" && !util.Contains(model.ValueRuleSeverities, rule.Severity) {
+		return errors.New("Invalid severity " + rule.Severity)
+	}
+
+	var err error
+	switch rule.Type {
+	case "RegEx":
+		_, err = regexp.Compile(rule.Value)
+	case "Range":
+		_, _, err = parseRange(rule.Value)
+	case "MinLength", "MaxLength":
+		_, err = strconv.Atoi(strings.TrimSpace(rule.Value))
+	case "JSONSchema":
+		_, err = jsonschema.CompileString("rule.json", rule.Value)
+	}
+	if err != nil {
+		return errors.New("Invalid " + rule.Type + " rule: " + err.Error())
+	}
+	return nil
+}
+
+//validateVariableRule checks the patterns of a variable rule before it is saved
+func validateVariableRule(rule model.VariableRule) error {
+	if _, err := regexp.Compile(rule.Name); err != nil {
+		return errors.New("Invalid name pattern: " + err.Error())
+	}
+	if _, err := regexp.Compile(rule.ChartPattern); err != nil {
+		return errors.New("Invalid chart pattern: " + err.Error())
+	}
+	return nil
+}
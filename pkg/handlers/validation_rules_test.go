@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestValidateRuleTypes(t *testing.T) {
+	tests := []struct {
+		ruleType  string
+		ruleValue string
+		valid     []string
+		invalid   []string
+	}{
+		{"OneOf", "debug, info,warn", []string{"debug", "info", "warn"}, []string{"error", "", "Info"}},
+		{"NotOneOf", "latest,master", []string{"1.0.0", ""}, []string{"latest", "master"}},
+		{"Range", "1..10", []string{"1", "5.5", "10"}, []string{"0", "11", "ten", ""}},
+		{"Range", "..100", []string{"-5", "100"}, []string{"101"}},
+		{"Range", "8000..", []string{"8080"}, []string{"80"}},
+		{"URL", "", []string{"http://foo.com", "https://foo.com:8443/api?a=b"},
+			[]string{"foo.com", "/api", "http://", ""}},
+		{"Hostname", "", []string{"foo", "foo.svc.cluster.local"}, []string{"", "foo_bar", "-foo", "http://foo"}},
+		{"Email", "", []string{"john@foo.com"}, []string{"john", "john@", ""}},
+		{"MinLength", "3", []string{"abc", "ççç"}, []string{"ab", ""}},
+		{"MaxLength", "3", []string{"", "abc"}, []string{"abcd"}},
+		{"JSONSchema", `{"type": "integer", "minimum": 1}`, []string{"1", "80"}, []string{"0", "abc", "[1]"}},
+		{"JSONSchema", `{"type": "array", "items": {"type": "string"}}`, []string{`["a", "b"]`, "- a\n- b"},
+			[]string{`[1]`, "a"}},
+	}
+
+	appContext := AppContext{}
+	for _, tt := range tests {
+		var variables []model.Variable
+		for _, value := range append(tt.valid, tt.invalid...) {
+			variables = append(variables, getVar("foo", value))
+		}
+		ivr, err := appContext.validate(999, variables, []model.VariableRule{getVarRule("foo", tt.ruleType, tt.ruleValue)})
+		assert.NoError(t, err)
+
+		invalid := make([]string, 0)
+		for _, iv := range ivr.InvalidVariables {
+			invalid = append(invalid, iv.Value)
+		}
+		assert.Equal(t, tt.invalid, invalid, tt.ruleType+" "+tt.ruleValue)
+	}
+}
+
+func TestValidateReference(t *testing.T) {
+	appContext, _ := getInterpolationAppContext()
+
+	variables := []model.Variable{
+		{Scope: "repo/foo", Name: "url", Value: "http://${bar:host}"},
+		{Scope: "repo/foo", Name: "url", Value: "http://bar.dev.local"},
+		{Scope: "repo/foo", Name: "url", Value: "http://${bar:missing}"},
+	}
+	ivr, err := appContext.validate(999, variables, []model.VariableRule{getVarRule("url", "Reference", "")})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ivr.InvalidVariables))
+	assert.Equal(t, "http://bar.dev.local", ivr.InvalidVariables[0].Value)
+	assert.Equal(t, "http://${bar:missing}", ivr.InvalidVariables[1].Value)
+}
+
+func TestValidateRuleScope(t *testing.T) {
+	appContext := AppContext{}
+	environment := mockGetEnv()
+	environment.ProductVersion = "19.0.1-0"
+	environment.ProductVersionID = 5
+	mockEnvDao := &mockRepo.EnvironmentDAOInterface{}
+	mockEnvDao.On("GetByID", 999).Return(&environment, nil)
+	appContext.Repositories.EnvironmentDAO = mockEnvDao
+
+	mockProductDAO := &mockRepo.ProductDAOInterface{}
+	mockProductDAO.On("ListProductVersionsByID", 5).Return(&model.ProductVersion{ProductID: 2, Version: "19.0.1-0"}, nil)
+	appContext.Repositories.ProductDAO = mockProductDAO
+
+	variable := getVar("foo", "")
+	variable.Scope = "repo/my-chart"
+
+	tests := []struct {
+		rule    model.VariableRule
+		applies bool
+	}{
+		{model.VariableRule{}, true},
+		{model.VariableRule{ChartPattern: "^repo/my-"}, true},
+		{model.VariableRule{ChartPattern: "^repo/other"}, false},
+		{model.VariableRule{EnvironmentID: 999}, true},
+		{model.VariableRule{EnvironmentID: 1}, false},
+		{model.VariableRule{EnvironmentGroup: "foo"}, true},
+		{model.VariableRule{EnvironmentGroup: "prod"}, false},
+		{model.VariableRule{ProductID: 2}, true},
+		{model.VariableRule{ProductID: 1}, false},
+		{model.VariableRule{EnvironmentGroup: "foo", ProductID: 1}, false},
+	}
+
+	for _, tt := range tests {
+		rule := getVarRule("foo", "NotEmpty", "")
+		rule.ChartPattern = tt.rule.ChartPattern
+		rule.EnvironmentID = tt.rule.EnvironmentID
+		rule.EnvironmentGroup = tt.rule.EnvironmentGroup
+		rule.ProductID = tt.rule.ProductID
+		rule.ValueRules[0].Severity = model.ValueRuleSeverityWarning
+
+		ivr, err := appContext.validate(999, []model.Variable{variable}, []model.VariableRule{rule})
+		assert.NoError(t, err)
+		if !tt.applies {
+			assert.Empty(t, ivr.InvalidVariables)
+			continue
+		}
+		assert.Equal(t, 1, len(ivr.InvalidVariables))
+		assert.Equal(t, "warning", ivr.InvalidVariables[0].Severity)
+	}
+	mockProductDAO.AssertNumberOfCalls(t, "ListProductVersionsByID", 3)
+}
+
+func TestValidateSecretIsMasked(t *testing.T) {
+	appContext, _ := getSecretAppContext()
+	variable, _ := appContext.Repositories.VariableDAO.GetByID(10)
+
+	ivr, err := appContext.validate(999, []model.Variable{*variable},
+		[]model.VariableRule{getVarRule("password", "MinLength", "8")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(ivr.InvalidVariables))
+	assert.Equal(t, "******", ivr.InvalidVariables[0].Value)
+}
+
+func TestNewValueRule_Invalid(t *testing.T) {
+	appContext := AppContext{}
+	mockValueRuleDAO := &mockRepo.ValueRuleDAOInterface{}
+	appContext.Repositories.ValueRuleDAO = mockValueRuleDAO
+
+	for _, rule := range []model.ValueRule{
+		{Type: "Unknown"},
+		{Type: "NotEmpty", Severity: "fatal"},
+		{Type: "RegEx", Value: "a("},
+		{Type: "Range", Value: "1-10"},
+		{Type: "MaxLength", Value: "ten"},
+		{Type: "JSONSchema", Value: `{"type": "number"`},
+	} {
+		req, _ := http.NewRequest("POST", "/valueRules", payload(rule))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(appContext.newValueRule).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400 for "+rule.Type)
+	}
+	mockValueRuleDAO.AssertNotCalled(t, "CreateValueRule", mock.Anything)
+}
@@ -178,7 +178,7 @@ func TestListValueRule(t *testing.T) {
 	response := string(rr.Body.Bytes())
 	assert.Contains(t, response, `{"list":[{"ID":888,`)
 	assert.Contains(t, response, `"type":"StartsWith",`)
-	assert.Contains(t, response, `"value":"http","VariableRuleID":999}]}`)
+	assert.Contains(t, response, `"value":"http","VariableRuleID":999,"severity":""}]}`)
 }
 
 func TestListValueRule_ParseError(t *testing.T) {
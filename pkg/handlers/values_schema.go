@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+)
+
+//valuesSchemaRule names the chart values.schema.json in the invalid variables it reports
+const valuesSchemaRule = "values.schema.json"
+
+//getValuesSchema returns the values.schema.json of a chart version, nil if the chart has none
+func (appContext *AppContext) getValuesSchema(chart string, chartVersion string) (*jsonschema.Schema, error) {
+	valuesChart, err := appContext.valuesChart(chart)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := appContext.HelmServiceAPI.GetTemplate(&appContext.Mutex, valuesChart, chartVersion, "schema")
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(valuesSchemaRule, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(valuesSchemaRule)
+}
+
+//validateValuesSchema validates the values document a release installs, merged onto the chart values.yaml
+//as helm does, against the chart schema. Each violation is reported on the variable holding the value,
+//or on the missing value.
+func (appContext *AppContext) validateValuesSchema(schema *jsonschema.Schema, environment *model.Environment,
+	chart string, chartVersion string, variables []model.Variable,
+	globalVariables []model.Variable) ([]model.InvalidVariable, error) {
+
+	values, _, err := appContext.releaseValues(environment, chart, chartVersion, variables, globalVariables)
+	if err != nil {
+		return nil, err
+	}
+	chartValues, err := appContext.getHelmChartValues(chart, chartVersion)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(coalesceValues(values, chartValues))
+	if err != nil {
+		return nil, err
+	}
+	document, err := jsonschema.DecodeJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.InvalidVariable, 0)
+	err = schema.ValidateInterface(document)
+	if err == nil {
+		return result, nil
+	}
+	validationError, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, err
+	}
+
+	for _, cause := range schemaCauses(validationError) {
+		for _, key := range schemaErrorKeys(cause) {
+			invalid := model.InvalidVariable{
+				Scope:        chart,
+				Name:         strings.TrimPrefix(key, "app."),
+				VariableRule: valuesSchemaRule,
+				RuleType:     "Schema",
+				ValueRule:    cause.Message,
+				Severity:     model.ValueRuleSeverityError,
+			}
+			if variable, found := schemaVariable(variables, key); found {
+				invalid.Scope = variable.Scope
+				invalid.Name = variable.Name
+				invalid.Value = maskedValue(variable)
+			}
+			result = append(result, invalid)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name == result[j].Name {
+			return result[i].ValueRule < result[j].ValueRule
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+//coalesceValues completes the values of a release with the chart values. As in helm, the release values
+//win and tables are merged.
+func coalesceValues(values map[string]interface{}, chartValues map[string]interface{}) map[string]interface{} {
+	for key, chartValue := range chartValues {
+		value, ok := values[key]
+		if !ok {
+			values[key] = chartValue
+			continue
+		}
+		table, isTable := value.(map[string]interface{})
+		chartTable, chartIsTable := chartValue.(map[string]interface{})
+		if isTable && chartIsTable {
+			coalesceValues(table, chartTable)
+		}
+	}
+	return values
+}
+
+//schemaCauses returns the innermost errors of a schema validation, the ones telling what is wrong
+func schemaCauses(validationError *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(validationError.Causes) == 0 {
+		return []*jsonschema.ValidationError{validationError}
+	}
+	causes := make([]*jsonschema.ValidationError, 0)
+	for _, cause := range validationError.Causes {
+		causes = append(causes, schemaCauses(cause)...)
+	}
+	return causes
+}
+
+//schemaErrorKeys returns the values keys, written as in helm --set, a schema error is about.
+//Missing properties are reported on the key they should have.
+func schemaErrorKeys(validationError *jsonschema.ValidationError) []string {
+	key := pointerKey(validationError.InstancePtr)
+	if !strings.HasSuffix(validationError.SchemaPtr, "/required") {
+		return []string{key}
+	}
+
+	keys := make([]string, 0)
+	missing := strings.TrimPrefix(validationError.Message, "missing properties: ")
+	for _, property := range strings.Split(missing, ", ") {
+		if unquoted, err := strconv.Unquote(property); err == nil {
+			property = unquoted
+		}
+		if key == "" {
+			keys = append(keys, property)
+		} else {
+			keys = append(keys, key+"."+property)
+		}
+	}
+	return keys
+}
+
+//pointerKey converts a JSON pointer (#/app/hosts/0) to a values key (app.hosts[0])
+func pointerKey(pointer string) string {
+	pointer = strings.TrimPrefix(strings.TrimPrefix(pointer, "#"), "/")
+	if pointer == "" {
+		return ""
+	}
+
+	key := ""
+	for _, token := range strings.Split(pointer, "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		if _, err := strconv.Atoi(token); err == nil && key != "" {
+			key += "[" + token + "]"
+		} else if key == "" {
+			key = token
+		} else {
+			key += "." + token
+		}
+	}
+	return key
+}
+
+//schemaVariable finds the variable setting a values key, or one of its parents
+func schemaVariable(variables []model.Variable, key string) (model.Variable, bool) {
+	var result model.Variable
+	found := false
+	for _, variable := range variables {
+		name := normalizeVariableName(variable.Name)
+		if name != key && !strings.HasPrefix(key, name+".") && !strings.HasPrefix(key, name+"[") {
+			continue
+		}
+		if !found || len(name) > len(normalizeVariableName(result.Name)) {
+			result = variable
+			found = true
+		}
+	}
+	return result, found
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/softplan/tenkai-api/pkg/configs"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	mockSvc "github.com/softplan/tenkai-api/pkg/service/_helm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testValuesSchema = `{
+	"type": "object",
+	"properties": {
+		"app": {
+			"type": "object",
+			"required": ["host"],
+			"properties": {
+				"replicas": {"type": "integer"},
+				"logLevel": {"enum": ["debug", "info"]},
+				"hosts": {"type": "array", "items": {"type": "string", "minLength": 1}}
+			}
+		}
+	}
+}`
+
+func getSchemaAppContext(schema string) (*AppContext, *mockSvc.HelmServiceInterface) {
+	appContext := AppContext{}
+	appContext.Configuration = &configs.Configuration{App: configs.App{Passkey: "qwert"}}
+
+	mockHelmSvc := &mockSvc.HelmServiceInterface{}
+	mockHelmSvc.On("GetTemplate", mock.Anything, "repo/foo", mock.Anything, "values").
+		Return([]byte(`{"app":{"logLevel":"info"}}`), nil)
+	mockHelmSvc.On("GetTemplate", mock.Anything, "repo/foo", mock.Anything, "schema").Return([]byte(schema), nil)
+	appContext.HelmServiceAPI = mockHelmSvc
+	return &appContext, mockHelmSvc
+}
+
+func getSchemaVariables() []model.Variable {
+	return []model.Variable{
+		{Scope: "repo/foo", Name: "replicas", Value: "three"},
+		{Scope: "repo/foo", Name: "logLevel", Value: "trace"},
+		{Scope: "repo/foo", Name: "hosts", Value: `["a.com", ""]`, Type: model.VariableTypeList},
+	}
+}
+
+func TestPointerKey(t *testing.T) {
+	assert.Equal(t, "", pointerKey("#"))
+	assert.Equal(t, "app.replicas", pointerKey("#/app/replicas"))
+	assert.Equal(t, "app.hosts[1]", pointerKey("#/app/hosts/1"))
+	assert.Equal(t, "app.a/b", pointerKey("#/app/a~1b"))
+	assert.Equal(t, "0", pointerKey("#/0"))
+}
+
+func TestValidateValuesSchema(t *testing.T) {
+	appContext, _ := getSchemaAppContext(testValuesSchema)
+	environment := mockGetEnv()
+
+	schema, err := appContext.getValuesSchema("repo/foo", "0.1.0")
+	assert.NoError(t, err)
+	assert.NotNil(t, schema)
+
+	result, err := appContext.validateValuesSchema(schema, &environment, "repo/foo", "0.1.0",
+		getSchemaVariables(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(result))
+
+	assert.Equal(t, "host", result[0].Name)
+	assert.Equal(t, "", result[0].Value)
+	assert.Equal(t, "hosts", result[1].Name)
+	assert.Equal(t, `["a.com", ""]`, result[1].Value)
+	assert.Equal(t, "logLevel", result[2].Name)
+	assert.Equal(t, "trace", result[2].Value)
+	assert.Equal(t, "replicas", result[3].Name)
+	assert.Equal(t, "Schema", result[3].RuleType)
+	assert.Equal(t, "values.schema.json", result[3].VariableRule)
+	assert.Equal(t, "error", result[3].Severity)
+	assert.Contains(t, result[3].ValueRule, "expected integer")
+}
+
+func TestValidateValuesSchema_ChartDefaults(t *testing.T) {
+	appContext, mockHelmSvc := getSchemaAppContext(`{
+		"type": "object",
+		"required": ["image", "app"],
+		"properties": {
+			"image": {"type": "object", "required": ["tag"]},
+			"app": {
+				"type": "object",
+				"required": ["replicas", "logLevel"],
+				"properties": {"replicas": {"type": "integer"}, "debug": {"type": "boolean"}}
+			}
+		}
+	}`)
+	mockHelmSvc.ExpectedCalls[0].ReturnArguments = mock.Arguments{
+		[]byte(`{"image":{"tag":"1.0"},"app":{"replicas":2,"debug":false,"logLevel":"info"}}`), nil}
+	environment := mockGetEnv()
+
+	schema, err := appContext.getValuesSchema("repo/foo", "0.1.0")
+	assert.NoError(t, err)
+
+	result, err := appContext.validateValuesSchema(schema, &environment, "repo/foo", "0.1.0", nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+
+	result, err = appContext.validateValuesSchema(schema, &environment, "repo/foo", "0.1.0",
+		[]model.Variable{{Scope: "repo/foo", Name: "replicas", Value: "many"}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "replicas", result[0].Name)
+}
+
+func TestCoalesceValues(t *testing.T) {
+	values := map[string]interface{}{"app": map[string]interface{}{"replicas": int64(3)}, "image": "custom"}
+	chartValues := map[string]interface{}{
+		"app":     map[string]interface{}{"replicas": float64(1), "debug": false},
+		"image":   map[string]interface{}{"tag": "1.0"},
+		"service": map[string]interface{}{"port": float64(80)},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"app":     map[string]interface{}{"replicas": int64(3), "debug": false},
+		"image":   "custom",
+		"service": map[string]interface{}{"port": float64(80)},
+	}, coalesceValues(values, chartValues))
+}
+
+func TestGetValuesSchema_NoSchema(t *testing.T) {
+	appContext, _ := getSchemaAppContext("")
+
+	schema, err := appContext.getValuesSchema("repo/foo", "0.1.0")
+	assert.NoError(t, err)
+	assert.Nil(t, schema)
+}
+
+func TestValidateVariables_Schema(t *testing.T) {
+	appContext, _ := getSchemaAppContext(testValuesSchema)
+	mockGetByID(appContext)
+	mockListVariableRules(appContext)
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 999, "repo/foo").Return(getSchemaVariables(), nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 999, "global").Return([]model.Variable{}, nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	body := map[string]interface{}{"environmentId": 999, "scope": "repo/foo", "chartVersion": "0.1.0"}
+	req, _ := http.NewRequest("POST", "/validateVariables", payload(body))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(appContext.validateVariables).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be ok.")
+	var result model.InvalidVariablesResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, 4, len(result.InvalidVariables))
+}
+
+func TestValidateCharts_Schema(t *testing.T) {
+	appContext, _ := getSchemaAppContext(testValuesSchema)
+	mockGetByID(appContext)
+
+	variables := append(getSchemaVariables(), model.Variable{Scope: "repo/foo", Name: "host", Value: "foo.com"})
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironmentsAndScopes", mock.Anything, mock.Anything).Return(variables, nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 999, "repo/foo").Return(variables, nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 999, "global").Return([]model.Variable{}, nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	body := map[string]interface{}{
+		"charts":       []model.Chart{{Repo: "repo", Name: "foo", Version: "0.1.0"}},
+		"environments": []int{999},
+	}
+	req, _ := http.NewRequest("POST", "/validateCharts", payload(body))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(appContext.validateNewVariablesBeforeInstall).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be ok.")
+	var result []struct {
+		EnvironmentID    int                     `json:"environmentId"`
+		Charts           []string                `json:"charts"`
+		SchemaViolations []model.InvalidVariable `json:"schemaViolations"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, []string{"repo/foo"}, result[0].Charts)
+	assert.Equal(t, 3, len(result[0].SchemaViolations))
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	helmapi "github.com/softplan/tenkai-api/pkg/service/_helm"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+//cleanupVariables lists the variables of one or all environments that look unused: their scope is not
+//deployed, their key is gone from the deployed chart or their chart is gone from the repository. In an
+//environment inherited by others, scopes not deployed have their own reason as the children may use them.
+//Without dryRun the selected candidates are deleted, keeping a revision so they can be restored.
+func (appContext *AppContext) cleanupVariables(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	principal := util.GetPrincipal(r)
+	isAdmin := util.Contains(principal.Roles, constraints.TenkaiAdmin)
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	var payload model.VariableCleanup
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !dryRun && len(payload.VariableIDs) == 0 {
+		http.Error(w, "variableIds is required", http.StatusBadRequest)
+		return
+	}
+
+	environments, err := appContext.getReplaceEnvironments(principal, isAdmin, payload.EnvironmentIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	report := model.VariableCleanupReport{DryRun: dryRun, Candidates: make([]model.VariableCleanupCandidate, 0),
+		Errors: make([]string, 0)}
+	candidates := make(map[uint]model.Variable)
+	charts := &chartIndex{charts: make(map[string]bool), repositories: make(map[string]bool)}
+	for _, environment := range environments {
+		found, err := appContext.findCleanupCandidates(environment, charts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, candidate := range found {
+			candidates[candidate.variable.ID] = candidate.variable
+			report.Candidates = append(report.Candidates, model.VariableCleanupCandidate{
+				EnvironmentID: int(environment.ID),
+				Environment:   environment.Name,
+				VariableID:    candidate.variable.ID,
+				Scope:         candidate.variable.Scope,
+				Name:          candidate.variable.Name,
+				Value:         maskedValue(candidate.variable),
+				Secret:        candidate.variable.Secret,
+				Reason:        candidate.reason,
+			})
+		}
+	}
+	for _, repository := range charts.unreadable {
+		report.Errors = append(report.Errors, "Charts of repository "+repository+
+			" could not be read, renamed charts were not checked")
+	}
+
+	if dryRun {
+		data, _ := json.Marshal(report)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+		return
+	}
+
+	selected := make(map[uint]bool)
+	for _, id := range payload.VariableIDs {
+		if _, ok := candidates[id]; !ok {
+			http.Error(w, "Variable "+strconv.Itoa(int(id))+" is not a cleanup candidate", http.StatusBadRequest)
+			return
+		}
+		selected[id] = true
+	}
+
+	if !isAdmin {
+		for _, environment := range environments {
+			if countCleanupSelected(report.Candidates, selected, environment) == 0 {
+				continue
+			}
+			if auth, _ := appContext.hasEnvironmentRole(principal, environment.ID, "ACTION_SAVE_VARIABLES"); !auth {
+				http.Error(w, global.AccessDenied+" - "+environment.Name, http.StatusUnauthorized)
+				return
+			}
+		}
+	}
+
+	ids := make([]int, 0, len(selected))
+	for id := range selected {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	if err := appContext.Repositories.VariableDAO.DeleteVariables(ids); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i, candidate := range report.Candidates {
+		if !selected[candidate.VariableID] {
+			continue
+		}
+		variable := candidates[candidate.VariableID]
+		appContext.recordVariableRevision(principal.Email, model.VariableDeleted, variable, variable.Value)
+		report.Candidates[i].Deleted = true
+		report.Deleted++
+	}
+
+	for _, environment := range environments {
+		count := countCleanupSelected(report.Candidates, selected, environment)
+		if count == 0 {
+			continue
+		}
+		auditValues := make(map[string]string)
+		auditValues["environment"] = environment.Name
+		auditValues["variables"] = strconv.Itoa(count)
+		appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "cleanupVariables", auditValues)
+	}
+
+	data, _ := json.Marshal(report)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+type cleanupCandidate struct {
+	variable model.Variable
+	reason   string
+}
+
+//chartIndex caches which charts exist in the repositories and which repositories can be read
+type chartIndex struct {
+	charts       map[string]bool
+	repositories map[string]bool
+	unreadable   []string
+}
+
+//findCleanupCandidates checks the variables of an environment against the releases running in its
+//namespace and the charts of the repository
+func (appContext *AppContext) findCleanupCandidates(environment *model.Environment,
+	charts *chartIndex) ([]cleanupCandidate, error) {
+
+	variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(environment.ID))
+	if err != nil {
+		return nil, err
+	}
+	parent, err := appContext.hasChildEnvironments(environment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeConfig := appContext.ConventionInterface.GetKubeConfigFileName(environment.Group, environment.Name)
+	helmReleases, err := appContext.HelmServiceAPI.ListHelmDeployments(kubeConfig, environment.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if helmReleases == nil {
+		helmReleases = &helmapi.HelmListResult{}
+	}
+
+	chartVars := make(map[string]map[string]interface{})
+	result := make([]cleanupCandidate, 0)
+	for _, v := range variables {
+		if v.Scope == "global" {
+			continue
+		}
+		gcm := strings.Contains(v.Scope, "gcm")
+
+		if !gcm {
+			if exists, known := appContext.chartExists(v.Scope, charts); known && !exists {
+				result = append(result, cleanupCandidate{variable: v, reason: model.CleanupChartRenamed})
+				continue
+			}
+		}
+		if !scopeRunning(helmReleases, v.Scope, environment.Namespace) {
+			reason := model.CleanupScopeNotDeployed
+			if parent {
+				reason = model.CleanupScopeInherited
+			}
+			result = append(result, cleanupCandidate{variable: v, reason: reason})
+			continue
+		}
+		if gcm || normalizeVariableName(v.Name) != "app."+v.Name {
+			continue
+		}
+
+		values, ok := chartVars[v.Scope]
+		if !ok {
+			values = appContext.deployedChartVars(helmReleases, v.Scope)
+			chartVars[v.Scope] = values
+		}
+		if values != nil && !hasValuesKey(values, v.Name) {
+			result = append(result, cleanupCandidate{variable: v, reason: model.CleanupKeyNotInChart})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].variable.Scope != result[j].variable.Scope {
+			return result[i].variable.Scope < result[j].variable.Scope
+		}
+		return result[i].variable.Name < result[j].variable.Name
+	})
+	return result, nil
+}
+
+//chartExists tells if a chart is in its repository. It is not known when the search finds no chart
+//of the repository at all, as the charts of the repository could not be read.
+func (appContext *AppContext) chartExists(chart string, charts *chartIndex) (bool, bool) {
+	if _, ok := charts.charts[chart]; !ok {
+		charts.charts[chart] = appContext.searchChart(chart, func(name string) bool {
+			return name == chart
+		})
+	}
+	if charts.charts[chart] {
+		return true, true
+	}
+
+	repository := chart
+	if idx := strings.Index(chart, "/"); idx > 0 {
+		repository = chart[:idx]
+	}
+	if _, ok := charts.repositories[repository]; !ok {
+		charts.repositories[repository] = appContext.searchChart(repository, func(name string) bool {
+			return strings.HasPrefix(name, repository+"/")
+		})
+		if !charts.repositories[repository] {
+			charts.unreadable = append(charts.unreadable, repository)
+		}
+	}
+	return false, charts.repositories[repository]
+}
+
+//searchChart tells if the search of a term finds a chart that matches
+func (appContext *AppContext) searchChart(term string, matches func(string) bool) bool {
+	searchResult := appContext.HelmServiceAPI.SearchCharts([]string{term}, false)
+	if searchResult == nil {
+		return false
+	}
+	for _, e := range *searchResult {
+		if matches(e.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+//deployedChartVars returns the app values of the chart version running for a scope, nil when
+//they can not be found
+func (appContext *AppContext) deployedChartVars(helmReleases *helmapi.HelmListResult,
+	scope string) map[string]interface{} {
+
+	for _, e := range helmReleases.Releases {
+		lastHifen := strings.LastIndex(e.Chart, "-")
+		if lastHifen < 0 || e.Chart[:lastHifen] != chartNameOf(scope) {
+			continue
+		}
+		values, err := appContext.getHelmChartAppVars(scope, e.Chart[lastHifen+1:])
+		if err != nil {
+			global.Logger.Error(global.AppFields{global.Function: "deployedChartVars"},
+				"Error reading values of "+e.Chart+": "+err.Error())
+			return nil
+		}
+		return values
+	}
+	return nil
+}
+
+var listIndex = regexp.MustCompile(`\[(\d+)\]`)
+
+//hasValuesKey tells whether a dotted variable name, with list indexes as in hosts[0], is a key of the
+//chart values. Maps without defaults accept any key below them, lists any index past their defaults.
+func hasValuesKey(values map[string]interface{}, name string) bool {
+	current := values
+	for _, segment := range strings.Split(name, ".") {
+		value, ok := current[listIndex.ReplaceAllString(segment, "")]
+		if !ok {
+			return false
+		}
+		for _, index := range listIndex.FindAllStringSubmatch(segment, -1) {
+			list, isList := value.([]interface{})
+			i, _ := strconv.Atoi(index[1])
+			if !isList || i >= len(list) {
+				return true
+			}
+			value = list[i]
+		}
+		next, isMap := value.(map[string]interface{})
+		if !isMap || len(next) == 0 {
+			return true
+		}
+		current = next
+	}
+	return true
+}
+
+func countCleanupSelected(candidates []model.VariableCleanupCandidate, selected map[uint]bool,
+	environment *model.Environment) int {
+
+	count := 0
+	for _, candidate := range candidates {
+		if candidate.EnvironmentID == int(environment.ID) && selected[candidate.VariableID] {
+			count++
+		}
+	}
+	return count
+}
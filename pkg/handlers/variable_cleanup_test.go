@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	helmapi "github.com/softplan/tenkai-api/pkg/service/_helm"
+	mockSvc "github.com/softplan/tenkai-api/pkg/service/_helm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getCleanupAppContext() (*AppContext, *mockRepo.VariableDAOInterface, *mockSvc.HelmServiceInterface) {
+	appContext, mockVariableDAO := getReplaceAppContext()
+	mockVariableDAO.On("DeleteVariables", mock.Anything).Return(nil)
+
+	mockConvention := mockConventionInterface(appContext)
+	mockConvention.On("GetKubeConfigFileName", "", "dev").Return("./config/_dev")
+	mockConvention.On("GetKubeConfigFileName", "", "qa").Return("./config/_qa")
+
+	mockHelmSvc := &mockSvc.HelmServiceInterface{}
+	mockHelmSvc.On("ListHelmDeployments", mock.Anything, "dev").Return(&helmapi.HelmListResult{
+		Releases: []helmapi.ListRelease{{Name: "api-dev", Chart: "api-0.1.0"}},
+	}, nil)
+	mockHelmSvc.On("ListHelmDeployments", mock.Anything, "qa").Return(&helmapi.HelmListResult{}, nil)
+	mockHelmSvc.On("SearchCharts", []string{"repo/api"}, false).
+		Return(&[]model.SearchResult{{Name: "repo/api"}, {Name: "repo/api-gateway"}})
+	mockHelmSvc.On("SearchCharts", []string{"repo/web"}, false).Return(&[]model.SearchResult{})
+	mockHelmSvc.On("GetTemplate", mock.Anything, "repo/api", "0.1.0", "values").
+		Return([]byte(`{"app":{"dbHost":"localhost","extra":{}}}`), nil)
+	mockHelmSvc.On("SearchCharts", []string{"repo"}, false).Return(&[]model.SearchResult{{Name: "repo/api"}})
+	appContext.HelmServiceAPI = mockHelmSvc
+
+	return appContext, mockVariableDAO, mockHelmSvc
+}
+
+func doCleanupVariables(appContext *AppContext, url string, body model.VariableCleanup,
+	principal model.Principal) (*httptest.ResponseRecorder, model.VariableCleanupReport) {
+
+	req, _ := http.NewRequest("POST", url, payload(body))
+	pSe, _ := json.Marshal(principal)
+	req.Header.Set("principal", string(pSe))
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(appContext.cleanupVariables).ServeHTTP(rr, req)
+
+	var report model.VariableCleanupReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	return rr, report
+}
+
+func TestCleanupVariables_DryRun(t *testing.T) {
+	appContext, mockVariableDAO, mockHelmSvc := getCleanupAppContext()
+
+	rr, report := doCleanupVariables(appContext, "/variables/cleanup?dryRun=true", model.VariableCleanup{},
+		replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 3, len(report.Candidates))
+	assert.Equal(t, "dev", report.Candidates[0].Environment)
+	assert.Equal(t, "cacheHost", report.Candidates[0].Name)
+	assert.Equal(t, model.CleanupKeyNotInChart, report.Candidates[0].Reason)
+	assert.Equal(t, "dbHost", report.Candidates[1].Name)
+	assert.Equal(t, model.CleanupChartRenamed, report.Candidates[1].Reason)
+	assert.Equal(t, "dbUrl", report.Candidates[2].Name)
+	assert.Equal(t, "******", report.Candidates[2].Value)
+	assert.Equal(t, 0, report.Deleted)
+	assert.Empty(t, report.Errors)
+
+	mockHelmSvc.AssertNumberOfCalls(t, "SearchCharts", 3)
+	mockVariableDAO.AssertNotCalled(t, "DeleteVariables", mock.Anything)
+}
+
+func TestCleanupVariables_ScopeNotDeployed(t *testing.T) {
+	appContext, _, mockHelmSvc := getCleanupAppContext()
+	mockHelmSvc.ExpectedCalls[3].ReturnArguments = []interface{}{&[]model.SearchResult{{Name: "repo/web"}}}
+
+	rr, report := doCleanupVariables(appContext, "/variables/cleanup?dryRun=true",
+		model.VariableCleanup{EnvironmentIDs: []int{2}}, replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, 2, len(report.Candidates))
+	assert.Equal(t, model.CleanupScopeNotDeployed, report.Candidates[0].Reason)
+	assert.Equal(t, model.CleanupScopeNotDeployed, report.Candidates[1].Reason)
+}
+
+func TestCleanupVariables_ParentEnvironment(t *testing.T) {
+	appContext, _, mockHelmSvc := getCleanupAppContext()
+	mockHelmSvc.ExpectedCalls[3].ReturnArguments = []interface{}{&[]model.SearchResult{{Name: "repo/web"}}}
+	dev := model.Environment{Name: "dev", Namespace: "dev", ParentID: 2}
+	qa := model.Environment{Name: "qa", Namespace: "qa"}
+	qa.ID = 2
+	mockEnvDao := appContext.Repositories.EnvironmentDAO.(*mockRepo.EnvironmentDAOInterface)
+	mockEnvDao.ExpectedCalls[2].ReturnArguments = []interface{}{[]model.Environment{dev, qa}, nil}
+
+	rr, report := doCleanupVariables(appContext, "/variables/cleanup?dryRun=true",
+		model.VariableCleanup{EnvironmentIDs: []int{2}}, replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, 2, len(report.Candidates))
+	assert.Equal(t, model.CleanupScopeInherited, report.Candidates[0].Reason)
+	assert.Equal(t, model.CleanupScopeInherited, report.Candidates[1].Reason)
+}
+
+func TestCleanupVariables_UnreadableRepository(t *testing.T) {
+	appContext, _, mockHelmSvc := getCleanupAppContext()
+	mockHelmSvc.ExpectedCalls[5].ReturnArguments = []interface{}{&[]model.SearchResult{}}
+
+	rr, report := doCleanupVariables(appContext, "/variables/cleanup?dryRun=true",
+		model.VariableCleanup{EnvironmentIDs: []int{2}}, replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, 2, len(report.Candidates))
+	assert.Equal(t, model.CleanupScopeNotDeployed, report.Candidates[0].Reason)
+	assert.Equal(t, model.CleanupScopeNotDeployed, report.Candidates[1].Reason)
+	assert.Equal(t, []string{"Charts of repository repo could not be read, renamed charts were not checked"},
+		report.Errors)
+}
+
+func TestCleanupVariables_Delete(t *testing.T) {
+	appContext, mockVariableDAO, _ := getCleanupAppContext()
+	auditSvc := mockDoAudit(appContext, "cleanupVariables", map[string]string{"environment": "dev",
+		"variables": "1"})
+	auditSvc.On("DoAudit", mock.Anything, mock.Anything, "beta@alfa.com", "cleanupVariables",
+		map[string]string{"environment": "qa", "variables": "1"})
+
+	rr, report := doCleanupVariables(appContext, "/variables/cleanup",
+		model.VariableCleanup{VariableIDs: []uint{11, 2}}, replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.False(t, report.DryRun)
+	assert.Equal(t, 2, report.Deleted)
+	assert.True(t, report.Candidates[0].Deleted)
+	assert.False(t, report.Candidates[1].Deleted)
+	assert.True(t, report.Candidates[2].Deleted)
+
+	mockVariableDAO.AssertCalled(t, "DeleteVariables", []int{2, 11})
+	mockRevisionDAO := appContext.Repositories.VariableRevisionDAO.(*mockRepo.VariableRevisionDAOInterface)
+	mockRevisionDAO.AssertNumberOfCalls(t, "CreateVariableRevision", 2)
+	revision := mockRevisionDAO.Calls[0].Arguments.Get(0).(model.VariableRevision)
+	assert.Equal(t, model.VariableDeleted, revision.Operation)
+	assert.Equal(t, "cache.old.local", revision.OldValue)
+	auditSvc.AssertNumberOfCalls(t, "DoAudit", 2)
+}
+
+func TestCleanupVariables_Invalid(t *testing.T) {
+	appContext, mockVariableDAO, _ := getCleanupAppContext()
+
+	rr, _ := doCleanupVariables(appContext, "/variables/cleanup", model.VariableCleanup{}, replaceAdmin)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+
+	rr, _ = doCleanupVariables(appContext, "/variables/cleanup", model.VariableCleanup{VariableIDs: []uint{1}},
+		replaceAdmin)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	mockVariableDAO.AssertNotCalled(t, "DeleteVariables", mock.Anything)
+}
+
+func TestCleanupVariables_AccessDenied(t *testing.T) {
+	appContext, mockVariableDAO, _ := getCleanupAppContext()
+	appContext.Repositories.EnvironmentDAO.(*mockRepo.EnvironmentDAOInterface).
+		On("GetAllEnvironments", "beta@alfa.com").Return([]model.Environment{}, nil)
+
+	user := mockUser()
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", user.Email).Return(user, nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	secOper := mockSecurityOperations()
+	mockUserEnvRoleDAO := &mockRepo.UserEnvironmentRoleDAOInterface{}
+	mockUserEnvRoleDAO.On("GetRoleByUserAndEnvironment", user, mock.Anything).Return(&secOper, nil)
+	appContext.Repositories.UserEnvironmentRoleDAO = mockUserEnvRoleDAO
+
+	rr, _ := doCleanupVariables(appContext, "/variables/cleanup?dryRun=true",
+		model.VariableCleanup{EnvironmentIDs: []int{1}}, model.Principal{Email: user.Email})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+
+	environments, _ := appContext.Repositories.EnvironmentDAO.GetAllEnvironments("")
+	appContext.Repositories.EnvironmentDAO.(*mockRepo.EnvironmentDAOInterface).ExpectedCalls[3].
+		ReturnArguments = []interface{}{environments, nil}
+	rr, _ = doCleanupVariables(appContext, "/variables/cleanup",
+		model.VariableCleanup{EnvironmentIDs: []int{1}, VariableIDs: []uint{2}}, model.Principal{Email: user.Email})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+	mockVariableDAO.AssertNotCalled(t, "DeleteVariables", mock.Anything)
+}
+
+func TestHasValuesKey(t *testing.T) {
+	values := map[string]interface{}{"app": map[string]interface{}{
+		"dbHost": "localhost",
+		"hosts":  []interface{}{"a.com"},
+		"ports":  []interface{}{map[string]interface{}{"name": "http", "port": float64(80)}},
+		"extra":  map[string]interface{}{},
+	}}
+
+	assert.True(t, hasValuesKey(values, "app.dbHost"))
+	assert.True(t, hasValuesKey(values, "app.hosts[0]"))
+	assert.True(t, hasValuesKey(values, "app.hosts[3]"))
+	assert.True(t, hasValuesKey(values, "app.ports[0].port"))
+	assert.True(t, hasValuesKey(values, "app.extra.anything"))
+	assert.False(t, hasValuesKey(values, "app.ports[0].protocol"))
+	assert.False(t, hasValuesKey(values, "app.aliases[0]"))
+	assert.False(t, hasValuesKey(values, "app.cacheHost"))
+}
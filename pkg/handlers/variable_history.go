@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+const secretMask = "******"
+
+func (appContext *AppContext) listVariableHistory(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	variable, err := appContext.Repositories.VariableDAO.GetByID(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	has, err := appContext.hasAccess(principal.Email, variable.EnvironmentID)
+	if err != nil || !has {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	result := &model.VariableRevisionResult{}
+	if result.List, err = appContext.Repositories.VariableRevisionDAO.ListVariableRevisions(variable.EnvironmentID,
+		variable.Scope, variable.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	maskRevisionSecrets(result.List)
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (appContext *AppContext) listEnvironmentVariableHistory(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	has, err := appContext.hasAccess(principal.Email, id)
+	if err != nil || !has {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	scope := r.URL.Query().Get("scope")
+	name := r.URL.Query().Get("name")
+
+	result := &model.VariableRevisionResult{}
+	if name != "" {
+		result.List, err = appContext.Repositories.VariableRevisionDAO.ListVariableRevisions(id, scope, name)
+	} else {
+		result.List, err = appContext.Repositories.VariableRevisionDAO.ListEnvironmentVariableRevisions(id, scope)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	maskRevisionSecrets(result.List)
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (appContext *AppContext) restoreEnvironmentVariables(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		auth, _ := appContext.hasEnvironmentRole(principal, uint(id), "ACTION_SAVE_VARIABLES")
+		if !auth {
+			http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var payload model.VariableRestoreRequest
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if payload.At.IsZero() {
+		http.Error(w, "The point in time to restore is required", http.StatusBadRequest)
+		return
+	}
+
+	environment, err := appContext.Repositories.EnvironmentDAO.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	changes, current, err := appContext.planVariableRestore(id, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !payload.DryRun && len(changes) > 0 {
+		if err := appContext.applyVariableRestore(id, principal.Email, changes, current); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		auditValues := make(map[string]string)
+		auditValues["environment"] = environment.Name
+		auditValues["at"] = payload.At.Format("2006-01-02T15:04:05Z07:00")
+		auditValues["scope"] = payload.Scope
+		auditValues["changes"] = strconv.Itoa(len(changes))
+		appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "restoreVariables", auditValues)
+	}
+
+	result := model.VariableRestoreResult{DryRun: payload.DryRun, Changes: changes}
+	for i := range result.Changes {
+		if result.Changes[i].Secret {
+			result.Changes[i].CurrentValue = secretMask
+			result.Changes[i].RestoredValue = secretMask
+		}
+	}
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//planVariableRestore finds the variables changed after the point in time and how to bring them back.
+//The first change made after that moment tells the value the variable had: nothing when it was
+//created later, the old value otherwise. Variables not changed since then are kept as they are.
+func (appContext *AppContext) planVariableRestore(envID int,
+	payload model.VariableRestoreRequest) ([]model.VariableRestoreChange, map[string]model.Variable, error) {
+
+	revisions, err := appContext.Repositories.VariableRevisionDAO.ListEnvironmentVariableRevisions(envID, payload.Scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(envID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current := make(map[string]model.Variable)
+	for _, v := range variables {
+		if payload.Scope == "" || v.Scope == payload.Scope {
+			current[v.Scope+"/"+v.Name] = v
+		}
+	}
+
+	firstAfter := make(map[string]model.VariableRevision)
+	lastBefore := make(map[string]model.VariableRevision)
+	keys := make([]string, 0)
+	for _, rev := range revisions {
+		key := rev.Scope + "/" + rev.Name
+		if !rev.CreatedAt.After(payload.At) {
+			lastBefore[key] = rev
+			continue
+		}
+		if _, ok := firstAfter[key]; ok {
+			continue
+		}
+		firstAfter[key] = rev
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changes := make([]model.VariableRestoreChange, 0)
+	for _, key := range keys {
+		rev := firstAfter[key]
+		existed := rev.Operation != model.VariableCreated
+		variable, exists := current[key]
+
+		//Revisions keep the fields after the change, a deletion keeps the ones of the deleted variable
+		fields := model.VariableRevision{Secret: variable.Secret, Type: variable.Type, Description: variable.Description}
+		if before, ok := lastBefore[key]; ok {
+			fields = before
+		} else if rev.Operation == model.VariableDeleted || !exists {
+			fields = rev
+		}
+
+		change := model.VariableRestoreChange{
+			Scope:               rev.Scope,
+			Name:                rev.Name,
+			CurrentValue:        variable.Value,
+			RestoredValue:       rev.OldValue,
+			Secret:              rev.Secret || variable.Secret || fields.Secret,
+			RestoredSecret:      fields.Secret,
+			RestoredType:        fields.Type,
+			RestoredDescription: fields.Description,
+		}
+
+		switch {
+		case existed && !exists:
+			change.Operation = model.VariableCreated
+		case !existed && exists:
+			change.Operation = model.VariableDeleted
+			change.RestoredValue = ""
+			change.RestoredSecret = false
+			change.RestoredType = ""
+			change.RestoredDescription = ""
+		case existed && exists && (variable.Value != rev.OldValue || variable.Secret != fields.Secret ||
+			variable.Type != fields.Type || variable.Description != fields.Description):
+			change.Operation = model.VariableUpdated
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, current, nil
+}
+
+//applyVariableRestore saves the restored variables at once, recording their revisions when saved
+func (appContext *AppContext) applyVariableRestore(envID int, user string, changes []model.VariableRestoreChange,
+	current map[string]model.Variable) error {
+
+	saved := make([]model.Variable, 0)
+	operations := make([]string, 0)
+	oldValues := make([]string, 0)
+	deleted := make([]model.Variable, 0)
+	deleteIDs := make([]int, 0)
+
+	for _, change := range changes {
+		variable := current[change.Scope+"/"+change.Name]
+		oldValue := variable.Value
+
+		if change.Operation == model.VariableDeleted {
+			deleted = append(deleted, variable)
+			deleteIDs = append(deleteIDs, int(variable.ID))
+			continue
+		}
+		if change.Operation == model.VariableCreated {
+			variable = model.Variable{EnvironmentID: envID, Scope: change.Scope, Name: change.Name}
+			oldValue = ""
+		}
+		variable.Value = change.RestoredValue
+		variable.Secret = change.RestoredSecret
+		variable.Type = change.RestoredType
+		variable.Description = change.RestoredDescription
+
+		saved = append(saved, variable)
+		operations = append(operations, change.Operation)
+		oldValues = append(oldValues, oldValue)
+	}
+
+	if err := appContext.Repositories.VariableDAO.RestoreVariables(saved, deleteIDs); err != nil {
+		return err
+	}
+
+	for i, variable := range saved {
+		appContext.recordVariableRevision(user, operations[i], variable, oldValues[i])
+	}
+	for _, variable := range deleted {
+		appContext.recordVariableRevision(user, model.VariableDeleted, variable, variable.Value)
+	}
+	return nil
+}
+
+//recordVariableRevision keeps a change in the variable history. The change itself is already
+//saved, so failures are only logged.
+func (appContext *AppContext) recordVariableRevision(user string, operation string, variable model.Variable,
+	oldValue string) {
+
+	revision := model.VariableRevision{
+		EnvironmentID: variable.EnvironmentID,
+		Scope:         variable.Scope,
+		Name:          variable.Name,
+		Operation:     operation,
+		OldValue:      oldValue,
+		Secret:        variable.Secret,
+		Type:          variable.Type,
+		Description:   variable.Description,
+		User:          user,
+	}
+	if operation != model.VariableDeleted {
+		revision.NewValue = variable.Value
+	}
+
+	if _, err := appContext.Repositories.VariableRevisionDAO.CreateVariableRevision(revision); err != nil {
+		global.Logger.Error(global.AppFields{global.Function: "recordVariableRevision"},
+			"Error recording revision of "+variable.Scope+"/"+variable.Name+": "+err.Error())
+	}
+}
+
+//recordSavedVariable records the change made by VariableDAO.CreateVariable, telling creates
+//from updates by the audit values it returns
+func (appContext *AppContext) recordSavedVariable(user string, variable model.Variable,
+	auditValues map[string]string, updated bool) {
+
+	if !updated {
+		return
+	}
+	if oldValue, ok := auditValues["variable_old_value"]; ok {
+		appContext.recordVariableRevision(user, model.VariableUpdated, variable, oldValue)
+		return
+	}
+	appContext.recordVariableRevision(user, model.VariableCreated, variable, "")
+}
+
+func maskRevisionSecrets(revisions []model.VariableRevision) {
+	for i := range revisions {
+		if revisions[i].Secret {
+			revisions[i].OldValue = secretMask
+			revisions[i].NewValue = secretMask
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var restoreAt = time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func getVariableRevision(scope string, name string, operation string, oldValue string, newValue string,
+	hours int) model.VariableRevision {
+
+	rev := model.VariableRevision{EnvironmentID: 999, Scope: scope, Name: name, Operation: operation,
+		OldValue: oldValue, NewValue: newValue, User: "beta@alfa.com"}
+	rev.CreatedAt = restoreAt.Add(time.Duration(hours) * time.Hour)
+	return rev
+}
+
+func getRestoreAppContext() (*AppContext, *mockRepo.VariableDAOInterface, *mockRepo.VariableRevisionDAOInterface) {
+	appContext := AppContext{}
+	mockGetByID(&appContext)
+
+	revisions := []model.VariableRevision{
+		getVariableRevision("repo/foo", "url", model.VariableCreated, "", "dev.host", -48),
+		getVariableRevision("repo/foo", "url", model.VariableUpdated, "dev.host", "qa.host", -24),
+		getVariableRevision("repo/foo", "url", model.VariableUpdated, "qa.host", "prod.host", 2),
+		getVariableRevision("repo/foo", "url", model.VariableUpdated, "prod.host", "other.host", 3),
+		getVariableRevision("repo/foo", "timeout", model.VariableCreated, "", "30", 1),
+		getVariableRevision("repo/foo", "user", model.VariableDeleted, "admin", "", 1),
+		getVariableRevision("repo/bar", "pool", model.VariableUpdated, "10", "20", 1),
+		getVariableRevision("repo/bar", "pool", model.VariableUpdated, "20", "10", 2),
+	}
+
+	url := model.Variable{EnvironmentID: 999, Scope: "repo/foo", Name: "url", Value: "other.host"}
+	url.ID = 1
+	timeout := model.Variable{EnvironmentID: 999, Scope: "repo/foo", Name: "timeout", Value: "30"}
+	timeout.ID = 2
+	pool := model.Variable{EnvironmentID: 999, Scope: "repo/bar", Name: "pool", Value: "10"}
+	pool.ID = 3
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 999).Return([]model.Variable{url, timeout, pool}, nil)
+	mockVariableDAO.On("RestoreVariables", mock.Anything, mock.Anything).Return(nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	mockVariableRevisionDAO := mockCreateVariableRevision(&appContext)
+	mockVariableRevisionDAO.On("ListEnvironmentVariableRevisions", 999, "").Return(revisions, nil)
+
+	return &appContext, mockVariableDAO, mockVariableRevisionDAO
+}
+
+func doRestoreEnvironmentVariables(appContext *AppContext, request model.VariableRestoreRequest) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/environments/999/variables/restore", payload(request))
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/variables/restore", appContext.restoreEnvironmentVariables).Methods("POST")
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRestoreEnvironmentVariables_DryRun(t *testing.T) {
+	appContext, mockVariableDAO, mockVariableRevisionDAO := getRestoreAppContext()
+
+	rr := doRestoreEnvironmentVariables(appContext, model.VariableRestoreRequest{At: restoreAt, DryRun: true})
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var result model.VariableRestoreResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []model.VariableRestoreChange{
+		{Scope: "repo/foo", Name: "timeout", Operation: model.VariableDeleted, CurrentValue: "30"},
+		{Scope: "repo/foo", Name: "url", Operation: model.VariableUpdated, CurrentValue: "other.host", RestoredValue: "qa.host"},
+		{Scope: "repo/foo", Name: "user", Operation: model.VariableCreated, RestoredValue: "admin"},
+	}, result.Changes)
+
+	mockVariableDAO.AssertNotCalled(t, "RestoreVariables", mock.Anything, mock.Anything)
+	mockVariableRevisionDAO.AssertNotCalled(t, "CreateVariableRevision", mock.Anything)
+}
+
+func TestRestoreEnvironmentVariables(t *testing.T) {
+	appContext, mockVariableDAO, mockVariableRevisionDAO := getRestoreAppContext()
+
+	auditValues := map[string]string{"environment": "bar", "at": "2020-03-10T12:00:00Z", "scope": "", "changes": "3"}
+	mockAudit := mockDoAudit(appContext, "restoreVariables", auditValues)
+
+	rr := doRestoreEnvironmentVariables(appContext, model.VariableRestoreRequest{At: restoreAt})
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	url := model.Variable{EnvironmentID: 999, Scope: "repo/foo", Name: "url", Value: "qa.host"}
+	url.ID = 1
+	mockVariableDAO.AssertCalled(t, "RestoreVariables", []model.Variable{url,
+		{EnvironmentID: 999, Scope: "repo/foo", Name: "user", Value: "admin"}}, []int{2})
+	mockVariableRevisionDAO.AssertNumberOfCalls(t, "CreateVariableRevision", 3)
+	mockVariableRevisionDAO.AssertCalled(t, "CreateVariableRevision", model.VariableRevision{EnvironmentID: 999,
+		Scope: "repo/foo", Name: "url", Operation: model.VariableUpdated, OldValue: "other.host",
+		NewValue: "qa.host", User: "beta@alfa.com"})
+	mockAudit.AssertNumberOfCalls(t, "DoAudit", 1)
+}
+
+func TestRestoreEnvironmentVariables_Scope(t *testing.T) {
+	appContext, mockVariableDAO, mockVariableRevisionDAO := getRestoreAppContext()
+	mockVariableRevisionDAO.On("ListEnvironmentVariableRevisions", 999, "repo/bar").Return([]model.VariableRevision{
+		getVariableRevision("repo/bar", "pool", model.VariableUpdated, "10", "20", 1),
+		getVariableRevision("repo/bar", "pool", model.VariableUpdated, "20", "10", 2),
+	}, nil)
+
+	rr := doRestoreEnvironmentVariables(appContext, model.VariableRestoreRequest{At: restoreAt, Scope: "repo/bar"})
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var result model.VariableRestoreResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Empty(t, result.Changes)
+	mockVariableDAO.AssertNotCalled(t, "RestoreVariables", mock.Anything, mock.Anything)
+}
+
+func TestRestoreEnvironmentVariables_SecretAndType(t *testing.T) {
+	appContext, mockVariableDAO, mockVariableRevisionDAO := getRestoreAppContext()
+
+	created := getVariableRevision("repo/bar", "password", model.VariableCreated, "", "old", -2)
+	created.Secret = true
+	created.Type = "string"
+	updated := getVariableRevision("repo/bar", "password", model.VariableUpdated, "old", "new", 1)
+	mockVariableRevisionDAO.On("ListEnvironmentVariableRevisions", 999, "repo/bar").
+		Return([]model.VariableRevision{created, updated}, nil)
+
+	password := model.Variable{EnvironmentID: 999, Scope: "repo/bar", Name: "password", Value: "new"}
+	password.ID = 4
+	mockVariableDAO.ExpectedCalls[0].ReturnArguments = []interface{}{[]model.Variable{password}, nil}
+	mockDoAudit(appContext, "restoreVariables", map[string]string{"environment": "bar",
+		"at": "2020-03-10T12:00:00Z", "scope": "repo/bar", "changes": "1"})
+
+	rr := doRestoreEnvironmentVariables(appContext, model.VariableRestoreRequest{At: restoreAt, Scope: "repo/bar"})
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var result model.VariableRestoreResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, []model.VariableRestoreChange{{Scope: "repo/bar", Name: "password",
+		Operation: model.VariableUpdated, CurrentValue: secretMask, RestoredValue: secretMask, Secret: true,
+		RestoredSecret: true, RestoredType: "string"}}, result.Changes)
+
+	restored := password
+	restored.Value = "old"
+	restored.Secret = true
+	restored.Type = "string"
+	mockVariableDAO.AssertCalled(t, "RestoreVariables", []model.Variable{restored}, []int{})
+}
+
+func TestRestoreEnvironmentVariables_AtRequired(t *testing.T) {
+	appContext, _, _ := getRestoreAppContext()
+
+	rr := doRestoreEnvironmentVariables(appContext, model.VariableRestoreRequest{DryRun: true})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+}
+
+func TestListVariableHistory(t *testing.T) {
+	appContext := AppContext{}
+	mockGetAllEnvironments(&appContext)
+
+	secret := model.Variable{EnvironmentID: 999, Scope: "repo/foo", Name: "password", Secret: true}
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetByID", uint(5)).Return(&secret, nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	rev := getVariableRevision("repo/foo", "password", model.VariableUpdated, "a1b2", "c3d4", 0)
+	rev.Secret = true
+	mockVariableRevisionDAO := &mockRepo.VariableRevisionDAOInterface{}
+	mockVariableRevisionDAO.On("ListVariableRevisions", 999, "repo/foo", "password").
+		Return([]model.VariableRevision{rev}, nil)
+	appContext.Repositories.VariableRevisionDAO = mockVariableRevisionDAO
+
+	req, _ := http.NewRequest("GET", "/variables/5/history", nil)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/variables/{id}/history", appContext.listVariableHistory).Methods("GET")
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var result model.VariableRevisionResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, 1, len(result.List))
+	assert.Equal(t, "******", result.List[0].OldValue)
+	assert.Equal(t, "******", result.List[0].NewValue)
+	assert.Equal(t, "beta@alfa.com", result.List[0].User)
+}
+
+func TestListEnvironmentVariableHistory(t *testing.T) {
+	appContext := AppContext{}
+	mockGetAllEnvironments(&appContext)
+
+	mockVariableRevisionDAO := &mockRepo.VariableRevisionDAOInterface{}
+	mockVariableRevisionDAO.On("ListEnvironmentVariableRevisions", 999, "repo/foo").
+		Return([]model.VariableRevision{getVariableRevision("repo/foo", "url", model.VariableCreated, "", "dev.host", 0)}, nil)
+	mockVariableRevisionDAO.On("ListVariableRevisions", 999, "repo/foo", "url").
+		Return([]model.VariableRevision{}, nil)
+	appContext.Repositories.VariableRevisionDAO = mockVariableRevisionDAO
+
+	for _, url := range []string{"/environments/999/variableHistory?scope=repo/foo",
+		"/environments/999/variableHistory?scope=repo/foo&name=url"} {
+		req, _ := http.NewRequest("GET", url, nil)
+		mockPrincipal(req)
+
+		rr := httptest.NewRecorder()
+		r := mux.NewRouter()
+		r.HandleFunc("/environments/{id}/variableHistory", appContext.listEnvironmentVariableHistory).Methods("GET")
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	}
+
+	mockVariableRevisionDAO.AssertNumberOfCalls(t, "ListEnvironmentVariableRevisions", 1)
+	mockVariableRevisionDAO.AssertNumberOfCalls(t, "ListVariableRevisions", 1)
+}
+
+func TestListEnvironmentVariableHistory_AccessDenied(t *testing.T) {
+	appContext := AppContext{}
+	mockGetAllEnvironmentsError(&appContext)
+
+	req, _ := http.NewRequest("GET", "/environments/999/variableHistory", nil)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/variableHistory", appContext.listEnvironmentVariableHistory).Methods("GET")
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+}
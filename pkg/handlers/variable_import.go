@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+const importFormatYAML = "yaml"
+const importFormatDotenv = "dotenv"
+const importFormatJSON = "json"
+const importFormatZip = "zip"
+
+const importActionCreate = "create"
+const importActionUpdate = "update"
+const importActionSkip = "skip"
+const importActionUnchanged = "unchanged"
+const importActionIgnored = "ignored"
+
+type variableImport struct {
+	format         string
+	scope          string
+	onConflict     string
+	dryRun         bool
+	secretPatterns []string
+	passphrase     string
+}
+
+func (appContext *AppContext) importVariables(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	if !util.Contains(principal.Roles, constraints.TenkaiAdmin) {
+		auth, _ := appContext.hasEnvironmentRole(principal, uint(id), "ACTION_SAVE_VARIABLES")
+		if !auth {
+			http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	query := r.URL.Query()
+	options := variableImport{
+		format:         query.Get("format"),
+		scope:          query.Get("scope"),
+		onConflict:     query.Get("onConflict"),
+		secretPatterns: query["secretPattern"],
+		passphrase:     r.Header.Get(exportPassphraseHeader),
+	}
+	options.dryRun, _ = strconv.ParseBool(query.Get("dryRun"))
+
+	if err := options.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := util.GetHTTPBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	imported, ignored, err := parseImportedVariables(options, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	environment, err := appContext.Repositories.EnvironmentDAO.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report, err := appContext.doImportVariables(environment, options, imported, principal.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, v := range ignored {
+		report.Variables = append(report.Variables, model.VariableImportResult{Scope: v.Scope, Name: v.Name,
+			Action: importActionIgnored, Reason: "Key is not under app, image, istio or service"})
+	}
+
+	if !options.dryRun {
+		auditValues := make(map[string]string)
+		auditValues["environment"] = environment.Name
+		auditValues["format"] = options.format
+		auditValues["scope"] = options.scope
+		auditValues["onConflict"] = options.onConflict
+		auditValues["variables"] = strconv.Itoa(len(imported))
+		appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "importVariables", auditValues)
+	}
+
+	for i := range report.Variables {
+		if report.Variables[i].Secret {
+			report.Variables[i].CurrentValue = secretMask
+			report.Variables[i].Value = secretMask
+		}
+	}
+
+	data, _ := json.Marshal(report)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (options *variableImport) validate() error {
+	if options.format != importFormatYAML && options.format != importFormatDotenv &&
+		options.format != importFormatJSON && options.format != importFormatZip {
+		return errors.New("Invalid format: " + options.format)
+	}
+
+	if options.scope == "" && (options.format == importFormatYAML || options.format == importFormatDotenv) {
+		return errors.New("Scope is required to import " + options.format + " files")
+	}
+
+	if options.onConflict == "" {
+		options.onConflict = importConflictSkip
+	}
+	if options.onConflict != importConflictSkip && options.onConflict != importConflictOverwrite {
+		return errors.New("Invalid onConflict: " + options.onConflict)
+	}
+
+	for _, pattern := range options.secretPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.New("Invalid secretPattern: " + pattern)
+		}
+	}
+	return nil
+}
+
+//isSecret tells if a variable name matches one of the secret patterns, ignoring case
+func (options *variableImport) isSecret(name string) bool {
+	for _, pattern := range options.secretPatterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+//parseImportedVariables reads the variables of the file. Keys of a values file that can't be
+//mapped to a Tenkai variable are returned apart, as ignored.
+func parseImportedVariables(options variableImport, body []byte) ([]model.Variable, []model.Variable, error) {
+
+	var variables []model.Variable
+	var ignored []model.Variable
+	var err error
+
+	switch options.format {
+	case importFormatYAML:
+		if variables, ignored, err = parseValuesFile(options.scope, body); err != nil {
+			return nil, nil, err
+		}
+	case importFormatZip:
+		if variables, ignored, err = parseValuesZip(options.scope, body); err != nil {
+			return nil, nil, err
+		}
+	case importFormatDotenv:
+		values, err := parseDotenv(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, value := range values {
+			variables = append(variables, model.Variable{Scope: options.scope, Name: name, Value: value})
+		}
+	case importFormatJSON:
+		all, err := parseVariablesJSON(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, v := range all {
+			if options.scope != "" && v.Scope != options.scope {
+				continue
+			}
+			variables = append(variables, model.Variable{Scope: v.Scope, Name: v.Name, Value: v.Value,
+				Secret: v.Secret, Description: v.Description, Type: v.Type})
+		}
+	}
+
+	secrets := newExportSecrets(options.passphrase)
+	for i := range variables {
+		if variables[i].Scope == "" || variables[i].Name == "" {
+			return nil, nil, errors.New("Scope and name are required for every variable")
+		}
+		value, encrypted, err := secrets.decrypt(variables[i].Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		variables[i].Value = value
+		variables[i].Secret = variables[i].Secret || encrypted || options.isSecret(variables[i].Name)
+	}
+
+	sort.Slice(variables, func(i, j int) bool {
+		if variables[i].Scope != variables[j].Scope {
+			return variables[i].Scope < variables[j].Scope
+		}
+		return variables[i].Name < variables[j].Name
+	})
+
+	return variables, ignored, nil
+}
+
+//parseValuesFile reads the variables of a Helm values file
+func parseValuesFile(scope string, body []byte) ([]model.Variable, []model.Variable, error) {
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(body, &values); err != nil {
+		return nil, nil, err
+	}
+
+	flatValues := make(map[string]string)
+	flattenValues("", values, flatValues)
+
+	variables := make([]model.Variable, 0)
+	ignored := make([]model.Variable, 0)
+	for key, value := range flatValues {
+		name, ok := denormalizeVariableName(key)
+		if !ok {
+			ignored = append(ignored, model.Variable{Scope: scope, Name: key})
+			continue
+		}
+		variables = append(variables, model.Variable{Scope: scope, Name: name, Value: value})
+	}
+	sort.Slice(ignored, func(i, j int) bool { return ignored[i].Name < ignored[j].Name })
+
+	return variables, ignored, nil
+}
+
+//parseValuesZip reads an archive with one values file per scope, as written by the zip export
+func parseValuesZip(scope string, body []byte) ([]model.Variable, []model.Variable, error) {
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	variables := make([]model.Variable, 0)
+	ignored := make([]model.Variable, 0)
+	for _, f := range archive.File {
+		fileScope := strings.TrimSuffix(strings.TrimSuffix(f.Name, ".yaml"), ".yml")
+		if f.FileInfo().IsDir() || fileScope == f.Name || (scope != "" && fileScope != scope) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		fileVariables, fileIgnored, err := parseValuesFile(fileScope, data)
+		if err != nil {
+			return nil, nil, errors.New(f.Name + ": " + err.Error())
+		}
+		variables = append(variables, fileVariables...)
+		ignored = append(ignored, fileIgnored...)
+	}
+
+	return variables, ignored, nil
+}
+
+//denormalizeVariableName is the inverse of normalizeVariableName, mapping a values key to a variable name
+func denormalizeVariableName(key string) (string, bool) {
+	if normalizeVariableName(key) == key {
+		return key, true
+	}
+	if strings.HasPrefix(key, "app.") && normalizeVariableName(key[4:]) == key {
+		return key[4:], true
+	}
+	return "", false
+}
+
+//parseDotenv reads KEY=value lines, skipping blank lines and comments
+func parseDotenv(body []byte) (map[string]string, error) {
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+
+		index := strings.Index(text, "=")
+		if index <= 0 {
+			return nil, errors.New("Invalid line " + strconv.Itoa(line) + ": " + text)
+		}
+
+		key := strings.TrimSpace(text[:index])
+		value := strings.TrimSpace(text[index+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '"' {
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				} else {
+					value = value[1 : len(value)-1]
+				}
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		} else if index := strings.Index(value, " #"); index > -1 {
+			value = strings.TrimSpace(value[:index])
+		}
+		values[key] = value
+	}
+
+	return values, scanner.Err()
+}
+
+//parseVariablesJSON reads the variables of a JSON environment export, as listed by /variables/{envId}
+//or a plain list of variables
+func parseVariablesJSON(body []byte) ([]model.Variable, error) {
+	var document struct {
+		model.EnvironmentExport
+		Variables []model.Variable
+	}
+	if err := json.Unmarshal(body, &document); err == nil {
+		for _, s := range document.Scopes {
+			for _, v := range s.Variables {
+				document.Variables = append(document.Variables, model.Variable{Scope: s.Scope, Name: v.Name,
+					Value: v.Value, Secret: v.Secret, Description: v.Description, Type: v.Type})
+			}
+		}
+		return document.Variables, nil
+	}
+
+	var variables []model.Variable
+	if err := json.Unmarshal(body, &variables); err != nil {
+		return nil, err
+	}
+	return variables, nil
+}
+
+//doImportVariables compares the imported variables with the ones of the environment, creating the
+//missing ones and updating the changed ones when overwriting. On a dry run nothing is written.
+func (appContext *AppContext) doImportVariables(environment *model.Environment, options variableImport,
+	imported []model.Variable, user string) (*model.VariableImportReport, error) {
+
+	variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(environment.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]model.Variable)
+	for _, v := range variables {
+		current[v.Scope+"/"+v.Name] = v
+	}
+
+	report := &model.VariableImportReport{
+		DryRun:      options.dryRun,
+		Environment: environment.Name,
+		Format:      options.format,
+		Variables:   make([]model.VariableImportResult, 0),
+	}
+
+	for _, v := range imported {
+		existing, exists := current[v.Scope+"/"+v.Name]
+		if exists {
+			v.Secret = v.Secret || existing.Secret
+		}
+
+		result := model.VariableImportResult{Scope: v.Scope, Name: v.Name, Value: v.Value, Secret: v.Secret}
+		if exists {
+			result.CurrentValue = appContext.decryptVariable(existing).Value
+		}
+
+		typeErr := validateVariableType(v)
+		switch {
+		case typeErr != nil:
+			result.Action = importActionIgnored
+			result.Reason = typeErr.Error()
+		case !exists:
+			result.Action = importActionCreate
+		case result.CurrentValue == v.Value && existing.Secret == v.Secret && (v.Type == "" || existing.Type == v.Type):
+			result.Action = importActionUnchanged
+		case options.onConflict == importConflictSkip:
+			result.Action = importActionSkip
+		default:
+			result.Action = importActionUpdate
+		}
+		report.Variables = append(report.Variables, result)
+
+		if options.dryRun || (result.Action != importActionCreate && result.Action != importActionUpdate) {
+			continue
+		}
+
+		if v.Secret {
+			v.Value = hex.EncodeToString(util.Encrypt([]byte(v.Value), appContext.Configuration.App.Passkey))
+		}
+
+		if result.Action == importActionCreate {
+			v.EnvironmentID = int(environment.ID)
+			if _, _, err := appContext.Repositories.VariableDAO.CreateVariable(v); err != nil {
+				return nil, err
+			}
+			appContext.recordVariableRevision(user, model.VariableCreated, v, "")
+			continue
+		}
+
+		oldValue := existing.Value
+		existing.Value = v.Value
+		existing.Secret = v.Secret
+		if v.Type != "" {
+			existing.Type = v.Type
+		}
+		if err := appContext.Repositories.VariableDAO.EditVariable(existing); err != nil {
+			return nil, err
+		}
+		appContext.recordVariableRevision(user, model.VariableUpdated, existing, oldValue)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/configs"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getVariableImportAppContext() (*AppContext, *mockRepo.VariableDAOInterface) {
+	appContext := AppContext{}
+	appContext.Configuration = &configs.Configuration{App: configs.App{Passkey: "qwert"}}
+	mockGetByID(&appContext)
+
+	url := model.Variable{EnvironmentID: 999, Scope: "repo/foo", Name: "url", Value: "dev.host"}
+	url.ID = 1
+	timeout := model.Variable{EnvironmentID: 999, Scope: "repo/foo", Name: "timeout", Value: "30"}
+	timeout.ID = 2
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 999).Return([]model.Variable{url, timeout}, nil)
+	mockVariableDAO.On("CreateVariable", mock.Anything).Return(nil, true, nil)
+	mockVariableDAO.On("EditVariable", mock.Anything).Return(nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockCreateVariableRevision(&appContext)
+
+	return &appContext, mockVariableDAO
+}
+
+func doImportVariables(appContext *AppContext, query string, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/environments/999/variables/import?"+query, bytes.NewBufferString(body))
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/variables/import", appContext.importVariables).Methods("POST")
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestImportVariables_YAMLDryRun(t *testing.T) {
+	appContext, mockVariableDAO := getVariableImportAppContext()
+
+	body := "replicaCount: 2\nimage:\n  tag: 1.0.1\napp:\n  url: qa.host\n  timeout: 30\n  dbPassword: secret\n"
+	rr := doImportVariables(appContext, "format=yaml&scope=repo/foo&dryRun=true&onConflict=overwrite"+
+		"&secretPattern=*password*", body)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var report model.VariableImportReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []model.VariableImportResult{
+		{Scope: "repo/foo", Name: "dbPassword", Action: "create", Value: "******", CurrentValue: "******", Secret: true},
+		{Scope: "repo/foo", Name: "image.tag", Action: "create", Value: "1.0.1"},
+		{Scope: "repo/foo", Name: "timeout", Action: "unchanged", CurrentValue: "30", Value: "30"},
+		{Scope: "repo/foo", Name: "url", Action: "update", CurrentValue: "dev.host", Value: "qa.host"},
+		{Scope: "repo/foo", Name: "replicaCount", Action: "ignored", Reason: "Key is not under app, image, istio or service"},
+	}, report.Variables)
+
+	mockVariableDAO.AssertNotCalled(t, "CreateVariable", mock.Anything)
+	mockVariableDAO.AssertNotCalled(t, "EditVariable", mock.Anything)
+}
+
+func TestImportVariables_DotenvSkipExisting(t *testing.T) {
+	appContext, mockVariableDAO := getVariableImportAppContext()
+
+	auditValues := map[string]string{"environment": "bar", "format": "dotenv", "scope": "repo/foo",
+		"onConflict": "skip", "variables": "2"}
+	mockAudit := mockDoAudit(appContext, "importVariables", auditValues)
+
+	body := "# comment\nexport url=\"qa.host\"\nretries=3 # default\n"
+	rr := doImportVariables(appContext, "format=dotenv&scope=repo/foo", body)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	mockVariableDAO.AssertNumberOfCalls(t, "CreateVariable", 1)
+	mockVariableDAO.AssertCalled(t, "CreateVariable", model.Variable{EnvironmentID: 999, Scope: "repo/foo",
+		Name: "retries", Value: "3"})
+	mockVariableDAO.AssertNotCalled(t, "EditVariable", mock.Anything)
+	mockAudit.AssertNumberOfCalls(t, "DoAudit", 1)
+}
+
+func TestImportVariables_JSONOverwrite(t *testing.T) {
+	appContext, mockVariableDAO := getVariableImportAppContext()
+	mockDoAudit(appContext, "importVariables", map[string]string{"environment": "bar", "format": "json",
+		"scope": "repo/foo", "onConflict": "overwrite", "variables": "1"})
+
+	body := `{"Variables":[{"scope":"repo/foo","name":"url","value":"prod.host","secret":true},` +
+		`{"scope":"repo/bar","name":"pool","value":"10"}]}`
+	rr := doImportVariables(appContext, "format=json&scope=repo/foo&onConflict=overwrite", body)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	mockVariableDAO.AssertNotCalled(t, "CreateVariable", mock.Anything)
+	mockVariableDAO.AssertCalled(t, "EditVariable", mock.MatchedBy(func(v model.Variable) bool {
+		return v.ID == 1 && v.Secret && v.Value != "prod.host" && appContext.decryptVariable(v).Value == "prod.host"
+	}))
+}
+
+func TestImportVariables_InvalidOptions(t *testing.T) {
+	appContext, _ := getVariableImportAppContext()
+
+	for _, query := range []string{"format=xml&scope=repo/foo", "format=yaml", "format=json&onConflict=rename",
+		"format=json&secretPattern=[a"} {
+		rr := doImportVariables(appContext, query, "{}")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestParseDotenv(t *testing.T) {
+	values, err := parseDotenv([]byte("A=1\n\n# c\nB='x # y'\nC=\"line\\nbreak\"\nD=\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "1", "B": "x # y", "C": "line\nbreak", "D": ""}, values)
+
+	_, err = parseDotenv([]byte("A=1\nINVALID\n"))
+	assert.EqualError(t, err, "Invalid line 2: INVALID")
+}
+
+func TestDenormalizeVariableName(t *testing.T) {
+	tests := []struct {
+		key  string
+		name string
+		ok   bool
+	}{
+		{"app.url", "url", true},
+		{"app.db.url", "db.url", true},
+		{"image.tag", "image.tag", true},
+		{"istio.enabled", "istio.enabled", true},
+		{"app.image.tag", "app.image.tag", true},
+		{"replicaCount", "", false},
+	}
+
+	for _, tt := range tests {
+		name, ok := denormalizeVariableName(tt.key)
+		assert.Equal(t, tt.name, name, tt.key)
+		assert.Equal(t, tt.ok, ok, tt.key)
+		if ok {
+			assert.Equal(t, tt.key, normalizeVariableName(name))
+		}
+	}
+}
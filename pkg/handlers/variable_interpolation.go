@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/service/interpolation"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+//validateEnvironmentInterpolation reports the references of the environment variables that can not be resolved
+func (appContext *AppContext) validateEnvironmentInterpolation(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	has, err := appContext.hasAccess(principal.Email, id)
+	if err != nil || !has {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	environment, err := appContext.Repositories.EnvironmentDAO.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	variables, err := appContext.getEffectiveVariables(environment, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	scopes := r.URL.Query()["scope"]
+	interpolator := appContext.environmentInterpolator(environment, variables)
+
+	report := model.InterpolationReport{Environment: environment.Name, Problems: make([]model.InterpolationProblem, 0)}
+	for _, v := range variables {
+		if len(scopes) > 0 && !util.Contains(scopes, v.Scope) {
+			continue
+		}
+		value := appContext.decryptVariable(v).Value
+		if !strings.Contains(value, "${") {
+			continue
+		}
+		result := interpolator.Expand(interpolationOrigin(v), value)
+		report.Problems = append(report.Problems, interpolationProblems(v.Scope, v.Name, result)...)
+	}
+
+	sort.SliceStable(report.Problems, func(i, j int) bool {
+		if report.Problems[i].Scope != report.Problems[j].Scope {
+			return report.Problems[i].Scope < report.Problems[j].Scope
+		}
+		return report.Problems[i].Name < report.Problems[j].Name
+	})
+	report.Valid = len(report.Problems) == 0
+
+	data, _ := json.Marshal(report)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//interpolateValue previews a value as it would be installed in the environment
+func (appContext *AppContext) interpolateValue(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload model.InterpolationRequest
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	principal := util.GetPrincipal(r)
+	has, err := appContext.hasAccess(principal.Email, id)
+	if err != nil || !has {
+		http.Error(w, errors.New(global.AccessDenied).Error(), http.StatusUnauthorized)
+		return
+	}
+
+	environment, err := appContext.Repositories.EnvironmentDAO.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	interpolator := appContext.newInterpolator(environment, appContext.getGlobalVariables(environment))
+	expanded := interpolator.Expand("", payload.Value)
+
+	result := model.InterpolationResult{
+		Value:    expanded.Value,
+		Secret:   expanded.Secret,
+		Problems: interpolationProblems(payload.Scope, "", expanded),
+	}
+	if result.Secret {
+		result.Value = secretMask
+	}
+
+	data, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//newInterpolator creates the interpolator used on install. Chart variables are read when referenced.
+func (appContext *AppContext) newInterpolator(environment *model.Environment,
+	globalVariables []model.Variable) *interpolation.Interpolator {
+
+	lookup := func(chart string) (map[string]interpolation.Value, error) {
+		variables, err := appContext.getEffectiveVariables(environment, chart)
+		if err != nil {
+			return nil, err
+		}
+		for i := range variables {
+			variables[i] = appContext.decryptVariable(variables[i])
+		}
+		return interpolationValues(variables, chart), nil
+	}
+	return interpolation.New(environmentFields(environment), interpolationValues(globalVariables, ""), lookup)
+}
+
+//environmentInterpolator creates an interpolator over the variables already loaded for the environment
+func (appContext *AppContext) environmentInterpolator(environment *model.Environment,
+	variables []model.Variable) *interpolation.Interpolator {
+
+	decrypted := make([]model.Variable, 0, len(variables))
+	for _, v := range variables {
+		decrypted = append(decrypted, appContext.decryptVariable(v))
+	}
+	lookup := func(chart string) (map[string]interpolation.Value, error) {
+		return interpolationValues(decrypted, chart), nil
+	}
+	return interpolation.New(environmentFields(environment), interpolationValues(decrypted, "global"), lookup)
+}
+
+//interpolationValues keys the decrypted variables of a chart by name. The chart matches the scope
+//or its chart name, an empty chart takes every variable.
+func interpolationValues(variables []model.Variable, chart string) map[string]interpolation.Value {
+	values := make(map[string]interpolation.Value)
+	for _, v := range variables {
+		if chart != "" && v.Scope != chart && chartNameOf(v.Scope) != chart {
+			continue
+		}
+		values[v.Name] = interpolation.Value{Value: v.Value, Secret: v.Secret}
+	}
+	return values
+}
+
+func environmentFields(environment *model.Environment) map[string]string {
+	return map[string]string{
+		"name":           environment.Name,
+		"namespace":      environment.Namespace,
+		"gateway":        environment.Gateway,
+		"group":          environment.Group,
+		"productVersion": environment.ProductVersion,
+	}
+}
+
+//interpolationOrigin is the reference other variables use to point to v
+func interpolationOrigin(v model.Variable) string {
+	if v.Scope == "global" {
+		return v.Name
+	}
+	return chartNameOf(v.Scope) + ":" + v.Name
+}
+
+func interpolationProblems(scope string, name string, result interpolation.Result) []model.InterpolationProblem {
+	problems := make([]model.InterpolationProblem, 0)
+	for _, e := range result.Errors {
+		problems = append(problems, model.InterpolationProblem{Scope: scope, Name: name, Reference: e.Reference,
+			Error: e.Message})
+	}
+	return problems
+}
+
+//interpolate expands a value on install. Unresolved references are kept, as they may be meant for the chart itself.
+func interpolate(interpolator *interpolation.Interpolator, origin string, value string) interpolation.Result {
+	result := interpolator.Expand(origin, value)
+	for _, e := range result.Errors {
+		global.Logger.Info(global.AppFields{global.Function: "interpolate", "variable": origin}, e.Error())
+	}
+	return result
+}
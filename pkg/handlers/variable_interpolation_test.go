@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/softplan/tenkai-api/pkg/configs"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/softplan/tenkai-api/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func getInterpolationAppContext() (*AppContext, *mockRepo.VariableDAOInterface) {
+	appContext := AppContext{}
+	appContext.Configuration = &configs.Configuration{App: configs.App{Passkey: "qwert"}}
+	mockEnvDao := mockGetByID(&appContext)
+	mockEnvDao.On("GetAllEnvironments", "beta@alfa.com").Return([]model.Environment{mockGetEnv()}, nil)
+
+	password := hex.EncodeToString(util.Encrypt([]byte("s3cr3t"), "qwert"))
+	variables := []model.Variable{
+		{Scope: "global", Name: "domain", Value: "dev.local"},
+		{Scope: "global", Name: "password", Value: password, Secret: true},
+		{Scope: "repo/foo", Name: "url", Value: "http://${bar:host}:${bar:port:-8080}/${ENV.namespace}"},
+		{Scope: "repo/foo", Name: "token", Value: "${missing}"},
+		{Scope: "repo/bar", Name: "host", Value: "bar.${domain}"},
+		{Scope: "repo/bar", Name: "a", Value: "${bar:b}"},
+		{Scope: "repo/bar", Name: "b", Value: "${bar:a}"},
+	}
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 999).Return(variables, nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 999, "global").Return(variables[0:2], nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 999, "bar").Return(variables[4:], nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	return &appContext, mockVariableDAO
+}
+
+func TestValidateEnvironmentInterpolation(t *testing.T) {
+	appContext, _ := getInterpolationAppContext()
+
+	req, _ := http.NewRequest("GET", "/environments/999/variables/validate", nil)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/variables/validate", appContext.validateEnvironmentInterpolation).Methods("GET")
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var report model.InterpolationReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.False(t, report.Valid)
+	assert.Equal(t, []model.InterpolationProblem{
+		{Scope: "repo/bar", Name: "a", Reference: "${bar:a}", Error: "Reference cycle bar:a -> bar:b -> bar:a"},
+		{Scope: "repo/bar", Name: "b", Reference: "${bar:b}", Error: "Reference cycle bar:b -> bar:a -> bar:b"},
+		{Scope: "repo/foo", Name: "token", Reference: "${missing}", Error: "Unresolved reference"},
+	}, report.Problems)
+}
+
+func TestValidateEnvironmentInterpolation_Scope(t *testing.T) {
+	appContext, _ := getInterpolationAppContext()
+
+	req, _ := http.NewRequest("GET", "/environments/999/variables/validate?scope=repo/bar", nil)
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/variables/validate", appContext.validateEnvironmentInterpolation).Methods("GET")
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+
+	var report model.InterpolationReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, 2, len(report.Problems))
+}
+
+func doInterpolateValue(appContext *AppContext, value string) model.InterpolationResult {
+	req, _ := http.NewRequest("POST", "/environments/999/variables/interpolate",
+		payload(model.InterpolationRequest{Scope: "repo/foo", Value: value}))
+	mockPrincipal(req)
+
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/variables/interpolate", appContext.interpolateValue).Methods("POST")
+	r.ServeHTTP(rr, req)
+
+	var result model.InterpolationResult
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	return result
+}
+
+func TestInterpolateValue(t *testing.T) {
+	appContext, _ := getInterpolationAppContext()
+
+	result := doInterpolateValue(appContext, "http://${bar:host}:${bar:port:-8080}/${ENV.namespace | upper}")
+	assert.Equal(t, "http://bar.dev.local:8080/DEV", result.Value)
+	assert.False(t, result.Secret)
+	assert.Empty(t, result.Problems)
+
+	result = doInterpolateValue(appContext, "user:${password}")
+	assert.Equal(t, "******", result.Value)
+	assert.True(t, result.Secret)
+
+	result = doInterpolateValue(appContext, "${domain | reverse}")
+	assert.Equal(t, "${domain | reverse}", result.Value)
+	assert.Equal(t, []model.InterpolationProblem{{Scope: "repo/foo", Reference: "${domain | reverse}",
+		Error: "Unknown function reverse"}}, result.Problems)
+}
+
+func TestGetValuesWithHelmDefault_Interpolation(t *testing.T) {
+	appContext, _ := getInterpolationAppContext()
+	environment := mockGetEnv()
+
+	password := hex.EncodeToString(util.Encrypt([]byte("s3cr3t"), "qwert"))
+	variables := []model.Variable{
+		{Scope: "repo/foo", Name: "url", Value: "http://${bar:host}/${ENV.name}"},
+		{Scope: "repo/foo", Name: "dsn", Value: password, Secret: true},
+		{Scope: "repo/foo", Name: "raw", Value: "$${HOSTNAME}"},
+	}
+	globalVariables := appContext.getGlobalVariables(&environment)
+
+	values, secrets, err := appContext.getValuesWithHelmDefault(variables, map[string]interface{}{"user": "${password}"},
+		globalVariables, &environment)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"app.dsn": true, "app.user": true}, secrets.keys)
+	assert.Equal(t, []string{"s3cr3t", "s3cr3t", "s3cr3t"}, secrets.values)
+	app := values["app"].(map[string]interface{})
+	assert.Equal(t, "http://bar.dev.local/bar", app["url"])
+	assert.Equal(t, "s3cr3t", app["dsn"])
+	assert.Equal(t, "${HOSTNAME}", app["raw"])
+	assert.Equal(t, "s3cr3t", app["user"])
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+//valueReplacer finds and replaces, literally or by a regular expression, the values of the variables
+//selected by name and scope
+type valueReplacer struct {
+	search  string
+	replace string
+	regex   *regexp.Regexp
+	name    *regexp.Regexp
+	scopes  []string
+}
+
+func newValueReplacer(payload model.VariableReplace) (*valueReplacer, error) {
+	if payload.Search == "" {
+		return nil, errors.New("Search is required")
+	}
+
+	replacer := &valueReplacer{search: payload.Search, replace: payload.Replace, scopes: payload.Scopes}
+	var err error
+	if payload.Regex {
+		if replacer.regex, err = regexp.Compile(payload.Search); err != nil {
+			return nil, errors.New("Invalid search pattern: " + err.Error())
+		}
+	}
+	if payload.NamePattern != "" {
+		if replacer.name, err = regexp.Compile(payload.NamePattern); err != nil {
+			return nil, errors.New("Invalid name pattern: " + err.Error())
+		}
+	}
+	return replacer, nil
+}
+
+func (replacer *valueReplacer) selects(variable model.Variable) bool {
+	if len(replacer.scopes) > 0 && !util.Contains(replacer.scopes, variable.Scope) {
+		return false
+	}
+	return replacer.name == nil || replacer.name.MatchString(variable.Name)
+}
+
+func (replacer *valueReplacer) apply(value string) string {
+	if replacer.regex != nil {
+		return replacer.regex.ReplaceAllString(value, replacer.replace)
+	}
+	return strings.Replace(value, replacer.search, replacer.replace, -1)
+}
+
+//replaceVariables replaces a value in the variables of several environments at once. With dryRun
+//it only lists the matches. Secret values are only searched in the environments where the principal
+//may reveal them.
+func (appContext *AppContext) replaceVariables(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	principal := util.GetPrincipal(r)
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	var payload model.VariableReplace
+	if err := util.UnmarshalPayload(r, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	replacer, err := newValueReplacer(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	isAdmin := util.Contains(principal.Roles, constraints.TenkaiAdmin)
+	environments, err := appContext.getReplaceEnvironments(principal, isAdmin, payload.EnvironmentIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	report := model.VariableReplaceReport{
+		DryRun:           dryRun,
+		Matches:          make([]model.VariableReplaceMatch, 0),
+		SkippedSecrets:   make([]string, 0),
+		Redeployed:       make([]string, 0),
+		RedeployFailures: make([]model.VariableRedeployFailure, 0),
+	}
+	changed := make([]model.Variable, 0)
+	oldValues := make(map[uint]string)
+	affected := make([]*model.Environment, 0)
+	invalid := false
+
+	for _, environment := range environments {
+		variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(environment.ID))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		matched := false
+		var canReveal *bool
+		for _, v := range variables {
+			if !replacer.selects(v) {
+				continue
+			}
+			if v.Secret && canReveal == nil {
+				auth := isAdmin
+				if !auth {
+					auth, _ = appContext.hasEnvironmentRole(principal, environment.ID, "ACTION_REVEAL_SECRET")
+				}
+				canReveal = &auth
+				if !auth {
+					report.SkippedSecrets = append(report.SkippedSecrets, environment.Name)
+				}
+			}
+			if v.Secret && !*canReveal {
+				continue
+			}
+			plain := appContext.decryptVariable(v)
+			newValue := replacer.apply(plain.Value)
+			if newValue == plain.Value {
+				continue
+			}
+			matched = true
+
+			match := model.VariableReplaceMatch{EnvironmentID: int(environment.ID), Environment: environment.Name,
+				VariableID: v.ID, Scope: v.Scope, Name: v.Name, OldValue: plain.Value, NewValue: newValue,
+				Secret: v.Secret}
+			plain.Value = newValue
+			if err := validateVariableType(plain); err != nil {
+				match.Error = err.Error()
+				invalid = true
+			}
+			if v.Secret {
+				match.OldValue, match.NewValue = secretMask, secretMask
+				plain.Value = hex.EncodeToString(util.Encrypt([]byte(newValue), appContext.Configuration.App.Passkey))
+			}
+			report.Matches = append(report.Matches, match)
+			changed = append(changed, plain)
+			oldValues[v.ID] = v.Value
+		}
+		if matched {
+			affected = append(affected, environment)
+		}
+	}
+
+	if !isAdmin && !dryRun {
+		for _, environment := range affected {
+			if err := appContext.checkReplacePermissions(principal, environment, payload.Redeploy); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+	}
+
+	if invalid {
+		data, _ := json.Marshal(report)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(data)
+		return
+	}
+
+	releases := make(map[uint][]model.InstallPayload)
+	if payload.Redeploy {
+		if releases, err = appContext.replacedReleases(affected, changed); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		checks := make([]model.DeployRulesCheck, 0)
+		for _, environment := range affected {
+			if len(releases[environment.ID]) == 0 {
+				continue
+			}
+			if err := appContext.checkDeployableProductVersion(int(environment.ProductVersionID)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			check, err := appContext.checkReplacedRules(principal, environment, releases[environment.ID], changed,
+				r.URL.Query().Get("overrideReason"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			checks = append(checks, *check)
+		}
+		if isBlocked(checks) {
+			writeRulesResult(w, checks)
+			return
+		}
+		if !dryRun {
+			appContext.auditRulesOverride(r, principal, checks)
+		}
+	}
+
+	if !dryRun && len(changed) > 0 {
+		if err := appContext.Repositories.VariableDAO.EditVariables(changed); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, v := range changed {
+			appContext.recordVariableRevision(principal.Email, model.VariableUpdated, v, oldValues[v.ID])
+		}
+		for _, environment := range affected {
+			auditValues := make(map[string]string)
+			auditValues["environment"] = environment.Name
+			auditValues["search"] = payload.Search
+			auditValues["replace"] = payload.Replace
+			auditValues["variables"] = strconv.Itoa(countReplaced(changed, environment))
+			appContext.Auditing.DoAudit(r.Context(), appContext.Elk, principal.Email, "replaceVariables", auditValues)
+		}
+
+		if payload.Redeploy {
+			appContext.redeployReplaced(principal, affected, releases, &report)
+		}
+	}
+
+	data, _ := json.Marshal(report)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//getReplaceEnvironments returns the environments filtered, or all the principal may access when there
+//is no filter
+func (appContext *AppContext) getReplaceEnvironments(principal model.Principal, isAdmin bool,
+	environmentIDs []int) ([]*model.Environment, error) {
+
+	result := make([]*model.Environment, 0)
+	if len(environmentIDs) == 0 {
+		email := principal.Email
+		if isAdmin {
+			email = ""
+		}
+		environments, err := appContext.Repositories.EnvironmentDAO.GetAllEnvironments(email)
+		if err != nil {
+			return nil, err
+		}
+		for i := range environments {
+			result = append(result, &environments[i])
+		}
+		return result, nil
+	}
+
+	for _, id := range environmentIDs {
+		if !isAdmin {
+			if has, err := appContext.hasAccess(principal.Email, id); err != nil || !has {
+				return nil, errors.New(global.AccessDenied)
+			}
+		}
+		environment, err := appContext.Repositories.EnvironmentDAO.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, environment)
+	}
+	return result, nil
+}
+
+func (appContext *AppContext) checkReplacePermissions(principal model.Principal, environment *model.Environment,
+	redeploy bool) error {
+
+	operations := []string{"ACTION_SAVE_VARIABLES"}
+	if redeploy {
+		operations = append(operations, "ACTION_DEPLOY")
+	}
+	for _, operation := range operations {
+		if auth, _ := appContext.hasEnvironmentRole(principal, environment.ID, operation); !auth {
+			return errors.New(global.AccessDenied + " - " + environment.Name)
+		}
+	}
+	return nil
+}
+
+func countReplaced(variables []model.Variable, environment *model.Environment) int {
+	count := 0
+	for _, v := range variables {
+		if v.EnvironmentID == int(environment.ID) {
+			count++
+		}
+	}
+	return count
+}
+
+//replacedReleases lists, by environment, the releases last deployed that use the replaced variables,
+//all the releases when a global variable was replaced
+func (appContext *AppContext) replacedReleases(environments []*model.Environment,
+	changed []model.Variable) (map[uint][]model.InstallPayload, error) {
+
+	result := make(map[uint][]model.InstallPayload)
+	for _, environment := range environments {
+		scopes := make(map[string]bool)
+		for _, v := range changed {
+			if v.EnvironmentID == int(environment.ID) {
+				scopes[v.Scope] = true
+			}
+		}
+
+		deployments, err := appContext.Repositories.DeploymentDAO.ListLastSuccessfulDeployments(int(environment.ID))
+		if err != nil {
+			return nil, err
+		}
+
+		deployed := make(map[string]bool)
+		for _, deployment := range deployments {
+			releaseName := getDeploymentReleaseName(deployment, environment)
+			installPayload := model.InstallPayload{
+				EnvironmentID: int(environment.ID),
+				Chart:         deployment.Chart,
+				ChartVersion:  deployment.ChartVersion,
+				Name:          strings.TrimSuffix(releaseName, "-"+environment.Namespace),
+			}
+			if deployed[releaseName] || (!scopes["global"] && !scopes[releaseSearchTerm(installPayload)]) {
+				continue
+			}
+			deployed[releaseName] = true
+			result[environment.ID] = append(result[environment.ID], installPayload)
+		}
+	}
+	return result, nil
+}
+
+//checkReplacedRules checks the rules of the variables of the releases to deploy again, with their
+//replaced values
+func (appContext *AppContext) checkReplacedRules(principal model.Principal, environment *model.Environment,
+	releases []model.InstallPayload, changed []model.Variable,
+	overrideReason string) (*model.DeployRulesCheck, error) {
+
+	replaced := make(map[uint]model.Variable)
+	for _, v := range changed {
+		replaced[v.ID] = v
+	}
+
+	variables := make([]model.Variable, 0)
+	for _, release := range releases {
+		releaseVariables, err := appContext.getEffectiveVariables(environment, releaseSearchTerm(release))
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range releaseVariables {
+			if r, ok := replaced[v.ID]; ok {
+				v = r
+			}
+			variables = append(variables, v)
+		}
+	}
+	return appContext.checkDeployRules(principal, environment, variables, overrideReason)
+}
+
+//redeployReplaced installs again the releases that use the replaced variables. The variables are
+//already saved, so the releases that fail are reported and the others still deployed.
+func (appContext *AppContext) redeployReplaced(principal model.Principal, environments []*model.Environment,
+	releases map[uint][]model.InstallPayload, report *model.VariableReplaceReport) {
+
+	out := &bytes.Buffer{}
+	for _, environment := range environments {
+		for _, installPayload := range releases[environment.ID] {
+			releaseName := environment.Name + "/" + installPayload.Name + "-" + environment.Namespace
+			if err := appContext.createReplaceRequestDeployment(principal, report); err != nil {
+				report.RedeployFailures = append(report.RedeployFailures,
+					model.VariableRedeployFailure{Release: releaseName, Error: err.Error()})
+				continue
+			}
+
+			if _, err := appContext.simpleInstall(environment, installPayload, out, false, false, principal.Email,
+				report.RequestDeploymentID); err != nil {
+				report.RedeployFailures = append(report.RedeployFailures,
+					model.VariableRedeployFailure{Release: releaseName, Error: err.Error()})
+				continue
+			}
+			report.Redeployed = append(report.Redeployed, releaseName)
+		}
+	}
+}
+
+//createReplaceRequestDeployment groups the deploys of a replace in one request deployment
+func (appContext *AppContext) createReplaceRequestDeployment(principal model.Principal,
+	report *model.VariableReplaceReport) error {
+
+	if report.RequestDeploymentID > 0 {
+		return nil
+	}
+	user, err := appContext.Repositories.UserDAO.FindByEmail(principal.Email)
+	if err != nil {
+		return err
+	}
+	requestDeployment := model.RequestDeployment{UserID: user.ID}
+	report.RequestDeploymentID, err = appContext.Repositories.RequestDeploymentDAO.
+		CreateRequestDeployment(requestDeployment)
+	return err
+}
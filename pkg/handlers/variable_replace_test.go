@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/softplan/tenkai-api/pkg/configs"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	mockRabbit "github.com/softplan/tenkai-api/pkg/rabbitmq/mocks"
+	"github.com/softplan/tenkai-api/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getReplaceAppContext() (*AppContext, *mockRepo.VariableDAOInterface) {
+	appContext := AppContext{}
+	appContext.Configuration = &configs.Configuration{App: configs.App{Passkey: "qwert"}}
+
+	dev := model.Environment{Name: "dev", Namespace: "dev"}
+	dev.ID = 1
+	qa := model.Environment{Name: "qa", Namespace: "qa"}
+	qa.ID = 2
+	mockEnvDao := &mockRepo.EnvironmentDAOInterface{}
+	mockEnvDao.On("GetByID", 1).Return(&dev, nil)
+	mockEnvDao.On("GetByID", 2).Return(&qa, nil)
+	mockEnvDao.On("GetAllEnvironments", "").Return([]model.Environment{dev, qa}, nil)
+	appContext.Repositories.EnvironmentDAO = mockEnvDao
+
+	secret := util.Encrypt([]byte("postgres://db.old.local/app"), "qwert")
+	devVariables := []model.Variable{
+		{Scope: "repo/api", Name: "dbHost", Value: "db.old.local", EnvironmentID: 1},
+		{Scope: "repo/api", Name: "cacheHost", Value: "cache.old.local", EnvironmentID: 1},
+		{Scope: "global", Name: "domain", Value: "example.com", EnvironmentID: 1},
+	}
+	qaVariables := []model.Variable{
+		{Scope: "repo/web", Name: "dbHost", Value: "db.old.local:5432", EnvironmentID: 2},
+		{Scope: "repo/web", Name: "dbUrl", Value: hex.EncodeToString(secret), Secret: true, EnvironmentID: 2},
+	}
+	for i := range devVariables {
+		devVariables[i].ID = uint(i + 1)
+	}
+	for i := range qaVariables {
+		qaVariables[i].ID = uint(i + 10)
+	}
+
+	mockVariableDAO := &mockRepo.VariableDAOInterface{}
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 1).Return(devVariables, nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironment", 2).Return(qaVariables, nil)
+	mockVariableDAO.On("EditVariables", mock.Anything).Return(nil)
+	appContext.Repositories.VariableDAO = mockVariableDAO
+
+	mockCreateVariableRevision(&appContext)
+	return &appContext, mockVariableDAO
+}
+
+func doReplaceVariables(appContext *AppContext, url string, body model.VariableReplace,
+	principal model.Principal) (*httptest.ResponseRecorder, model.VariableReplaceReport) {
+
+	req, _ := http.NewRequest("POST", url, payload(body))
+	pSe, _ := json.Marshal(principal)
+	req.Header.Set("principal", string(pSe))
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(appContext.replaceVariables).ServeHTTP(rr, req)
+
+	var report model.VariableReplaceReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	return rr, report
+}
+
+var replaceAdmin = model.Principal{Email: "beta@alfa.com", Roles: []string{"tenkai-admin"}}
+
+func TestReplaceVariables_DryRun(t *testing.T) {
+	appContext, mockVariableDAO := getReplaceAppContext()
+
+	rr, report := doReplaceVariables(appContext, "/variables/replace?dryRun=true",
+		model.VariableReplace{Search: "old.local", Replace: "new.local"}, replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 4, len(report.Matches))
+	assert.Equal(t, "dev", report.Matches[0].Environment)
+	assert.Equal(t, "db.old.local", report.Matches[0].OldValue)
+	assert.Equal(t, "db.new.local", report.Matches[0].NewValue)
+	assert.Equal(t, "cache.new.local", report.Matches[1].NewValue)
+	assert.Equal(t, "db.new.local:5432", report.Matches[2].NewValue)
+	assert.Equal(t, "******", report.Matches[3].OldValue)
+	assert.Equal(t, "******", report.Matches[3].NewValue)
+	mockVariableDAO.AssertNotCalled(t, "EditVariables", mock.Anything)
+}
+
+func TestReplaceVariables_Filters(t *testing.T) {
+	appContext, _ := getReplaceAppContext()
+
+	rr, report := doReplaceVariables(appContext, "/variables/replace?dryRun=true",
+		model.VariableReplace{Search: `db\.old\.(\w+)`, Replace: "db.new.$1", Regex: true, NamePattern: "^db",
+			Scopes: []string{"repo/api", "repo/web"}, EnvironmentIDs: []int{2}}, replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, 2, len(report.Matches))
+	assert.Equal(t, "db.new.local:5432", report.Matches[0].NewValue)
+	assert.Equal(t, "dbUrl", report.Matches[1].Name)
+}
+
+func TestReplaceVariables_Apply(t *testing.T) {
+	appContext, mockVariableDAO := getReplaceAppContext()
+	auditValues := map[string]string{"environment": "dev", "search": "old.local", "replace": "new.local",
+		"variables": "2"}
+	auditSvc := mockDoAudit(appContext, "replaceVariables", auditValues)
+	auditValues = map[string]string{"environment": "qa", "search": "old.local", "replace": "new.local",
+		"variables": "2"}
+	auditSvc.On("DoAudit", mock.Anything, mock.Anything, "beta@alfa.com", "replaceVariables", auditValues)
+
+	rr, report := doReplaceVariables(appContext, "/variables/replace",
+		model.VariableReplace{Search: "old.local", Replace: "new.local", EnvironmentIDs: []int{1, 2}}, replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.False(t, report.DryRun)
+	assert.Equal(t, 4, len(report.Matches))
+
+	mockVariableDAO.AssertNumberOfCalls(t, "EditVariables", 1)
+	saved := mockVariableDAO.Calls[2].Arguments.Get(0).([]model.Variable)
+	assert.Equal(t, 4, len(saved))
+	assert.Equal(t, "db.new.local", saved[0].Value)
+	assert.Equal(t, "postgres://db.new.local/app", appContext.decryptVariable(saved[3]).Value)
+
+	appContext.Repositories.VariableRevisionDAO.(*mockRepo.VariableRevisionDAOInterface).
+		AssertNumberOfCalls(t, "CreateVariableRevision", 4)
+	auditSvc.AssertNumberOfCalls(t, "DoAudit", 2)
+}
+
+func TestReplaceVariables_AccessDenied(t *testing.T) {
+	appContext, mockVariableDAO := getReplaceAppContext()
+
+	user := mockUser()
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", user.Email).Return(user, nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	secOper := mockSecurityOperations()
+	mockUserEnvRoleDAO := &mockRepo.UserEnvironmentRoleDAOInterface{}
+	mockUserEnvRoleDAO.On("GetRoleByUserAndEnvironment", user, mock.Anything).Return(&secOper, nil)
+	appContext.Repositories.UserEnvironmentRoleDAO = mockUserEnvRoleDAO
+
+	dev, _ := appContext.Repositories.EnvironmentDAO.GetByID(1)
+	mockEnvDao := appContext.Repositories.EnvironmentDAO.(*mockRepo.EnvironmentDAOInterface)
+	mockEnvDao.On("GetAllEnvironments", user.Email).Return([]model.Environment{*dev}, nil)
+	principal := model.Principal{Email: user.Email}
+
+	rr, report := doReplaceVariables(appContext, "/variables/replace?dryRun=true",
+		model.VariableReplace{Search: "old.local", Replace: "new.local"}, principal)
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, 2, len(report.Matches))
+	for _, match := range report.Matches {
+		assert.Equal(t, 1, match.EnvironmentID)
+	}
+	mockVariableDAO.AssertNotCalled(t, "GetAllVariablesByEnvironment", 2)
+
+	rr, _ = doReplaceVariables(appContext, "/variables/replace?dryRun=true",
+		model.VariableReplace{Search: "old.local", Replace: "new.local", EnvironmentIDs: []int{2}}, principal)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+
+	rr, _ = doReplaceVariables(appContext, "/variables/replace",
+		model.VariableReplace{Search: "old.local", Replace: "new.local"}, principal)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Response should be 401.")
+	mockVariableDAO.AssertNotCalled(t, "EditVariables", mock.Anything)
+}
+
+func TestReplaceVariables_Invalid(t *testing.T) {
+	appContext, mockVariableDAO := getReplaceAppContext()
+
+	for _, body := range []model.VariableReplace{
+		{Replace: "new.local"},
+		{Search: "db.(", Regex: true},
+		{Search: "old.local", NamePattern: "db("},
+	} {
+		rr, _ := doReplaceVariables(appContext, "/variables/replace", body, replaceAdmin)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	}
+
+	typed := model.Variable{Scope: "repo/api", Name: "replicas", Value: "3", Type: model.VariableTypeInt,
+		EnvironmentID: 1}
+	mockVariableDAO.ExpectedCalls[0].ReturnArguments = []interface{}{[]model.Variable{typed}, nil}
+	rr, report := doReplaceVariables(appContext, "/variables/replace",
+		model.VariableReplace{Search: "3", Replace: "three", EnvironmentIDs: []int{1}}, replaceAdmin)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	assert.Contains(t, report.Matches[0].Error, "Invalid value for replicas")
+	mockVariableDAO.AssertNotCalled(t, "EditVariables", mock.Anything)
+}
+
+func getReplaceRedeployAppContext(rules ...model.VariableRule) (*AppContext, *mockRepo.VariableDAOInterface,
+	*mockRepo.DeploymentDAOInterface) {
+
+	appContext, mockVariableDAO := getReplaceAppContext()
+	mockDoAudit(appContext, "replaceVariables", map[string]string{"environment": "dev", "search": "old.local",
+		"replace": "new.local", "variables": "2"})
+	mockConventionInterface(appContext).On("GetKubeConfigFileName", "", "dev").Return("./config/_dev")
+	appContext.RabbitImpl = getMockRabbitMQ()
+	mockListVariableRules(appContext, rules...)
+
+	devVariables, _ := mockVariableDAO.GetAllVariablesByEnvironment(1)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 1, "repo/api").Return(devVariables[:2], nil)
+	mockVariableDAO.On("GetAllVariablesByEnvironmentAndScope", 1, mock.Anything).Return([]model.Variable{}, nil)
+	mockHelmSvc := mockUpgrade(appContext)
+	mockHelmSvc.On("GetTemplate", mock.Anything, mock.Anything, mock.Anything, "values").
+		Return([]byte(`{"app":{"myvar":"myvalue"}}`), nil)
+
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", "beta@alfa.com").Return(mockUser(), nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	mockRequestDeploymentDAO := &mockRepo.RequestDeploymentDAOInterface{}
+	mockRequestDeploymentDAO.On("CreateRequestDeployment", mock.Anything).Return(7, nil)
+	appContext.Repositories.RequestDeploymentDAO = mockRequestDeploymentDAO
+
+	mockDeploymentDAO := &mockRepo.DeploymentDAOInterface{}
+	mockDeploymentDAO.On("ListLastSuccessfulDeployments", 1).Return([]model.Deployment{
+		{Chart: "repo/api", ChartVersion: "0.1.0", ReleaseName: "api-dev"},
+		{Chart: "repo/web", ChartVersion: "0.2.0", ReleaseName: "web-dev"},
+	}, nil)
+	mockDeploymentDAO.On("CreateDeployment", mock.Anything).Return(1, nil)
+	appContext.Repositories.DeploymentDAO = mockDeploymentDAO
+
+	return appContext, mockVariableDAO, mockDeploymentDAO
+}
+
+func TestReplaceVariables_Redeploy(t *testing.T) {
+	appContext, _, mockDeploymentDAO := getReplaceRedeployAppContext()
+
+	rr, report := doReplaceVariables(appContext, "/variables/replace",
+		model.VariableReplace{Search: "old.local", Replace: "new.local", EnvironmentIDs: []int{1}, Redeploy: true},
+		replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, []string{"dev/api-dev"}, report.Redeployed)
+	assert.Empty(t, report.RedeployFailures)
+	assert.Equal(t, 7, report.RequestDeploymentID)
+	mockDeploymentDAO.AssertNumberOfCalls(t, "CreateDeployment", 1)
+	appContext.Repositories.RequestDeploymentDAO.(*mockRepo.RequestDeploymentDAOInterface).
+		AssertNumberOfCalls(t, "CreateRequestDeployment", 1)
+}
+
+func TestReplaceVariables_RedeployBlockedByRules(t *testing.T) {
+	rule := getVarRule("dbHost", "NotOneOf", "db.new.local")
+	rule.ValueRules[0].Severity = model.ValueRuleSeverityError
+	appContext, mockVariableDAO, mockDeploymentDAO := getReplaceRedeployAppContext(rule)
+
+	req, _ := http.NewRequest("POST", "/variables/replace", payload(model.VariableReplace{Search: "old.local",
+		Replace: "new.local", EnvironmentIDs: []int{1}, Redeploy: true}))
+	mockPrincipal(req)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(appContext.replaceVariables).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400.")
+	var result model.DeployRulesResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.True(t, result.Blocked)
+	assert.Equal(t, "db.new.local", result.Checks[0].Violations[0].Value)
+	mockVariableDAO.AssertNotCalled(t, "EditVariables", mock.Anything)
+	mockDeploymentDAO.AssertNotCalled(t, "CreateDeployment", mock.Anything)
+}
+
+func TestReplaceVariables_RedeployFailure(t *testing.T) {
+	appContext, mockVariableDAO, _ := getReplaceRedeployAppContext()
+	appContext.RabbitImpl.(*mockRabbit.RabbitInterface).ExpectedCalls[0].ReturnArguments =
+		mock.Arguments{errors.New("publish failed")}
+
+	rr, report := doReplaceVariables(appContext, "/variables/replace",
+		model.VariableReplace{Search: "old.local", Replace: "new.local", EnvironmentIDs: []int{1}, Redeploy: true},
+		replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	mockVariableDAO.AssertNumberOfCalls(t, "EditVariables", 1)
+	assert.Empty(t, report.Redeployed)
+	assert.Equal(t, []model.VariableRedeployFailure{{Release: "dev/api-dev", Error: "publish failed"}},
+		report.RedeployFailures)
+}
+
+func TestReplaceVariables_SkipsSecretsWithoutReveal(t *testing.T) {
+	appContext, _ := getReplaceAppContext()
+
+	user := mockUser()
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", user.Email).Return(user, nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	secOper := mockSecurityOperations()
+	secOper.Policies = append(secOper.Policies, "ACTION_SAVE_VARIABLES")
+	mockUserEnvRoleDAO := &mockRepo.UserEnvironmentRoleDAOInterface{}
+	mockUserEnvRoleDAO.On("GetRoleByUserAndEnvironment", user, mock.Anything).Return(&secOper, nil)
+	appContext.Repositories.UserEnvironmentRoleDAO = mockUserEnvRoleDAO
+
+	qa, _ := appContext.Repositories.EnvironmentDAO.GetByID(2)
+	mockEnvDao := appContext.Repositories.EnvironmentDAO.(*mockRepo.EnvironmentDAOInterface)
+	mockEnvDao.On("GetAllEnvironments", user.Email).Return([]model.Environment{*qa}, nil)
+
+	rr, report := doReplaceVariables(appContext, "/variables/replace?dryRun=true",
+		model.VariableReplace{Search: "old.local", Replace: "new.local", EnvironmentIDs: []int{2}},
+		model.Principal{Email: user.Email})
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, 1, len(report.Matches))
+	assert.Equal(t, "dbHost", report.Matches[0].Name)
+	assert.Equal(t, []string{"qa"}, report.SkippedSecrets)
+
+	secOper.Policies = append(secOper.Policies, "ACTION_REVEAL_SECRET")
+	rr, report = doReplaceVariables(appContext, "/variables/replace?dryRun=true",
+		model.VariableReplace{Search: "old.local", Replace: "new.local", EnvironmentIDs: []int{2}},
+		model.Principal{Email: user.Email})
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, 2, len(report.Matches))
+	assert.Empty(t, report.SkippedSecrets)
+}
@@ -23,6 +23,11 @@ func (appContext *AppContext) newVariableRule(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if err := validateVariableRule(payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if _, err := appContext.Repositories.VariableRuleDAO.CreateVariableRule(payload); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -41,6 +46,11 @@ func (appContext *AppContext) editVariableRule(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if err := validateVariableRule(payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if err := appContext.Repositories.VariableRuleDAO.EditVariableRule(payload); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -184,7 +184,7 @@ func TestListVariableRule(t *testing.T) {
 	response := string(rr.Body.Bytes())
 	assert.Contains(t, response, `{"list":[{"ID":999,`)
 	assert.Contains(t, response, `"name":"urlapi.*","ValueRules":[{"ID":888,`)
-	assert.Contains(t, response, `"type":"StartsWith","value":"http","VariableRuleID":999}]}]}`)
+	assert.Contains(t, response, `"type":"StartsWith","value":"http","VariableRuleID":999,"severity":""}],"environmentGroup":"",`)
 }
 
 func TestListVariableRule_Error(t *testing.T) {
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/softplan/tenkai-api/pkg/constraints"
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	"github.com/softplan/tenkai-api/pkg/global"
+	"github.com/softplan/tenkai-api/pkg/util"
+)
+
+const searchMatchExact = "exact"
+const searchMatchContains = "contains"
+const searchMatchRegex = "regex"
+
+//textMatcher matches a text exactly, by containing it or by a regular expression
+type textMatcher struct {
+	text  string
+	mode  string
+	regex *regexp.Regexp
+}
+
+func newTextMatcher(text string, mode string) (*textMatcher, error) {
+	if text == "" {
+		return nil, nil
+	}
+	if mode == "" {
+		mode = searchMatchContains
+	}
+
+	matcher := &textMatcher{text: text, mode: mode}
+	switch mode {
+	case searchMatchExact, searchMatchContains:
+	case searchMatchRegex:
+		var err error
+		if matcher.regex, err = regexp.Compile(text); err != nil {
+			return nil, errors.New("Invalid pattern " + text + ": " + err.Error())
+		}
+	default:
+		return nil, errors.New("Invalid match " + mode)
+	}
+	return matcher, nil
+}
+
+func (matcher *textMatcher) matches(value string) bool {
+	switch matcher.mode {
+	case searchMatchExact:
+		return value == matcher.text
+	case searchMatchRegex:
+		return matcher.regex.MatchString(value)
+	}
+	return strings.Contains(value, matcher.text)
+}
+
+//searchVariables finds variables by name and value across the environments of the principal.
+//Secret values are only searched in the environments where the principal may reveal them.
+func (appContext *AppContext) searchVariables(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	principal := util.GetPrincipal(r)
+	keys := r.URL.Query()
+
+	nameMatcher, err := newTextMatcher(keys.Get("name"), keys.Get("nameMatch"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	valueMatcher, err := newTextMatcher(keys.Get("value"), keys.Get("valueMatch"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if nameMatcher == nil && valueMatcher == nil {
+		http.Error(w, "name or value is required", http.StatusBadRequest)
+		return
+	}
+
+	pageSize, success := isNumber(keys.Get("pageSize"))
+	if !success || pageSize == 0 {
+		http.Error(w, "pageSize must be a number", http.StatusBadRequest)
+		return
+	}
+	page, success := validatePageParam(keys.Get("page"))
+	if !success || page == 0 {
+		http.Error(w, "Page must be a number", http.StatusBadRequest)
+		return
+	}
+
+	environments, err := appContext.Repositories.EnvironmentDAO.GetAllEnvironments(principal.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	isAdmin := util.Contains(principal.Roles, constraints.TenkaiAdmin)
+	found := make([]model.Variable, 0)
+	names := make(map[int]string)
+	for _, environment := range environments {
+		variables, err := appContext.Repositories.VariableDAO.GetAllVariablesByEnvironment(int(environment.ID))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		names[int(environment.ID)] = environment.Name
+
+		var canReveal *bool
+		for _, v := range variables {
+			if nameMatcher != nil && !nameMatcher.matches(v.Name) {
+				continue
+			}
+			if valueMatcher != nil {
+				if v.Secret && canReveal == nil {
+					auth := isAdmin
+					if !auth {
+						auth, _ = appContext.hasEnvironmentRole(principal, environment.ID, "ACTION_REVEAL_SECRET")
+					}
+					canReveal = &auth
+				}
+				if v.Secret && !*canReveal {
+					continue
+				}
+				if !valueMatcher.matches(appContext.decryptVariable(v).Value) {
+					continue
+				}
+			}
+			v.Value = maskedValue(v)
+			found = append(found, v)
+		}
+	}
+
+	response := model.VariableSearchResponse{
+		Count:      int64(len(found)),
+		TotalPages: getTotalPages(pageSize, len(found)),
+		Data:       make([]model.VariableSearchGroup, 0),
+	}
+
+	start := (page - 1) * pageSize
+	for i := start; i < len(found) && i < start+pageSize; i++ {
+		v := found[i]
+		last := len(response.Data) - 1
+		if last < 0 || response.Data[last].EnvironmentID != v.EnvironmentID || response.Data[last].Scope != v.Scope {
+			response.Data = append(response.Data, model.VariableSearchGroup{EnvironmentID: v.EnvironmentID,
+				Environment: names[v.EnvironmentID], Scope: v.Scope, Variables: make([]model.Variable, 0)})
+			last++
+		}
+		response.Data[last].Variables = append(response.Data[last].Variables, v)
+	}
+
+	data, _ := json.Marshal(response)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
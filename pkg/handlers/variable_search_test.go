@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/softplan/tenkai-api/pkg/dbms/model"
+	mockRepo "github.com/softplan/tenkai-api/pkg/dbms/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getSearchAppContext() *AppContext {
+	appContext, _ := getReplaceAppContext()
+	environments, _ := appContext.Repositories.EnvironmentDAO.GetAllEnvironments("")
+	appContext.Repositories.EnvironmentDAO.(*mockRepo.EnvironmentDAOInterface).
+		On("GetAllEnvironments", "beta@alfa.com").Return(environments, nil)
+	return appContext
+}
+
+func doSearchVariables(appContext *AppContext, url string,
+	principal model.Principal) (*httptest.ResponseRecorder, model.VariableSearchResponse) {
+
+	req, _ := http.NewRequest("GET", url, nil)
+	pSe, _ := json.Marshal(principal)
+	req.Header.Set("principal", string(pSe))
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(appContext.searchVariables).ServeHTTP(rr, req)
+
+	var response model.VariableSearchResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	return rr, response
+}
+
+func TestSearchVariables_ByName(t *testing.T) {
+	appContext := getSearchAppContext()
+
+	rr, response := doSearchVariables(appContext, "/variables/search?name=dbHost&nameMatch=exact", replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, int64(2), response.Count)
+	assert.Equal(t, 1, response.TotalPages)
+	assert.Equal(t, 2, len(response.Data))
+	assert.Equal(t, "dev", response.Data[0].Environment)
+	assert.Equal(t, "repo/api", response.Data[0].Scope)
+	assert.Equal(t, "qa", response.Data[1].Environment)
+	assert.Equal(t, "db.old.local:5432", response.Data[1].Variables[0].Value)
+}
+
+func TestSearchVariables_ByValue(t *testing.T) {
+	appContext := getSearchAppContext()
+
+	rr, response := doSearchVariables(appContext, "/variables/search?value=db.old.local", replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, int64(3), response.Count)
+	assert.Equal(t, 2, len(response.Data))
+	assert.Equal(t, 2, len(response.Data[1].Variables))
+	assert.Equal(t, "dbUrl", response.Data[1].Variables[1].Name)
+	assert.Equal(t, "******", response.Data[1].Variables[1].Value)
+}
+
+func TestSearchVariables_SecretsWithoutReveal(t *testing.T) {
+	appContext := getSearchAppContext()
+
+	user := mockUser()
+	mockUserDAO := &mockRepo.UserDAOInterface{}
+	mockUserDAO.On("FindByEmail", user.Email).Return(user, nil)
+	appContext.Repositories.UserDAO = mockUserDAO
+
+	secOper := mockSecurityOperations()
+	mockUserEnvRoleDAO := &mockRepo.UserEnvironmentRoleDAOInterface{}
+	mockUserEnvRoleDAO.On("GetRoleByUserAndEnvironment", user, mock.Anything).Return(&secOper, nil)
+	appContext.Repositories.UserEnvironmentRoleDAO = mockUserEnvRoleDAO
+
+	rr, response := doSearchVariables(appContext, "/variables/search?value=db.old.local",
+		model.Principal{Email: user.Email})
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, int64(2), response.Count)
+
+	rr, response = doSearchVariables(appContext, "/variables/search?name=dbUrl", model.Principal{Email: user.Email})
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, int64(1), response.Count)
+	assert.Equal(t, "******", response.Data[0].Variables[0].Value)
+}
+
+func TestSearchVariables_Paginated(t *testing.T) {
+	appContext := getSearchAppContext()
+
+	rr, response := doSearchVariables(appContext,
+		`/variables/search?value=old\.local&valueMatch=regex&page=2&pageSize=2`, replaceAdmin)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Response should be Ok.")
+	assert.Equal(t, int64(4), response.Count)
+	assert.Equal(t, 2, response.TotalPages)
+	assert.Equal(t, 1, len(response.Data))
+	assert.Equal(t, "qa", response.Data[0].Environment)
+	assert.Equal(t, 2, len(response.Data[0].Variables))
+	assert.Equal(t, "dbHost", response.Data[0].Variables[0].Name)
+}
+
+func TestSearchVariables_Invalid(t *testing.T) {
+	appContext := getSearchAppContext()
+
+	for _, url := range []string{
+		"/variables/search",
+		"/variables/search?name=db(&nameMatch=regex",
+		"/variables/search?value=db&valueMatch=like",
+		"/variables/search?name=db&pageSize=0",
+		"/variables/search?name=db&page=abc",
+	} {
+		rr, _ := doSearchVariables(appContext, url, replaceAdmin)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Response should be 400 for "+url)
+	}
+}
@@ -27,11 +27,20 @@ func (appContext *AppContext) deleteVariable(w http.ResponseWriter, r *http.Requ
 	sl := vars["id"]
 	id, _ := strconv.Atoi(sl)
 	w.Header().Set(global.ContentType, global.JSONContentType)
+
+	variable, err := appContext.Repositories.VariableDAO.GetByID(uint(id))
+	if err != nil {
+		log.Println("Error deleting variable: ", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
 	if err := appContext.Repositories.VariableDAO.DeleteVariable(id); err != nil {
 		log.Println("Error deleting variable: ", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+	appContext.recordVariableRevision(principal.Email, model.VariableDeleted, *variable, variable.Value)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -60,15 +69,31 @@ func (appContext *AppContext) editVariable(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
+	if err := validateVariableType(payload.Data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if payload.Data.Secret {
 		secret := util.Encrypt([]byte(payload.Data.Value), appContext.Configuration.App.Passkey)
 		payload.Data.Value = hex.EncodeToString(secret)
 	}
 
+	operation, oldValue := model.VariableCreated, ""
+	if payload.Data.ID > 0 {
+		old, err := appContext.Repositories.VariableDAO.GetByID(payload.Data.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		operation, oldValue = model.VariableUpdated, old.Value
+	}
+
 	if err := appContext.Repositories.VariableDAO.EditVariable(payload.Data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	appContext.recordVariableRevision(principal.Email, operation, payload.Data, oldValue)
 
 	w.WriteHeader(http.StatusCreated)
 
@@ -128,11 +153,13 @@ func (appContext *AppContext) copyVariableValue(w http.ResponseWriter, r *http.R
 	}
 
 	var targetVar *model.Variable
+	operation, oldValue := model.VariableCreated, ""
 	if payload.TarVarID > 0 {
 		if targetVar, err = appContext.Repositories.VariableDAO.GetByID(payload.TarVarID); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		operation, oldValue = model.VariableUpdated, targetVar.Value
 		targetVar.Value = sourceVar.Value
 	} else {
 		new := model.Variable{}
@@ -141,6 +168,7 @@ func (appContext *AppContext) copyVariableValue(w http.ResponseWriter, r *http.R
 		new.Name = sourceVar.Name
 		new.Value = sourceVar.Value
 		new.Secret = sourceVar.Secret
+		new.Type = sourceVar.Type
 		new.Description = sourceVar.Description
 		new.EnvironmentID = int(payload.TarEnvID)
 		targetVar = &new
@@ -150,6 +178,7 @@ func (appContext *AppContext) copyVariableValue(w http.ResponseWriter, r *http.R
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	appContext.recordVariableRevision(principal.Email, operation, *targetVar, oldValue)
 
 	w.WriteHeader(http.StatusCreated)
 
@@ -32,6 +32,7 @@ func TestEditVariable(t *testing.T) {
 
 	appContext.Repositories = Repositories{}
 	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockVariableRevisionDAO := mockCreateVariableRevision(appContext)
 
 	req, err := http.NewRequest("POST", "/variables", payload(getDataVariableElement(true)))
 	assert.NoError(t, err)
@@ -57,6 +58,10 @@ func TestEditVariable(t *testing.T) {
 	handler.ServeHTTP(rr, req)
 
 	mockVariableDAO.AssertNumberOfCalls(t, "EditVariable", 1)
+	mockVariableRevisionDAO.AssertCalled(t, "CreateVariableRevision", mock.MatchedBy(func(rev model.VariableRevision) bool {
+		return rev.Operation == model.VariableCreated && rev.Name == "my_variable" && rev.User == "beta@alfa.com" &&
+			rev.NewValue != "my_value"
+	}))
 	assert.Equal(t, http.StatusCreated, rr.Code, "Response is not Ok.")
 }
 
@@ -67,9 +72,11 @@ func TestDeleteVariable(t *testing.T) {
 
 	mockVariableDAO := &mocks.VariableDAOInterface{}
 	mockVariableDAO.On("DeleteVariable", mock.Anything).Return(nil)
+	mockVariableDAO.On("GetByID", uint(1)).Return(&model.Variable{Scope: "foo", Name: "bar", Value: "old"}, nil)
 
 	appContext.Repositories = Repositories{}
 	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockVariableRevisionDAO := mockCreateVariableRevision(&appContext)
 
 	req, err := http.NewRequest("DELETE", "/variables/delete/1", nil)
 	assert.NoError(t, err)
@@ -86,6 +93,8 @@ func TestDeleteVariable(t *testing.T) {
 	r.ServeHTTP(rr, req)
 
 	mockVariableDAO.AssertNumberOfCalls(t, "DeleteVariable", 1)
+	mockVariableRevisionDAO.AssertCalled(t, "CreateVariableRevision", model.VariableRevision{Scope: "foo", Name: "bar",
+		Operation: model.VariableDeleted, OldValue: "old", User: "beta@gmail.com"})
 	assert.Equal(t, http.StatusOK, rr.Code, "Response is not Ok.")
 
 }
@@ -110,6 +119,7 @@ func TestDeleteVariable_DeleteVariableError(t *testing.T) {
 
 	mockVariableDAO := &mocks.VariableDAOInterface{}
 	mockVariableDAO.On("DeleteVariable", mock.Anything).Return(errors.New("some error"))
+	mockVariableDAO.On("GetByID", uint(1)).Return(&model.Variable{}, nil)
 	appContext.Repositories.VariableDAO = mockVariableDAO
 
 	req, err := http.NewRequest("DELETE", "/variables/delete/1", nil)
@@ -202,9 +212,9 @@ func TestGetVariables(t *testing.T) {
 	response := string(rr.Body.Bytes())
 	assert.Contains(t, response, `{"Variables":[{"ID":0,`)
 	assert.Contains(t, response, `"scope":"global","chartVersion":"","name":"username","value":"user",`)
-	assert.Contains(t, response, `"secret":false,"description":"Login username.","environmentId":999},{"ID":0,`)
+	assert.Contains(t, response, `"secret":false,"description":"Login username.","environmentId":999,"type":""},{"ID":0,`)
 	assert.Contains(t, response, `"scope":"bar","chartVersion":"","name":"password","value":"password","secret":false,`)
-	assert.Contains(t, response, `"description":"Login password.","environmentId":999}]}`)
+	assert.Contains(t, response, `"description":"Login password.","environmentId":999,"type":""}]}`)
 }
 
 func TestGetVariables_Unauthorized(t *testing.T) {
@@ -282,6 +292,7 @@ func TestCopyVariableValue(t *testing.T) {
 
 	appContext.Repositories = Repositories{}
 	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockCreateVariableRevision(appContext)
 
 	var p model.CopyVariableValue
 	p.SrcVarID = 999
@@ -330,6 +341,7 @@ func TestCopyEntireVariable(t *testing.T) {
 
 	appContext.Repositories = Repositories{}
 	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockCreateVariableRevision(appContext)
 
 	var p model.CopyVariableValue
 	p.SrcVarID = 999
@@ -68,6 +68,14 @@ func (appContext *AppContext) saveVariableValues(w http.ResponseWriter, r *http.
 		}
 	}
 
+	for _, item := range payload.Data {
+		if err := validateVariableType(item); err != nil {
+			global.Logger.Error(logFields, "Error validateVariableType")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	cacheVars := make(map[string]map[string]interface{})
 
 	for _, item := range payload.Data {
@@ -94,6 +102,7 @@ func (appContext *AppContext) saveVariableValues(w http.ResponseWriter, r *http.
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		appContext.recordSavedVariable(principal.Email, item, auditValues, updated)
 		appContext.audit(updated, auditValues, targetEnvironment, principal, r)
 	}
 
@@ -156,6 +165,7 @@ func (appContext *AppContext) saveVariablesWithDefaultValue(cacheVars map[string
 			if auditValues, updated, err = appContext.Repositories.VariableDAO.CreateVariableWithDefaultValue(item); err != nil {
 				return err
 			}
+			appContext.recordSavedVariable(principal.Email, item, auditValues, updated)
 			appContext.audit(updated, auditValues, targetEnvironment, principal, r)
 		}
 	}
@@ -173,13 +183,21 @@ func (appContext *AppContext) audit(updated bool, auditValues map[string]string,
 
 func (appContext *AppContext) getHelmChartAppVars(chart string, chartVersion string) (map[string]interface{}, error) {
 
-	if strings.HasSuffix(chart, "-gcm") {
-		var config model.ConfigMap
-		var err error
-		if config, err = appContext.Repositories.ConfigDAO.GetConfigByName("commonValuesConfigMapChart"); err != nil {
-			return nil, err
-		}
-		chart = config.Value
+	result, err := appContext.getHelmChartValues(chart, chartVersion)
+	if err != nil {
+		return nil, err
+	}
+	app, _ := result["app"].(map[string]interface{})
+
+	return app, nil
+}
+
+//getHelmChartValues returns the values.yaml of a chart version
+func (appContext *AppContext) getHelmChartValues(chart string, chartVersion string) (map[string]interface{}, error) {
+
+	chart, err := appContext.valuesChart(chart)
+	if err != nil {
+		return nil, err
 	}
 
 	chartVariables, err := appContext.HelmServiceAPI.GetTemplate(&appContext.Mutex, chart, chartVersion, "values")
@@ -187,11 +205,24 @@ func (appContext *AppContext) getHelmChartAppVars(chart string, chartVersion str
 		return nil, err
 	}
 
-	var result map[string]interface{}
+	result := make(map[string]interface{})
 	json.Unmarshal(chartVariables, &result)
-	app := result["app"].(map[string]interface{})
+	if result == nil {
+		result = make(map[string]interface{})
+	}
+	return result, nil
+}
 
-	return app, nil
+//valuesChart returns the chart holding the values of a chart, the common values chart for the -gcm ones
+func (appContext *AppContext) valuesChart(chart string) (string, error) {
+	if strings.HasSuffix(chart, "-gcm") {
+		config, err := appContext.Repositories.ConfigDAO.GetConfigByName("commonValuesConfigMapChart")
+		if err != nil {
+			return "", err
+		}
+		return config.Value, nil
+	}
+	return chart, nil
 }
 
 func (appContext *AppContext) getVariablesByEnvironmentAndScope(w http.ResponseWriter, r *http.Request) {
@@ -472,9 +503,12 @@ func (appContext *AppContext) validateNewVariablesBeforeInstall(w http.ResponseW
 	}
 
 	envs := make([]string, 0)
+	environments := make(map[int]*model.Environment)
 	for _, envID := range payload.Environments {
 		envs = append(envs, strconv.Itoa(envID))
-		if _, err := appContext.Repositories.EnvironmentDAO.GetByID(envID); err != nil {
+		environment, err := appContext.Repositories.EnvironmentDAO.GetByID(envID)
+		environments[envID] = environment
+		if err != nil {
 			logger.Error(logFields, fmt.Sprintf("Invalida environment id: %d", envID))
 			http.Error(w, fmt.Sprintf("Invalid environment: %d", envID), http.StatusBadRequest)
 			return
@@ -511,12 +545,65 @@ func (appContext *AppContext) validateNewVariablesBeforeInstall(w http.ResponseW
 
 	result := compare(variablesTemplate, variablesDatabase, payload.Environments)
 
+	for _, chart := range payload.Charts {
+		chartFullname := fmt.Sprintf("%s/%s", chart.Repo, chart.Name)
+		schema, err := appContext.getValuesSchema(chartFullname, chart.Version)
+		if err != nil {
+			http.Error(w, "Invalid chart schema - "+chartFullname, http.StatusBadRequest)
+			logger.Error(logFields, err.Error())
+			return
+		}
+		if schema == nil {
+			continue
+		}
+		for _, envID := range payload.Environments {
+			environment := environments[envID]
+			variables, err := appContext.getEffectiveVariables(environment, chartFullname)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				logger.Error(logFields, err.Error())
+				return
+			}
+			violations, err := appContext.validateValuesSchema(schema, environment, chartFullname, chart.Version,
+				variables, appContext.getGlobalVariables(environment))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				logger.Error(logFields, err.Error())
+				return
+			}
+			if len(violations) > 0 {
+				result = addSchemaViolations(result, envID, chartFullname, violations)
+			}
+		}
+	}
+
 	data, _ := json.Marshal(result)
 
 	w.Header().Add(global.ContentType, global.JSONContentType)
 	w.Write(data)
 }
 
+//addSchemaViolations adds the schema violations of a chart to the failed charts of an environment
+func addSchemaViolations(result []map[string]interface{}, envID int, chart string,
+	violations []model.InvalidVariable) []map[string]interface{} {
+
+	for _, item := range result {
+		if item["environmentId"] == envID {
+			charts := item["charts"].([]string)
+			if !util.Contains(charts, chart) {
+				item["charts"] = append(charts, chart)
+			}
+			if current, ok := item["schemaViolations"].([]model.InvalidVariable); ok {
+				violations = append(current, violations...)
+			}
+			item["schemaViolations"] = violations
+			return result
+		}
+	}
+	return append(result, map[string]interface{}{"environmentId": envID, "charts": []string{chart},
+		"schemaViolations": violations})
+}
+
 func formatAllDatabaseVariables(variables []model.Variable) []model.VariablesByChartAndEnvironment {
 	list := make([]model.VariablesByChartAndEnvironment, 0)
 	for _, variable := range variables {
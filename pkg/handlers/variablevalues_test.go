@@ -53,6 +53,7 @@ func TestSaveVariableValues(t *testing.T) {
 
 	appContext.Repositories.EnvironmentDAO = mockEnvDao
 	appContext.Repositories.VariableDAO = mockVariableDAO
+	mockVariableRevisionDAO := mockCreateVariableRevision(&appContext)
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(appContext.saveVariableValues)
@@ -62,6 +63,7 @@ func TestSaveVariableValues(t *testing.T) {
 	mockEnvDao.AssertNumberOfCalls(t, "GetAllEnvironments", 1)
 	mockVariableDAO.AssertNumberOfCalls(t, "CreateVariable", 1)
 	mockAudit.AssertNumberOfCalls(t, "DoAudit", 2)
+	mockVariableRevisionDAO.AssertNumberOfCalls(t, "CreateVariableRevision", 2)
 
 	assert.Equal(t, http.StatusCreated, rr.Code, "Response should be Created.")
 }
@@ -203,7 +205,7 @@ func TestGetVariablesByEnvironmentAndScope(t *testing.T) {
 	response := string(rr.Body.Bytes())
 	assert.Contains(t, response, `{"Variables":[{"ID":0,`)
 	assert.Contains(t, response, `"scope":"global","chartVersion":"","name":"username","value":"user",`)
-	assert.Contains(t, response, `"secret":false,"description":"Login username.","environmentId":999}]}`)
+	assert.Contains(t, response, `"secret":false,"description":"Login username.","environmentId":999,"type":""}]}`)
 }
 
 func TestGetVariablesByEnvironmentAndScope_UnmarshalPayloadError(t *testing.T) {
@@ -295,7 +297,7 @@ func TestGetVariablesByEnvironmentAndScopeWithScopeVersion(t *testing.T) {
 	response := string(rr.Body.Bytes())
 	assert.Contains(t, response, `{"Variables":[{"ID":0,`)
 	assert.Contains(t, response, `"scope":"global","chartVersion":"","name":"username","value":"user",`)
-	assert.Contains(t, response, `"secret":false,"description":"Login username.","environmentId":999}]}`)
+	assert.Contains(t, response, `"secret":false,"description":"Login username.","environmentId":999,"type":""}]}`)
 }
 
 func TestGetVariablesWithScopeVersionError(t *testing.T) {
@@ -25,12 +25,14 @@ type HelmServiceInterface interface {
 	SearchCharts(searchTerms []string, allVersions bool) *[]model.SearchResult
 	DeleteHelmRelease(kubeconfig string, releaseName string, purge bool) error
 	Get(kubeconfig string, releaseName string, revision int) (string, error)
+	GetManifest(kubeconfig string, releaseName string, revision int) (string, error)
 	IsThereAnyPodWithThisVersion(kubeconfig string, namespace string, releaseName string, tag string) (bool, error)
 	GetReleaseHistory(kubeconfig string, releaseName string) (bool, error)
 	GetHelmReleaseHistory(kubeconfig string, releaseName string) (ReleaseHistory, error)
 	GetTemplate(mutex *sync.Mutex, chartName string, version string, kind string) ([]byte, error)
 	GetDeployment(chartName string, version string) ([]byte, error)
 	GetValues(chartName string, version string) ([]byte, error)
+	GetValuesSchema(chartName string, version string) ([]byte, error)
 	ListHelmDeployments(kubeconfig string, namespace string) (*HelmListResult, error)
 	RepoUpdate() error
 	RollbackRelease(kubeconfig string, releaseName string, revision int) error
@@ -53,6 +53,25 @@ func (svc HelmServiceImpl) Get(kubeconfig string, releaseName string, revision i
 	return result, nil
 }
 
+//GetManifest - Rendered manifest of a release revision
+func (svc HelmServiceImpl) GetManifest(kubeconfig string, releaseName string, revision int) (string, error) {
+
+	tillerHost, tunnel, err := svc.GetHelmConnection().SetupConnection(kubeconfig)
+	defer svc.GetHelmConnection().Teardown(tunnel)
+	if err != nil {
+		return "", err
+	}
+
+	client := svc.GetHelmConnection().NewClient(tillerHost)
+
+	res, err := client.ReleaseContent(releaseName, helm.ContentReleaseVersion(int32(revision)))
+	if err != nil {
+		return "", prettyError(err)
+	}
+
+	return res.Release.Manifest, nil
+}
+
 func formatValues(format string, values chartutil.Values) (string, error) {
 	switch format {
 	case "", "yaml":
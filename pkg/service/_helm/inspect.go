@@ -41,6 +41,8 @@ func (svc HelmServiceImpl) GetTemplate(mutex *sync.Mutex, chartName string, vers
 	mutex.Lock()
 	if kind == "values" {
 		result, err = svc.GetValues(chartName, version)
+	} else if kind == "schema" {
+		result, err = svc.GetValuesSchema(chartName, version)
 	} else {
 		if kind == "deployment" {
 			result, err = svc.GetDeployment(chartName, version)
@@ -119,6 +121,38 @@ func (svc HelmServiceImpl) GetValues(chartName string, version string) ([]byte,
 
 }
 
+//GetValuesSchema - Retrieve the values.schema.json of a chart, nil if the chart has none
+func (svc HelmServiceImpl) GetValuesSchema(chartName string, version string) ([]byte, error) {
+
+	logFields := global.AppFields{global.Function: "GetValuesSchema"}
+
+	insp := &inspectCmd{
+		out: os.Stdout,
+	}
+
+	if len(version) > 0 {
+		insp.version = version
+	}
+
+	if err := insp.prepare(chartName); err != nil {
+		global.Logger.Error(logFields, "Error insp.prepare(): "+err.Error()+" on chart: "+chartName+" - "+version)
+		return nil, err
+	}
+
+	chrt, err := chartutil.Load(insp.chartpath)
+	if err != nil {
+		global.Logger.Error(logFields, "Error chartutil.Load(): "+err.Error()+" on chart: "+chartName+" - "+version)
+		return nil, err
+	}
+
+	for _, f := range chrt.Files {
+		if f.TypeUrl == "values.schema.json" {
+			return f.Value, nil
+		}
+	}
+	return nil, nil
+}
+
 func (i *inspectCmd) prepare(chart string) error {
 
 	if i.version == "" && i.devel {
@@ -31,6 +31,7 @@ type installCmd struct {
 	name           string
 	namespace      string
 	valueFiles     valueFiles
+	document       string
 	chartPath      string
 	dryRun         bool
 	disableHooks   bool
@@ -83,7 +84,7 @@ func (i *installCmd) run() error {
 		i.namespace = defaultNamespace()
 	}
 
-	rawVals, err := vals(i.valueFiles, i.values, i.stringValues, i.fileValues, i.certFile, i.keyFile, i.caFile)
+	rawVals, err := vals(i.valueFiles, i.document, i.values, i.stringValues, i.fileValues, i.certFile, i.keyFile, i.caFile)
 	if err != nil {
 		return err
 	}
@@ -231,9 +232,16 @@ func mergeValues(dest map[string]interface{}, src map[string]interface{}) map[st
 
 // vals merges values from files specified via -f/--values and
 // directly via --set or --set-string or --set-file, marshaling them to YAML
-func vals(valueFiles valueFiles, values []string, stringValues []string, fileValues []string, CertFile, KeyFile, CAFile string) ([]byte, error) {
+func vals(valueFiles valueFiles, document string, values []string, stringValues []string, fileValues []string, CertFile, KeyFile, CAFile string) ([]byte, error) {
 	base := map[string]interface{}{}
 
+	// Values document supplied by Tenkai
+	if document != "" {
+		if err := yaml.Unmarshal([]byte(document), &base); err != nil {
+			return []byte{}, fmt.Errorf("failed to parse values document: %s", err)
+		}
+	}
+
 	// User specified a values files via -f/--values
 	for _, filePath := range valueFiles {
 		currentMap := map[string]interface{}{}
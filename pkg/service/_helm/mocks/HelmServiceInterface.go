@@ -138,6 +138,27 @@ func (_m *HelmServiceInterface) GetHelmReleaseHistory(kubeconfig string, release
 	return r0, r1
 }
 
+// GetManifest provides a mock function with given fields: kubeconfig, releaseName, revision
+func (_m *HelmServiceInterface) GetManifest(kubeconfig string, releaseName string, revision int) (string, error) {
+	ret := _m.Called(kubeconfig, releaseName, revision)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, string, int) string); ok {
+		r0 = rf(kubeconfig, releaseName, revision)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, int) error); ok {
+		r1 = rf(kubeconfig, releaseName, revision)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetPods provides a mock function with given fields: kubeconfig, namespace
 func (_m *HelmServiceInterface) GetPods(kubeconfig string, namespace string) ([]model.Pod, error) {
 	ret := _m.Called(kubeconfig, namespace)
@@ -274,6 +295,29 @@ func (_m *HelmServiceInterface) GetValues(chartName string, version string) ([]b
 	return r0, r1
 }
 
+// GetValuesSchema provides a mock function with given fields: chartName, version
+func (_m *HelmServiceInterface) GetValuesSchema(chartName string, version string) ([]byte, error) {
+	ret := _m.Called(chartName, version)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(string, string) []byte); ok {
+		r0 = rf(chartName, version)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(chartName, version)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetVirtualServices provides a mock function with given fields: kubeconfig, namespace
 func (_m *HelmServiceInterface) GetVirtualServices(kubeconfig string, namespace string) ([]string, error) {
 	ret := _m.Called(kubeconfig, namespace)
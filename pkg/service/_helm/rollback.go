@@ -29,8 +29,16 @@ func (svc HelmServiceImpl) RollbackRelease(kubeconfig string, releaseName string
 	cmd.name = releaseName
 	cmd.revision = int32(revision)
 
-	exec := svc.HelmCommandExecutor(svc.HelmExecutorFunc)
-	return exec(kubeconfig, cmd)
+	//HelmCommandExecutor only reports connection errors, keep the error of the rollback itself
+	var rollbackErr error
+	exec := svc.HelmCommandExecutor(func(kubeconfig string, cmd HelmCommand) error {
+		rollbackErr = svc.HelmExecutorFunc(kubeconfig, cmd)
+		return rollbackErr
+	})
+	if err := exec(kubeconfig, cmd); err != nil {
+		return err
+	}
+	return rollbackErr
 
 }
 
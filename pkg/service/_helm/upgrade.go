@@ -25,6 +25,7 @@ type upgradeCmd struct {
 	force         bool
 	disableHooks  bool
 	valueFiles    valueFiles
+	document      string
 	values        []string
 	stringValues  []string
 	fileValues    []string
@@ -61,7 +62,9 @@ type UpgradeRequest struct {
 	ChartVersion string
 	Namespace    string
 	Variables    []string
-	Dryrun       bool
+	//Values is a values document (YAML) applied before the variables
+	Values string
+	Dryrun bool
 }
 
 //Upgrade Method
@@ -97,6 +100,7 @@ func (svc HelmServiceImpl) Upgrade(upgradeRequest UpgradeRequest, out *bytes.Buf
 	upgrade.force = true
 	upgrade.release = upgradeRequest.Release
 	upgrade.chart = upgradeRequest.Chart
+	upgrade.document = upgradeRequest.Values
 	upgrade.values = upgradeRequest.Variables
 	upgrade.wait = upgrade.wait || upgrade.atomic
 	upgrade.namespace = upgradeRequest.Namespace
@@ -129,6 +133,7 @@ func (u *upgradeCmd) doInstall(err error, releaseHistory *rls.GetHistoryResponse
 			out:          u.out,
 			name:         u.release,
 			valueFiles:   u.valueFiles,
+			document:     u.document,
 			dryRun:       u.dryRun,
 			verify:       u.verify,
 			disableHooks: u.disableHooks,
@@ -172,7 +177,7 @@ func (u *upgradeCmd) run() error {
 		}
 	}
 
-	rawVals, err := vals(u.valueFiles, u.values, u.stringValues, u.fileValues, u.certFile, u.keyFile, u.caFile)
+	rawVals, err := vals(u.valueFiles, u.document, u.values, u.stringValues, u.fileValues, u.certFile, u.keyFile, u.caFile)
 	if err != nil {
 		return err
 	}
@@ -183,7 +188,7 @@ func (u *upgradeCmd) run() error {
 		return err
 	}
 
-	_, err = u.client.UpdateReleaseFromChart(
+	resp, err := u.client.UpdateReleaseFromChart(
 		u.release,
 		ch,
 		helm.UpdateValueOverrides(rawVals),
@@ -228,6 +233,10 @@ func (u *upgradeCmd) run() error {
 
 	}
 
+	if u.Debug && resp != nil {
+		printRelease(u.out, resp.Release)
+	}
+
 	fmt.Fprintf(u.out, "Release %q has been upgraded.\n", u.release)
 
 	return nil
@@ -0,0 +1,263 @@
+package interpolation
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+//Value is a variable that can be referenced
+type Value struct {
+	Value  string
+	Secret bool
+}
+
+//Lookup returns the variables of a chart keyed by name. The chart is the one written in
+//the reference, either the chart name or the full scope (repo/chart).
+type Lookup func(chart string) (map[string]Value, error)
+
+//Error is a reference that could not be resolved
+type Error struct {
+	//Reference is the reference as written in the value
+	Reference string
+	Message   string
+}
+
+func (e *Error) Error() string {
+	return e.Message + ": " + e.Reference
+}
+
+//Result is an interpolated value
+type Result struct {
+	Value string
+	//Secret tells if a secret was referenced, in which case the value must not be displayed
+	Secret bool
+	//Errors lists the references left untouched in the value
+	Errors []*Error
+}
+
+//Err returns the first error of the result, if any
+func (r Result) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return r.Errors[0]
+}
+
+var functions = map[string]func(string) string{
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"base64":    func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"urlencode": url.QueryEscape,
+}
+
+//Interpolator resolves references inside variable values. Supported forms are:
+//
+//	${name}             a global variable
+//	${chart:name}       a variable of another chart
+//	${ENV.field}        a field of the environment (name, namespace, gateway, group, productVersion)
+//	${NAMESPACE}        the environment namespace, kept for compatibility
+//	${ref:-default}     the default when the reference is missing or empty, it may hold references itself
+//	${ref | fn | fn}    functions applied to the value (lower, upper, base64, urlencode)
+//	$${...}             a literal ${...}
+//
+//Referenced values are interpolated as well, a reference cycle is reported as an error.
+//Unresolved references are kept as they were written.
+type Interpolator struct {
+	environment map[string]string
+	global      map[string]Value
+	lookup      Lookup
+	charts      map[string]map[string]Value
+}
+
+//New creates an interpolator. Environment field names are case insensitive.
+func New(environment map[string]string, global map[string]Value, lookup Lookup) *Interpolator {
+	fields := make(map[string]string)
+	for k, v := range environment {
+		fields[strings.ToLower(k)] = v
+	}
+	return &Interpolator{environment: fields, global: global, lookup: lookup, charts: make(map[string]map[string]Value)}
+}
+
+//Expand interpolates value. Origin is the reference of the variable holding the value (name for
+//globals, chart:name otherwise) so that a variable referencing itself is reported as a cycle.
+func (i *Interpolator) Expand(origin string, value string) Result {
+	result := Result{}
+	var stack []string
+	if origin != "" {
+		stack = []string{origin}
+	}
+	result.Value = i.expand(value, stack, &result)
+	return result
+}
+
+func (i *Interpolator) expand(value string, stack []string, result *Result) string {
+	var sb strings.Builder
+	for pos := 0; pos < len(value); {
+		if strings.HasPrefix(value[pos:], "$${") {
+			sb.WriteString("${")
+			pos += 3
+			continue
+		}
+		if !strings.HasPrefix(value[pos:], "${") {
+			sb.WriteByte(value[pos])
+			pos++
+			continue
+		}
+
+		end := closingBrace(value, pos+2)
+		if end < 0 {
+			result.Errors = append(result.Errors, &Error{Reference: value[pos:], Message: "Unterminated reference"})
+			sb.WriteString(value[pos:])
+			break
+		}
+
+		if resolved, ok := i.evaluate(value[pos+2:end], stack, result); ok {
+			sb.WriteString(resolved)
+		} else {
+			sb.WriteString(value[pos : end+1])
+		}
+		pos = end + 1
+	}
+	return sb.String()
+}
+
+func (i *Interpolator) evaluate(expression string, stack []string, result *Result) (string, bool) {
+	parts := splitTopLevel(expression, "|")
+	head := parts[0]
+	if len(parts) > 1 {
+		head = strings.TrimSpace(head)
+	}
+	reference, defaultValue, hasDefault := splitDefault(head)
+
+	value, found, err := i.resolve(reference, stack, result)
+	if err != nil {
+		result.Errors = append(result.Errors, &Error{Reference: "${" + expression + "}", Message: err.Error()})
+		return "", false
+	}
+
+	if (!found || value == "") && hasDefault {
+		value = i.expand(defaultValue, stack, result)
+		found = true
+	}
+	if !found {
+		result.Errors = append(result.Errors, &Error{Reference: "${" + expression + "}", Message: "Unresolved reference"})
+		return "", false
+	}
+
+	for _, name := range parts[1:] {
+		fn, ok := functions[strings.TrimSpace(name)]
+		if !ok {
+			result.Errors = append(result.Errors, &Error{Reference: "${" + expression + "}",
+				Message: "Unknown function " + strings.TrimSpace(name)})
+			return "", false
+		}
+		value = fn(value)
+	}
+
+	return value, true
+}
+
+func (i *Interpolator) resolve(reference string, stack []string, result *Result) (string, bool, error) {
+	if reference == "NAMESPACE" {
+		reference = "ENV.namespace"
+	}
+	if strings.HasPrefix(reference, "ENV.") {
+		value, ok := i.environment[strings.ToLower(reference[4:])]
+		return value, ok, nil
+	}
+
+	var variable Value
+	var ok bool
+	if index := strings.Index(reference, ":"); index > -1 {
+		variables, err := i.chart(reference[:index])
+		if err != nil {
+			return "", false, err
+		}
+		variable, ok = variables[reference[index+1:]]
+	} else {
+		variable, ok = i.global[reference]
+	}
+	if !ok {
+		return "", false, nil
+	}
+
+	if variable.Secret {
+		result.Secret = true
+	}
+	if !strings.Contains(variable.Value, "${") {
+		return variable.Value, true, nil
+	}
+
+	for _, key := range stack {
+		if key == reference {
+			return "", false, errors.New("Reference cycle " + strings.Join(append(stack, reference), " -> "))
+		}
+	}
+	nested := append(append([]string{}, stack...), reference)
+	return i.expand(variable.Value, nested, result), true, nil
+}
+
+func (i *Interpolator) chart(name string) (map[string]Value, error) {
+	if variables, ok := i.charts[name]; ok {
+		return variables, nil
+	}
+	if i.lookup == nil {
+		return nil, nil
+	}
+	variables, err := i.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	i.charts[name] = variables
+	return variables, nil
+}
+
+//closingBrace returns the index of the brace closing the reference opened right before start
+func closingBrace(value string, start int) int {
+	depth := 1
+	for pos := start; pos < len(value); pos++ {
+		if strings.HasPrefix(value[pos:], "${") {
+			depth++
+			pos++
+			continue
+		}
+		if value[pos] == '}' {
+			depth--
+			if depth == 0 {
+				return pos
+			}
+		}
+	}
+	return -1
+}
+
+//splitTopLevel splits the expression on sep, ignoring separators inside nested references
+func splitTopLevel(expression string, sep string) []string {
+	var parts []string
+	depth := 0
+	last := 0
+	for pos := 0; pos < len(expression); pos++ {
+		switch {
+		case strings.HasPrefix(expression[pos:], "${"):
+			depth++
+			pos++
+		case expression[pos] == '}' && depth > 0:
+			depth--
+		case depth == 0 && strings.HasPrefix(expression[pos:], sep):
+			parts = append(parts, expression[last:pos])
+			last = pos + len(sep)
+			pos += len(sep) - 1
+		}
+	}
+	return append(parts, expression[last:])
+}
+
+func splitDefault(expression string) (string, string, bool) {
+	parts := splitTopLevel(expression, ":-")
+	if len(parts) == 1 {
+		return strings.TrimSpace(expression), "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.Join(parts[1:], ":-"), true
+}
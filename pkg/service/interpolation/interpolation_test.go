@@ -0,0 +1,104 @@
+package interpolation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getInterpolator() *Interpolator {
+	environment := map[string]string{"name": "qa", "namespace": "qa-ns", "gateway": "istio-system/qa-gw",
+		"productVersion": "1.2.0"}
+	global := map[string]Value{
+		"host":     {Value: "qa.host"},
+		"url":      {Value: "https://${host}/${ENV.name}"},
+		"empty":    {Value: ""},
+		"password": {Value: "s3cr3t", Secret: true},
+		"a":        {Value: "${b}"},
+		"b":        {Value: "${c}"},
+		"c":        {Value: "${a}"},
+	}
+	lookup := func(chart string) (map[string]Value, error) {
+		switch chart {
+		case "foo", "repo/foo":
+			return map[string]Value{"port": {Value: "8080"}, "self": {Value: "${foo:self}"}}, nil
+		case "broken":
+			return nil, errors.New("database is down")
+		}
+		return nil, nil
+	}
+	return New(environment, global, lookup)
+}
+
+func TestExpand(t *testing.T) {
+	interpolator := getInterpolator()
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"${NAMESPACE}", "qa-ns"},
+		{"${ENV.namespace}.svc", "qa-ns.svc"},
+		{"${ENV.ProductVersion}", "1.2.0"},
+		{"${ENV.gateway}", "istio-system/qa-gw"},
+		{"http://${host}:${foo:port}", "http://qa.host:8080"},
+		{"${repo/foo:port}", "8080"},
+		{"${url}", "https://qa.host/qa"},
+		{"${missing:-default}", "default"},
+		{"${empty:-default}", "default"},
+		{"${host:-default}", "qa.host"},
+		{"${missing:-${host}}", "qa.host"},
+		{"${missing:-http://x:8080}", "http://x:8080"},
+		{"${ENV.name | upper}", "QA"},
+		{"${missing:-Qa Host | lower | urlencode}", "qa+host"},
+		{"${host|base64}", "cWEuaG9zdA=="},
+		{"$${host}", "${host}"},
+		{"$${host} ${host}", "${host} qa.host"},
+	}
+
+	for _, tt := range tests {
+		result := interpolator.Expand("", tt.value)
+		assert.NoError(t, result.Err(), tt.value)
+		assert.Equal(t, tt.want, result.Value, tt.value)
+		assert.False(t, result.Secret, tt.value)
+	}
+}
+
+func TestExpand_Errors(t *testing.T) {
+	interpolator := getInterpolator()
+
+	tests := []struct {
+		origin string
+		value  string
+		want   string
+		err    string
+	}{
+		{"", "${missing}", "${missing}", "Unresolved reference: ${missing}"},
+		{"", "${ENV.cluster}", "${ENV.cluster}", "Unresolved reference: ${ENV.cluster}"},
+		{"", "${bar:port}", "${bar:port}", "Unresolved reference: ${bar:port}"},
+		{"", "${host | reverse}", "${host | reverse}", "Unknown function reverse: ${host | reverse}"},
+		{"", "x ${host", "x ${host", "Unterminated reference: ${host"},
+		{"", "${broken:port}", "${broken:port}", "database is down: ${broken:port}"},
+		{"", "${a}", "${a}", "Reference cycle a -> b -> c -> a: ${a}"},
+		{"foo:self", "${foo:self}", "${foo:self}", "Reference cycle foo:self -> foo:self: ${foo:self}"},
+		{"host", "${host}", "qa.host", ""},
+	}
+
+	for _, tt := range tests {
+		result := interpolator.Expand(tt.origin, tt.value)
+		assert.Equal(t, tt.want, result.Value, tt.value)
+		if tt.err == "" {
+			assert.NoError(t, result.Err(), tt.value)
+		} else {
+			assert.EqualError(t, result.Err(), tt.err, tt.value)
+		}
+	}
+}
+
+func TestExpand_Secret(t *testing.T) {
+	result := getInterpolator().Expand("", "user:${password}")
+	assert.Equal(t, "user:s3cr3t", result.Value)
+	assert.True(t, result.Secret)
+}
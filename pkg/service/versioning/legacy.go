@@ -0,0 +1,170 @@
+package versioning
+
+import (
+	"strconv"
+	"strings"
+)
+
+type legacyScheme struct{}
+
+func (s *legacyScheme) Name() string {
+	return Legacy
+}
+
+func (s *legacyScheme) Compare(a string, b string) int {
+	return compareParts(legacyParts(a), legacyParts(b))
+}
+
+func (s *legacyScheme) Compatible(current string, candidate string, productVersion string, hotFix bool) bool {
+
+	if hotFix {
+		// If product is a hotFix, so ignore product version and consider only the service version
+		if getMajorVersionOfHotfix(current) != getMajorVersionOfHotfix(candidate) {
+			return false
+		}
+		return getMinorVersionOfHotfix(candidate) > getMinorVersionOfHotfix(current)
+	}
+
+	// Avoid to compare different major versions
+	productMajor := getMajorVersion(productVersion)
+	if productMajor != getMajorVersion(candidate) {
+		return false
+	}
+
+	// Avoid to compare different minor versions
+	splited := strings.Split(candidate, productMajor)
+	if len(splited) == 2 {
+		minVer := strings.Split(normalize(splited[1]), ".")
+		if len(minVer) > 2 {
+			return false
+		}
+	}
+
+	latestMinor := getMinorVersion(candidate)
+	productMinor := getMinorVersion(productVersion)
+
+	currentIsRC := isReleaseCandidate(current)
+	latestIsRC := isReleaseCandidate(candidate)
+	productIsRC := isReleaseCandidate(productVersion)
+
+	// If product and latest are RC and current version isn't RC
+	if latestIsRC && productIsRC && !currentIsRC {
+		return latestMinor > productMinor
+	}
+
+	// Avoid to compare a release candidate with a version
+	if latestIsRC != productIsRC {
+		return false
+	}
+
+	// If currentTag's majorVersion < productVersion (it occurs when productVersion is a copy of other productVersion)
+	if majorVersionToInt(getMajorVersion(current)) < majorVersionToInt(productMajor) {
+		return true
+	}
+	return latestMinor > getMinorVersion(current)
+}
+
+func legacyParts(version string) []int {
+	v := strings.ReplaceAll(version, "RC", "")
+	parts := make([]int, 0)
+	for _, p := range strings.Split(normalize(v), ".") {
+		if p == "" {
+			continue
+		}
+		value, _ := strconv.Atoi(p)
+		parts = append(parts, value)
+	}
+	return parts
+}
+
+func normalize(s string) string {
+	return strings.ReplaceAll(s, "-", ".")
+}
+
+func majorVersionToInt(version string) int {
+	v := strings.ReplaceAll(version, ".", "")
+	v = strings.ReplaceAll(v, "-", "")
+	v = strings.ReplaceAll(v, "RC", "")
+
+	var err error
+	var value int
+	if value, err = strconv.Atoi(v); err != nil {
+		return 0
+	}
+	return value
+}
+
+func isReleaseCandidate(version string) bool {
+	return strings.Contains(version, "RC")
+}
+
+func getMajorVersion(version string) string {
+	major := ""
+	foundMajor := false
+
+	for i := len(version) - 1; i >= 0; i-- {
+		v := string(version[i])
+
+		if foundMajor {
+			major = v + major
+		} else {
+			if v == "." || v == "-" {
+				foundMajor = true
+			}
+		}
+	}
+
+	return major
+}
+
+func getMajorVersionOfHotfix(version string) string {
+
+	dotCount := strings.Split(version, ".")
+
+	if len(dotCount)-1 == 2 {
+		return version
+	}
+
+	major := ""
+	foundMajor := false
+	for i := len(version) - 1; i >= 0; i-- {
+		v := string(version[i])
+
+		if foundMajor {
+			major = v + major
+		} else {
+			if v == "." {
+				foundMajor = true
+			}
+		}
+	}
+	return major
+}
+
+func getMinorVersion(version string) int {
+	minor := ""
+	minorInt := -1
+
+	for i := len(version) - 1; i >= 0; i-- {
+		v := string(version[i])
+
+		if v != "." && v != "-" {
+			minor = v + minor
+		} else {
+			minorInt, _ = strconv.Atoi(minor)
+			return minorInt
+		}
+	}
+
+	return -1
+}
+
+func getMinorVersionOfHotfix(version string) int {
+
+	dotCount := strings.Split(version, ".")
+	if len(dotCount)-1 == 2 {
+		return -1
+	}
+
+	return getMinorVersion(version)
+}
@@ -0,0 +1,115 @@
+package versioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMajorVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"20.1.1-0", "20.1.1"},
+		{"20.1.1-0.1", "20.1.1-0"},
+		{"20.1.1-RC-0", "20.1.1-RC"},
+		{"20", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, getMajorVersion(tt.version), tt.version)
+	}
+}
+
+func TestGetMajorVersionOfHotfix(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"20.1.1-0", "20.1.1-0"},
+		{"20.1.1-0.1", "20.1.1-0"},
+		{"20.1.1", "20.1.1"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, getMajorVersionOfHotfix(tt.version), tt.version)
+	}
+}
+
+func TestGetMinorVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    int
+	}{
+		{"20.1.1-0", 0},
+		{"20.1.1-0.10", 10},
+		{"20.1.1-RC-01", 1},
+		{"20", -1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, getMinorVersion(tt.version), tt.version)
+	}
+}
+
+func TestGetMinorVersionOfHotfix(t *testing.T) {
+	tests := []struct {
+		version string
+		want    int
+	}{
+		{"20.1.1-0", -1},
+		{"20.1.1-0.10", 10},
+		{"20.1.1", -1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, getMinorVersionOfHotfix(tt.version), tt.version)
+	}
+}
+
+func TestLegacyCompatible(t *testing.T) {
+	tests := []struct {
+		name           string
+		current        string
+		candidate      string
+		productVersion string
+		hotFix         bool
+		want           bool
+	}{
+		{"new build", "19.0.1-0", "19.0.1-1", "19.0.1-0", false, true},
+		{"other major", "19.0.1-0", "19.0.2-0", "19.0.1-0", false, false},
+		{"new release candidate", "20.1.0-RC-1", "20.1.0-RC-2", "20.1.0-RC-1", false, true},
+		{"hotfix build ignored", "20.1.0-0", "20.1.0-0.1", "20.1.0-0", false, false},
+		{"rc over older rc", "20.2.0-RC-9", "20.2.1-RC-1", "20.2.1-RC-0", false, true},
+		{"rc over release", "20.1.0-0", "20.2.1-RC-1", "20.2.1-RC-0", false, true},
+		{"release over rc product", "20.2.1-0", "20.2.1-1", "20.2.1-RC-0", false, false},
+		{"older build", "19.0.1-5", "19.0.1-4", "19.0.1-0", false, false},
+		{"hotfix patch", "20.1.1-15.5", "20.1.1-15.6", "20.1.1-1.1", true, true},
+		{"hotfix other build", "20.1.1-15.5", "20.1.1-1.2", "20.1.1-1.1", true, false},
+		{"hotfix new build", "20.1.1-6", "20.1.1-10", "20.1.1-1.1", true, false},
+		{"hotfix first patch", "20.1.1-6", "20.1.1-6.1", "20.1.1-1.1", true, true},
+	}
+
+	scheme, _ := New(Legacy, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, scheme.Compatible(tt.current, tt.candidate, tt.productVersion, tt.hotFix))
+		})
+	}
+}
+
+func TestLegacyCompare(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"19.0.1-1", "19.0.1-0", 1},
+		{"19.0.1-0", "19.0.1-0", 0},
+		{"19.0.1-0", "19.0.1-0.1", -1},
+		{"20.1.0-RC-2", "20.1.0-RC-10", -1},
+		{"19.10.0-0", "19.9.0-0", 1},
+	}
+
+	scheme, _ := New(Legacy, "")
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, sign(scheme.Compare(tt.a, tt.b)), tt.a+" vs "+tt.b)
+	}
+}
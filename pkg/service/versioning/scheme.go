@@ -0,0 +1,63 @@
+package versioning
+
+import (
+	"errors"
+	"strings"
+)
+
+//Legacy is the historical Tenkai scheme (X.Y.Z-N, X.Y.Z-N.M and X.Y.Z-RC-N)
+const Legacy = "legacy"
+
+//SemVer is the Semantic Versioning 2.0.0 scheme
+const SemVer = "semver"
+
+//CalVer is the calendar versioning scheme (YYYY.MM.DD, YY.0M.MICRO, ...)
+const CalVer = "calver"
+
+//Regex is a custom scheme whose components are the capture groups of a regular expression
+const Regex = "regex"
+
+//Scheme parses and compares the docker image tags of a product
+type Scheme interface {
+	//Name returns the scheme name
+	Name() string
+	//Compare returns a negative number when a < b, zero when they are equivalent and
+	//a positive number when a > b. Tags outside the scheme are lower than any valid tag.
+	Compare(a string, b string) int
+	//Compatible tells if candidate may be suggested as an upgrade of current inside
+	//a product version. On hot fixes only patches of the current tag are compatible.
+	Compatible(current string, candidate string, productVersion string, hotFix bool) bool
+}
+
+//New returns the scheme with the given name. An empty name means the legacy scheme.
+//The pattern is only used by the regex scheme.
+func New(name string, pattern string) (Scheme, error) {
+	switch strings.ToLower(name) {
+	case "", Legacy:
+		return &legacyScheme{}, nil
+	case SemVer:
+		return &semVerScheme{}, nil
+	case CalVer:
+		return &calVerScheme{}, nil
+	case Regex:
+		return newRegexScheme(pattern)
+	}
+	return nil, errors.New("Unknown version scheme: " + name)
+}
+
+//LatestCompatible returns the greatest candidate compatible with current, or an empty
+//string if there is none. When two candidates are equivalent the last one wins.
+func LatestCompatible(scheme Scheme, current string, productVersion string, hotFix bool,
+	candidates []string) string {
+
+	latest := ""
+	for _, candidate := range candidates {
+		if !scheme.Compatible(current, candidate, productVersion, hotFix) {
+			continue
+		}
+		if latest == "" || scheme.Compare(candidate, latest) >= 0 {
+			latest = candidate
+		}
+	}
+	return latest
+}
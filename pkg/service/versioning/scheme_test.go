@@ -0,0 +1,223 @@
+package versioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(value int) int {
+	if value < 0 {
+		return -1
+	}
+	if value > 0 {
+		return 1
+	}
+	return 0
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{"", "", Legacy, false},
+		{"legacy", "", Legacy, false},
+		{"SemVer", "", SemVer, false},
+		{"calver", "", CalVer, false},
+		{"regex", `^r(\d+)_(\d+)$`, Regex, false},
+		{"regex", "", "", true},
+		{"regex", "(", "", true},
+		{"regex", `^r\d+$`, "", true},
+		{"date", "", "", true},
+	}
+
+	for _, tt := range tests {
+		scheme, err := New(tt.name, tt.pattern)
+		if tt.wantErr {
+			assert.Error(t, err, tt.name+" "+tt.pattern)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, scheme.Name())
+	}
+}
+
+func TestSemVerCompare(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.1", "1.0.0", 1},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0", "10.0.0", -1},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.0.0+build.1", "1.0.0+build.2", 0},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"1.0.0-beta", "1.0.0-beta.2", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0", "1.0.0", -1},
+		{"latest", "0.0.1", -1},
+		{"latest", "master", 0},
+		{"01.0.0", "0.0.1", -1},
+	}
+
+	scheme, _ := New(SemVer, "")
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, sign(scheme.Compare(tt.a, tt.b)), tt.a+" vs "+tt.b)
+		assert.Equal(t, -tt.want, sign(scheme.Compare(tt.b, tt.a)), tt.b+" vs "+tt.a)
+	}
+}
+
+func TestSemVerCompatible(t *testing.T) {
+	tests := []struct {
+		name           string
+		current        string
+		candidate      string
+		productVersion string
+		hotFix         bool
+		want           bool
+	}{
+		{"patch", "1.2.0", "1.2.1", "1.0.0", false, true},
+		{"minor", "1.2.0", "1.3.0", "1.0.0", false, true},
+		{"major", "1.2.0", "2.0.0", "1.0.0", false, false},
+		{"older", "1.2.0", "1.1.9", "1.0.0", false, false},
+		{"same", "1.2.0", "1.2.0", "1.0.0", false, false},
+		{"prerelease on release product", "1.2.0", "1.3.0-rc.1", "1.0.0", false, false},
+		{"prerelease on prerelease product", "1.2.0", "1.3.0-rc.1", "1.3.0-rc.0", false, true},
+		{"prerelease of current", "1.3.0-rc.1", "1.3.0-rc.2", "1.0.0", false, true},
+		{"release of current prerelease", "1.3.0-rc.1", "1.3.0", "1.0.0", false, true},
+		{"product on other major", "1.2.0", "2.1.0", "2.0.0", false, true},
+		{"legacy product version", "1.2.0", "1.3.0", "release-19", false, true},
+		{"legacy product version other major", "1.2.0", "2.0.0", "release-19", false, false},
+		{"invalid candidate", "1.2.0", "latest", "1.0.0", false, false},
+		{"invalid current", "latest", "1.2.0", "1.0.0", false, true},
+		{"hotfix patch", "1.2.0", "1.2.1", "1.0.0", true, true},
+		{"hotfix minor", "1.2.0", "1.3.0", "1.0.0", true, false},
+		{"hotfix older patch", "1.2.3", "1.2.2", "1.0.0", true, false},
+	}
+
+	scheme, _ := New(SemVer, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, scheme.Compatible(tt.current, tt.candidate, tt.productVersion, tt.hotFix))
+		})
+	}
+}
+
+func TestCalVerCompare(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"2020.01.15", "2020.1.15", 0},
+		{"2020.02", "2020.01.31", 1},
+		{"2020.12.1", "2021.01.1", -1},
+		{"20.04.1", "20.04.2", -1},
+		{"2020.04.1-beta", "2020.04.1", -1},
+		{"2020.04.1.3", "2020.04.1.2", 1},
+		{"2020.13.1", "2020.01.1", -1},
+		{"1.2.3", "2020.01.1", -1},
+	}
+
+	scheme, _ := New(CalVer, "")
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, sign(scheme.Compare(tt.a, tt.b)), tt.a+" vs "+tt.b)
+	}
+}
+
+func TestCalVerCompatible(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   string
+		candidate string
+		hotFix    bool
+		want      bool
+	}{
+		{"next month", "2020.04.1", "2020.05.0", false, true},
+		{"next year", "2020.12.3", "2021.01.0", false, true},
+		{"older", "2020.04.1", "2020.03.9", false, false},
+		{"modifier", "2020.04.1", "2020.05.0-beta", false, false},
+		{"hotfix same month", "2020.04.1", "2020.04.2", true, true},
+		{"hotfix next month", "2020.04.1", "2020.05.0", true, false},
+	}
+
+	scheme, _ := New(CalVer, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, scheme.Compatible(tt.current, tt.candidate, "2020.04", tt.hotFix))
+		})
+	}
+}
+
+func TestRegexScheme(t *testing.T) {
+	scheme, err := New(Regex, `^release-(\d+)\.(\d+)(?:-(?P<pre>[a-z]+\d*))?$`)
+	assert.NoError(t, err)
+
+	compare := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"release-1.2", "release-1.10", -1},
+		{"release-2.0", "release-1.10", 1},
+		{"release-1.2-beta1", "release-1.2", -1},
+		{"release-1.2-alpha", "release-1.2-beta", -1},
+		{"release-1.2", "release-1.2", 0},
+		{"v1.2", "release-1.2", -1},
+	}
+	for _, tt := range compare {
+		assert.Equal(t, tt.want, sign(scheme.Compare(tt.a, tt.b)), tt.a+" vs "+tt.b)
+	}
+
+	compatible := []struct {
+		current   string
+		candidate string
+		hotFix    bool
+		want      bool
+	}{
+		{"release-1.2", "release-1.3", false, true},
+		{"release-1.2", "release-2.0", false, false},
+		{"release-1.2", "release-1.3-beta", false, false},
+		{"release-1.2", "release-1.1", false, false},
+		{"release-1.2", "release-1.3", true, false},
+	}
+	for _, tt := range compatible {
+		assert.Equal(t, tt.want, scheme.Compatible(tt.current, tt.candidate, "release-1.0", tt.hotFix),
+			tt.current+" -> "+tt.candidate)
+	}
+}
+
+func TestLatestCompatible(t *testing.T) {
+	tests := []struct {
+		scheme     string
+		current    string
+		product    string
+		hotFix     bool
+		candidates []string
+		want       string
+	}{
+		{SemVer, "1.2.0", "1.0.0", false, []string{"1.10.0", "2.0.0", "1.9.3", "1.3.0-rc.1"}, "1.10.0"},
+		{SemVer, "1.2.0", "1.0.0", true, []string{"1.10.0", "1.2.10", "1.2.9"}, "1.2.10"},
+		{SemVer, "1.2.0", "1.0.0", false, []string{"latest", "1.1.0"}, ""},
+		{CalVer, "2020.04.1", "2020.04", false, []string{"2020.10.0", "2021.01.0", "2020.12.5"}, "2021.01.0"},
+		{Legacy, "19.0.1-0", "19.0.1-0", false, []string{"19.0.1-1", "19.0.1-3", "19.0.1-2"}, "19.0.1-3"},
+		{Legacy, "19.0.1-0", "19.0.1-0", false, []string{}, ""},
+	}
+
+	for _, tt := range tests {
+		scheme, _ := New(tt.scheme, "")
+		assert.Equal(t, tt.want, LatestCompatible(scheme, tt.current, tt.product, tt.hotFix, tt.candidates),
+			tt.scheme+" "+tt.current)
+	}
+}
@@ -0,0 +1,267 @@
+package versioning
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//version is a tag parsed by the semver, calver and regex schemes
+type version struct {
+	parts []int
+	pre   []string
+}
+
+type parseFunc func(tag string) (*version, error)
+
+var semVerPattern = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+[0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*)?$`)
+
+var calVerPattern = regexp.MustCompile(`^v?(\d{4}|\d{2})\.(\d{1,2})(?:\.(\d+))?(?:\.(\d+))?(?:[-_]([0-9a-zA-Z.-]+))?$`)
+
+type semVerScheme struct{}
+
+func (s *semVerScheme) Name() string {
+	return SemVer
+}
+
+func (s *semVerScheme) Compare(a string, b string) int {
+	return compareTags(parseSemVer, a, b)
+}
+
+//Compatible accepts greater tags of the product major version, or of the current minor version on hot fixes
+func (s *semVerScheme) Compatible(current string, candidate string, productVersion string, hotFix bool) bool {
+	return compatible(parseSemVer, current, candidate, productVersion, hotFix, 1, 2)
+}
+
+func parseSemVer(tag string) (*version, error) {
+	m := semVerPattern.FindStringSubmatch(tag)
+	if m == nil {
+		return nil, errors.New("Invalid semantic version: " + tag)
+	}
+	return newVersion(m[1:4], m[4])
+}
+
+type calVerScheme struct{}
+
+func (s *calVerScheme) Name() string {
+	return CalVer
+}
+
+func (s *calVerScheme) Compare(a string, b string) int {
+	return compareTags(parseCalVer, a, b)
+}
+
+//Compatible accepts any later tag, or a later tag of the current year and month on hot fixes
+func (s *calVerScheme) Compatible(current string, candidate string, productVersion string, hotFix bool) bool {
+	return compatible(parseCalVer, current, candidate, productVersion, hotFix, 0, 2)
+}
+
+func parseCalVer(tag string) (*version, error) {
+	m := calVerPattern.FindStringSubmatch(tag)
+	if m == nil {
+		return nil, errors.New("Invalid calendar version: " + tag)
+	}
+	v, err := newVersion(m[1:5], m[5])
+	if err != nil {
+		return nil, err
+	}
+	if v.parts[1] < 1 || v.parts[1] > 12 {
+		return nil, errors.New("Invalid month in calendar version: " + tag)
+	}
+	return v, nil
+}
+
+type regexScheme struct {
+	pattern *regexp.Regexp
+	pre     int
+}
+
+func newRegexScheme(pattern string) (Scheme, error) {
+	if pattern == "" {
+		return nil, errors.New("A pattern is required by the regex version scheme")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.NumSubexp() == 0 {
+		return nil, errors.New("The version pattern must have at least one capture group")
+	}
+
+	scheme := &regexScheme{pattern: re, pre: -1}
+	for i, name := range re.SubexpNames() {
+		if name == "pre" {
+			scheme.pre = i
+		}
+	}
+	return scheme, nil
+}
+
+func (s *regexScheme) Name() string {
+	return Regex
+}
+
+func (s *regexScheme) Compare(a string, b string) int {
+	return compareTags(s.parse, a, b)
+}
+
+//Compatible accepts greater tags with the same first component as the product version,
+//or with the same first two components as the current tag on hot fixes
+func (s *regexScheme) Compatible(current string, candidate string, productVersion string, hotFix bool) bool {
+	return compatible(s.parse, current, candidate, productVersion, hotFix, 1, 2)
+}
+
+//parse uses every capture group as a numeric component, except the one named "pre"
+//which holds the prerelease identifiers
+func (s *regexScheme) parse(tag string) (*version, error) {
+	m := s.pattern.FindStringSubmatch(tag)
+	if m == nil {
+		return nil, errors.New("Version " + tag + " does not match " + s.pattern.String())
+	}
+
+	parts := make([]string, 0)
+	pre := ""
+	for i := 1; i < len(m); i++ {
+		if i == s.pre {
+			pre = m[i]
+		} else {
+			parts = append(parts, m[i])
+		}
+	}
+	return newVersion(parts, pre)
+}
+
+func newVersion(parts []string, pre string) (*version, error) {
+	v := &version{parts: make([]int, 0)}
+	for _, p := range parts {
+		value := 0
+		if p != "" {
+			var err error
+			if value, err = strconv.Atoi(p); err != nil {
+				return nil, err
+			}
+		}
+		v.parts = append(v.parts, value)
+	}
+	if pre != "" {
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, nil
+}
+
+func compareTags(parse parseFunc, a string, b string) int {
+	va, errA := parse(a)
+	vb, errB := parse(b)
+	if errA != nil || errB != nil {
+		return boolToInt(errA == nil) - boolToInt(errB == nil)
+	}
+	return compareVersions(va, vb)
+}
+
+//compatible accepts candidates greater than current, sharing the first lineDepth components with
+//the product version, or the first hotFixDepth components with current on hot fixes. Prereleases
+//are only suggested when the product version or the current tag are prereleases too.
+func compatible(parse parseFunc, current string, candidate string, productVersion string,
+	hotFix bool, lineDepth int, hotFixDepth int) bool {
+
+	c, err := parse(candidate)
+	if err != nil {
+		return false
+	}
+
+	cur, curErr := parse(current)
+	product, productErr := parse(productVersion)
+
+	line := product
+	if hotFix || productErr != nil {
+		line = cur
+	}
+	if hotFix {
+		lineDepth = hotFixDepth
+	}
+
+	if line != nil {
+		for i := 0; i < lineDepth; i++ {
+			if part(c.parts, i) != part(line.parts, i) {
+				return false
+			}
+		}
+	}
+
+	if len(c.pre) > 0 {
+		acceptsPre := (curErr == nil && len(cur.pre) > 0) || (productErr == nil && len(product.pre) > 0)
+		if !acceptsPre {
+			return false
+		}
+	}
+
+	return curErr != nil || compareVersions(c, cur) > 0
+}
+
+func compareVersions(a *version, b *version) int {
+	if c := compareParts(a.parts, b.parts); c != 0 {
+		return c
+	}
+	return comparePrerelease(a.pre, b.pre)
+}
+
+func compareParts(a []int, b []int) int {
+	size := len(a)
+	if len(b) > size {
+		size = len(b)
+	}
+	for i := 0; i < size; i++ {
+		if x, y := part(a, i), part(b, i); x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+//comparePrerelease follows the SemVer precedence rules: a version without prerelease is
+//greater, numeric identifiers are lower than alphanumeric ones and compared numerically
+func comparePrerelease(a []string, b []string) int {
+	if len(a) == 0 || len(b) == 0 {
+		return len(b) - len(a)
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, errX := strconv.Atoi(a[i])
+		y, errY := strconv.Atoi(b[i])
+		switch {
+		case errX == nil && errY == nil:
+			if x != y {
+				return x - y
+			}
+		case errX == nil:
+			return -1
+		case errY == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return len(a) - len(b)
+}
+
+func part(parts []int, i int) int {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
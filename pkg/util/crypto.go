@@ -18,23 +18,38 @@ func createHash(key string) string {
 
 //Encrypt something
 func Encrypt(data []byte, passphrase string) []byte {
-	block, _ := aes.NewCipher([]byte(createHash(passphrase)))
-	gcm, err := cipher.NewGCM(block)
+	ciphertext, err := EncryptWithKey(data, []byte(createHash(passphrase)))
 	if err != nil {
 		panic(err.Error())
 	}
+	return ciphertext
+}
+
+//EncryptWithKey encrypts with AES-GCM under the given key, the nonce is kept before the data
+func EncryptWithKey(data []byte, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
 	nonce := make([]byte, gcm.NonceSize())
 	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		panic(err.Error())
+		return nil, err
 	}
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return ciphertext
+	return gcm.Seal(nonce, nonce, data, nil), nil
 }
 
 //Decrypt something
 func Decrypt(data []byte, passphrase string) ([]byte, error) {
+	return DecryptWithKey(data, []byte(createHash(passphrase)))
+}
+
+//DecryptWithKey decrypts what EncryptWithKey encrypted
+func DecryptWithKey(data []byte, key []byte) ([]byte, error) {
 	fakeResult := make([]byte, 0)
-	key := []byte(createHash(passphrase))
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return fakeResult, err
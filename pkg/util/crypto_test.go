@@ -18,3 +18,15 @@ func TestEncryptDecrypt(t *testing.T) {
 	assert.Nil(t, error)
 	assert.Equal(t, password, string(decriptPassword))
 }
+
+func TestEncryptDecryptWithKey(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	encrypted, err := EncryptWithKey([]byte("Minha senha"), key)
+	assert.Nil(t, err)
+	decrypted, err := DecryptWithKey(encrypted, key)
+	assert.Nil(t, err)
+	assert.Equal(t, "Minha senha", string(decrypted))
+
+	_, err = DecryptWithKey(encrypted, []byte("fedcba9876543210fedcba9876543210"))
+	assert.Error(t, err)
+}